@@ -0,0 +1,143 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/config-source/cdb/cmd/cdb/table"
+	"github.com/config-source/cdb/pkg/audit"
+	"github.com/spf13/cobra"
+)
+
+var (
+	actor      string
+	action     string
+	targetKind string
+	targetID   string
+	since      string
+	until      string
+	beforeID   int64
+	limit      int
+	asJSON     bool
+)
+
+// parseTime accepts an RFC 3339 timestamp or a duration which is taken as
+// that long ago.
+func parseTime(raw string) (*time.Time, error) {
+	if raw == "" {
+		return nil, nil
+	}
+
+	if ago, err := time.ParseDuration(raw); err == nil {
+		parsed := time.Now().Add(-ago)
+		return &parsed, nil
+	}
+
+	parsed, err := time.ParseInLocation(time.RFC3339, raw, time.Local)
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse %q as a time, expected a duration like 24h or a format like %s", raw, time.RFC3339)
+	}
+
+	return &parsed, nil
+}
+
+func optional(value string) *string {
+	if value == "" {
+		return nil
+	}
+
+	return &value
+}
+
+func buildFilter() (audit.Filter, error) {
+	filter := audit.Filter{
+		Action:     optional(action),
+		TargetKind: optional(targetKind),
+		TargetID:   optional(targetID),
+		Limit:      limit,
+	}
+
+	// Filtered on the server so that browsing the log doesn't need permission
+	// to list users.
+	if id, err := strconv.Atoi(actor); err == nil {
+		filter.ActorID = &id
+	} else {
+		filter.ActorEmail = optional(actor)
+	}
+
+	var err error
+	if filter.Since, err = parseTime(since); err != nil {
+		return audit.Filter{}, err
+	}
+
+	if filter.Until, err = parseTime(until); err != nil {
+		return audit.Filter{}, err
+	}
+
+	if beforeID != 0 {
+		filter.BeforeID = &beforeID
+	}
+
+	return filter, nil
+}
+
+var Command = &cobra.Command{
+	Use:   "audit",
+	Short: "Browse the audit log of changes, newest first",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		filter, err := buildFilter()
+		if err != nil {
+			return err
+		}
+
+		entries, err := config.Client.ListAuditLog(cmd.Context(), filter)
+		if err != nil {
+			return err
+		}
+
+		if asJSON {
+			output, err := json.MarshalIndent(entries, "", "    ")
+			if err != nil {
+				return err
+			}
+
+			fmt.Println(string(output))
+			return nil
+		}
+
+		tbl := table.Table{
+			Headings: []string{"ID", "Time", "Actor", "Action", "Target", "Request ID"},
+			Rows:     make([][]string, len(entries)),
+		}
+
+		for idx, entry := range entries {
+			tbl.Rows[idx] = []string{
+				strconv.FormatInt(entry.ID, 10),
+				entry.CreatedAt.Local().Format(time.RFC3339),
+				entry.ActorEmail,
+				entry.Action,
+				fmt.Sprintf("%s/%s", entry.TargetKind, entry.TargetID),
+				entry.RequestID,
+			}
+		}
+
+		fmt.Println(tbl)
+		return nil
+	},
+}
+
+func init() {
+	Command.Flags().StringVar(&actor, "actor", "", "Only show changes made by this user ID or email")
+	Command.Flags().StringVar(&action, "action", "", "Only show this action, for example environment.delete")
+	Command.Flags().StringVar(&targetKind, "target-kind", "", "Only show changes to this kind of target, for example config_value")
+	Command.Flags().StringVar(&targetID, "target-id", "", "Only show changes to the target with this ID, use with --target-kind")
+	Command.Flags().StringVar(&since, "since", "", "Only show changes since this time or duration ago, for example 24h")
+	Command.Flags().StringVar(&until, "until", "", "Only show changes before this time or duration ago")
+	Command.Flags().Int64Var(&beforeID, "before", 0, "Only show entries older than this entry ID, to page through the log")
+	Command.Flags().IntVar(&limit, "limit", 0, fmt.Sprintf("Show at most this many entries, defaults to %d", audit.DefaultLimit))
+	Command.Flags().BoolVar(&asJSON, "json", false, "Print the entries as JSON including their before and after state")
+}
@@ -0,0 +1,41 @@
+package configuration
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/config-source/cdb/pkg/configvalues"
+	"github.com/spf13/cobra"
+)
+
+var evaluateConfigCmd = &cobra.Command{
+	Use:   "evaluate <environment> [attribute=value...]",
+	Short: "Show the configuration for an environment with targeting rules applied to the given context",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		evalCtx := configvalues.EvaluationContext{}
+		for _, pair := range args[1:] {
+			attribute, value, ok := strings.Cut(pair, "=")
+			if !ok {
+				return fmt.Errorf("context must be given as attribute=value got: %s", pair)
+			}
+
+			evalCtx[attribute] = value
+		}
+
+		values, err := config.Client.EvaluateConfiguration(context.Background(), args[0], evalCtx, overlays...)
+		if err != nil {
+			return err
+		}
+
+		printConfigTable(values)
+		return nil
+	},
+}
+
+func init() {
+	evaluateConfigCmd.Flags().StringArrayVarP(&overlays, "overlay", "o", nil, "Apply an overlay such as region:eu, may be given multiple times with later overlays taking precedence.")
+	Command.AddCommand(evaluateConfigCmd)
+}
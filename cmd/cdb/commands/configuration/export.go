@@ -0,0 +1,41 @@
+package configuration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/spf13/cobra"
+)
+
+var (
+	selector     string
+	revealExport bool
+)
+
+var exportConfigCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export the configuration of every environment matching a label selector as JSON",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		exported, err := config.Client.ExportConfiguration(context.Background(), selector, revealExport)
+		if err != nil {
+			return err
+		}
+
+		output, err := json.MarshalIndent(exported, "", "    ")
+		if err != nil {
+			return err
+		}
+
+		fmt.Println(string(output))
+		return nil
+	},
+}
+
+func init() {
+	exportConfigCmd.Flags().StringVarP(&selector, "selector", "l", "", "Only export environments matching a label selector such as tier=prod,region in (eu,us).")
+	exportConfigCmd.Flags().BoolVar(&revealExport, "reveal", false, "Reveal the values of sensitive keys instead of redacting them, this is audited.")
+	Command.AddCommand(exportConfigCmd)
+}
@@ -13,16 +13,27 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// overlays are the dimension:name selectors of overlays to apply on top of
+// the environment's configuration.
+var overlays []string
+
+// reveal asks for the values of sensitive keys instead of them being redacted.
+var reveal bool
+
+const redacted = "<redacted>"
+
 func valueToRow(cv configvalues.ConfigValue) []string {
 	repr := ""
-	switch cv.ValueType {
-	case configkeys.TypeString:
+	switch {
+	case cv.Redacted:
+		repr = redacted
+	case cv.ValueType == configkeys.TypeString:
 		repr = *cv.StrValue
-	case configkeys.TypeInteger:
+	case cv.ValueType == configkeys.TypeInteger:
 		repr = fmt.Sprintf("%d", *cv.IntValue)
-	case configkeys.TypeFloat:
+	case cv.ValueType == configkeys.TypeFloat:
 		repr = fmt.Sprintf("%f", *cv.FloatValue)
-	case configkeys.TypeBoolean:
+	case cv.ValueType == configkeys.TypeBoolean:
 		repr = fmt.Sprintf("%t", *cv.BoolValue)
 	default:
 		repr = "UNKNOWN VALUE!"
@@ -63,15 +74,24 @@ var getConfigCmd = &cobra.Command{
 			key = args[1]
 		}
 
+		getValue, getValues := config.Client.GetConfigurationValue, config.Client.GetConfiguration
+		if reveal {
+			getValue, getValues = config.Client.RevealConfigurationValue, config.Client.RevealConfiguration
+		}
+
 		if key != "" {
-			value, err := config.Client.GetConfigurationValue(ctx, env, key)
+			value, err := getValue(ctx, env, key, overlays...)
 			if err != nil {
 				return err
 			}
 
-			fmt.Println(value.Value())
+			if value.Redacted {
+				fmt.Println(redacted)
+			} else {
+				fmt.Println(value.Value())
+			}
 		} else {
-			values, err := config.Client.GetConfiguration(ctx, env)
+			values, err := getValues(ctx, env, overlays...)
 			if err != nil {
 				return err
 			}
@@ -82,3 +102,8 @@ var getConfigCmd = &cobra.Command{
 		return nil
 	},
 }
+
+func init() {
+	getConfigCmd.Flags().BoolVar(&reveal, "reveal", false, "Reveal the values of sensitive keys instead of redacting them, this is audited.")
+	getConfigCmd.Flags().StringArrayVarP(&overlays, "overlay", "o", nil, "Apply an overlay such as region:eu, may be given multiple times with later overlays taking precedence.")
+}
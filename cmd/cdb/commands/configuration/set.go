@@ -6,10 +6,12 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/config-source/cdb/cmd/cdb/config"
 	"github.com/config-source/cdb/pkg/configkeys"
 	"github.com/config-source/cdb/pkg/configvalues"
+	"github.com/config-source/cdb/pkg/scheduledchanges"
 	"github.com/spf13/cobra"
 )
 
@@ -17,8 +19,58 @@ var (
 	env   string
 	key   string
 	value string
+	at    string
+
+	service string
+	overlay string
 )
 
+// applyAtFormats are the accepted formats for --at, times without a zone are
+// in local time.
+var applyAtFormats = []string{
+	time.RFC3339,
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+}
+
+func parseApplyAt(raw string) (time.Time, error) {
+	for _, format := range applyAtFormats {
+		applyAt, err := time.ParseInLocation(format, raw, time.Local)
+		if err == nil {
+			return applyAt, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("unable to parse %q as a time, expected a format like %s", raw, time.RFC3339)
+}
+
+func scheduleConfigValue(ctx context.Context, envID int, configValue *configvalues.ConfigValue) error {
+	applyAt, err := parseApplyAt(at)
+	if err != nil {
+		return err
+	}
+
+	configValue.Name = key
+	change, err := config.Client.ScheduleChange(ctx, scheduledchanges.ScheduledChange{
+		EnvironmentID: envID,
+		ApplyAt:       applyAt,
+		Values:        []*configvalues.ConfigValue{configValue},
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf(
+		"Scheduled %s=%s for %s at %s (scheduled change %d)\n",
+		key,
+		valueAsString(configValue),
+		env,
+		change.ApplyAt.Local().Format(time.RFC3339),
+		change.ID,
+	)
+	return nil
+}
+
 func valueAsString(cv *configvalues.ConfigValue) string {
 	switch v := cv.Value().(type) {
 	case string:
@@ -54,30 +106,54 @@ func determineValueType(value string) (interface{}, configkeys.ValueType) {
 	return value, configkeys.TypeString
 }
 
+// ParseValue builds a ConfigValue from a command line argument, guessing the
+// value type from its contents.
+func ParseValue(raw string) (*configvalues.ConfigValue, error) {
+	configValue := &configvalues.ConfigValue{}
+	value, valueType := determineValueType(raw)
+
+	switch valueType {
+	case configkeys.TypeString:
+		configValue.SetStrValue(value.(string))
+	case configkeys.TypeBoolean:
+		configValue.SetBoolValue(value.(bool))
+	case configkeys.TypeInteger:
+		configValue.SetIntValue(value.(int))
+	case configkeys.TypeFloat:
+		configValue.SetFloatValue(value.(float64))
+	default:
+		return nil, errors.New("somehow couldn't find the data type of the config key")
+	}
+
+	if err := configValue.Valid(); err != nil {
+		return nil, err
+	}
+
+	return configValue, nil
+}
+
 var setConfigCmd = &cobra.Command{
 	Use: "set",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		configValue := &configvalues.ConfigValue{}
-		value, valueType := determineValueType(value)
-
-		switch valueType {
-		case configkeys.TypeString:
-			configValue.SetStrValue(value.(string))
-		case configkeys.TypeBoolean:
-			configValue.SetBoolValue(value.(bool))
-		case configkeys.TypeInteger:
-			configValue.SetIntValue(value.(int))
-		case configkeys.TypeFloat:
-			configValue.SetFloatValue(value.(float64))
-		default:
-			return errors.New("somehow couldn't find the data type of the config key")
+		configValue, err := ParseValue(value)
+		if err != nil {
+			return err
+		}
+
+		if at != "" && overlay != "" {
+			return errors.New("overlay values cannot be scheduled")
 		}
 
-		if err := configValue.Valid(); err != nil {
+		envID, err := config.ResolveEnvironmentID(cmd.Context(), service, env)
+		if err != nil {
 			return err
 		}
 
-		created, err := config.Client.SetConfigurationValue(context.Background(), env, key, configValue)
+		if at != "" {
+			return scheduleConfigValue(cmd.Context(), envID, configValue)
+		}
+
+		created, err := config.Client.SetConfigurationValue(cmd.Context(), strconv.Itoa(envID), key, configValue, overlay)
 		if err != nil {
 			return err
 		}
@@ -89,8 +165,11 @@ var setConfigCmd = &cobra.Command{
 
 func init() {
 	setConfigCmd.Flags().StringVarP(&env, "environment", "e", "", "The environment you want to set the value for, accepts an environment name or ID.")
+	setConfigCmd.Flags().StringVarP(&service, "service", "s", "", "Which service the environment belongs to, required when using an environment name.")
 	setConfigCmd.Flags().StringVarP(&key, "key", "k", "", "The configuration key you want to set the value for, accepts a key name or ID.")
 	setConfigCmd.Flags().StringVarP(&value, "value", "v", "", "The value you want to set the config key to.")
+	setConfigCmd.Flags().StringVar(&at, "at", "", "Schedule the value to be set at this time instead of now, for example 2024-10-01T09:00:00Z.")
+	setConfigCmd.Flags().StringVarP(&overlay, "overlay", "o", "", "Set the value for an overlay such as region:eu instead of the environment itself.")
 	setConfigCmd.MarkFlagRequired("environment") // nolint:errcheck
 	setConfigCmd.MarkFlagRequired("key")         // nolint:errcheck
 	setConfigCmd.MarkFlagRequired("value")       // nolint:errcheck
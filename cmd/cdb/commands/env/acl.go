@@ -0,0 +1,136 @@
+package env
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+
+	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/config-source/cdb/cmd/cdb/table"
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/spf13/cobra"
+)
+
+var (
+	aclUserID int
+	aclRole   string
+	aclAccess string
+)
+
+var aclCmd = &cobra.Command{
+	Use:   "acl <subcommand>",
+	Short: "Grant users or roles access to a single environment",
+}
+
+var aclListCmd = &cobra.Command{
+	Use:   "list <environment>",
+	Short: "List the access control entries of an environment",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envID, err := resolveEnvironmentID(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		entries, err := config.Client.ListEnvironmentACL(context.Background(), envID)
+		if err != nil {
+			return err
+		}
+
+		tbl := table.Table{
+			Headings: []string{"ID", "User ID", "Role", "Access"},
+			Rows:     make([][]string, len(entries)),
+		}
+
+		for idx, entry := range entries {
+			userID, role := "", ""
+			if entry.UserID != nil {
+				userID = strconv.Itoa(int(*entry.UserID))
+			}
+
+			if entry.Role != nil {
+				role = *entry.Role
+			}
+
+			tbl.Rows[idx] = []string{strconv.Itoa(entry.ID), userID, role, string(entry.Access)}
+		}
+
+		fmt.Println(tbl)
+		return nil
+	},
+}
+
+var aclGrantCmd = &cobra.Command{
+	Use:   "grant <environment>",
+	Short: "Give a user or role read or write access to an environment",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envID, err := resolveEnvironmentID(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		entry := auth.ACLEntry{
+			EnvironmentID: envID,
+			Access:        auth.Access(aclAccess),
+		}
+
+		switch {
+		case aclUserID != 0 && aclRole != "":
+			return errors.New("only one of --user or --role can be given")
+		case aclUserID != 0:
+			userID := auth.UserID(aclUserID)
+			entry.UserID = &userID
+		case aclRole != "":
+			entry.Role = &aclRole
+		default:
+			return errors.New("one of --user or --role is required")
+		}
+
+		entry, err = config.Client.CreateEnvironmentACLEntry(context.Background(), entry)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Successfully created:", entry)
+		return nil
+	},
+}
+
+var aclRevokeCmd = &cobra.Command{
+	Use:   "revoke <environment> <entry-id>",
+	Short: "Remove an access control entry from an environment",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envID, err := resolveEnvironmentID(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		entryID, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+
+		err = config.Client.DeleteEnvironmentACLEntry(context.Background(), envID, entryID)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Successfully revoked entry %d\n", entryID)
+		return nil
+	},
+}
+
+func init() {
+	aclCmd.PersistentFlags().StringVarP(&service, "service", "s", "", "Which service the environment belongs to, required when using an environment name.")
+	aclGrantCmd.Flags().IntVar(&aclUserID, "user", 0, "The ID of the user to grant access to.")
+	aclGrantCmd.Flags().StringVar(&aclRole, "role", "", "The name of the role to grant access to.")
+	aclGrantCmd.Flags().StringVar(&aclAccess, "access", string(auth.AccessRead), "The access to grant: read or write.")
+
+	aclCmd.AddCommand(aclListCmd)
+	aclCmd.AddCommand(aclGrantCmd)
+	aclCmd.AddCommand(aclRevokeCmd)
+	Command.AddCommand(aclCmd)
+}
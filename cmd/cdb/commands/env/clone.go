@@ -0,0 +1,59 @@
+package env
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/config-source/cdb/pkg/configvalues"
+	"github.com/spf13/cobra"
+)
+
+var flatten bool
+
+// resolveEnvironmentID accepts an environment ID or a name in the service
+// given by --service.
+func resolveEnvironmentID(ctx context.Context, nameOrID string) (int, error) {
+	return config.ResolveEnvironmentID(ctx, service, nameOrID)
+}
+
+var envCloneCmd = &cobra.Command{
+	Use:   "clone <source-environment> <new-environment-name>",
+	Short: "Create a new environment with the same values as an existing one",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sourceID, err := resolveEnvironmentID(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		req := configvalues.CloneRequest{
+			Name:             args[1],
+			FlattenInherited: flatten,
+		}
+
+		if promotesTo != "" {
+			promotesToID, err := resolveEnvironmentID(cmd.Context(), promotesTo)
+			if err != nil {
+				return err
+			}
+
+			req.PromotesToID = &promotesToID
+		}
+
+		env, err := config.Client.CloneEnvironment(context.Background(), sourceID, req)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Successfully cloned %s to environment: %s (id %d)\n", args[0], env.Name, env.ID)
+		return nil
+	},
+}
+
+func init() {
+	envCloneCmd.Flags().StringVarP(&service, "service", "s", "", "Which service the environments belong to, required when using environment names.")
+	envCloneCmd.Flags().StringVarP(&promotesTo, "promotes-to", "p", "", "What the new environment promotes to, accepts an environment name or ID.")
+	envCloneCmd.Flags().BoolVar(&flatten, "flatten", false, "Copy inherited values as values set directly on the new environment.")
+	Command.AddCommand(envCloneCmd)
+}
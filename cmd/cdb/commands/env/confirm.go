@@ -0,0 +1,28 @@
+package env
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+var assumeYes bool
+
+// confirm asks the user to confirm a destructive change, returning true
+// immediately if --yes was given.
+func confirm(prompt string) (bool, error) {
+	if assumeYes {
+		return true, nil
+	}
+
+	fmt.Printf("%s [y/N] ", prompt)
+	reader := bufio.NewReader(os.Stdin)
+	ans, err := reader.ReadString('\n')
+	if err != nil {
+		return false, fmt.Errorf("failed to read from stdin: %w", err)
+	}
+
+	ans = strings.ToLower(strings.TrimSpace(ans))
+	return ans == "y" || ans == "yes", nil
+}
@@ -13,6 +13,9 @@ import (
 
 var promotesTo string
 var service string
+var inheritanceMode string
+var inheritanceKeys []string
+var labels map[string]string
 
 func getPromotesToID(ctx context.Context) int {
 	if id, err := strconv.ParseUint(promotesTo, 10, 64); err == nil {
@@ -32,7 +35,10 @@ var envCreateCmd = &cobra.Command{
 	Short: "Create a new environment",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		env := environments.Environment{
-			Name: args[0],
+			Name:            args[0],
+			InheritanceMode: environments.InheritanceMode(inheritanceMode),
+			InheritanceKeys: inheritanceKeys,
+			Labels:          labels,
 		}
 
 		if promotesTo != "" {
@@ -57,6 +63,9 @@ var envCreateCmd = &cobra.Command{
 func init() {
 	envCreateCmd.Flags().StringVarP(&service, "service", "s", "", "Which service this environment belongs to")
 	envCreateCmd.Flags().StringVarP(&promotesTo, "promotes-to", "p", "", "What environment this promotes to, accepts an environment name or ID.")
+	envCreateCmd.Flags().StringVar(&inheritanceMode, "inheritance", string(environments.InheritFull), "What this environment inherits from the one it promotes to: full, none, allowlist or denylist.")
+	envCreateCmd.Flags().StringSliceVar(&inheritanceKeys, "inheritance-keys", nil, "The config keys used by the allowlist and denylist inheritance modes.")
+	envCreateCmd.Flags().StringToStringVar(&labels, "label", nil, "Labels to add to the environment as key=value, may be repeated.")
 	envCreateCmd.MarkFlagRequired("service") // nolint:errcheck
 	Command.AddCommand(envCreateCmd)
 }
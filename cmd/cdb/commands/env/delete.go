@@ -0,0 +1,74 @@
+package env
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/spf13/cobra"
+)
+
+var envDeleteCmd = &cobra.Command{
+	Use:   "delete <environment>",
+	Short: "Move an environment and the values set on it to the trash",
+	Long: `Move an environment and the values set on it to the trash. It can be restored
+with "cdb trash restore" until it is purged.
+
+The environments that inherit from it and the values which will be destroyed
+when it is purged are shown before asking for confirmation.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := resolveEnvironmentID(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		preview, err := config.Client.PreviewDeleteEnvironment(context.Background(), id)
+		if err != nil {
+			return err
+		}
+
+		if len(preview.Descendants) > 0 {
+			fmt.Println("Environments inheriting from this one:")
+			for _, descendant := range preview.Descendants {
+				fmt.Printf("  %s\n", descendant.Name)
+			}
+		}
+
+		if len(preview.Values) > 0 {
+			fmt.Println("Values which will be destroyed when purged:")
+			for _, cv := range preview.Values {
+				overlay := ""
+				if cv.OverlayID != nil {
+					overlay = fmt.Sprintf(" (overlay %d)", *cv.OverlayID)
+				}
+
+				fmt.Printf("  %s: %s%s\n", cv.Name, cv.ValueAsString(), overlay)
+			}
+		}
+
+		ok, err := confirm(fmt.Sprintf("Delete %s?", preview.Environment.Name))
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return errors.New("aborted")
+		}
+
+		err = config.Client.DeleteEnvironment(context.Background(), id)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Moved environment to the trash:", preview.Environment.Name)
+		return nil
+	},
+}
+
+func init() {
+	envDeleteCmd.Flags().StringVarP(&service, "service", "s", "", "Which service the environment belongs to, required when using an environment name.")
+	envDeleteCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Don't ask for confirmation.")
+	Command.AddCommand(envDeleteCmd)
+}
@@ -0,0 +1,150 @@
+package env
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/config-source/cdb/cmd/cdb/commands/configuration"
+	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/config-source/cdb/pkg/configvalues"
+	"github.com/config-source/cdb/pkg/ephemeral"
+	"github.com/spf13/cobra"
+)
+
+var (
+	parent        string
+	ephemeralName string
+	ttl           time.Duration
+	overrides     []string
+)
+
+func parseOverrides() ([]*configvalues.ConfigValue, error) {
+	values := make([]*configvalues.ConfigValue, 0, len(overrides))
+	for _, override := range overrides {
+		key, raw, ok := strings.Cut(override, "=")
+		if !ok || key == "" {
+			return nil, fmt.Errorf("invalid override %q, expected key=value", override)
+		}
+
+		value, err := configuration.ParseValue(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		value.Name = key
+		values = append(values, value)
+	}
+
+	return values, nil
+}
+
+var ephemeralCmd = &cobra.Command{
+	Use:   "ephemeral <subcommand>",
+	Short: "Manage short lived environments which are deleted once they expire",
+}
+
+var ephemeralCreateCmd = &cobra.Command{
+	Use:   "create",
+	Short: "Create an ephemeral child of an existing environment",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		parentID, err := resolveEnvironmentID(cmd.Context(), parent)
+		if err != nil {
+			return err
+		}
+
+		values, err := parseOverrides()
+		if err != nil {
+			return err
+		}
+
+		req := ephemeral.Request{
+			ParentID: parentID,
+			Name:     ephemeralName,
+			Values:   values,
+		}
+
+		if ttl > 0 {
+			expiresAt := time.Now().Add(ttl)
+			req.ExpiresAt = &expiresAt
+		}
+
+		env, err := config.Client.CreateEphemeralEnvironment(context.Background(), req)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf(
+			"Successfully created environment: %s (id %d), expires at %s\n",
+			env.Name,
+			env.ID,
+			env.ExpiresAt.Local().Format(time.RFC3339),
+		)
+		return nil
+	},
+}
+
+var ephemeralExtendCmd = &cobra.Command{
+	Use:   "extend <environment-id>",
+	Short: "Push back when an ephemeral environment expires",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+
+		if ttl <= 0 {
+			return errors.New("--ttl must be greater than zero")
+		}
+
+		env, err := config.Client.ExtendEphemeralEnvironment(context.Background(), id, time.Now().Add(ttl))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Environment %s now expires at %s\n", env.Name, env.ExpiresAt.Local().Format(time.RFC3339))
+		return nil
+	},
+}
+
+var ephemeralDeleteCmd = &cobra.Command{
+	Use:   "delete <environment-id>",
+	Short: "Delete an ephemeral environment before it expires",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+
+		err = config.Client.DeleteEphemeralEnvironment(context.Background(), id)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Successfully deleted environment:", id)
+		return nil
+	},
+}
+
+func init() {
+	ephemeralCreateCmd.Flags().StringVarP(&parent, "parent", "p", "", "The environment to inherit from, accepts an environment name or ID.")
+	ephemeralCreateCmd.Flags().StringVarP(&service, "service", "s", "", "Which service the parent environment belongs to, required when --parent is a name.")
+	ephemeralCreateCmd.Flags().StringVarP(&ephemeralName, "name", "n", "", "The name of the new environment, generated from the parent's name if not provided.")
+	ephemeralCreateCmd.Flags().DurationVar(&ttl, "ttl", 0, "How long the environment lives for, defaults to the server's configured TTL.")
+	ephemeralCreateCmd.Flags().StringArrayVar(&overrides, "set", nil, "Override a config value in the new environment as key=value, may be repeated.")
+	ephemeralCreateCmd.MarkFlagRequired("parent") // nolint:errcheck
+
+	ephemeralExtendCmd.Flags().DurationVar(&ttl, "ttl", 0, "How long from now the environment should live for.")
+	ephemeralExtendCmd.MarkFlagRequired("ttl") // nolint:errcheck
+
+	ephemeralCmd.AddCommand(ephemeralCreateCmd)
+	ephemeralCmd.AddCommand(ephemeralExtendCmd)
+	ephemeralCmd.AddCommand(ephemeralDeleteCmd)
+	Command.AddCommand(ephemeralCmd)
+}
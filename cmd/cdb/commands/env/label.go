@@ -0,0 +1,64 @@
+package env
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/config-source/cdb/pkg/environments"
+	"github.com/spf13/cobra"
+)
+
+var envLabelCmd = &cobra.Command{
+	Use:   "label <environment> <key=value|key->...",
+	Short: "Add, change or remove labels on an environment",
+	Long: `Add, change or remove labels on an environment.
+
+Labels are given as key=value to set them or key- to remove them, for example:
+
+    cdb env label 12 tier=prod region-`,
+	Args: cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envID, err := resolveEnvironmentID(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		env, err := config.Client.GetEnvironment(context.Background(), envID)
+		if err != nil {
+			return err
+		}
+
+		if env.Labels == nil {
+			env.Labels = environments.Labels{}
+		}
+
+		for _, change := range args[1:] {
+			if key, ok := strings.CutSuffix(change, "-"); ok && !strings.Contains(change, "=") {
+				delete(env.Labels, key)
+				continue
+			}
+
+			key, value, ok := strings.Cut(change, "=")
+			if !ok || key == "" {
+				return fmt.Errorf("invalid label %q, expected key=value or key-", change)
+			}
+
+			env.Labels[key] = value
+		}
+
+		env, err = config.Client.UpdateEnvironment(context.Background(), env)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Labels of %s are now: %s\n", env.Name, formatLabels(env.Labels))
+		return nil
+	},
+}
+
+func init() {
+	envLabelCmd.Flags().StringVarP(&service, "service", "s", "", "Which service the environment belongs to, required when using an environment name.")
+	Command.AddCommand(envLabelCmd)
+}
@@ -0,0 +1,60 @@
+package env
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/config-source/cdb/cmd/cdb/table"
+	"github.com/config-source/cdb/pkg/environments"
+	"github.com/spf13/cobra"
+)
+
+var selector string
+
+func formatLabels(labels environments.Labels) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
+
+var envListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List environments, optionally filtered by a label selector",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envs, err := config.Client.ListEnvironments(context.Background(), selector)
+		if err != nil {
+			return err
+		}
+
+		tbl := table.Table{
+			Headings: []string{"ID", "Service", "Name", "Labels"},
+			Rows:     make([][]string, len(envs)),
+		}
+
+		for idx, env := range envs {
+			tbl.Rows[idx] = []string{
+				strconv.Itoa(env.ID),
+				env.Service,
+				env.Name,
+				formatLabels(env.Labels),
+			}
+		}
+
+		fmt.Println(tbl)
+		return nil
+	},
+}
+
+func init() {
+	envListCmd.Flags().StringVarP(&selector, "selector", "l", "", "Only list environments matching a label selector such as tier=prod,region in (eu,us).")
+	Command.AddCommand(envListCmd)
+}
@@ -0,0 +1,99 @@
+package env
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/config-source/cdb/pkg/configvalues"
+	"github.com/spf13/cobra"
+)
+
+func printChange(change configvalues.ValueChange) {
+	switch {
+	case change.Before == nil:
+		fmt.Printf("  + %s: %s\n", change.Key, change.After.ValueAsString())
+	case change.After == nil:
+		fmt.Printf("  - %s: %s\n", change.Key, change.Before.ValueAsString())
+	default:
+		fmt.Printf("  ~ %s: %s -> %s\n", change.Key, change.Before.ValueAsString(), change.After.ValueAsString())
+	}
+}
+
+var envReparentCmd = &cobra.Command{
+	Use:   "reparent <environment> <new-parent>",
+	Short: "Change which environment an environment promotes to",
+	Long: `Change which environment an environment promotes to. Use "none" as the new
+parent to stop the environment inheriting from anything.
+
+The changes to the resolved configuration of the environment and everything
+that inherits from it are shown before asking for confirmation.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := resolveEnvironmentID(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		env, err := config.Client.GetEnvironment(context.Background(), id)
+		if err != nil {
+			return err
+		}
+
+		env.PromotesToID = nil
+		if args[1] != "none" {
+			parentID, err := resolveEnvironmentID(cmd.Context(), args[1])
+			if err != nil {
+				return err
+			}
+
+			env.PromotesToID = &parentID
+		}
+
+		preview, err := config.Client.PreviewUpdateEnvironment(context.Background(), env)
+		if err != nil {
+			return err
+		}
+
+		changed := false
+		for _, diff := range preview.Diffs {
+			if len(diff.Changes) == 0 {
+				continue
+			}
+
+			changed = true
+			fmt.Printf("%s:\n", diff.Environment.Name)
+			for _, change := range diff.Changes {
+				printChange(change)
+			}
+		}
+
+		if !changed {
+			fmt.Println("No resolved configuration will change.")
+		}
+
+		ok, err := confirm(fmt.Sprintf("Reparent %s?", env.Name))
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return errors.New("aborted")
+		}
+
+		_, err = config.Client.UpdateEnvironment(context.Background(), env)
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("Successfully reparented environment:", env.Name)
+		return nil
+	},
+}
+
+func init() {
+	envReparentCmd.Flags().StringVarP(&service, "service", "s", "", "Which service the environments belong to, required when using environment names.")
+	envReparentCmd.Flags().BoolVarP(&assumeYes, "yes", "y", false, "Don't ask for confirmation.")
+	Command.AddCommand(envReparentCmd)
+}
@@ -2,36 +2,87 @@ package commands
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"syscall"
+	"time"
 
 	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/config-source/cdb/pkg/auth"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
 )
 
+var (
+	tokenName   string
+	tokenTTL    time.Duration
+	tokenScopes []string
+)
+
 func login(ctx context.Context) (string, error) {
-	email, err := input("Email: ")
-	if err != nil {
-		return "", err
+	req := auth.APITokenRequest{Name: tokenName}
+	for _, raw := range tokenScopes {
+		spec, err := auth.ParseScopeSpec(raw)
+		if err != nil {
+			return "", err
+		}
+
+		req.Scopes = append(req.Scopes, spec)
 	}
 
-	fmt.Print("Password: ")
-	bytepw, err := term.ReadPassword(syscall.Stdin)
-	fmt.Print("\n")
+	email, password, err := credentials()
 	if err != nil {
 		return "", err
 	}
 
-	_, err = config.Client.Login(ctx, email, string(bytepw))
+	_, err = config.Client.Login(ctx, email, password, "")
+	if errors.Is(err, auth.ErrMFAEnrolmentRequired) {
+		fmt.Println("Your permissions require you to enrol in MFA before logging in.")
+		if err := enrolMFA(ctx, email, password); err != nil {
+			return "", err
+		}
+
+		err = auth.ErrMFARequired
+	}
+
+	if errors.Is(err, auth.ErrMFARequired) {
+		code, inputErr := input("MFA code: ")
+		if inputErr != nil {
+			return "", inputErr
+		}
+
+		_, err = config.Client.Login(ctx, email, password, code)
+	}
+
 	if err != nil {
 		return "", err
 	}
 
-	token, err := config.Client.IssueAPIToken(ctx, email, string(bytepw))
+	if tokenTTL > 0 {
+		expiresAt := time.Now().Add(tokenTTL)
+		req.ExpiresAt = &expiresAt
+	}
+
+	token, err := config.Client.IssueAPIToken(ctx, req)
 	return token.Token, err
 }
 
+func credentials() (email, password string, err error) {
+	email, err = input("Email: ")
+	if err != nil {
+		return "", "", err
+	}
+
+	fmt.Print("Password: ")
+	bytepw, err := term.ReadPassword(syscall.Stdin)
+	fmt.Print("\n")
+	if err != nil {
+		return "", "", err
+	}
+
+	return email, string(bytepw), nil
+}
+
 var loginCmd = &cobra.Command{
 	Use:   "login",
 	Short: "Generate an API token for use with this CLI",
@@ -45,3 +96,14 @@ var loginCmd = &cobra.Command{
 		return nil
 	},
 }
+
+func init() {
+	loginCmd.Flags().StringVar(&tokenName, "token-name", "cdb cli", "Name of the API token, shown by cdb token list.")
+	loginCmd.Flags().DurationVar(&tokenTTL, "ttl", 0, "How long the API token lasts for, by default it lasts until it's revoked.")
+	loginCmd.Flags().StringArrayVar(
+		&tokenScopes,
+		"scope",
+		nil,
+		"Limit the API token to service/environment:access, for example payments/*:read. Can be given more than once.",
+	)
+}
@@ -0,0 +1,84 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/spf13/cobra"
+)
+
+// enrolMFA walks the user through adding a TOTP secret to their
+// authenticator app and prints their recovery codes.
+func enrolMFA(ctx context.Context, email, password string) error {
+	enrolment, err := config.Client.EnrolMFA(ctx, email, password)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Add this account to your authenticator app, most can scan the URI as a QR code:")
+	fmt.Println()
+	fmt.Println("\t" + enrolment.ProvisioningURI)
+	fmt.Println()
+	fmt.Println("Or enter the secret by hand: " + enrolment.Secret)
+
+	code, err := input("Code from your authenticator app: ")
+	if err != nil {
+		return err
+	}
+
+	codes, err := config.Client.ConfirmMFA(ctx, email, password, code)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("Store these recovery codes somewhere safe, each can be used once instead of a code:")
+	fmt.Println()
+	for _, code := range codes {
+		fmt.Println("\t" + code)
+	}
+	fmt.Println()
+	fmt.Println("Codes can only be used once so wait for the next one before logging in.")
+
+	return nil
+}
+
+var mfaCmd = &cobra.Command{
+	Use:   "mfa",
+	Short: "Manage multi-factor authentication",
+}
+
+var mfaEnrolCmd = &cobra.Command{
+	Use:   "enrol",
+	Short: "Enrol in multi-factor authentication",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		email, password, err := credentials()
+		if err != nil {
+			return err
+		}
+
+		return enrolMFA(cmd.Context(), email, password)
+	},
+}
+
+var mfaResetCmd = &cobra.Command{
+	Use:   "reset <user id>",
+	Short: "Remove a user's MFA enrolment so that they can enrol again",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+
+		return config.Client.ResetMFA(cmd.Context(), auth.UserID(id))
+	},
+}
+
+func init() {
+	mfaCmd.AddCommand(mfaEnrolCmd)
+	mfaCmd.AddCommand(mfaResetCmd)
+}
@@ -0,0 +1,191 @@
+package role
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/config-source/cdb/cmd/cdb/commands/user"
+	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/config-source/cdb/cmd/cdb/table"
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/spf13/cobra"
+)
+
+var Command = &cobra.Command{
+	Use:   "role <subcommand>",
+	Short: "Manage roles, their permissions and who they're assigned to",
+}
+
+func parsePermissions(args []string) []auth.Permission {
+	permissions := make([]auth.Permission, len(args))
+	for idx, arg := range args {
+		permissions[idx] = auth.Permission(strings.ToUpper(arg))
+	}
+
+	return permissions
+}
+
+var roleListUser string
+
+var roleListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List roles and their permissions, or the roles of a user",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if roleListUser != "" {
+			id, err := user.ResolveUserID(cmd.Context(), roleListUser)
+			if err != nil {
+				return err
+			}
+
+			roles, err := config.Client.GetUserRoles(cmd.Context(), id)
+			if err != nil {
+				return err
+			}
+
+			for _, role := range roles {
+				fmt.Println(role)
+			}
+
+			return nil
+		}
+
+		roles, err := config.Client.ListRoles(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		tbl := table.Table{
+			Headings: []string{"Name", "Permissions"},
+			Rows:     make([][]string, len(roles)),
+		}
+
+		for idx, role := range roles {
+			permissions := make([]string, len(role.Permissions))
+			for i, permission := range role.Permissions {
+				permissions[i] = string(permission)
+			}
+
+			tbl.Rows[idx] = []string{role.Name, strings.Join(permissions, ", ")}
+		}
+
+		fmt.Println(tbl)
+		return nil
+	},
+}
+
+var roleCreateCmd = &cobra.Command{
+	Use:   "create <name> [permission...]",
+	Short: "Create a role granting the given permissions",
+	Args:  cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		role, err := config.Client.CreateRole(cmd.Context(), auth.Role{
+			Name:        args[0],
+			Permissions: parsePermissions(args[1:]),
+		})
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Successfully created role %s\n", role.Name)
+		return nil
+	},
+}
+
+var roleDeleteCmd = &cobra.Command{
+	Use:   "delete <name>",
+	Short: "Delete a role, unassigning it from every user",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := config.Client.DeleteRole(cmd.Context(), args[0]); err != nil {
+			return err
+		}
+
+		fmt.Printf("Successfully deleted role %s\n", args[0])
+		return nil
+	},
+}
+
+var roleGrantCmd = &cobra.Command{
+	Use:   "grant <role> <permission...>",
+	Short: "Grant permissions to a role",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, permission := range parsePermissions(args[1:]) {
+			if err := config.Client.GrantPermission(cmd.Context(), args[0], permission); err != nil {
+				return err
+			}
+
+			fmt.Printf("Successfully granted %s to %s\n", permission, args[0])
+		}
+
+		return nil
+	},
+}
+
+var roleRevokeCmd = &cobra.Command{
+	Use:   "revoke <role> <permission...>",
+	Short: "Revoke permissions from a role",
+	Args:  cobra.MinimumNArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		for _, permission := range parsePermissions(args[1:]) {
+			if err := config.Client.RevokePermission(cmd.Context(), args[0], permission); err != nil {
+				return err
+			}
+
+			fmt.Printf("Successfully revoked %s from %s\n", permission, args[0])
+		}
+
+		return nil
+	},
+}
+
+var roleAssignCmd = &cobra.Command{
+	Use:   "assign <role> <user>",
+	Short: "Assign a role to a user by ID or email",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := user.ResolveUserID(cmd.Context(), args[1])
+		if err != nil {
+			return err
+		}
+
+		if err := config.Client.AssignRole(cmd.Context(), id, args[0]); err != nil {
+			return err
+		}
+
+		fmt.Printf("Successfully assigned %s to %s\n", args[0], args[1])
+		return nil
+	},
+}
+
+var roleUnassignCmd = &cobra.Command{
+	Use:   "unassign <role> <user>",
+	Short: "Remove a role from a user by ID or email",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := user.ResolveUserID(cmd.Context(), args[1])
+		if err != nil {
+			return err
+		}
+
+		if err := config.Client.RemoveRole(cmd.Context(), id, args[0]); err != nil {
+			return err
+		}
+
+		fmt.Printf("Successfully removed %s from %s\n", args[0], args[1])
+		return nil
+	},
+}
+
+func init() {
+	roleListCmd.Flags().StringVar(&roleListUser, "user", "", "List the roles assigned to this user ID or email instead")
+
+	Command.AddCommand(roleListCmd)
+	Command.AddCommand(roleCreateCmd)
+	Command.AddCommand(roleDeleteCmd)
+	Command.AddCommand(roleGrantCmd)
+	Command.AddCommand(roleRevokeCmd)
+	Command.AddCommand(roleAssignCmd)
+	Command.AddCommand(roleUnassignCmd)
+}
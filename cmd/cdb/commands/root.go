@@ -3,8 +3,14 @@ package commands
 import (
 	"os"
 
+	"github.com/config-source/cdb/cmd/cdb/commands/audit"
 	"github.com/config-source/cdb/cmd/cdb/commands/configuration"
 	"github.com/config-source/cdb/cmd/cdb/commands/env"
+	"github.com/config-source/cdb/cmd/cdb/commands/role"
+	"github.com/config-source/cdb/cmd/cdb/commands/service"
+	"github.com/config-source/cdb/cmd/cdb/commands/token"
+	"github.com/config-source/cdb/cmd/cdb/commands/trash"
+	"github.com/config-source/cdb/cmd/cdb/commands/user"
 	"github.com/config-source/cdb/cmd/cdb/config"
 	"github.com/spf13/cobra"
 )
@@ -21,8 +27,15 @@ var rootCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(configuration.Command)
 	rootCmd.AddCommand(env.Command)
+	rootCmd.AddCommand(service.Command)
+	rootCmd.AddCommand(token.Command)
+	rootCmd.AddCommand(role.Command)
+	rootCmd.AddCommand(trash.Command)
+	rootCmd.AddCommand(user.Command)
+	rootCmd.AddCommand(audit.Command)
 	rootCmd.AddCommand(setupCmd)
 	rootCmd.AddCommand(loginCmd)
+	rootCmd.AddCommand(mfaCmd)
 }
 
 func Execute() {
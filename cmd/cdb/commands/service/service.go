@@ -0,0 +1,140 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/config-source/cdb/cmd/cdb/table"
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/services"
+	"github.com/spf13/cobra"
+)
+
+var Command = &cobra.Command{
+	Use: "service <subcommand>",
+	Aliases: []string{
+		"svc",
+	},
+}
+
+var roleCmd = &cobra.Command{
+	Use:   "role <subcommand>",
+	Short: "Manage which users are owners or developers of a service",
+}
+
+// resolveServiceID accepts a service ID or name.
+func resolveServiceID(ctx context.Context, nameOrID string) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+
+	svc, err := config.Client.GetServiceByName(ctx, nameOrID)
+	if err != nil {
+		return 0, err
+	}
+
+	return svc.ID, nil
+}
+
+func parseUserID(raw string) (auth.UserID, error) {
+	id, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("invalid user ID %q: %w", raw, err)
+	}
+
+	return auth.UserID(id), nil
+}
+
+var roleListCmd = &cobra.Command{
+	Use:   "list <service>",
+	Short: "List the users with a role on a service",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		serviceID, err := resolveServiceID(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		roles, err := config.Client.ListServiceRoles(context.Background(), serviceID)
+		if err != nil {
+			return err
+		}
+
+		tbl := table.Table{
+			Headings: []string{"User ID", "Role"},
+			Rows:     make([][]string, len(roles)),
+		}
+
+		for idx, role := range roles {
+			tbl.Rows[idx] = []string{strconv.Itoa(int(role.UserID)), role.Role}
+		}
+
+		fmt.Println(tbl)
+		return nil
+	},
+}
+
+var roleAssignCmd = &cobra.Command{
+	Use:   "assign <service> <user-id> <owner|developer>",
+	Short: "Give a user a role on a service, replacing any role they already have on it",
+	Args:  cobra.ExactArgs(3),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		serviceID, err := resolveServiceID(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		userID, err := parseUserID(args[1])
+		if err != nil {
+			return err
+		}
+
+		role := services.RoleName(strings.ToUpper(args[2]))
+		if err := role.Valid(); err != nil {
+			return err
+		}
+
+		err = config.Client.AssignServiceRole(context.Background(), serviceID, userID, role)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("User %d is now %s of %s\n", userID, role, args[0])
+		return nil
+	},
+}
+
+var roleRemoveCmd = &cobra.Command{
+	Use:   "remove <service> <user-id>",
+	Short: "Remove a user's role on a service",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		serviceID, err := resolveServiceID(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		userID, err := parseUserID(args[1])
+		if err != nil {
+			return err
+		}
+
+		err = config.Client.RemoveServiceRole(context.Background(), serviceID, userID)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Removed user %d's role on %s\n", userID, args[0])
+		return nil
+	},
+}
+
+func init() {
+	roleCmd.AddCommand(roleListCmd)
+	roleCmd.AddCommand(roleAssignCmd)
+	roleCmd.AddCommand(roleRemoveCmd)
+	Command.AddCommand(roleCmd)
+}
@@ -0,0 +1,98 @@
+package token
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/spf13/cobra"
+)
+
+var Command = &cobra.Command{
+	Use:   "token <subcommand>",
+	Short: "Manage your API tokens",
+}
+
+func formatTime(t *time.Time, fallback string) string {
+	if t == nil {
+		return fallback
+	}
+
+	return t.Local().Format(time.RFC3339)
+}
+
+// formatScopes shows scopes by service and environment ID, * standing for
+// every environment of the service.
+func formatScopes(scopes auth.Scopes) string {
+	if !scopes.Restricted() {
+		return "unrestricted"
+	}
+
+	formatted := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		env := "*"
+		if scope.EnvironmentID != nil {
+			env = strconv.Itoa(*scope.EnvironmentID)
+		}
+
+		formatted = append(formatted, fmt.Sprintf("%d/%s:%s", scope.ServiceID, env, scope.Access))
+	}
+
+	return strings.Join(formatted, ",")
+}
+
+var tokenListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List your API tokens which haven't been revoked",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		tokens, err := config.Client.ListAPITokens(context.Background())
+		if err != nil {
+			return err
+		}
+
+		fmt.Println("ID\tName\tCreated\tExpires\tLast Used\tScopes")
+		for _, token := range tokens {
+			fmt.Printf(
+				"%d\t%s\t%s\t%s\t%s\t%s\n",
+				token.ID,
+				token.Name,
+				token.CreatedAt.Local().Format(time.RFC3339),
+				formatTime(token.ExpiresAt, "never"),
+				formatTime(token.LastUsedAt, "never"),
+				formatScopes(token.Scopes),
+			)
+		}
+
+		return nil
+	},
+}
+
+var tokenRevokeCmd = &cobra.Command{
+	Use:   "revoke <id>",
+	Short: "Revoke an API token so it can no longer be used",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[0])
+		if err != nil {
+			return err
+		}
+
+		err = config.Client.RevokeAPIToken(context.Background(), id)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Successfully revoked API token %d\n", id)
+		return nil
+	},
+}
+
+func init() {
+	Command.AddCommand(tokenListCmd)
+	Command.AddCommand(tokenRevokeCmd)
+}
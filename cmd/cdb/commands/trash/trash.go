@@ -0,0 +1,77 @@
+package trash
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/config-source/cdb/pkg/trash"
+	"github.com/spf13/cobra"
+)
+
+var Command = &cobra.Command{
+	Use:   "trash <subcommand>",
+	Short: "View and restore deleted environments, config keys and services",
+}
+
+func deletedAt(t *time.Time) string {
+	if t == nil {
+		return ""
+	}
+
+	return t.Local().Format(time.RFC3339)
+}
+
+var trashListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List everything in the trash",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		items, err := config.Client.ListTrash(context.Background())
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Items are purged %s after they are deleted.\n", items.Retention)
+		for _, svc := range items.Services {
+			fmt.Printf("%s\t%d\t%s\t%s\n", trash.KindService, svc.ID, svc.Name, deletedAt(svc.DeletedAt))
+		}
+
+		for _, env := range items.Environments {
+			fmt.Printf("%s\t%d\t%s/%s\t%s\n", trash.KindEnvironment, env.ID, env.Service, env.Name, deletedAt(env.DeletedAt))
+		}
+
+		for _, ck := range items.ConfigKeys {
+			fmt.Printf("%s\t%d\t%s/%s\t%s\n", trash.KindConfigKey, ck.ID, ck.Service, ck.Name, deletedAt(ck.DeletedAt))
+		}
+
+		return nil
+	},
+}
+
+var trashRestoreCmd = &cobra.Command{
+	Use:   "restore <services|environments|config-keys> <id>",
+	Short: "Take an item back out of the trash",
+	Args:  cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return err
+		}
+
+		err = config.Client.RestoreFromTrash(context.Background(), trash.Kind(args[0]), id)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Successfully restored %s %d\n", args[0], id)
+		return nil
+	},
+}
+
+func init() {
+	Command.AddCommand(trashListCmd)
+	Command.AddCommand(trashRestoreCmd)
+}
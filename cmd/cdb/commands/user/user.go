@@ -0,0 +1,262 @@
+package user
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/config-source/cdb/cmd/cdb/config"
+	"github.com/config-source/cdb/cmd/cdb/table"
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var Command = &cobra.Command{
+	Use:   "user <subcommand>",
+	Short: "Manage users",
+}
+
+// ResolveUserID accepts a user ID or email.
+func ResolveUserID(ctx context.Context, emailOrID string) (auth.UserID, error) {
+	if id, err := strconv.Atoi(emailOrID); err == nil {
+		return auth.UserID(id), nil
+	}
+
+	users, err := config.Client.ListUsers(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, user := range users {
+		if user.Email == emailOrID {
+			return user.ID, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: %s", auth.ErrUserNotFound, emailOrID)
+}
+
+func status(user auth.User) string {
+	if user.Disabled {
+		return "disabled"
+	}
+
+	return "enabled"
+}
+
+func printUsers(users ...auth.User) {
+	tbl := table.Table{
+		Headings: []string{"ID", "Email", "Status"},
+		Rows:     make([][]string, len(users)),
+	}
+
+	for idx, user := range users {
+		tbl.Rows[idx] = []string{strconv.Itoa(int(user.ID)), user.Email, status(user)}
+	}
+
+	fmt.Println(tbl)
+}
+
+var userListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List users",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		users, err := config.Client.ListUsers(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		printUsers(users...)
+		return nil
+	},
+}
+
+var userGetCmd = &cobra.Command{
+	Use:   "get <user>",
+	Short: "Show a user by ID or email",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := ResolveUserID(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		user, err := config.Client.GetUser(cmd.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		printUsers(user)
+		return nil
+	},
+}
+
+var userCreateCmd = &cobra.Command{
+	Use:   "create <email>",
+	Short: "Create a user, their password is prompted for",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		fmt.Print("Password: ")
+		bytepw, err := term.ReadPassword(syscall.Stdin)
+		fmt.Print("\n")
+		if err != nil {
+			return err
+		}
+
+		user, err := config.Client.CreateUser(cmd.Context(), args[0], string(bytepw))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Successfully created user %s with ID %d\n", user.Email, user.ID)
+		return nil
+	},
+}
+
+var userDeleteCmd = &cobra.Command{
+	Use:   "delete <user>",
+	Short: "Delete a user by ID or email",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := ResolveUserID(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		if err := config.Client.DeleteUser(cmd.Context(), id); err != nil {
+			return err
+		}
+
+		fmt.Printf("Successfully deleted user %d\n", id)
+		return nil
+	},
+}
+
+var userDisableCmd = &cobra.Command{
+	Use:   "disable <user>",
+	Short: "Stop a user logging in and revoke their sessions and API tokens",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := ResolveUserID(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		user, err := config.Client.DisableUser(cmd.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Successfully disabled user %s\n", user.Email)
+		return nil
+	},
+}
+
+var userEnableCmd = &cobra.Command{
+	Use:   "enable <user>",
+	Short: "Let a disabled user log in again",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := ResolveUserID(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		user, err := config.Client.EnableUser(cmd.Context(), id)
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Successfully enabled user %s\n", user.Email)
+		return nil
+	},
+}
+
+var userResetPasswordCmd = &cobra.Command{
+	Use:   "reset-password <user>",
+	Short: "Send a user a token they can set a new password with",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		id, err := ResolveUserID(cmd.Context(), args[0])
+		if err != nil {
+			return err
+		}
+
+		if err := config.Client.StartPasswordReset(cmd.Context(), id); err != nil {
+			return err
+		}
+
+		fmt.Printf("Successfully sent a password reset to user %d\n", id)
+		return nil
+	},
+}
+
+var userLockoutsCmd = &cobra.Command{
+	Use:   "lockouts",
+	Short: "List accounts and IP addresses locked after too many failed logins",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		lockouts, err := config.Client.ListLoginLockouts(cmd.Context())
+		if err != nil {
+			return err
+		}
+
+		tbl := table.Table{
+			Headings: []string{"Kind", "Account or IP", "Failures", "Locked Until"},
+			Rows:     make([][]string, len(lockouts)),
+		}
+
+		for idx, lockout := range lockouts {
+			lockedUntil := ""
+			if lockout.LockedUntil != nil {
+				lockedUntil = lockout.LockedUntil.Local().Format(time.RFC3339)
+			}
+
+			tbl.Rows[idx] = []string{
+				string(lockout.Kind),
+				lockout.Key,
+				strconv.Itoa(lockout.Failures),
+				lockedUntil,
+			}
+		}
+
+		fmt.Println(tbl)
+		return nil
+	},
+}
+
+var userUnlockCmd = &cobra.Command{
+	Use:   "unlock <email or IP address>",
+	Short: "Lift the lockout of an account or IP address",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		kind := auth.LoginFailureAccount
+		if net.ParseIP(args[0]) != nil {
+			kind = auth.LoginFailureIP
+		}
+
+		if err := config.Client.UnlockLogin(cmd.Context(), kind, args[0]); err != nil {
+			return err
+		}
+
+		fmt.Printf("Successfully unlocked %s\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	Command.AddCommand(userListCmd)
+	Command.AddCommand(userGetCmd)
+	Command.AddCommand(userCreateCmd)
+	Command.AddCommand(userDeleteCmd)
+	Command.AddCommand(userDisableCmd)
+	Command.AddCommand(userEnableCmd)
+	Command.AddCommand(userResetPasswordCmd)
+	Command.AddCommand(userLockoutsCmd)
+	Command.AddCommand(userUnlockCmd)
+}
@@ -0,0 +1,26 @@
+package config
+
+import (
+	"context"
+	"errors"
+	"strconv"
+)
+
+// ResolveEnvironmentID accepts an environment ID or the name of an environment
+// in service.
+func ResolveEnvironmentID(ctx context.Context, service, nameOrID string) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		return id, nil
+	}
+
+	if service == "" {
+		return 0, errors.New("--service is required when using environment names")
+	}
+
+	env, err := Client.GetEnvironmentByName(ctx, service, nameOrID)
+	if err != nil {
+		return 0, err
+	}
+
+	return env.ID, nil
+}
@@ -43,6 +43,15 @@ flag.`,
 			return err
 		}
 
+		policy, err := getPasswordPolicy()
+		if err != nil {
+			return err
+		}
+
+		if err := policy.Check(string(bytepw)); err != nil {
+			return err
+		}
+
 		user, err := gateway.Register(context.Background(), email, string(bytepw))
 		if err != nil {
 			return err
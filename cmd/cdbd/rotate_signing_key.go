@@ -0,0 +1,75 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/config-source/cdb/internal/settings"
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/spf13/cobra"
+)
+
+var rotateSigningKeyCmd = &cobra.Command{
+	Use:   "rotate-signing-key",
+	Short: "Add a new key for signing tokens",
+	Long: `Add a new key for signing tokens.
+
+Tokens start being signed with the new key once it's active, by default after
+twice $SIGNING_KEY_RELOAD_INTERVAL so that every cdbd has loaded it first.
+Older keys are still used to verify tokens so outstanding tokens stay valid.
+
+The key is generated for --algorithm which defaults to $JWT_SIGNING_ALGORITHM
+and must be RS256 or EdDSA.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		logger := settings.GetLogger()
+
+		algorithm := auth.Algorithm(rotateAlgorithm)
+		if algorithm == "" {
+			algorithm = settings.JWTSigningAlgorithm()
+		}
+
+		if !algorithm.Asymmetric() {
+			return fmt.Errorf("signing keys can only be rotated for RS256 or EdDSA not %s", algorithm)
+		}
+
+		activateAfter := rotateActivateAfter
+		if !cmd.Flags().Changed("activate-after") {
+			activateAfter = 2 * settings.SigningKeyReloadInterval()
+		}
+
+		pool, err := pgxpool.New(
+			cmd.Context(),
+			settings.DBUrl(),
+		)
+		if err != nil {
+			return err
+		}
+		defer pool.Close()
+
+		key, err := auth.NewKeyStore(logger, pool).AddSigningKey(cmd.Context(), algorithm, time.Now().Add(activateAfter))
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Added %s signing key %s, active from %s\n", key.Algorithm, key.ID, key.ActiveAt.Local().Format(time.RFC3339))
+		return nil
+	},
+}
+
+var (
+	rotateAlgorithm     string
+	rotateActivateAfter time.Duration
+)
+
+func init() {
+	rotateSigningKeyCmd.Flags().StringVar(&rotateAlgorithm, "algorithm", "", "RS256 or EdDSA, defaults to $JWT_SIGNING_ALGORITHM.")
+	rotateSigningKeyCmd.Flags().DurationVar(
+		&rotateActivateAfter,
+		"activate-after",
+		0,
+		"How long until tokens are signed with the new key, defaults to twice $SIGNING_KEY_RELOAD_INTERVAL.",
+	)
+
+	rootCmd.AddCommand(rotateSigningKeyCmd)
+}
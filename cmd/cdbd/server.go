@@ -1,26 +1,92 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"os"
 
+	"github.com/config-source/cdb/internal/jobs"
+	"github.com/config-source/cdb/internal/middleware"
 	"github.com/config-source/cdb/internal/server"
 	"github.com/config-source/cdb/internal/settings"
+	"github.com/config-source/cdb/pkg/audit"
 	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/auth/ldap"
+	"github.com/config-source/cdb/pkg/auth/oidc"
 	"github.com/config-source/cdb/pkg/auth/postgres"
 	"github.com/config-source/cdb/pkg/configkeys"
 	"github.com/config-source/cdb/pkg/configvalues"
 	"github.com/config-source/cdb/pkg/environments"
+	"github.com/config-source/cdb/pkg/ephemeral"
+	"github.com/config-source/cdb/pkg/scheduledchanges"
 	"github.com/config-source/cdb/pkg/services"
+	"github.com/config-source/cdb/pkg/trash"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/pseidemann/finish"
 	"github.com/rs/zerolog"
 	"github.com/spf13/cobra"
 )
 
-func getAuthenticationGateway(log zerolog.Logger, pool *pgxpool.Pool) auth.AuthenticationGateway {
+func getAuthenticationGateway(
+	ctx context.Context,
+	log zerolog.Logger,
+	pool *pgxpool.Pool,
+	authz auth.AuthorizationGateway,
+	auditLog *audit.Log,
+) (auth.AuthenticationGateway, error) {
 	gatewayName := settings.AuthenticationGateway()
 	switch gatewayName {
+	case "oidc":
+		users := postgres.NewGateway(log, pool)
+		return oidc.NewGateway(
+			ctx,
+			log,
+			oidc.Config{
+				IssuerURL:    settings.OIDCIssuerURL(),
+				ClientID:     settings.OIDCClientID(),
+				ClientSecret: settings.OIDCClientSecret(),
+				RedirectURL:  settings.OIDCRedirectURL(),
+				Scopes:       settings.OIDCScopes(),
+				GroupsClaim:  settings.OIDCGroupsClaim(),
+				GroupRoles:   settings.OIDCGroupRoles(),
+
+				LinkExistingUsers: settings.OIDCLinkExistingUsers(),
+			},
+			users,
+			users,
+			authz,
+			auditLog,
+		)
+	case "ldap":
+		tlsConfig, err := getLDAPTLSConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		return ldap.NewGateway(
+			log,
+			ldap.Config{
+				URL:            settings.LDAPURL(),
+				StartTLS:       settings.LDAPStartTLS(),
+				TLSConfig:      tlsConfig,
+				BindDN:         settings.LDAPBindDN(),
+				BindPassword:   settings.LDAPBindPassword(),
+				UserBaseDN:     settings.LDAPUserBaseDN(),
+				UserFilter:     settings.LDAPUserFilter(),
+				EmailAttribute: settings.LDAPEmailAttribute(),
+				GroupBaseDN:    settings.LDAPGroupBaseDN(),
+				GroupFilter:    settings.LDAPGroupFilter(),
+				GroupRoles:     settings.LDAPGroupRoles(),
+			},
+			postgres.NewGateway(log, pool),
+			authz,
+			auditLog,
+		)
 	default:
 		if gatewayName == "" {
 			log.Warn().Msg("no AUTHENTICATION_GATEWAY configured, using postgres as default")
@@ -28,7 +94,53 @@ func getAuthenticationGateway(log zerolog.Logger, pool *pgxpool.Pool) auth.Authe
 			log.Error().Str("gatewayName", gatewayName).Msg("is not a valid gateway")
 		}
 
-		return postgres.NewGateway(log, pool)
+		return postgres.NewGateway(log, pool), nil
+	}
+}
+
+// getLDAPTLSConfig returns nil, so the gateway uses the system trust store,
+// unless LDAP_CA_FILE is set.
+func getLDAPTLSConfig() (*tls.Config, error) {
+	caFile := settings.LDAPCAFile()
+	if caFile == "" {
+		return nil, nil
+	}
+
+	pem, err := os.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates found in %s", caFile)
+	}
+
+	serverName := ""
+	if parsed, err := url.Parse(settings.LDAPURL()); err == nil {
+		serverName = parsed.Hostname()
+	}
+
+	return &tls.Config{RootCAs: pool, ServerName: serverName}, nil
+}
+
+func getPasswordPolicy() (auth.PasswordPolicy, error) {
+	return auth.NewPasswordPolicy(settings.PasswordMinLength(), settings.BreachedPasswordsFile())
+}
+
+func getNotifier(log zerolog.Logger) (auth.Notifier, error) {
+	switch notifier := settings.PasswordResetNotifier(); notifier {
+	case "log":
+		return auth.NewLogNotifier(log), nil
+	case "file":
+		path := settings.PasswordResetFile()
+		if path == "" {
+			return nil, errors.New("PASSWORD_RESET_FILE must be set when PASSWORD_RESET_NOTIFIER is file")
+		}
+
+		return auth.NewFileNotifier(path), nil
+	default:
+		return nil, fmt.Errorf("%s is not a valid PASSWORD_RESET_NOTIFIER, must be log or file", notifier)
 	}
 }
 
@@ -60,49 +172,167 @@ var serverCmd = &cobra.Command{
 			return err
 		}
 
-		authenticationGateway := getAuthenticationGateway(logger, pool)
+		auditLog := audit.NewLog(logger, pool)
 		authorizationGateway := getAuthorizationGateway(logger, pool)
+		authenticationGateway, err := getAuthenticationGateway(cmd.Context(), logger, pool, authorizationGateway, auditLog)
+		if err != nil {
+			return err
+		}
 
 		envsRepo := environments.NewRepository(logger, pool)
 		keysRepo := configkeys.NewRepository(logger, pool)
-		valuesRepo := configvalues.NewRepository(logger, pool, envsRepo)
+		valuesRepo := configvalues.NewRepository(logger, pool, envsRepo, keysRepo)
 		svcRepo := services.NewRepository(logger, pool)
 		tokenRegistry := auth.NewTokenRegistry(logger, pool)
+		loginThrottle := auth.NewLoginThrottle(logger, pool, auth.LockoutPolicy{
+			MaxAccountFailures: settings.LoginMaxAccountFailures(),
+			MaxIPFailures:      settings.LoginMaxIPFailures(),
+			BaseDelay:          settings.LoginBackoff(),
+			MaxDelay:           settings.LoginMaxBackoff(),
+			LockoutDuration:    settings.LoginLockoutDuration(),
+		})
+		keyStore := auth.NewKeyStore(logger, pool)
+		scheduledChangesRepo := scheduledchanges.NewRepository(logger, pool)
 
-		envsService := environments.NewService(envsRepo, authorizationGateway)
-		keysService := configkeys.NewService(keysRepo, authorizationGateway)
+		envsService := environments.NewService(envsRepo, authorizationGateway, auditLog)
+		keysService := configkeys.NewService(keysRepo, authorizationGateway, auditLog)
 		valuesService := configvalues.NewService(
+			logger,
 			valuesRepo,
 			envsRepo,
 			keysRepo,
 			authorizationGateway,
 			settings.DynamicConfigKeys(),
+			auditLog,
 		)
+		passwordPolicy, err := getPasswordPolicy()
+		if err != nil {
+			return err
+		}
+
+		notifier, err := getNotifier(logger)
+		if err != nil {
+			return err
+		}
+
 		userService := auth.NewUserService(
 			authenticationGateway,
 			authorizationGateway,
 			tokenRegistry,
+			loginThrottle,
+			auth.NewMFAStore(logger, pool),
 			settings.AllowPublicRegistration(),
 			settings.DefaultRegisterRole(),
+			passwordPolicy,
+			notifier,
+			auditLog,
+		)
+		svcService := services.NewServiceService(svcRepo, authorizationGateway, auditLog)
+		scheduledChangeService := scheduledchanges.NewService(
+			scheduledChangesRepo,
+			envsRepo,
+			valuesService,
+			authenticationGateway,
+			authorizationGateway,
+			auditLog,
+		)
+		ephemeralService := ephemeral.NewService(
+			envsRepo,
+			valuesService,
+			authorizationGateway,
+			settings.EphemeralEnvironmentTTL(),
+			auditLog,
 		)
-		svcService := services.NewServiceService(svcRepo, authorizationGateway)
+		trashService := trash.NewService(
+			envsRepo,
+			keysRepo,
+			svcRepo,
+			authorizationGateway,
+			settings.TrashRetention(),
+			auditLog,
+		)
+
+		keys, err := auth.NewKeySet(settings.JWTSigningAlgorithm(), settings.JWTSigningKey())
+		if err != nil {
+			return err
+		}
+
+		if err := keyStore.Reload(cmd.Context(), keys); err != nil {
+			return err
+		}
 
 		var server http.Handler = server.New(
 			logger,
-			settings.JWTSigningKey(),
+			keys,
 			pool,
 			userService,
 			valuesService,
 			envsService,
 			keysService,
 			svcService,
+			scheduledChangeService,
+			ephemeralService,
+			trashService,
 			settings.FrontendLocation(),
 		)
+		if settings.TrustForwardedFor() {
+			server = middleware.ForwardedFor(server)
+		}
 
 		httpServer := &http.Server{Addr: settings.ListenAddr(), Handler: server}
 
+		runner := jobs.NewRunner(
+			logger,
+			jobs.Job{
+				// Picks up keys added by cdbd rotate-signing-key.
+				Name:     "reload-signing-keys",
+				Interval: settings.SigningKeyReloadInterval(),
+				Run: func(ctx context.Context) error {
+					return keyStore.Reload(ctx, keys)
+				},
+			},
+			jobs.Job{
+				Name:     "apply-scheduled-changes",
+				Interval: settings.ScheduledChangesPollInterval(),
+				Run: func(ctx context.Context) error {
+					applied, err := scheduledChangeService.ApplyDueChanges(ctx)
+					if applied > 0 {
+						logger.Info().Int("applied", applied).Msg("applied scheduled changes")
+					}
+
+					return err
+				},
+			},
+			jobs.Job{
+				Name:     "reap-ephemeral-environments",
+				Interval: settings.EphemeralEnvironmentReapInterval(),
+				Run: func(ctx context.Context) error {
+					_, err := ephemeralService.ReapExpiredEnvironments(ctx)
+					return err
+				},
+			},
+			jobs.Job{
+				Name:     "purge-trash",
+				Interval: settings.TrashPurgeInterval(),
+				Run: func(ctx context.Context) error {
+					purged, err := trashService.Purge(ctx)
+					if purged != (trash.PurgeResult{}) {
+						logger.Info().
+							Int64("environments", purged.Environments).
+							Int64("configKeys", purged.ConfigKeys).
+							Int64("services", purged.Services).
+							Msg("purged expired items from the trash")
+					}
+
+					return err
+				},
+			},
+		)
+		runner.Start(cmd.Context())
+
 		fin := finish.New()
 		fin.Add(httpServer)
+		fin.Add(runner, finish.WithName("background jobs"))
 
 		go func() {
 			if err := httpServer.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) && err != nil {
@@ -24,6 +24,7 @@ declare global {
 			CreatedAt: string;
 			ServiceID: number;
 			Service: string;
+			Sensitive: boolean;
 		}
 
 		interface Environment {
@@ -34,6 +35,10 @@ declare global {
 			Service: string;
 			CreatedAt: string;
 			Sensitive: boolean;
+			InheritanceMode: 'full' | 'none' | 'allowlist' | 'denylist';
+			InheritanceKeys: string[];
+			Labels: Record<string, string>;
+			ExpiresAt?: string;
 		}
 
 		interface CurrentUserInfo {
@@ -15,11 +15,11 @@ export async function getCurrentUser() {
 	};
 }
 
-/** @type (email: string, password: string) => Promise<App.Response<App.User>> */
-export async function login(email, password) {
+/** @type (email: string, password: string, mfaCode?: string) => Promise<App.Response<App.User>> */
+export async function login(email, password, mfaCode = '') {
 	const res = await fetch('/api/v1/auth/login', {
 		method: 'POST',
-		body: JSON.stringify({ Email: email, Password: password })
+		body: JSON.stringify({ Email: email, Password: password, MFACode: mfaCode })
 	});
 	const data = await res.json();
 	if (isError(data)) {
@@ -9,39 +9,53 @@ import (
 	"github.com/config-source/cdb/pkg/configkeys"
 	"github.com/config-source/cdb/pkg/configvalues"
 	"github.com/config-source/cdb/pkg/environments"
+	"github.com/config-source/cdb/pkg/ephemeral"
+	"github.com/config-source/cdb/pkg/scheduledchanges"
 	"github.com/config-source/cdb/pkg/services"
+	"github.com/config-source/cdb/pkg/trash"
 	"github.com/rs/zerolog"
 )
 
 type V1 struct {
-	log             zerolog.Logger
-	tokenSigningKey []byte
+	log  zerolog.Logger
+	keys *auth.KeySet
 
 	userService        *auth.UserService
 	configValueService *configvalues.Service
 	envService         *environments.Service
 	svcService         *services.ServiceService
 	configKeyService   *configkeys.Service
+
+	scheduledChangeService *scheduledchanges.Service
+	ephemeralService       *ephemeral.Service
+	trashService           *trash.Service
 }
 
 func NewV1(
 	log zerolog.Logger,
-	tokenSigningKey []byte,
+	keys *auth.KeySet,
 	userService *auth.UserService,
 	configValueService *configvalues.Service,
 	envService *environments.Service,
 	configKeyService *configkeys.Service,
 	svcService *services.ServiceService,
+	scheduledChangeService *scheduledchanges.Service,
+	ephemeralService *ephemeral.Service,
+	trashService *trash.Service,
 ) (*V1, http.Handler) {
 	api := &V1{
-		log:             log,
-		tokenSigningKey: tokenSigningKey,
+		log:  log,
+		keys: keys,
 
 		configValueService: configValueService,
 		envService:         envService,
 		configKeyService:   configKeyService,
 		userService:        userService,
 		svcService:         svcService,
+
+		scheduledChangeService: scheduledChangeService,
+		ephemeralService:       ephemeralService,
+		trashService:           trashService,
 	}
 
 	// v1 routes
@@ -54,32 +68,90 @@ func NewV1(
 	v1Mux.HandleFunc("POST /api/v1/environments", api.CreateEnvironment)
 	v1Mux.HandleFunc("PUT /api/v1/environments/{id}", api.UpdateEnvironment)
 	v1Mux.HandleFunc("DELETE /api/v1/environments/{id}", api.DeleteEnvironment)
+	v1Mux.HandleFunc("POST /api/v1/environments/{id}/clone", api.CloneEnvironment)
+	v1Mux.HandleFunc("GET /api/v1/environments/{id}/acl", api.ListEnvironmentACL)
+	v1Mux.HandleFunc("POST /api/v1/environments/{id}/acl", api.CreateEnvironmentACLEntry)
+	v1Mux.HandleFunc("DELETE /api/v1/environments/{id}/acl/{entryID}", api.DeleteEnvironmentACLEntry)
+
+	v1Mux.HandleFunc("POST /api/v1/environments/ephemeral", api.CreateEphemeralEnvironment)
+	v1Mux.HandleFunc("POST /api/v1/environments/ephemeral/{id}/extend", api.ExtendEphemeralEnvironment)
+	v1Mux.HandleFunc("DELETE /api/v1/environments/ephemeral/{id}", api.DeleteEphemeralEnvironment)
+
+	v1Mux.HandleFunc("GET /api/v1/overlays", api.ListOverlays)
+	v1Mux.HandleFunc("POST /api/v1/overlays", api.CreateOverlay)
+	v1Mux.HandleFunc("DELETE /api/v1/overlays/{id}", api.DeleteOverlay)
 
 	v1Mux.HandleFunc("GET /api/v1/services/by-name/{name}", api.GetServiceByName)
 	v1Mux.HandleFunc("GET /api/v1/services/by-id/{id}", api.GetServiceByID)
 	v1Mux.HandleFunc("GET /api/v1/services", api.ListServices)
 	v1Mux.HandleFunc("POST /api/v1/services", api.CreateService)
+	v1Mux.HandleFunc("DELETE /api/v1/services/{id}", api.DeleteService)
+	v1Mux.HandleFunc("GET /api/v1/services/{id}/roles", api.ListServiceRoles)
+	v1Mux.HandleFunc("PUT /api/v1/services/{id}/roles/{userID}", api.AssignServiceRole)
+	v1Mux.HandleFunc("DELETE /api/v1/services/{id}/roles/{userID}", api.RemoveServiceRole)
 
 	v1Mux.HandleFunc("POST /api/v1/config-keys", api.CreateConfigKey)
 	v1Mux.HandleFunc("GET /api/v1/config-keys", api.ListConfigKeys)
 	v1Mux.HandleFunc("GET /api/v1/config-keys/by-id/{id}", api.GetConfigKeyByID)
 	v1Mux.HandleFunc("GET /api/v1/config-keys/{serviceName}/by-name/{name}", api.GetConfigKeyByName)
+	v1Mux.HandleFunc("DELETE /api/v1/config-keys/{id}", api.DeleteConfigKey)
+
+	v1Mux.HandleFunc("GET /api/v1/trash", api.ListTrash)
+	v1Mux.HandleFunc("POST /api/v1/trash/{kind}/{id}/restore", api.RestoreFromTrash)
 
 	v1Mux.HandleFunc("POST /api/v1/config-values", api.CreateConfigValue)
 	v1Mux.HandleFunc("GET /api/v1/config-values/{environment}/{key}", api.GetConfigurationValue)
 	v1Mux.HandleFunc("POST /api/v1/config-values/{environment}/{key}", api.SetConfigurationValue)
 	v1Mux.HandleFunc("GET /api/v1/config-values/{environment}", api.GetConfiguration)
 	v1Mux.HandleFunc("POST /api/v1/config-values/{environment}", api.SetConfigurationValues)
+	v1Mux.HandleFunc("POST /api/v1/evaluate/{environment}", api.EvaluateConfiguration)
+	v1Mux.HandleFunc("GET /api/v1/export", api.ExportConfiguration)
+
+	v1Mux.HandleFunc("GET /api/v1/scheduled-changes", api.ListScheduledChanges)
+	v1Mux.HandleFunc("POST /api/v1/scheduled-changes", api.ScheduleChange)
+	v1Mux.HandleFunc("GET /api/v1/scheduled-changes/{id}", api.GetScheduledChange)
+	v1Mux.HandleFunc("POST /api/v1/scheduled-changes/{id}/reschedule", api.RescheduleChange)
+	v1Mux.HandleFunc("DELETE /api/v1/scheduled-changes/{id}", api.CancelScheduledChange)
 
+	v1Mux.HandleFunc("GET /api/v1/users", api.ListUsers)
+	v1Mux.HandleFunc("POST /api/v1/users", api.CreateUser)
 	v1Mux.HandleFunc("GET /api/v1/users/me", api.GetLoggedInUser)
+	v1Mux.HandleFunc("GET /api/v1/users/{id}", api.GetUser)
+	v1Mux.HandleFunc("DELETE /api/v1/users/{id}", api.DeleteUser)
+	v1Mux.HandleFunc("POST /api/v1/users/{id}/disable", api.DisableUser)
+	v1Mux.HandleFunc("POST /api/v1/users/{id}/enable", api.EnableUser)
+	v1Mux.HandleFunc("PUT /api/v1/users/me/password", api.ChangePassword)
+	v1Mux.HandleFunc("POST /api/v1/users/{id}/password-reset", api.StartPasswordReset)
+	v1Mux.HandleFunc("DELETE /api/v1/users/{id}/mfa", api.ResetMFA)
+	v1Mux.HandleFunc("GET /api/v1/users/{id}/roles", api.GetUserRoles)
+	v1Mux.HandleFunc("PUT /api/v1/users/{id}/roles/{role}", api.AssignRole)
+	v1Mux.HandleFunc("DELETE /api/v1/users/{id}/roles/{role}", api.RemoveRole)
+
+	v1Mux.HandleFunc("GET /api/v1/roles", api.ListRoles)
+	v1Mux.HandleFunc("POST /api/v1/roles", api.CreateRole)
+	v1Mux.HandleFunc("DELETE /api/v1/roles/{name}", api.DeleteRole)
+	v1Mux.HandleFunc("PUT /api/v1/roles/{name}/permissions/{permission}", api.GrantPermission)
+	v1Mux.HandleFunc("DELETE /api/v1/roles/{name}/permissions/{permission}", api.RevokePermission)
+
+	v1Mux.HandleFunc("GET /api/v1/auth/lockouts", api.ListLoginLockouts)
+	v1Mux.HandleFunc("DELETE /api/v1/auth/lockouts/{kind}/{key}", api.UnlockLogin)
 	v1Mux.HandleFunc("POST /api/v1/auth/api-tokens", api.IssueAPIToken)
 	v1Mux.HandleFunc("GET /api/v1/auth/api-tokens", api.ListAPITokens)
+	v1Mux.HandleFunc("DELETE /api/v1/auth/api-tokens/{id}", api.RevokeAPIToken)
+
+	v1Mux.HandleFunc("GET /api/v1/audit", api.ListAuditLog)
 
 	apiMux := http.NewServeMux()
 	apiMux.HandleFunc("DELETE /api/v1/auth/logout", api.Logout)
+	apiMux.HandleFunc("POST /api/v1/auth/refresh", api.Refresh)
 	apiMux.HandleFunc("POST /api/v1/auth/login", api.Login)
 	apiMux.HandleFunc("POST /api/v1/auth/register", api.Register)
-	apiMux.Handle("/api/v1/", middleware.AuthenticationRequired(log, userService, tokenSigningKey, v1Mux))
+	apiMux.HandleFunc("POST /api/v1/auth/password-reset", api.ResetPassword)
+	apiMux.HandleFunc("POST /api/v1/auth/mfa/enrol", api.EnrolMFA)
+	apiMux.HandleFunc("POST /api/v1/auth/mfa/confirm", api.ConfirmMFA)
+	apiMux.HandleFunc("GET /api/v1/auth/oidc/login", api.StartRedirectLogin)
+	apiMux.HandleFunc("GET /api/v1/auth/oidc/callback", api.FinishRedirectLogin)
+	apiMux.Handle("/api/v1/", middleware.AuthenticationRequired(log, userService, keys, v1Mux))
 
 	return api, apiMux
 }
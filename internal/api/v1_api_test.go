@@ -6,14 +6,18 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/config-source/cdb/internal/middleware"
 	"github.com/config-source/cdb/pkg/auth"
 	"github.com/config-source/cdb/pkg/configkeys"
 	"github.com/config-source/cdb/pkg/configvalues"
 	"github.com/config-source/cdb/pkg/environments"
+	"github.com/config-source/cdb/pkg/ephemeral"
 	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/config-source/cdb/pkg/scheduledchanges"
 	"github.com/config-source/cdb/pkg/services"
+	"github.com/config-source/cdb/pkg/trash"
 	"github.com/rs/zerolog"
 )
 
@@ -35,7 +39,7 @@ func testAPI(
 	t.Helper()
 
 	gateway := auth.NewTestGateway()
-	tokenSigningKey := []byte("test key")
+	tokenSigningKey := auth.NewTestKeySet("test key")
 
 	pool := postgresutils.InitTestDB(t)
 	repoLogger := zerolog.New(nil).Level(zerolog.Disabled)
@@ -43,16 +47,27 @@ func testAPI(
 	svcRepo := services.NewRepository(repoLogger, pool)
 	envRepo := environments.NewRepository(repoLogger, pool)
 	keyRepo := configkeys.NewRepository(repoLogger, pool)
-	valueRepo := configvalues.NewRepository(repoLogger, pool, envRepo)
+	valueRepo := configvalues.NewRepository(repoLogger, pool, envRepo, keyRepo)
+	valueService := configvalues.NewService(repoLogger, valueRepo, envRepo, keyRepo, gateway, true, nil)
 
 	api, mux := NewV1(
 		zerolog.New(nil).Level(zerolog.Disabled),
 		tokenSigningKey,
-		auth.NewTestServiceWithGateway(gateway),
-		configvalues.NewService(valueRepo, envRepo, keyRepo, gateway, true),
-		environments.NewService(envRepo, gateway),
-		configkeys.NewService(keyRepo, gateway),
-		services.NewServiceService(svcRepo, gateway),
+		auth.NewUserService(gateway, gateway, auth.NewTokenRegistry(repoLogger, pool), nil, nil, true, "Operator", auth.PasswordPolicy{}, &auth.TestNotifier{}, nil),
+		valueService,
+		environments.NewService(envRepo, gateway, nil),
+		configkeys.NewService(keyRepo, gateway, nil),
+		services.NewServiceService(svcRepo, gateway, nil),
+		scheduledchanges.NewService(
+			scheduledchanges.NewRepository(repoLogger, pool),
+			envRepo,
+			valueService,
+			gateway,
+			gateway,
+			nil,
+		),
+		ephemeral.NewService(envRepo, valueService, gateway, time.Hour, nil),
+		trash.NewService(envRepo, keyRepo, svcRepo, gateway, time.Hour, nil),
 	)
 
 	tc := TestContext{
@@ -67,14 +82,16 @@ func testAPI(
 	if alwaysAuthd {
 		return tc, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			user := auth.User{}
-			idToken, err := auth.GenerateIdToken(tokenSigningKey, user)
+			// Tokens without a session aren't checked against the
+			// registry.
+			tokens, err := auth.GenerateTokens(tokenSigningKey, user, auth.Session{})
 			if err != nil {
 				panic(err)
 			}
 
 			r.Header.Set(
 				"Authorization",
-				fmt.Sprintf("%s%s", middleware.AuthorizationHeaderPrefix, idToken),
+				fmt.Sprintf("%s%s", middleware.AuthorizationHeaderPrefix, tokens.IDToken),
 			)
 
 			mux.ServeHTTP(w, r)
@@ -95,6 +112,24 @@ func TestProtectedRoutesAreProtected(t *testing.T) {
 		{endpoint: "/api/v1/environments/tree", method: "GET"},
 		{endpoint: "/api/v1/environments", method: "GET"},
 		{endpoint: "/api/v1/environments", method: "POST"},
+		{endpoint: "/api/v1/environments/1/clone", method: "POST"},
+		{endpoint: "/api/v1/environments/1/acl", method: "GET"},
+		{endpoint: "/api/v1/environments/1/acl", method: "POST"},
+		{endpoint: "/api/v1/environments/1/acl/1", method: "DELETE"},
+		{endpoint: "/api/v1/services/1", method: "DELETE"},
+		{endpoint: "/api/v1/services/1/roles", method: "GET"},
+		{endpoint: "/api/v1/services/1/roles/1", method: "PUT"},
+		{endpoint: "/api/v1/services/1/roles/1", method: "DELETE"},
+		{endpoint: "/api/v1/config-keys/1", method: "DELETE"},
+		{endpoint: "/api/v1/trash", method: "GET"},
+		{endpoint: "/api/v1/trash/environments/1/restore", method: "POST"},
+		{endpoint: "/api/v1/environments/ephemeral", method: "POST"},
+		{endpoint: "/api/v1/environments/ephemeral/1/extend", method: "POST"},
+		{endpoint: "/api/v1/environments/ephemeral/1", method: "DELETE"},
+
+		{endpoint: "/api/v1/overlays", method: "GET"},
+		{endpoint: "/api/v1/overlays", method: "POST"},
+		{endpoint: "/api/v1/overlays/1", method: "DELETE"},
 
 		{endpoint: "/api/v1/config-keys", method: "POST"},
 		{endpoint: "/api/v1/config-keys", method: "GET"},
@@ -105,6 +140,39 @@ func TestProtectedRoutesAreProtected(t *testing.T) {
 		{endpoint: "/api/v1/config-values/test/testKey", method: "GET"},
 		{endpoint: "/api/v1/config-values/test/testKey", method: "POST"},
 		{endpoint: "/api/v1/config-values/test", method: "GET"},
+		{endpoint: "/api/v1/evaluate/1", method: "POST"},
+		{endpoint: "/api/v1/export", method: "GET"},
+
+		{endpoint: "/api/v1/scheduled-changes", method: "GET"},
+		{endpoint: "/api/v1/scheduled-changes", method: "POST"},
+		{endpoint: "/api/v1/scheduled-changes/1", method: "GET"},
+		{endpoint: "/api/v1/scheduled-changes/1", method: "DELETE"},
+		{endpoint: "/api/v1/scheduled-changes/1/reschedule", method: "POST"},
+
+		{endpoint: "/api/v1/auth/api-tokens", method: "GET"},
+		{endpoint: "/api/v1/auth/api-tokens", method: "POST"},
+		{endpoint: "/api/v1/auth/api-tokens/1", method: "DELETE"},
+
+		{endpoint: "/api/v1/users", method: "GET"},
+		{endpoint: "/api/v1/users", method: "POST"},
+		{endpoint: "/api/v1/users/1", method: "GET"},
+		{endpoint: "/api/v1/users/1", method: "DELETE"},
+		{endpoint: "/api/v1/users/1/disable", method: "POST"},
+		{endpoint: "/api/v1/users/1/enable", method: "POST"},
+		{endpoint: "/api/v1/users/me/password", method: "PUT"},
+		{endpoint: "/api/v1/users/1/password-reset", method: "POST"},
+		{endpoint: "/api/v1/users/1/mfa", method: "DELETE"},
+		{endpoint: "/api/v1/users/1/roles", method: "GET"},
+		{endpoint: "/api/v1/users/1/roles/Operator", method: "PUT"},
+		{endpoint: "/api/v1/users/1/roles/Operator", method: "DELETE"},
+		{endpoint: "/api/v1/roles", method: "GET"},
+		{endpoint: "/api/v1/roles", method: "POST"},
+		{endpoint: "/api/v1/roles/Operator", method: "DELETE"},
+		{endpoint: "/api/v1/roles/Operator/permissions/CAN_MANAGE_USERS", method: "PUT"},
+		{endpoint: "/api/v1/roles/Operator/permissions/CAN_MANAGE_USERS", method: "DELETE"},
+		{endpoint: "/api/v1/auth/lockouts", method: "GET"},
+		{endpoint: "/api/v1/auth/lockouts/account/test@example.com", method: "DELETE"},
+		{endpoint: "/api/v1/audit", method: "GET"},
 	}
 
 	for _, route := range protectedRoutes {
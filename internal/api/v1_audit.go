@@ -0,0 +1,98 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/config-source/cdb/internal/middleware"
+	"github.com/config-source/cdb/pkg/audit"
+)
+
+// parseAuditFilter builds a filter from the query parameters, parameters which
+// aren't given don't filter the entries.
+func parseAuditFilter(query url.Values) (audit.Filter, error) {
+	var filter audit.Filter
+
+	optional := func(name string) *string {
+		if !query.Has(name) {
+			return nil
+		}
+
+		value := query.Get(name)
+		return &value
+	}
+
+	filter.ActorEmail = optional("actorEmail")
+	filter.Action = optional("action")
+	filter.TargetKind = optional("targetKind")
+	filter.TargetID = optional("targetID")
+
+	if raw := query.Get("actorID"); raw != "" {
+		actorID, err := strconv.Atoi(raw)
+		if err != nil {
+			return audit.Filter{}, fmt.Errorf("actorID must be an integer: %w", err)
+		}
+
+		filter.ActorID = &actorID
+	}
+
+	for name, dest := range map[string]**time.Time{"since": &filter.Since, "until": &filter.Until} {
+		raw := query.Get(name)
+		if raw == "" {
+			continue
+		}
+
+		parsed, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return audit.Filter{}, fmt.Errorf("%s must be an RFC 3339 timestamp: %w", name, err)
+		}
+
+		*dest = &parsed
+	}
+
+	if raw := query.Get("beforeID"); raw != "" {
+		beforeID, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil {
+			return audit.Filter{}, fmt.Errorf("beforeID must be an integer: %w", err)
+		}
+
+		filter.BeforeID = &beforeID
+	}
+
+	if raw := query.Get("limit"); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil {
+			return audit.Filter{}, fmt.Errorf("limit must be an integer: %w", err)
+		}
+
+		filter.Limit = limit
+	}
+
+	return filter, nil
+}
+
+func (a *V1) ListAuditLog(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	filter, err := parseAuditFilter(r.URL.Query())
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	entries, err := a.userService.ListAuditLog(r.Context(), actor, filter)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, entries)
+}
@@ -1,9 +1,15 @@
 package api
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"strconv"
+	"time"
 
 	"github.com/config-source/cdb/internal/middleware"
 	"github.com/config-source/cdb/pkg/auth"
@@ -12,15 +18,32 @@ import (
 type Credentials struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
+	// MFACode is a TOTP or recovery code, required for users enrolled in MFA.
+	MFACode string `json:"mfaCode,omitempty"`
 }
 
-func (a *V1) doLogin(w http.ResponseWriter, r *http.Request, user auth.User) {
-	tokens, err := auth.GenerateTokens(a.tokenSigningKey, user)
+// MFARecoveryCodes are returned once when enrolment in MFA is confirmed.
+type MFARecoveryCodes struct {
+	RecoveryCodes []string
+}
+
+const (
+	loginStateCookieName   = "cdb-login-state"
+	loginNonceCookieName   = "cdb-login-nonce"
+	codeVerifierCookieName = "cdb-code-verifier"
+)
+
+func (a *V1) setAuthCookies(w http.ResponseWriter, r *http.Request, user auth.User) (auth.TokenSet, error) {
+	tokens, err := a.userService.IssueTokens(r.Context(), a.keys, user)
 	if err != nil {
-		a.sendErr(w, r, err)
-		return
+		return tokens, err
 	}
 
+	writeAuthCookies(w, r, tokens)
+	return tokens, nil
+}
+
+func writeAuthCookies(w http.ResponseWriter, r *http.Request, tokens auth.TokenSet) {
 	authCookies := map[string]string{
 		middleware.IDTokenCookieName:      tokens.IDToken,
 		middleware.AccessTokenCookieName:  tokens.AccessToken,
@@ -40,6 +63,14 @@ func (a *V1) doLogin(w http.ResponseWriter, r *http.Request, user auth.User) {
 			},
 		)
 	}
+}
+
+func (a *V1) doLogin(w http.ResponseWriter, r *http.Request, user auth.User) {
+	tokens, err := a.setAuthCookies(w, r, user)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
 
 	a.sendJson(w, tokens)
 }
@@ -56,7 +87,13 @@ func (a *V1) Login(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	user, err := a.userService.Login(r.Context(), creds.Email, creds.Password)
+	user, err := a.userService.Login(
+		r.Context(),
+		creds.Email,
+		creds.Password,
+		creds.MFACode,
+		middleware.ClientIP(r),
+	)
 	if err != nil {
 		a.sendErr(w, r, err)
 		return
@@ -65,6 +102,141 @@ func (a *V1) Login(w http.ResponseWriter, r *http.Request) {
 	a.doLogin(w, r, user)
 }
 
+// EnrolMFA returns a new TOTP secret for the user to add to their
+// authenticator app. Like Login it takes credentials so that users who must
+// enrol before they can login are able to.
+func (a *V1) EnrolMFA(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var creds Credentials
+	err := decoder.Decode(&creds)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	enrolment, err := a.userService.EnrolMFA(r.Context(), creds.Email, creds.Password, middleware.ClientIP(r))
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, enrolment)
+}
+
+func (a *V1) ConfirmMFA(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var creds Credentials
+	err := decoder.Decode(&creds)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	codes, err := a.userService.ConfirmMFA(
+		r.Context(),
+		creds.Email,
+		creds.Password,
+		creds.MFACode,
+		middleware.ClientIP(r),
+	)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, MFARecoveryCodes{RecoveryCodes: codes})
+}
+
+// StartRedirectLogin sends the browser to the identity provider. The state,
+// nonce and PKCE code verifier are kept in short lived cookies for the
+// callback.
+func (a *V1) StartRedirectLogin(w http.ResponseWriter, r *http.Request) {
+	login, err := a.userService.StartRedirectLogin()
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	loginCookies := map[string]string{
+		loginStateCookieName:   login.State,
+		loginNonceCookieName:   login.Nonce,
+		codeVerifierCookieName: login.CodeVerifier,
+	}
+
+	for name, value := range loginCookies {
+		http.SetCookie(
+			w,
+			&http.Cookie{
+				Name:     name,
+				Value:    value,
+				HttpOnly: true,
+				Path:     "/api/v1/auth/oidc",
+				MaxAge:   int((10 * time.Minute).Seconds()),
+				// Lax so that the cookies are sent when the identity
+				// provider redirects back to us.
+				SameSite: http.SameSiteLaxMode,
+			},
+		)
+	}
+
+	http.Redirect(w, r, login.URL, http.StatusFound)
+}
+
+// FinishRedirectLogin handles the identity provider redirecting back to CDB
+// and logs the user in.
+func (a *V1) FinishRedirectLogin(w http.ResponseWriter, r *http.Request) {
+	stateCookie, stateErr := r.Cookie(loginStateCookieName)
+	nonceCookie, nonceErr := r.Cookie(loginNonceCookieName)
+	verifierCookie, verifierErr := r.Cookie(codeVerifierCookieName)
+	if stateErr != nil || nonceErr != nil || verifierErr != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, errors.New("login was not started or has expired"))
+		return
+	}
+
+	for _, name := range []string{loginStateCookieName, loginNonceCookieName, codeVerifierCookieName} {
+		http.SetCookie(w, &http.Cookie{Name: name, Path: "/api/v1/auth/oidc", MaxAge: -1})
+	}
+
+	query := r.URL.Query()
+	if providerErr := query.Get("error"); providerErr != "" {
+		w.WriteHeader(http.StatusUnauthorized)
+		a.sendErr(w, r, fmt.Errorf("identity provider returned an error: %s", providerErr))
+		return
+	}
+
+	state := query.Get("state")
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(stateCookie.Value)) != 1 {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, errors.New("login state does not match"))
+		return
+	}
+
+	user, err := a.userService.FinishRedirectLogin(
+		r.Context(),
+		query.Get("code"),
+		verifierCookie.Value,
+		nonceCookie.Value,
+	)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	if _, err := a.setAuthCookies(w, r, user); err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusFound)
+}
+
 func (a *V1) Register(w http.ResponseWriter, r *http.Request) {
 	decoder := json.NewDecoder(r.Body)
 	decoder.DisallowUnknownFields()
@@ -86,27 +258,57 @@ func (a *V1) Register(w http.ResponseWriter, r *http.Request) {
 	a.doLogin(w, r, user)
 }
 
-func (a *V1) Logout(w http.ResponseWriter, r *http.Request) {
-	authCookies := []string{
-		middleware.IDTokenCookieName,
-		middleware.AccessTokenCookieName,
-		middleware.RefreshTokenCookieName,
-	}
-
+// refreshTokenFromRequest returns the refresh token from the X-Refresh-Token
+// header or failing that the session cookie.
+func refreshTokenFromRequest(r *http.Request) (string, error) {
 	refreshToken := r.Header.Get("X-Refresh-Token")
 	if refreshToken == "" {
 		cookie, err := r.Cookie(middleware.RefreshTokenCookieName)
 		if err != nil && !errors.Is(err, http.ErrNoCookie) {
-			a.sendErr(w, r, err)
-			return
+			return "", err
 		} else if err == nil {
 			refreshToken = cookie.Value
 		}
 	}
 
 	if refreshToken == "" {
+		return "", errors.New("no refresh token supplied")
+	}
+
+	return refreshToken, nil
+}
+
+// Refresh exchanges a refresh token for a new set of tokens. The refresh token
+// can't be used again afterwards.
+func (a *V1) Refresh(w http.ResponseWriter, r *http.Request) {
+	refreshToken, err := refreshTokenFromRequest(r)
+	if err != nil {
 		w.WriteHeader(http.StatusBadRequest)
-		a.sendErr(w, r, errors.New("no refresh token supplied"))
+		a.sendErr(w, r, err)
+		return
+	}
+
+	tokens, err := a.userService.RefreshTokens(r.Context(), a.keys, refreshToken)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	writeAuthCookies(w, r, tokens)
+	a.sendJson(w, tokens)
+}
+
+func (a *V1) Logout(w http.ResponseWriter, r *http.Request) {
+	authCookies := []string{
+		middleware.IDTokenCookieName,
+		middleware.AccessTokenCookieName,
+		middleware.RefreshTokenCookieName,
+	}
+
+	refreshToken, err := refreshTokenFromRequest(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
 		return
 	}
 
@@ -125,7 +327,7 @@ func (a *V1) Logout(w http.ResponseWriter, r *http.Request) {
 		)
 	}
 
-	err := a.userService.Logout(r.Context(), refreshToken)
+	err = a.userService.Logout(r.Context(), a.keys, refreshToken)
 	if err != nil {
 		a.sendErr(w, r, err)
 	}
@@ -148,15 +350,62 @@ func (a *V1) IssueAPIToken(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	token, err := a.userService.IssueAPIToken(r.Context(), a.tokenSigningKey, user)
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	// The body is optional so older clients which don't send one get an
+	// unnamed token which never expires.
+	var req auth.APITokenRequest
+	err = decoder.Decode(&req)
+	if err != nil && !errors.Is(err, io.EOF) {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	scopes, err := a.resolveScopes(r.Context(), user, req.Scopes)
 	if err != nil {
 		a.sendErr(w, r, err)
 		return
 	}
 
+	token, err := a.userService.IssueAPIToken(r.Context(), a.keys, user, req.Name, req.ExpiresAt, scopes)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
 	a.sendJson(w, token)
 }
 
+// resolveScopes looks up the services and environments named in specs. They
+// are looked up as the actor so a token can't be scoped to anything its
+// creator can't see.
+func (a *V1) resolveScopes(ctx context.Context, actor auth.User, specs []auth.ScopeSpec) (auth.Scopes, error) {
+	scopes := make(auth.Scopes, 0, len(specs))
+	for _, spec := range specs {
+		svc, err := a.svcService.GetServiceByName(ctx, actor, spec.Service)
+		if err != nil {
+			return nil, err
+		}
+
+		scope := auth.Scope{ServiceID: svc.ID, Access: spec.Access}
+		if !spec.AllEnvironments() {
+			env, err := a.envService.GetEnvironmentByName(ctx, actor, spec.Service, spec.Environment)
+			if err != nil {
+				return nil, err
+			}
+
+			scope.EnvironmentID = &env.ID
+		}
+
+		scopes = append(scopes, scope)
+	}
+
+	return scopes, nil
+}
+
 func (a *V1) ListAPITokens(w http.ResponseWriter, r *http.Request) {
 	user, err := middleware.GetUser(r)
 	if err != nil {
@@ -172,3 +421,26 @@ func (a *V1) ListAPITokens(w http.ResponseWriter, r *http.Request) {
 
 	a.sendJson(w, tokens)
 }
+
+func (a *V1) RevokeAPIToken(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.userService.RevokeAPIToken(r.Context(), user, id)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
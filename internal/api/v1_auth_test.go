@@ -167,8 +167,13 @@ func TestRegisterWhenPublicRegistrationDisabled(t *testing.T) {
 		tc.gateway,
 		tc.gateway,
 		&auth.TokenRegistry{},
+		nil,
+		nil,
 		false,
 		"",
+		auth.PasswordPolicy{},
+		&auth.TestNotifier{},
+		nil,
 	)
 
 	creds := Credentials{
@@ -191,3 +196,112 @@ func TestRegisterWhenPublicRegistrationDisabled(t *testing.T) {
 		t.Fatalf("Expected status code 400 got: %d %s", rr.Code, rr.Body.String())
 	}
 }
+
+func loginForTokens(t *testing.T, tc TestContext, mux http.Handler) auth.TokenSet {
+	t.Helper()
+
+	_, err := tc.gateway.CreateUser(context.Background(), auth.User{
+		Email:    "test@example.com",
+		Password: "Testing123!@",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	marshalled, err := json.Marshal(Credentials{Email: "test@example.com", Password: "Testing123!@"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/auth/login", bytes.NewBuffer(marshalled))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != 200 {
+		t.Fatalf("Expected status code 200 got: %d %s", rr.Code, rr.Body.String())
+	}
+
+	var tokens auth.TokenSet
+	if err := json.NewDecoder(rr.Body).Decode(&tokens); err != nil {
+		t.Fatal(err)
+	}
+
+	return tokens
+}
+
+func refresh(mux http.Handler, refreshToken string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/api/v1/auth/refresh", nil)
+	req.Header.Set("X-Refresh-Token", refreshToken)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr
+}
+
+func getMe(mux http.Handler, idToken string) int {
+	req := httptest.NewRequest("GET", "/api/v1/users/me", nil)
+	req.Header.Set("Authorization", middleware.AuthorizationHeaderPrefix+idToken)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	return rr.Code
+}
+
+func TestRefreshRotatesRefreshTokens(t *testing.T) {
+	tc, mux := testAPI(t, false)
+	tokens := loginForTokens(t, tc, mux)
+
+	rr := refresh(mux, tokens.RefreshToken)
+	if rr.Code != 200 {
+		t.Fatalf("Expected status code 200 got: %d %s", rr.Code, rr.Body.String())
+	}
+
+	var refreshed auth.TokenSet
+	if err := json.NewDecoder(rr.Body).Decode(&refreshed); err != nil {
+		t.Fatal(err)
+	}
+
+	if refreshed.RefreshToken == tokens.RefreshToken {
+		t.Fatal("Expected a new refresh token to be issued")
+	}
+
+	if getCookieValue(rr.Result().Cookies(), middleware.RefreshTokenCookieName) != refreshed.RefreshToken {
+		t.Error("Expected the refresh token cookie to be updated")
+	}
+
+	if code := getMe(mux, refreshed.IDToken); code != 200 {
+		t.Fatalf("Expected the refreshed ID token to be accepted got: %d", code)
+	}
+
+	// Reusing the old refresh token means it has leaked so the session,
+	// including the tokens issued for it since, is revoked.
+	if rr := refresh(mux, tokens.RefreshToken); rr.Code != 401 {
+		t.Errorf("Expected a reused refresh token to be rejected got: %d %s", rr.Code, rr.Body.String())
+	}
+
+	if rr := refresh(mux, refreshed.RefreshToken); rr.Code != 401 {
+		t.Errorf("Expected the session to be revoked got: %d %s", rr.Code, rr.Body.String())
+	}
+
+	if code := getMe(mux, refreshed.IDToken); code != 401 {
+		t.Errorf("Expected ID tokens of the revoked session to be rejected got: %d", code)
+	}
+}
+
+func TestLogoutRevokesSession(t *testing.T) {
+	tc, mux := testAPI(t, false)
+	tokens := loginForTokens(t, tc, mux)
+
+	req := httptest.NewRequest("DELETE", "/api/v1/auth/logout", nil)
+	req.Header.Set("X-Refresh-Token", tokens.RefreshToken)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+	if rr.Code != 200 {
+		t.Fatalf("Expected status code 200 got: %d %s", rr.Code, rr.Body.String())
+	}
+
+	if code := getMe(mux, tokens.IDToken); code != 401 {
+		t.Errorf("Expected the ID token to be rejected after logging out got: %d", code)
+	}
+
+	if rr := refresh(mux, tokens.RefreshToken); rr.Code != 401 {
+		t.Errorf("Expected the refresh token to be rejected after logging out got: %d %s", rr.Code, rr.Body.String())
+	}
+}
@@ -105,3 +105,26 @@ func (a *V1) CreateConfigKey(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 	a.sendJson(w, configKey)
 }
+
+func (a *V1) DeleteConfigKey(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.configKeyService.DeleteConfigKey(r.Context(), user, id)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
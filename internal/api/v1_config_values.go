@@ -8,8 +8,32 @@ import (
 
 	"github.com/config-source/cdb/internal/middleware"
 	"github.com/config-source/cdb/pkg/configvalues"
+	"github.com/config-source/cdb/pkg/environments"
 )
 
+// overlayIDFromQuery returns the ID of the overlay selected by the overlay
+// query parameter for writes, or nil if none was given.
+func (a *V1) overlayIDFromQuery(r *http.Request, environmentID int) (*int, error) {
+	selector := r.URL.Query().Get("overlay")
+	if selector == "" {
+		return nil, nil
+	}
+
+	overlays, err := a.configValueService.ResolveOverlays(r.Context(), environmentID, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	return &overlays[0].ID, nil
+}
+
+// revealFromQuery reports whether the values of sensitive keys should be
+// revealed rather than redacted.
+func revealFromQuery(r *http.Request) bool {
+	reveal, _ := strconv.ParseBool(r.URL.Query().Get("reveal"))
+	return reveal
+}
+
 func (a *V1) GetConfigurationValue(w http.ResponseWriter, r *http.Request) {
 	user, err := middleware.GetUser(r)
 	if err != nil {
@@ -31,7 +55,14 @@ func (a *V1) GetConfigurationValue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cv, err := a.configValueService.GetConfigurationValue(r.Context(), user, environmentID, configKey)
+	cv, err := a.configValueService.GetConfigurationValue(
+		r.Context(),
+		user,
+		environmentID,
+		configKey,
+		revealFromQuery(r),
+		r.URL.Query()["overlay"]...,
+	)
 	if err != nil {
 		a.sendErr(w, r, err)
 		return
@@ -72,6 +103,13 @@ func (a *V1) SetConfigurationValue(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	overlayID, err := a.overlayIDFromQuery(r, environmentID)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	newConfigValue.OverlayID = overlayID
 	cv, err := a.configValueService.SetConfigurationValue(
 		r.Context(),
 		user,
@@ -112,6 +150,16 @@ func (a *V1) SetConfigurationValues(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	overlayID, err := a.overlayIDFromQuery(r, environmentID)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	for _, cv := range newConfigValues {
+		cv.OverlayID = overlayID
+	}
+
 	cv, err := a.configValueService.SetConfigurationValues(
 		r.Context(),
 		user,
@@ -168,7 +216,13 @@ func (a *V1) GetConfiguration(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cv, err := a.configValueService.GetConfiguration(r.Context(), user, environmentID)
+	cv, err := a.configValueService.GetConfiguration(
+		r.Context(),
+		user,
+		environmentID,
+		revealFromQuery(r),
+		r.URL.Query()["overlay"]...,
+	)
 	if err != nil {
 		a.sendErr(w, r, err)
 		return
@@ -176,3 +230,63 @@ func (a *V1) GetConfiguration(w http.ResponseWriter, r *http.Request) {
 
 	a.sendJson(w, cv)
 }
+
+func (a *V1) EvaluateConfiguration(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	environmentID, err := strconv.Atoi(r.PathValue("environment"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	var evalCtx configvalues.EvaluationContext
+	err = json.NewDecoder(r.Body).Decode(&evalCtx)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	cv, err := a.configValueService.EvaluateConfiguration(
+		r.Context(),
+		user,
+		environmentID,
+		evalCtx,
+		revealFromQuery(r),
+		r.URL.Query()["overlay"]...,
+	)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, cv)
+}
+
+func (a *V1) ExportConfiguration(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	selector, err := environments.ParseLabelSelector(r.URL.Query().Get("selector"))
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	exported, err := a.configValueService.ExportConfiguration(r.Context(), user, selector, revealFromQuery(r))
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, exported)
+}
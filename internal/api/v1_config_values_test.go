@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"fmt"
 	"net/http/httptest"
 	"testing"
 
@@ -326,3 +327,64 @@ func TestSetConfigurationByKey(t *testing.T) {
 		t.Fatalf("Expected 10 got: %v", maxReplicas)
 	}
 }
+
+func TestEvaluateConfiguration(t *testing.T) {
+	tc, mux := testAPI(t, true)
+
+	svc, err := tc.serviceRepo.CreateService(context.Background(), services.Service{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	production, err := tc.environmentRepo.CreateEnvironment(context.Background(), environments.Environment{Name: "production", ServiceID: svc.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := createKeys(t, tc, []configkeys.ConfigKey{
+		{
+			Name:      "banner",
+			ValueType: configkeys.TypeString,
+			ServiceID: svc.ID,
+		},
+	})
+
+	eu := "eu-banner"
+	cv := configvalues.NewString(production.ID, keys[0].ID, "banner")
+	cv.TargetingRules = []configvalues.TargetingRule{
+		{
+			Conditions: []configvalues.Condition{
+				{Attribute: "region", Operator: configvalues.OperatorEquals, Values: []string{"eu"}},
+			},
+			StrValue: &eu,
+		},
+	}
+	_, err = tc.valueRepo.CreateConfigValue(context.Background(), cv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body := bytes.NewBufferString(`{"region": "eu"}`)
+	req := httptest.NewRequest("POST", fmt.Sprintf("/api/v1/evaluate/%d", production.ID), body)
+	rr := httptest.NewRecorder()
+	rr.Body = bytes.NewBuffer([]byte{})
+
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != 200 {
+		t.Fatalf("Expected status code 200 got: %d %s", rr.Code, rr.Body.String())
+	}
+
+	var values []configvalues.ConfigValue
+	if err := json.NewDecoder(rr.Body).Decode(&values); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(values) != 1 {
+		t.Fatalf("Expected 1 config value got: %v", values)
+	}
+
+	if values[0].Value().(string) != eu {
+		t.Fatalf("Expected %q got: %v", eu, values[0])
+	}
+}
@@ -7,9 +7,17 @@ import (
 	"strconv"
 
 	"github.com/config-source/cdb/internal/middleware"
+	"github.com/config-source/cdb/pkg/configvalues"
 	"github.com/config-source/cdb/pkg/environments"
 )
 
+// isPreview reports whether the request asked to see the effect of a change
+// with ?preview=true instead of making it.
+func isPreview(r *http.Request) bool {
+	preview, _ := strconv.ParseBool(r.URL.Query().Get("preview"))
+	return preview
+}
+
 func (a *V1) GetEnvironmentByName(w http.ResponseWriter, r *http.Request) {
 	user, err := middleware.GetUser(r)
 	if err != nil {
@@ -91,6 +99,41 @@ func (a *V1) CreateEnvironment(w http.ResponseWriter, r *http.Request) {
 	a.sendJson(w, env)
 }
 
+func (a *V1) CloneEnvironment(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var req configvalues.CloneRequest
+	err = decoder.Decode(&req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	env, err := a.configValueService.CloneEnvironment(r.Context(), user, id, req)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	a.sendJson(w, env)
+}
+
 func (a *V1) GetEnvironmentTree(w http.ResponseWriter, r *http.Request) {
 	user, err := middleware.GetUser(r)
 	if err != nil {
@@ -114,7 +157,13 @@ func (a *V1) ListEnvironments(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	environs, err := a.envService.ListEnvironments(r.Context(), user)
+	selector, err := environments.ParseLabelSelector(r.URL.Query().Get("selector"))
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	environs, err := a.envService.ListEnvironments(r.Context(), user, selector)
 	if err != nil {
 		a.sendErr(w, r, err)
 		return
@@ -154,6 +203,17 @@ func (a *V1) UpdateEnvironment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isPreview(r) {
+		preview, err := a.configValueService.PreviewEnvironmentUpdate(r.Context(), user, env)
+		if err != nil {
+			a.sendErr(w, r, err)
+			return
+		}
+
+		a.sendJson(w, preview)
+		return
+	}
+
 	updated, err := a.envService.UpdateEnvironment(r.Context(), user, env)
 	if err != nil {
 		a.sendErr(w, r, err)
@@ -177,6 +237,17 @@ func (a *V1) DeleteEnvironment(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if isPreview(r) {
+		preview, err := a.configValueService.PreviewEnvironmentDelete(r.Context(), user, id)
+		if err != nil {
+			a.sendErr(w, r, err)
+			return
+		}
+
+		a.sendJson(w, preview)
+		return
+	}
+
 	err = a.envService.DeleteEnvironment(r.Context(), user, id)
 	if err != nil {
 		a.sendErr(w, r, err)
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/config-source/cdb/internal/middleware"
+	"github.com/config-source/cdb/pkg/environments"
+)
+
+func (a *V1) CreateOverlay(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var overlay environments.Overlay
+	err = decoder.Decode(&overlay)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	overlay, err = a.envService.CreateOverlay(r.Context(), user, overlay)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	a.sendJson(w, overlay)
+}
+
+func (a *V1) ListOverlays(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	var serviceID *int
+	if svc := r.URL.Query().Get("service"); svc != "" {
+		id, err := strconv.Atoi(svc)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			a.sendErr(w, r, err)
+			return
+		}
+
+		serviceID = &id
+	}
+
+	overlays, err := a.envService.ListOverlays(r.Context(), user, serviceID)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, overlays)
+}
+
+func (a *V1) DeleteOverlay(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.envService.DeleteOverlay(r.Context(), user, id)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
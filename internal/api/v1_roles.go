@@ -0,0 +1,181 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/config-source/cdb/internal/middleware"
+	"github.com/config-source/cdb/pkg/auth"
+)
+
+func (a *V1) ListRoles(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	roles, err := a.userService.ListRoles(r.Context(), actor)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, roles)
+}
+
+func (a *V1) CreateRole(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var role auth.Role
+	err = decoder.Decode(&role)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	created, err := a.userService.CreateRole(r.Context(), actor, role)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	a.sendJson(w, created)
+}
+
+func (a *V1) DeleteRole(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.userService.DeleteRole(r.Context(), actor, r.PathValue("name"))
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
+
+func (a *V1) GrantPermission(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.userService.GrantPermissions(
+		r.Context(),
+		actor,
+		r.PathValue("name"),
+		[]auth.Permission{auth.Permission(r.PathValue("permission"))},
+	)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
+
+func (a *V1) RevokePermission(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.userService.RevokePermissions(
+		r.Context(),
+		actor,
+		r.PathValue("name"),
+		[]auth.Permission{auth.Permission(r.PathValue("permission"))},
+	)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
+
+func (a *V1) GetUserRoles(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	roles, err := a.userService.GetUserRoles(r.Context(), actor, auth.UserID(id))
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, roles)
+}
+
+func (a *V1) AssignRole(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.userService.AssignRole(r.Context(), actor, auth.UserID(id), r.PathValue("role"))
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
+
+func (a *V1) RemoveRole(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.userService.RemoveRole(r.Context(), actor, auth.UserID(id), r.PathValue("role"))
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
@@ -0,0 +1,158 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/config-source/cdb/internal/middleware"
+	"github.com/config-source/cdb/pkg/scheduledchanges"
+)
+
+type RescheduleRequest struct {
+	ApplyAt time.Time
+}
+
+func (a *V1) ScheduleChange(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var change scheduledchanges.ScheduledChange
+	err = decoder.Decode(&change)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	change, err = a.scheduledChangeService.ScheduleChange(r.Context(), user, change)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	a.sendJson(w, change)
+}
+
+func (a *V1) GetScheduledChange(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	change, err := a.scheduledChangeService.GetScheduledChange(r.Context(), user, id)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, change)
+}
+
+func (a *V1) ListScheduledChanges(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	var environmentID *int
+	if env := r.URL.Query().Get("environment"); env != "" {
+		id, err := strconv.Atoi(env)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			a.sendErr(w, r, err)
+			return
+		}
+
+		environmentID = &id
+	}
+
+	var status *scheduledchanges.Status
+	if s := r.URL.Query().Get("status"); s != "" {
+		parsed := scheduledchanges.Status(strings.ToUpper(s))
+		status = &parsed
+	}
+
+	changes, err := a.scheduledChangeService.ListScheduledChanges(r.Context(), user, environmentID, status)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, changes)
+}
+
+func (a *V1) CancelScheduledChange(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	change, err := a.scheduledChangeService.CancelScheduledChange(r.Context(), user, id)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, change)
+}
+
+func (a *V1) RescheduleChange(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var req RescheduleRequest
+	err = decoder.Decode(&req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	change, err := a.scheduledChangeService.RescheduleChange(r.Context(), user, id, req.ApplyAt)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, change)
+}
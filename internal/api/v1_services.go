@@ -7,6 +7,7 @@ import (
 	"strconv"
 
 	"github.com/config-source/cdb/internal/middleware"
+	"github.com/config-source/cdb/pkg/auth"
 	"github.com/config-source/cdb/pkg/services"
 )
 
@@ -99,3 +100,126 @@ func (a *V1) ListServices(w http.ResponseWriter, r *http.Request) {
 
 	a.sendJson(w, svcirons)
 }
+
+func (a *V1) DeleteService(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.svcService.DeleteService(r.Context(), user, id)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
+
+type AssignServiceRoleRequest struct {
+	Role services.RoleName
+}
+
+// serviceAndUserIDs parses the {id} and {userID} path values used by the
+// service role endpoints.
+func serviceAndUserIDs(r *http.Request) (int, auth.UserID, error) {
+	serviceID, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	userID, err := strconv.Atoi(r.PathValue("userID"))
+	if err != nil {
+		return 0, 0, err
+	}
+
+	return serviceID, auth.UserID(userID), nil
+}
+
+func (a *V1) ListServiceRoles(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	roles, err := a.svcService.ListRoles(r.Context(), user, id)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, roles)
+}
+
+func (a *V1) AssignServiceRole(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	serviceID, userID, err := serviceAndUserIDs(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var req AssignServiceRoleRequest
+	err = decoder.Decode(&req)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.svcService.AssignRole(r.Context(), user, serviceID, userID, req.Role)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
+
+func (a *V1) RemoveServiceRole(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	serviceID, userID, err := serviceAndUserIDs(r)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.svcService.RemoveRole(r.Context(), user, serviceID, userID)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
@@ -0,0 +1,294 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/config-source/cdb/internal/middleware"
+	"github.com/config-source/cdb/pkg/auth"
+)
+
+// NewUser is the body of a request to create a user. Passwords are only ever
+// accepted, auth.User never serializes them.
+type NewUser struct {
+	Email    string
+	Password string
+}
+
+type PasswordChange struct {
+	CurrentPassword string
+	NewPassword     string
+}
+
+type PasswordReset struct {
+	Token       string
+	NewPassword string
+}
+
+func (a *V1) ListUsers(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	users, err := a.userService.ListUsers(r.Context(), actor)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, users)
+}
+
+func (a *V1) CreateUser(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var newUser NewUser
+	err = decoder.Decode(&newUser)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	user, err := a.userService.CreateUser(
+		r.Context(),
+		actor,
+		auth.User{Email: newUser.Email, Password: newUser.Password},
+	)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	a.sendJson(w, user)
+}
+
+func (a *V1) GetUser(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	user, err := a.userService.GetUser(r.Context(), actor, auth.UserID(id))
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, user)
+}
+
+func (a *V1) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.userService.DeleteUser(r.Context(), actor, auth.UserID(id))
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
+
+func (a *V1) DisableUser(w http.ResponseWriter, r *http.Request) {
+	a.setUserDisabled(w, r, a.userService.DisableUser)
+}
+
+func (a *V1) EnableUser(w http.ResponseWriter, r *http.Request) {
+	a.setUserDisabled(w, r, a.userService.EnableUser)
+}
+
+func (a *V1) setUserDisabled(
+	w http.ResponseWriter,
+	r *http.Request,
+	set func(ctx context.Context, actor auth.User, userID auth.UserID) (auth.User, error),
+) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	user, err := set(r.Context(), actor, auth.UserID(id))
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, user)
+}
+
+func (a *V1) ChangePassword(w http.ResponseWriter, r *http.Request) {
+	user, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var change PasswordChange
+	err = decoder.Decode(&change)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.userService.ChangePassword(r.Context(), user, change.CurrentPassword, change.NewPassword)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
+
+// StartPasswordReset sends the user a password reset token through the
+// configured notifier, it's never returned to the caller.
+func (a *V1) StartPasswordReset(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.userService.StartPasswordReset(r.Context(), actor, auth.UserID(id))
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
+
+// ResetMFA removes a user's MFA enrolment, for example when they've lost
+// their device, so that they can enrol again.
+func (a *V1) ResetMFA(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	id, err := strconv.Atoi(r.PathValue("id"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.userService.ResetMFA(r.Context(), actor, auth.UserID(id))
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
+
+func (a *V1) ResetPassword(w http.ResponseWriter, r *http.Request) {
+	decoder := json.NewDecoder(r.Body)
+	decoder.DisallowUnknownFields()
+
+	var reset PasswordReset
+	err := decoder.Decode(&reset)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.userService.ResetPassword(r.Context(), reset.Token, reset.NewPassword)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
+
+func (a *V1) ListLoginLockouts(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	lockouts, err := a.userService.ListLoginLockouts(r.Context(), actor)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, lockouts)
+}
+
+// UnlockLogin lifts the lockout of an account, keyed by email, or an IP
+// address.
+func (a *V1) UnlockLogin(w http.ResponseWriter, r *http.Request) {
+	actor, err := middleware.GetUser(r)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	err = a.userService.UnlockLogin(
+		r.Context(),
+		actor,
+		auth.LoginFailureKind(r.PathValue("kind")),
+		r.PathValue("key"),
+	)
+	if err != nil {
+		a.sendErr(w, r, err)
+		return
+	}
+
+	a.sendJson(w, nil)
+}
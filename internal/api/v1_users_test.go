@@ -0,0 +1,135 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/config-source/cdb/pkg/auth"
+)
+
+func TestCreateUser(t *testing.T) {
+	_, mux := testAPI(t, true)
+
+	marshalled, err := json.Marshal(NewUser{
+		Email:    "test@example.com",
+		Password: "Testing123!@",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", "/api/v1/users", bytes.NewBuffer(marshalled))
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != 201 {
+		t.Fatalf("Expected status code 201 got: %d %s", rr.Code, rr.Body.String())
+	}
+
+	if strings.Contains(rr.Body.String(), "Testing123!@") || strings.Contains(rr.Body.String(), "Password") {
+		t.Fatalf("Expected the password not to be returned got: %s", rr.Body.String())
+	}
+
+	var user auth.User
+	if err := json.NewDecoder(rr.Body).Decode(&user); err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Email != "test@example.com" {
+		t.Fatalf("Expected test@example.com got: %s", user.Email)
+	}
+}
+
+func TestDisableUser(t *testing.T) {
+	tc, mux := testAPI(t, true)
+
+	user, err := tc.gateway.CreateUser(context.Background(), auth.User{
+		Email:    "test@example.com",
+		Password: "Testing123!@",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("POST", fmt.Sprintf("/api/v1/users/%d/disable", user.ID), nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != 200 {
+		t.Fatalf("Expected status code 200 got: %d %s", rr.Code, rr.Body.String())
+	}
+
+	var disabled auth.User
+	if err := json.NewDecoder(rr.Body).Decode(&disabled); err != nil {
+		t.Fatal(err)
+	}
+
+	if !disabled.Disabled {
+		t.Fatal("Expected the user to be disabled")
+	}
+
+	marshalled, err := json.Marshal(Credentials{
+		Email:    "test@example.com",
+		Password: "Testing123!@",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/auth/login", bytes.NewBuffer(marshalled))
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != 401 {
+		t.Fatalf("Expected a disabled user's login to fail with 401 got: %d %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", fmt.Sprintf("/api/v1/users/%d/enable", user.ID), nil)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != 200 {
+		t.Fatalf("Expected status code 200 got: %d %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/auth/login", bytes.NewBuffer(marshalled))
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != 200 {
+		t.Fatalf("Expected an enabled user's login to succeed got: %d %s", rr.Code, rr.Body.String())
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	tc, mux := testAPI(t, true)
+
+	user, err := tc.gateway.CreateUser(context.Background(), auth.User{
+		Email:    "test@example.com",
+		Password: "Testing123!@",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest("DELETE", fmt.Sprintf("/api/v1/users/%d", user.ID), nil)
+	rr := httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != 200 {
+		t.Fatalf("Expected status code 200 got: %d %s", rr.Code, rr.Body.String())
+	}
+
+	req = httptest.NewRequest("GET", fmt.Sprintf("/api/v1/users/%d", user.ID), nil)
+	rr = httptest.NewRecorder()
+	mux.ServeHTTP(rr, req)
+
+	if rr.Code != 404 {
+		t.Fatalf("Expected status code 404 got: %d %s", rr.Code, rr.Body.String())
+	}
+}
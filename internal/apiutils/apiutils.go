@@ -5,11 +5,15 @@ import (
 	"errors"
 	"net/http"
 
+	"github.com/config-source/cdb/pkg/audit"
 	"github.com/config-source/cdb/pkg/auth"
 	"github.com/config-source/cdb/pkg/configkeys"
 	"github.com/config-source/cdb/pkg/configvalues"
 	"github.com/config-source/cdb/pkg/environments"
+	"github.com/config-source/cdb/pkg/ephemeral"
+	"github.com/config-source/cdb/pkg/scheduledchanges"
 	"github.com/config-source/cdb/pkg/services"
+	"github.com/config-source/cdb/pkg/trash"
 	"github.com/rs/zerolog"
 )
 
@@ -71,22 +75,65 @@ func SendErr(log zerolog.Logger, w http.ResponseWriter, r *http.Request, err err
 	switch {
 	case
 		errors.Is(err, auth.ErrUserNotFound),
+		errors.Is(err, auth.ErrRoleNotFound),
+		errors.Is(err, auth.ErrACLEntryNotFound),
+		errors.Is(err, auth.ErrAPITokenNotFound),
 		errors.Is(err, environments.ErrNotFound),
+		errors.Is(err, environments.ErrOverlayNotFound),
 		errors.Is(err, configkeys.ErrNotFound),
 		errors.Is(err, services.ErrNotFound),
-		errors.Is(err, configvalues.ErrNotFound):
+		errors.Is(err, configvalues.ErrNotFound),
+		errors.Is(err, scheduledchanges.ErrNotFound):
 		w.WriteHeader(http.StatusNotFound)
 	case
 		errors.Is(err, configvalues.ErrNotValid),
+		errors.Is(err, environments.ErrInvalidInheritance),
+		errors.Is(err, environments.ErrInvalidOverlay),
+		errors.Is(err, environments.ErrInvalidLabel),
+		errors.Is(err, environments.ErrInvalidLabelSelector),
+		errors.Is(err, environments.ErrNotEphemeral),
+		errors.Is(err, environments.ErrPromotionCycle),
+		errors.Is(err, environments.ErrPromotionService),
+		errors.Is(err, environments.ErrHasDescendants),
+		errors.Is(err, services.ErrUnknownRole),
+		errors.Is(err, trash.ErrDependencyDeleted),
+		errors.Is(err, trash.ErrNameInUse),
+		errors.Is(err, trash.ErrUnknownKind),
+		errors.Is(err, ephemeral.ErrExpiresAtInPast),
+		errors.Is(err, ephemeral.ErrNoParent),
 		errors.Is(err, configvalues.ErrAlreadySet),
+		errors.Is(err, scheduledchanges.ErrNotPending),
+		errors.Is(err, scheduledchanges.ErrApplyAtInPast),
+		errors.Is(err, scheduledchanges.ErrNoValues),
+		errors.Is(err, auth.ErrInvalidACLEntry),
+		errors.Is(err, auth.ErrACLEntryExists),
 		errors.Is(err, auth.ErrPublicRegisterDisabled),
-		errors.Is(err, auth.ErrEmailInUse):
+		errors.Is(err, auth.ErrAPITokenExpiresAtInPast),
+		errors.Is(err, auth.ErrInvalidScope),
+		errors.Is(err, auth.ErrWeakPassword),
+		errors.Is(err, auth.ErrPasswordChangeUnsupported),
+		errors.Is(err, auth.ErrPasswordResetTokenInvalid),
+		errors.Is(err, auth.ErrUnknownLoginFailureKind),
+		errors.Is(err, auth.ErrMFAAlreadyEnrolled),
+		errors.Is(err, auth.ErrMFANotEnrolled),
+		errors.Is(err, auth.ErrMFAUnsupported),
+		errors.Is(err, auth.ErrDisableSelf),
+		errors.Is(err, auth.ErrRoleExists),
+		errors.Is(err, auth.ErrRoleNameEmpty),
+		errors.Is(err, auth.ErrDefaultRole),
+		errors.Is(err, auth.ErrUnknownPermission),
+		errors.Is(err, auth.ErrPasswordLoginDisabled),
+		errors.Is(err, auth.ErrRedirectLoginUnsupported),
+		errors.Is(err, auth.ErrEmailInUse),
+		errors.Is(err, audit.ErrInvalidFilter):
 		w.WriteHeader(http.StatusBadRequest)
 	case errors.Is(err, auth.ErrUnauthorized),
 		errors.Is(err, auth.ErrInvalidPassword):
 		w.WriteHeader(http.StatusForbidden)
 	case errors.Is(err, auth.ErrUnauthenticated):
 		w.WriteHeader(http.StatusUnauthorized)
+	case errors.Is(err, auth.ErrTooManyLoginAttempts):
+		w.WriteHeader(http.StatusTooManyRequests)
 	// This is safe because subsequent calls to WriteHeader are ignored so
 	// callers can set the status code before calling errorResponse but if they
 	// haven't we want to send a 500.
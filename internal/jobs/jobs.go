@@ -0,0 +1,115 @@
+package jobs
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Job is a unit of background work that cdbd runs periodically.
+//
+// Jobs must be safe to run concurrently from multiple cdbd replicas, usually
+// by relying on Postgres row locking to claim work.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(context.Context) error
+}
+
+// Runner runs a set of Jobs on their configured intervals until it is shutdown.
+type Runner struct {
+	log  zerolog.Logger
+	jobs []Job
+
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRunner(log zerolog.Logger, jobs ...Job) *Runner {
+	return &Runner{
+		log:  log,
+		jobs: jobs,
+	}
+}
+
+// Add registers a job with the runner. It must be called before Start.
+func (r *Runner) Add(job Job) {
+	r.jobs = append(r.jobs, job)
+}
+
+// Start launches a goroutine per job. Each job runs once immediately and then
+// on every tick of its interval.
+func (r *Runner) Start(ctx context.Context) {
+	ctx, r.cancel = context.WithCancel(ctx)
+
+	for _, job := range r.jobs {
+		r.wg.Add(1)
+		go r.loop(ctx, job)
+	}
+}
+
+func (r *Runner) loop(ctx context.Context, job Job) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		r.runOnce(ctx, job)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Runner) runOnce(ctx context.Context, job Job) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			r.log.Error().
+				Str("job", job.Name).
+				Interface("panic", recovered).
+				Msg("background job panicked")
+		}
+	}()
+
+	startTime := time.Now()
+	err := job.Run(ctx)
+	if err != nil && ctx.Err() == nil {
+		r.log.Err(err).
+			Str("job", job.Name).
+			Msg("background job failed")
+		return
+	}
+
+	r.log.Debug().
+		Str("job", job.Name).
+		Dur("durationMilliseconds", time.Since(startTime)).
+		Msg("background job finished")
+}
+
+// Shutdown stops all jobs and waits for any in-flight runs to finish or for ctx
+// to expire. It satisfies finish.Server so it can be registered alongside the
+// HTTP server.
+func (r *Runner) Shutdown(ctx context.Context) error {
+	if r.cancel != nil {
+		r.cancel()
+	}
+
+	done := make(chan struct{})
+	go func() {
+		r.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
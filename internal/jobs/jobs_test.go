@@ -0,0 +1,71 @@
+package jobs_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/config-source/cdb/internal/jobs"
+	"github.com/rs/zerolog"
+)
+
+func TestRunnerRunsJobsUntilShutdown(t *testing.T) {
+	var runs atomic.Int32
+	runner := jobs.NewRunner(
+		zerolog.New(nil).Level(zerolog.Disabled),
+		jobs.Job{
+			Name:     "counter",
+			Interval: time.Millisecond,
+			Run: func(ctx context.Context) error {
+				runs.Add(1)
+				return nil
+			},
+		},
+	)
+
+	runner.Start(context.Background())
+	time.Sleep(20 * time.Millisecond)
+
+	if err := runner.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	stopped := runs.Load()
+	if stopped < 2 {
+		t.Fatalf("Expected job to run at least twice got: %d", stopped)
+	}
+
+	time.Sleep(5 * time.Millisecond)
+	if runs.Load() != stopped {
+		t.Fatal("Expected job to stop running after shutdown")
+	}
+}
+
+func TestRunnerSurvivesFailingJobs(t *testing.T) {
+	var runs atomic.Int32
+	runner := jobs.NewRunner(zerolog.New(nil).Level(zerolog.Disabled))
+	runner.Add(jobs.Job{
+		Name:     "failing",
+		Interval: time.Millisecond,
+		Run: func(ctx context.Context) error {
+			if runs.Add(1) == 1 {
+				panic("boom")
+			}
+
+			return errors.New("failed")
+		},
+	})
+
+	runner.Start(context.Background())
+	time.Sleep(20 * time.Millisecond)
+
+	if err := runner.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if runs.Load() < 2 {
+		t.Fatalf("Expected job to keep running after failures got: %d", runs.Load())
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/config-source/cdb/internal/apiutils"
+	"github.com/config-source/cdb/pkg/audit"
 	"github.com/rs/zerolog"
 )
 
@@ -26,6 +27,7 @@ func AccessLog(log zerolog.Logger, next http.Handler) http.Handler {
 				Str("url", r.URL.String()).
 				Str("method", r.Method).
 				Int("statusCode", wr.Status()).
+				Str("requestID", audit.RequestID(r.Context())).
 				Dur("responseTimeMilliseconds", responseTime).
 				Msg("request served")
 		},
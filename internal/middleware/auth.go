@@ -30,7 +30,7 @@ func GetUser(r *http.Request) (auth.User, error) {
 	return *user, nil
 }
 
-func Authentication(log zerolog.Logger, userSvc *auth.UserService, signingKey []byte, next http.Handler) http.Handler {
+func Authentication(log zerolog.Logger, userSvc *auth.UserService, keys *auth.KeySet, next http.Handler) http.Handler {
 	return http.HandlerFunc(
 		func(w http.ResponseWriter, r *http.Request) {
 			var token string
@@ -68,10 +68,29 @@ func Authentication(log zerolog.Logger, userSvc *auth.UserService, signingKey []
 				return
 			}
 
-			user, err := auth.ValidateIdToken(signingKey, token)
+			claims, err := auth.ValidateIdTokenClaims(keys, token)
+			user := claims.User
+			if err == nil && claims.IsAPIToken() {
+				// API tokens can be revoked and may have expired since they
+				// were issued.
+				err = userSvc.AuthenticateAPIToken(r.Context(), token)
+			} else if err == nil {
+				// ID tokens die with the session they were issued for.
+				err = userSvc.AuthenticateSession(r.Context(), claims)
+			} else {
+				// The token may have been issued by an external identity
+				// provider instead.
+				if external, externalErr := userSvc.AuthenticateBearerToken(r.Context(), token); externalErr == nil {
+					user, err = external, nil
+				}
+			}
+
 			if err != nil {
 				log.Err(err).Msg("invalid token")
-				w.WriteHeader(http.StatusBadRequest)
+				if !errors.Is(err, auth.ErrUnauthenticated) {
+					w.WriteHeader(http.StatusBadRequest)
+				}
+
 				apiutils.SendErr(log, w, r, err)
 				return
 			}
@@ -82,11 +101,11 @@ func Authentication(log zerolog.Logger, userSvc *auth.UserService, signingKey []
 	)
 }
 
-func AuthenticationRequired(log zerolog.Logger, userSvc *auth.UserService, signingKey []byte, next http.Handler) http.Handler {
+func AuthenticationRequired(log zerolog.Logger, userSvc *auth.UserService, keys *auth.KeySet, next http.Handler) http.Handler {
 	return Authentication(
 		log,
 		userSvc,
-		signingKey,
+		keys,
 		http.HandlerFunc(
 			func(w http.ResponseWriter, r *http.Request) {
 				if _, err := GetUser(r); err == nil {
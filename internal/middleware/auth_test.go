@@ -13,7 +13,18 @@ import (
 	"github.com/rs/zerolog"
 )
 
-var signingKey []byte = []byte("testing")
+var signingKey = auth.NewTestKeySet("testing")
+
+func idToken(t *testing.T, user auth.User) string {
+	t.Helper()
+
+	tokens, err := auth.GenerateTokens(signingKey, user, auth.Session{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tokens.IDToken
+}
 
 func TestAuthenticationMiddlewareNoToken(t *testing.T) {
 	var capturedUser auth.User
@@ -52,10 +63,7 @@ func TestAuthenticationMiddlewareTokenInHeader(t *testing.T) {
 		ID:    1,
 		Email: "test@example.com",
 	}
-	token, err := auth.GenerateIdToken(signingKey, expectedUser)
-	if err != nil {
-		t.Fatal(err)
-	}
+	token := idToken(t, expectedUser)
 
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
 
@@ -84,10 +92,7 @@ func TestAuthenticationMiddlewareTokenInvalidHeader(t *testing.T) {
 		ID:    1,
 		Email: "test@example.com",
 	}
-	token, err := auth.GenerateIdToken(signingKey, expectedUser)
-	if err != nil {
-		t.Fatal(err)
-	}
+	token := idToken(t, expectedUser)
 
 	req.Header.Add("Authorization", fmt.Sprintf("JWT %s", token))
 
@@ -116,10 +121,7 @@ func TestAuthenticationMiddlewareTokenInvalidToken(t *testing.T) {
 		ID:    1,
 		Email: "test@example.com",
 	}
-	token, err := auth.GenerateIdToken(signingKey, expectedUser)
-	if err != nil {
-		t.Fatal(err)
-	}
+	token := idToken(t, expectedUser)
 
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token[10:]))
 
@@ -150,10 +152,7 @@ func TestAuthenticationMiddlewareTokenInCookie(t *testing.T) {
 		ID:    1,
 		Email: "test@example.com",
 	}
-	token, err := auth.GenerateIdToken(signingKey, expectedUser)
-	if err != nil {
-		t.Fatal(err)
-	}
+	token := idToken(t, expectedUser)
 
 	req.AddCookie(&http.Cookie{
 		Name:  middleware.IDTokenCookieName,
@@ -187,10 +186,7 @@ func TestAuthenticationRequiredMiddlewareAllowsHandlerWithValidToken(t *testing.
 		ID:    1,
 		Email: "test@example.com",
 	}
-	token, err := auth.GenerateIdToken(signingKey, user)
-	if err != nil {
-		t.Fatal(err)
-	}
+	token := idToken(t, user)
 
 	req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
 
@@ -0,0 +1,34 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ForwardedFor sets the request's RemoteAddr to the client address added to
+// X-Forwarded-For by the proxy in front of cdbd. Only the last address is used
+// since any before it could have been sent by the client. It must only be used
+// when cdbd can't be reached except through the proxy.
+func ForwardedFor(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			addresses := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+			if client := strings.TrimSpace(addresses[len(addresses)-1]); client != "" {
+				r.RemoteAddr = net.JoinHostPort(client, "0")
+			}
+
+			next.ServeHTTP(w, r)
+		},
+	)
+}
+
+// ClientIP returns the IP address the request came from.
+func ClientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
@@ -0,0 +1,35 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/config-source/cdb/internal/middleware"
+)
+
+func TestForwardedForUsesTheAddressAddedByTheProxy(t *testing.T) {
+	var clientIP string
+	handler := middleware.ForwardedFor(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			clientIP = middleware.ClientIP(r)
+		},
+	))
+
+	req := httptest.NewRequest("POST", "/api/v1/auth/login", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.1, 198.51.100.1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if clientIP != "198.51.100.1" {
+		t.Errorf("Expected the last forwarded address got: %s", clientIP)
+	}
+
+	req = httptest.NewRequest("POST", "/api/v1/auth/login", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	if clientIP != "10.0.0.1" {
+		t.Errorf("Expected the remote address without X-Forwarded-For got: %s", clientIP)
+	}
+}
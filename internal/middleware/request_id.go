@@ -0,0 +1,54 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/config-source/cdb/pkg/audit"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength stops clients filling the audit log with huge IDs.
+const maxRequestIDLength = 128
+
+// validRequestID only accepts printable ASCII so that client supplied IDs are
+// safe to log.
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for _, c := range requestID {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	raw := make([]byte, 16)
+	// rand.Read never returns an error on supported platforms.
+	rand.Read(raw) // nolint:errcheck
+	return hex.EncodeToString(raw)
+}
+
+// RequestID gives every request an ID, reusing the one sent in X-Request-ID by
+// a proxy or client when it's valid, which is returned in the response and
+// recorded in the audit log.
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if !validRequestID(requestID) {
+				requestID = newRequestID()
+			}
+
+			w.Header().Set(RequestIDHeader, requestID)
+			next.ServeHTTP(w, r.WithContext(audit.WithRequestID(r.Context(), requestID)))
+		},
+	)
+}
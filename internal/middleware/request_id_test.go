@@ -0,0 +1,43 @@
+package middleware_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/config-source/cdb/internal/middleware"
+	"github.com/config-source/cdb/pkg/audit"
+)
+
+func TestRequestID(t *testing.T) {
+	var requestID string
+	handler := middleware.RequestID(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			requestID = audit.RequestID(r.Context())
+		},
+	))
+
+	req := httptest.NewRequest("GET", "/api/v1/audit", nil)
+	req.Header.Set(middleware.RequestIDHeader, "from-the-proxy")
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, req)
+
+	if requestID != "from-the-proxy" {
+		t.Errorf("Expected the request ID from the header got: %s", requestID)
+	}
+
+	if rr.Header().Get(middleware.RequestIDHeader) != requestID {
+		t.Errorf("Expected the response to carry the request ID got: %s", rr.Header().Get(middleware.RequestIDHeader))
+	}
+
+	for _, invalid := range []string{"", "has spaces", strings.Repeat("a", 129)} {
+		req = httptest.NewRequest("GET", "/api/v1/audit", nil)
+		req.Header.Set(middleware.RequestIDHeader, invalid)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+
+		if requestID == invalid || len(requestID) != 32 {
+			t.Errorf("Expected a generated request ID instead of %q got: %s", invalid, requestID)
+		}
+	}
+}
@@ -6,13 +6,17 @@ import (
 	"net/url"
 
 	"github.com/config-source/cdb/internal/api"
+	"github.com/config-source/cdb/internal/apiutils"
 	"github.com/config-source/cdb/internal/middleware"
 	"github.com/config-source/cdb/pkg/auth"
 	"github.com/config-source/cdb/pkg/configkeys"
 	"github.com/config-source/cdb/pkg/configvalues"
 	"github.com/config-source/cdb/pkg/environments"
+	"github.com/config-source/cdb/pkg/ephemeral"
 	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/config-source/cdb/pkg/scheduledchanges"
 	"github.com/config-source/cdb/pkg/services"
+	"github.com/config-source/cdb/pkg/trash"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 )
@@ -25,13 +29,16 @@ type Server struct {
 
 func New(
 	log zerolog.Logger,
-	tokenSigningKey []byte,
+	keys *auth.KeySet,
 	postgresPool *pgxpool.Pool,
 	userService *auth.UserService,
 	configValueService *configvalues.Service,
 	envService *environments.Service,
 	configKeyService *configkeys.Service,
 	svcService *services.ServiceService,
+	scheduledChangeService *scheduledchanges.Service,
+	ephemeralService *ephemeral.Service,
+	trashService *trash.Service,
 	frontendLocation string,
 ) *Server {
 	var frontendHandler http.Handler
@@ -48,12 +55,15 @@ func New(
 
 	apiServer, apiMux := api.NewV1(
 		log,
-		tokenSigningKey,
+		keys,
 		userService,
 		configValueService,
 		envService,
 		configKeyService,
 		svcService,
+		scheduledChangeService,
+		ephemeralService,
+		trashService,
 	)
 
 	mux := http.NewServeMux()
@@ -69,10 +79,15 @@ func New(
 
 		w.Write(nil) // nolint:errcheck
 	})
+	// Lets other systems verify tokens issued by CDB without sharing a
+	// secret.
+	mux.HandleFunc("GET /.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		apiutils.SendJSON(log, w, keys.JWKS())
+	})
 	mux.Handle("/", frontendHandler)
 
 	return &Server{
-		handler: middleware.AccessLog(log, mux),
+		handler: middleware.RequestID(middleware.AccessLog(log, mux)),
 		apiV1:   apiServer,
 		ui:      frontendHandler,
 	}
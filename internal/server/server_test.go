@@ -3,13 +3,17 @@ package server
 import (
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/config-source/cdb/pkg/auth"
 	"github.com/config-source/cdb/pkg/configkeys"
 	"github.com/config-source/cdb/pkg/configvalues"
 	"github.com/config-source/cdb/pkg/environments"
+	"github.com/config-source/cdb/pkg/ephemeral"
 	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/config-source/cdb/pkg/scheduledchanges"
 	"github.com/config-source/cdb/pkg/services"
+	"github.com/config-source/cdb/pkg/trash"
 	"github.com/rs/zerolog"
 )
 
@@ -22,17 +26,28 @@ func TestHealthCheckSuccess(t *testing.T) {
 	svcRepo := services.NewRepository(repoLogger, pool)
 	envRepo := environments.NewRepository(repoLogger, pool)
 	keyRepo := configkeys.NewRepository(repoLogger, pool)
-	valueRepo := configvalues.NewRepository(repoLogger, pool, envRepo)
+	valueRepo := configvalues.NewRepository(repoLogger, pool, envRepo, keyRepo)
+	valueService := configvalues.NewService(repoLogger, valueRepo, envRepo, keyRepo, gateway, true, nil)
 
 	server := New(
 		zerolog.New(nil).Level(zerolog.Disabled),
-		[]byte("test key"),
+		auth.NewTestKeySet("test key"),
 		pool,
 		auth.NewTestServiceWithGateway(gateway),
-		configvalues.NewService(valueRepo, envRepo, keyRepo, gateway, true),
-		environments.NewService(envRepo, gateway),
-		configkeys.NewService(keyRepo, gateway),
-		services.NewServiceService(svcRepo, gateway),
+		valueService,
+		environments.NewService(envRepo, gateway, nil),
+		configkeys.NewService(keyRepo, gateway, nil),
+		services.NewServiceService(svcRepo, gateway, nil),
+		scheduledchanges.NewService(
+			scheduledchanges.NewRepository(repoLogger, pool),
+			envRepo,
+			valueService,
+			gateway,
+			gateway,
+			nil,
+		),
+		ephemeral.NewService(envRepo, valueService, gateway, time.Hour, nil),
+		trash.NewService(envRepo, keyRepo, svcRepo, gateway, time.Hour, nil),
 		"/frontend",
 	)
 
@@ -57,17 +72,28 @@ func TestHealthCheckFailure(t *testing.T) {
 	svcRepo := services.NewRepository(repoLogger, pool)
 	envRepo := environments.NewRepository(repoLogger, pool)
 	keyRepo := configkeys.NewRepository(repoLogger, pool)
-	valueRepo := configvalues.NewRepository(repoLogger, pool, envRepo)
+	valueRepo := configvalues.NewRepository(repoLogger, pool, envRepo, keyRepo)
+	valueService := configvalues.NewService(repoLogger, valueRepo, envRepo, keyRepo, gateway, true, nil)
 
 	server := New(
 		zerolog.New(nil).Level(zerolog.Disabled),
-		[]byte("test key"),
+		auth.NewTestKeySet("test key"),
 		pool,
 		userService,
-		configvalues.NewService(valueRepo, envRepo, keyRepo, gateway, true),
-		environments.NewService(envRepo, gateway),
-		configkeys.NewService(keyRepo, gateway),
-		services.NewServiceService(svcRepo, gateway),
+		valueService,
+		environments.NewService(envRepo, gateway, nil),
+		configkeys.NewService(keyRepo, gateway, nil),
+		services.NewServiceService(svcRepo, gateway, nil),
+		scheduledchanges.NewService(
+			scheduledchanges.NewRepository(repoLogger, pool),
+			envRepo,
+			valueService,
+			gateway,
+			gateway,
+			nil,
+		),
+		ephemeral.NewService(envRepo, valueService, gateway, time.Hour, nil),
+		trash.NewService(envRepo, keyRepo, svcRepo, gateway, time.Hour, nil),
 		"/frontend",
 	)
 
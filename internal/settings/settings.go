@@ -2,6 +2,7 @@ package settings
 
 import (
 	"os"
+	"strconv"
 	"strings"
 	"time"
 
@@ -115,10 +116,15 @@ func FrontendLocation() string {
 var keyCache []byte
 
 // JWTSigningKey returns the configured symmetric key for signing JWTs issued by
-// CDB's auth system.
+// CDB's auth system. It's only required when signing with HS512, otherwise it's
+// used to verify tokens issued before switching algorithm.
 func JWTSigningKey() []byte {
 	if keyCache == nil {
 		key := os.Getenv("JWT_SIGNING_KEY")
+		if key == "" && JWTSigningAlgorithm().Asymmetric() {
+			return nil
+		}
+
 		if key == "" {
 			logger := GetLogger()
 			logger.
@@ -135,6 +141,104 @@ func JWTSigningKey() []byte {
 	return keyCache
 }
 
+// JWTSigningAlgorithm returns the algorithm JWTs are signed with as defined by
+// $JWT_SIGNING_ALGORITHM, one of HS512, RS256 or EdDSA. RS256 and EdDSA keys
+// are generated and stored in the database, see cdbd rotate-signing-key.
+//
+// Defaults to HS512.
+func JWTSigningAlgorithm() auth.Algorithm {
+	algorithm := auth.Algorithm(os.Getenv("JWT_SIGNING_ALGORITHM"))
+	if algorithm == "" {
+		return auth.AlgorithmHS512
+	}
+
+	if err := algorithm.Valid(); err != nil {
+		logger := GetLogger()
+		logger.Error().Err(err).Str("algorithm", string(algorithm)).Msg("JWT_SIGNING_ALGORITHM is invalid")
+		os.Exit(1)
+	}
+
+	return algorithm
+}
+
+// SigningKeyReloadInterval returns how often cdbd reloads signing keys from
+// the database as defined by $SIGNING_KEY_RELOAD_INTERVAL
+//
+// Defaults to 1 minute.
+func SigningKeyReloadInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("SIGNING_KEY_RELOAD_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return time.Minute
+	}
+
+	return interval
+}
+
+// ScheduledChangesPollInterval returns how often cdbd checks for scheduled
+// config changes which are due as defined by $SCHEDULED_CHANGES_POLL_INTERVAL
+//
+// Defaults to 30 seconds.
+func ScheduledChangesPollInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("SCHEDULED_CHANGES_POLL_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return 30 * time.Second
+	}
+
+	return interval
+}
+
+// EphemeralEnvironmentTTL returns how long ephemeral environments live when
+// created without an expiry as defined by $EPHEMERAL_ENVIRONMENT_TTL
+//
+// Defaults to 24 hours.
+func EphemeralEnvironmentTTL() time.Duration {
+	ttl, err := time.ParseDuration(os.Getenv("EPHEMERAL_ENVIRONMENT_TTL"))
+	if err != nil || ttl <= 0 {
+		return 24 * time.Hour
+	}
+
+	return ttl
+}
+
+// EphemeralEnvironmentReapInterval returns how often cdbd deletes expired
+// ephemeral environments as defined by $EPHEMERAL_ENVIRONMENT_REAP_INTERVAL
+//
+// Defaults to 1 minute.
+func EphemeralEnvironmentReapInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("EPHEMERAL_ENVIRONMENT_REAP_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return time.Minute
+	}
+
+	return interval
+}
+
+// TrashRetention returns how long deleted environments, config keys and
+// services are kept before being purged as defined by $TRASH_RETENTION
+//
+// Defaults to 30 days.
+func TrashRetention() time.Duration {
+	retention, err := time.ParseDuration(os.Getenv("TRASH_RETENTION"))
+	if err != nil || retention <= 0 {
+		return 30 * 24 * time.Hour
+	}
+
+	return retention
+}
+
+// TrashPurgeInterval returns how often cdbd purges expired items from the
+// trash as defined by $TRASH_PURGE_INTERVAL
+//
+// Defaults to 1 hour.
+func TrashPurgeInterval() time.Duration {
+	interval, err := time.ParseDuration(os.Getenv("TRASH_PURGE_INTERVAL"))
+	if err != nil || interval <= 0 {
+		return time.Hour
+	}
+
+	return interval
+}
+
 func AuthenticationGateway() string {
 	return os.Getenv("AUTHENTICATION_GATEWAY")
 }
@@ -159,3 +263,256 @@ func DefaultRegisterRole() string {
 
 	return val
 }
+
+// OIDCIssuerURL returns the issuer of the OpenID Connect identity provider
+// used when AUTHENTICATION_GATEWAY is oidc as defined by $OIDC_ISSUER_URL
+func OIDCIssuerURL() string {
+	return os.Getenv("OIDC_ISSUER_URL")
+}
+
+func OIDCClientID() string {
+	return os.Getenv("OIDC_CLIENT_ID")
+}
+
+func OIDCClientSecret() string {
+	return os.Getenv("OIDC_CLIENT_SECRET")
+}
+
+// OIDCRedirectURL returns the URL the identity provider sends users back to
+// as defined by $OIDC_REDIRECT_URL, it should point at
+// /api/v1/auth/oidc/callback.
+func OIDCRedirectURL() string {
+	return os.Getenv("OIDC_REDIRECT_URL")
+}
+
+// OIDCScopes returns the scopes requested in addition to openid as defined by
+// the space separated $OIDC_SCOPES
+//
+// Defaults to email and profile.
+func OIDCScopes() []string {
+	scopes := strings.Fields(os.Getenv("OIDC_SCOPES"))
+	if len(scopes) == 0 {
+		return []string{"email", "profile"}
+	}
+
+	return scopes
+}
+
+// OIDCGroupsClaim returns the ID token claim listing a user's groups as
+// defined by $OIDC_GROUPS_CLAIM
+//
+// Defaults to groups.
+func OIDCGroupsClaim() string {
+	claim := os.Getenv("OIDC_GROUPS_CLAIM")
+	if claim == "" {
+		return "groups"
+	}
+
+	return claim
+}
+
+// OIDCGroupRoles returns the mapping of identity provider groups to CDB roles
+// as defined by $OIDC_GROUP_ROLES in the form group=Role,other-group=Role
+func OIDCGroupRoles() map[string]string {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("OIDC_GROUP_ROLES"), ",") {
+		group, role, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		if !ok || group == "" || role == "" {
+			continue
+		}
+
+		mapping[group] = role
+	}
+
+	return mapping
+}
+
+// OIDCLinkExistingUsers returns whether users who existed before they first
+// logged in through the identity provider are linked to it by email as defined
+// by $OIDC_LINK_EXISTING_USERS
+//
+// Only intended for upgrading deployments whose users predate identity links.
+func OIDCLinkExistingUsers() bool {
+	return strings.ToLower(os.Getenv("OIDC_LINK_EXISTING_USERS")) == "true"
+}
+
+// LDAPURL returns the URL of the directory used when AUTHENTICATION_GATEWAY is
+// ldap as defined by $LDAP_URL
+func LDAPURL() string {
+	return os.Getenv("LDAP_URL")
+}
+
+// LDAPStartTLS returns whether ldap:// connections are upgraded with StartTLS
+// as defined by $LDAP_START_TLS
+func LDAPStartTLS() bool {
+	return strings.ToLower(os.Getenv("LDAP_START_TLS")) == "true"
+}
+
+// LDAPCAFile returns the path to a PEM file of certificate authorities trusted
+// for the directory's certificate as defined by $LDAP_CA_FILE
+//
+// Defaults to the system trust store when empty.
+func LDAPCAFile() string {
+	return os.Getenv("LDAP_CA_FILE")
+}
+
+func LDAPBindDN() string {
+	return os.Getenv("LDAP_BIND_DN")
+}
+
+func LDAPBindPassword() string {
+	return os.Getenv("LDAP_BIND_PASSWORD")
+}
+
+func LDAPUserBaseDN() string {
+	return os.Getenv("LDAP_USER_BASE_DN")
+}
+
+// LDAPUserFilter returns the filter used to find users as they log in as
+// defined by $LDAP_USER_FILTER, %s is replaced with their email.
+func LDAPUserFilter() string {
+	return os.Getenv("LDAP_USER_FILTER")
+}
+
+func LDAPEmailAttribute() string {
+	return os.Getenv("LDAP_EMAIL_ATTRIBUTE")
+}
+
+func LDAPGroupBaseDN() string {
+	return os.Getenv("LDAP_GROUP_BASE_DN")
+}
+
+// LDAPGroupFilter returns the filter used to find a user's groups as defined
+// by $LDAP_GROUP_FILTER, %s is replaced with their DN.
+func LDAPGroupFilter() string {
+	return os.Getenv("LDAP_GROUP_FILTER")
+}
+
+// LDAPGroupRoles returns the mapping of group DNs to CDB roles as defined by
+// $LDAP_GROUP_ROLES in the form cn=group,dc=example,dc=com=Role;cn=other...=Role
+//
+// DNs contain commas so mappings are separated by semicolons.
+func LDAPGroupRoles() map[string]string {
+	mapping := make(map[string]string)
+	for _, pair := range strings.Split(os.Getenv("LDAP_GROUP_ROLES"), ";") {
+		pair = strings.TrimSpace(pair)
+		idx := strings.LastIndex(pair, "=")
+		if idx <= 0 || idx == len(pair)-1 {
+			continue
+		}
+
+		mapping[pair[:idx]] = pair[idx+1:]
+	}
+
+	return mapping
+}
+
+// PasswordMinLength returns the minimum length of passwords as defined by
+// $PASSWORD_MIN_LENGTH
+//
+// Defaults to 12.
+func PasswordMinLength() int {
+	length, err := strconv.Atoi(os.Getenv("PASSWORD_MIN_LENGTH"))
+	if err != nil || length < 0 {
+		return 12
+	}
+
+	return length
+}
+
+// BreachedPasswordsFile returns the path to a file of breached passwords, one
+// per line, which can't be used as defined by $BREACHED_PASSWORDS_FILE
+func BreachedPasswordsFile() string {
+	return os.Getenv("BREACHED_PASSWORDS_FILE")
+}
+
+// PasswordResetNotifier returns how password reset tokens are delivered as
+// defined by $PASSWORD_RESET_NOTIFIER, either log or file.
+//
+// Defaults to log.
+func PasswordResetNotifier() string {
+	notifier := os.Getenv("PASSWORD_RESET_NOTIFIER")
+	if notifier == "" {
+		return "log"
+	}
+
+	return notifier
+}
+
+// PasswordResetFile returns the file password reset tokens are appended to
+// when PASSWORD_RESET_NOTIFIER is file as defined by $PASSWORD_RESET_FILE
+func PasswordResetFile() string {
+	return os.Getenv("PASSWORD_RESET_FILE")
+}
+
+// LoginMaxAccountFailures returns how many failed logins lock an account as
+// defined by $LOGIN_MAX_ACCOUNT_FAILURES
+//
+// Defaults to 5.
+func LoginMaxAccountFailures() int {
+	failures, err := strconv.Atoi(os.Getenv("LOGIN_MAX_ACCOUNT_FAILURES"))
+	if err != nil || failures <= 0 {
+		return 5
+	}
+
+	return failures
+}
+
+// LoginMaxIPFailures returns how many failed logins lock an IP address as
+// defined by $LOGIN_MAX_IP_FAILURES
+//
+// Defaults to 50.
+func LoginMaxIPFailures() int {
+	failures, err := strconv.Atoi(os.Getenv("LOGIN_MAX_IP_FAILURES"))
+	if err != nil || failures <= 0 {
+		return 50
+	}
+
+	return failures
+}
+
+// LoginBackoff returns how long logins are refused after the first failure,
+// doubling with every failure after it, as defined by $LOGIN_BACKOFF
+//
+// Defaults to 1 second.
+func LoginBackoff() time.Duration {
+	backoff, err := time.ParseDuration(os.Getenv("LOGIN_BACKOFF"))
+	if err != nil || backoff < 0 {
+		return time.Second
+	}
+
+	return backoff
+}
+
+// LoginMaxBackoff returns the longest logins are refused for before the
+// account or IP address is locked as defined by $LOGIN_MAX_BACKOFF
+//
+// Defaults to 1 minute.
+func LoginMaxBackoff() time.Duration {
+	backoff, err := time.ParseDuration(os.Getenv("LOGIN_MAX_BACKOFF"))
+	if err != nil || backoff < 0 {
+		return time.Minute
+	}
+
+	return backoff
+}
+
+// LoginLockoutDuration returns how long accounts and IP addresses are locked
+// for as defined by $LOGIN_LOCKOUT_DURATION
+//
+// Defaults to 15 minutes.
+func LoginLockoutDuration() time.Duration {
+	duration, err := time.ParseDuration(os.Getenv("LOGIN_LOCKOUT_DURATION"))
+	if err != nil || duration <= 0 {
+		return 15 * time.Minute
+	}
+
+	return duration
+}
+
+// TrustForwardedFor returns whether client IP addresses are taken from the
+// X-Forwarded-For header as defined by $TRUST_FORWARDED_FOR. It must only be
+// enabled when cdbd is behind a proxy which sets the header.
+func TrustForwardedFor() bool {
+	return strings.ToLower(os.Getenv("TRUST_FORWARDED_FOR")) == "true"
+}
@@ -0,0 +1,22 @@
+CREATE TABLE scheduled_changes (
+    id SERIAL PRIMARY KEY,
+    environment_id integer REFERENCES environments
+        ON DELETE CASCADE
+        NOT NULL,
+    author_id integer NOT NULL,
+
+    apply_at timestamptz NOT NULL,
+    status TEXT NOT NULL DEFAULT 'PENDING'
+        CONSTRAINT scheduled_change_status CHECK (
+            status IN ('PENDING', 'APPLYING', 'APPLIED', 'FAILED', 'CANCELLED')
+        ),
+    config_values jsonb NOT NULL,
+    error TEXT NOT NULL DEFAULT '',
+
+    claimed_at timestamptz,
+    applied_at timestamptz,
+    created_at timestamp DEFAULT current_timestamp
+);
+
+CREATE INDEX ON scheduled_changes(environment_id);
+CREATE INDEX ON scheduled_changes(apply_at) WHERE status IN ('PENDING', 'APPLYING');
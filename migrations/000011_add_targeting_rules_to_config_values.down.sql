@@ -0,0 +1,2 @@
+ALTER TABLE config_values
+DROP COLUMN targeting_rules;
@@ -0,0 +1,2 @@
+ALTER TABLE config_values
+ADD COLUMN targeting_rules jsonb;
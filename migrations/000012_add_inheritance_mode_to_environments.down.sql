@@ -0,0 +1,5 @@
+ALTER TABLE environments
+DROP COLUMN inheritance_keys;
+
+ALTER TABLE environments
+DROP COLUMN inheritance_mode;
@@ -0,0 +1,7 @@
+ALTER TABLE environments
+ADD COLUMN inheritance_mode TEXT NOT NULL DEFAULT 'full'
+    CONSTRAINT environment_inheritance_mode_valid
+    CHECK (inheritance_mode IN ('full', 'none', 'allowlist', 'denylist'));
+
+ALTER TABLE environments
+ADD COLUMN inheritance_keys TEXT[] NOT NULL DEFAULT '{}';
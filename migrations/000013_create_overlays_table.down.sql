@@ -0,0 +1,16 @@
+BEGIN;
+
+DELETE FROM config_values WHERE overlay_id IS NOT NULL;
+
+DROP INDEX config_values_environment_key_overlay;
+DROP INDEX config_values_environment_key;
+
+ALTER TABLE config_values
+ADD UNIQUE (environment_id, config_key_id);
+
+ALTER TABLE config_values
+DROP COLUMN overlay_id;
+
+DROP TABLE overlays;
+
+COMMIT;
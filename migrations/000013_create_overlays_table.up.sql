@@ -0,0 +1,29 @@
+BEGIN;
+
+CREATE TABLE overlays (
+    id SERIAL PRIMARY KEY,
+    service_id integer REFERENCES services
+        ON DELETE CASCADE
+        NOT NULL,
+    dimension TEXT NOT NULL CONSTRAINT overlay_dimension_not_empty CHECK (dimension <> ''),
+    name TEXT NOT NULL CONSTRAINT overlay_name_not_empty CHECK (name <> ''),
+    created_at timestamp DEFAULT current_timestamp,
+
+    UNIQUE (service_id, dimension, name)
+);
+
+ALTER TABLE config_values
+ADD COLUMN overlay_id integer REFERENCES overlays ON DELETE CASCADE;
+
+ALTER TABLE config_values
+DROP CONSTRAINT config_values_environment_id_config_key_id_key;
+
+CREATE UNIQUE INDEX config_values_environment_key
+ON config_values (environment_id, config_key_id)
+WHERE overlay_id IS NULL;
+
+CREATE UNIQUE INDEX config_values_environment_key_overlay
+ON config_values (environment_id, config_key_id, overlay_id)
+WHERE overlay_id IS NOT NULL;
+
+COMMIT;
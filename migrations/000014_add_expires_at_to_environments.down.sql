@@ -0,0 +1,16 @@
+BEGIN;
+
+DELETE FROM permissions_to_roles
+USING permissions
+WHERE permissions_to_roles.permission_id = permissions.id
+    AND permissions.name = 'CAN_MANAGE_EPHEMERAL_ENVIRONMENTS';
+
+DELETE FROM permissions
+WHERE name = 'CAN_MANAGE_EPHEMERAL_ENVIRONMENTS';
+
+DROP INDEX environments_expires_at;
+
+ALTER TABLE environments
+DROP COLUMN expires_at;
+
+COMMIT;
@@ -0,0 +1,21 @@
+BEGIN;
+
+ALTER TABLE environments
+ADD COLUMN expires_at timestamptz;
+
+CREATE INDEX environments_expires_at ON environments (expires_at)
+WHERE expires_at IS NOT NULL;
+
+INSERT INTO permissions (name) VALUES
+    ('CAN_MANAGE_EPHEMERAL_ENVIRONMENTS');
+
+INSERT INTO permissions_to_roles (permission_id, role_id)
+SELECT permissions.id, roles.id
+FROM roles
+JOIN permissions
+ON (
+    (roles.name = 'Administrator' OR roles.name = 'Operator') AND
+    permissions.name = 'CAN_MANAGE_EPHEMERAL_ENVIRONMENTS'
+);
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+DROP INDEX services_deleted_at;
+DROP INDEX config_keys_deleted_at;
+DROP INDEX environments_deleted_at;
+
+ALTER TABLE services
+DROP COLUMN deleted_at;
+
+ALTER TABLE config_keys
+DROP COLUMN deleted_at;
+
+ALTER TABLE environments
+DROP COLUMN deleted_at;
+
+COMMIT;
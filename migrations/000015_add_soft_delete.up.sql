@@ -0,0 +1,21 @@
+BEGIN;
+
+ALTER TABLE environments
+ADD COLUMN deleted_at timestamptz;
+
+ALTER TABLE config_keys
+ADD COLUMN deleted_at timestamptz;
+
+ALTER TABLE services
+ADD COLUMN deleted_at timestamptz;
+
+CREATE INDEX environments_deleted_at ON environments (deleted_at)
+WHERE deleted_at IS NOT NULL;
+
+CREATE INDEX config_keys_deleted_at ON config_keys (deleted_at)
+WHERE deleted_at IS NOT NULL;
+
+CREATE INDEX services_deleted_at ON services (deleted_at)
+WHERE deleted_at IS NOT NULL;
+
+COMMIT;
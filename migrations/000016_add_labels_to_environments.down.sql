@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP INDEX environments_labels;
+
+ALTER TABLE environments
+DROP COLUMN labels;
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+ALTER TABLE environments
+ADD COLUMN labels jsonb NOT NULL DEFAULT '{}';
+
+CREATE INDEX environments_labels ON environments USING GIN (labels jsonb_path_ops);
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+DROP TABLE users_to_service_roles;
+
+DROP TABLE service_role_permissions;
+
+COMMIT;
@@ -0,0 +1,36 @@
+BEGIN;
+
+CREATE TABLE service_role_permissions (
+    role TEXT NOT NULL,
+    permission_id integer NOT NULL REFERENCES permissions ON DELETE CASCADE,
+    UNIQUE (role, permission_id)
+);
+
+CREATE TABLE users_to_service_roles (
+    user_id integer NOT NULL REFERENCES users ON DELETE CASCADE,
+    service_id integer NOT NULL REFERENCES services ON DELETE CASCADE,
+    role TEXT NOT NULL CONSTRAINT service_role_known CHECK (role IN ('OWNER', 'DEVELOPER')),
+    PRIMARY KEY (user_id, service_id)
+);
+
+CREATE INDEX users_to_service_roles_service_id ON users_to_service_roles (service_id);
+
+-- Owners manage the keys and environments of their service.
+INSERT INTO service_role_permissions (role, permission_id)
+SELECT 'OWNER', permissions.id
+FROM permissions
+WHERE permissions.name IN (
+    'CAN_CONFIGURE_ENVIRONMENTS',
+    'CAN_CONFIGURE_SENSITIVE_ENVIRONMENTS',
+    'CAN_MANAGE_ENVIRONMENTS',
+    'CAN_MANAGE_CONFIG_KEYS',
+    'CAN_MANAGE_EPHEMERAL_ENVIRONMENTS'
+);
+
+-- Developers configure the non-sensitive environments of their service.
+INSERT INTO service_role_permissions (role, permission_id)
+SELECT 'DEVELOPER', permissions.id
+FROM permissions
+WHERE permissions.name = 'CAN_CONFIGURE_ENVIRONMENTS';
+
+COMMIT;
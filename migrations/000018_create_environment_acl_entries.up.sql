@@ -0,0 +1,21 @@
+BEGIN;
+
+CREATE TABLE environment_acl_entries (
+    id SERIAL PRIMARY KEY,
+    environment_id integer NOT NULL REFERENCES environments ON DELETE CASCADE,
+    user_id integer REFERENCES users ON DELETE CASCADE,
+    role_id integer REFERENCES roles ON DELETE CASCADE,
+    access TEXT NOT NULL CONSTRAINT acl_access_known CHECK (access IN ('read', 'write')),
+    created_at timestamptz NOT NULL DEFAULT now(),
+
+    -- Entries grant access to exactly one user or role.
+    CONSTRAINT acl_single_grantee CHECK ((user_id IS NULL) <> (role_id IS NULL))
+);
+
+CREATE UNIQUE INDEX environment_acl_entries_user ON environment_acl_entries (environment_id, user_id)
+WHERE user_id IS NOT NULL;
+
+CREATE UNIQUE INDEX environment_acl_entries_role ON environment_acl_entries (environment_id, role_id)
+WHERE role_id IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+DELETE FROM service_role_permissions
+USING permissions
+WHERE service_role_permissions.permission_id = permissions.id
+    AND permissions.name IN ('CAN_READ_CONFIGURATION', 'CAN_READ_SENSITIVE_CONFIGURATION');
+
+DELETE FROM permissions_to_roles
+USING permissions
+WHERE permissions_to_roles.permission_id = permissions.id
+    AND permissions.name IN ('CAN_READ_CONFIGURATION', 'CAN_READ_SENSITIVE_CONFIGURATION');
+
+DELETE FROM permissions
+WHERE name IN ('CAN_READ_CONFIGURATION', 'CAN_READ_SENSITIVE_CONFIGURATION');
+
+COMMIT;
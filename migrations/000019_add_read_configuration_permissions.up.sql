@@ -0,0 +1,39 @@
+BEGIN;
+
+INSERT INTO permissions (name) VALUES
+    ('CAN_READ_CONFIGURATION'),
+    ('CAN_READ_SENSITIVE_CONFIGURATION');
+
+-- Anyone who could configure environments before could also read them so keep
+-- it that way for every existing role.
+INSERT INTO permissions_to_roles (permission_id, role_id)
+SELECT read_permissions.id, permissions_to_roles.role_id
+FROM permissions_to_roles
+JOIN permissions ON permissions.id = permissions_to_roles.permission_id
+JOIN permissions read_permissions ON (
+    (permissions.name = 'CAN_CONFIGURE_ENVIRONMENTS' AND read_permissions.name = 'CAN_READ_CONFIGURATION') OR
+    (permissions.name = 'CAN_CONFIGURE_SENSITIVE_ENVIRONMENTS' AND read_permissions.name = 'CAN_READ_SENSITIVE_CONFIGURATION')
+);
+
+-- Administrator gets all permissions.
+INSERT INTO permissions_to_roles (permission_id, role_id)
+SELECT permissions.id, roles.id
+FROM roles
+JOIN permissions
+ON (
+    roles.name = 'Administrator' AND
+    permissions.name IN ('CAN_READ_CONFIGURATION', 'CAN_READ_SENSITIVE_CONFIGURATION')
+)
+ON CONFLICT DO NOTHING;
+
+INSERT INTO service_role_permissions (role, permission_id)
+SELECT 'OWNER', permissions.id
+FROM permissions
+WHERE permissions.name IN ('CAN_READ_CONFIGURATION', 'CAN_READ_SENSITIVE_CONFIGURATION');
+
+INSERT INTO service_role_permissions (role, permission_id)
+SELECT 'DEVELOPER', permissions.id
+FROM permissions
+WHERE permissions.name = 'CAN_READ_CONFIGURATION';
+
+COMMIT;
@@ -0,0 +1,14 @@
+BEGIN;
+
+DELETE FROM permissions_to_roles
+USING permissions
+WHERE permissions_to_roles.permission_id = permissions.id
+    AND permissions.name = 'CAN_REVEAL_SENSITIVE_VALUES';
+
+DELETE FROM permissions
+WHERE name = 'CAN_REVEAL_SENSITIVE_VALUES';
+
+ALTER TABLE config_keys
+DROP COLUMN sensitive;
+
+COMMIT;
@@ -0,0 +1,18 @@
+BEGIN;
+
+ALTER TABLE config_keys
+ADD COLUMN sensitive boolean NOT NULL DEFAULT false;
+
+INSERT INTO permissions (name) VALUES
+    ('CAN_REVEAL_SENSITIVE_VALUES');
+
+INSERT INTO permissions_to_roles (permission_id, role_id)
+SELECT permissions.id, roles.id
+FROM roles
+JOIN permissions
+ON (
+    roles.name = 'Administrator' AND
+    permissions.name = 'CAN_REVEAL_SENSITIVE_VALUES'
+);
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+-- Hashed tokens can't be turned back into tokens so they are all dropped.
+DELETE FROM api_tokens;
+
+ALTER TABLE api_tokens DROP COLUMN token_hash;
+ALTER TABLE api_tokens ADD COLUMN token TEXT NOT NULL UNIQUE CONSTRAINT token_not_empty CHECK (token <> '');
+
+ALTER TABLE api_tokens DROP COLUMN revoked_at;
+ALTER TABLE api_tokens DROP COLUMN last_used_at;
+ALTER TABLE api_tokens DROP COLUMN expires_at;
+ALTER TABLE api_tokens DROP COLUMN name;
+ALTER TABLE api_tokens DROP COLUMN id;
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+ALTER TABLE api_tokens ADD COLUMN id SERIAL PRIMARY KEY;
+ALTER TABLE api_tokens ADD COLUMN name TEXT NOT NULL DEFAULT '';
+ALTER TABLE api_tokens ADD COLUMN expires_at timestamp;
+ALTER TABLE api_tokens ADD COLUMN last_used_at timestamp;
+ALTER TABLE api_tokens ADD COLUMN revoked_at timestamp;
+
+-- Tokens are only stored as a hash from now on, existing tokens keep working.
+ALTER TABLE api_tokens ADD COLUMN token_hash TEXT;
+UPDATE api_tokens SET token_hash = encode(sha256(convert_to(token, 'UTF8')), 'hex');
+ALTER TABLE api_tokens ALTER COLUMN token_hash SET NOT NULL;
+ALTER TABLE api_tokens ADD CONSTRAINT api_tokens_token_hash_key UNIQUE (token_hash);
+ALTER TABLE api_tokens DROP COLUMN token;
+
+COMMIT;
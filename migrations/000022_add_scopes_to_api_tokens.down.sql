@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE api_tokens
+DROP COLUMN scopes;
+
+COMMIT;
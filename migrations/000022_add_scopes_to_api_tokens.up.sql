@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE api_tokens
+ADD COLUMN scopes JSONB NOT NULL DEFAULT '[]';
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+CREATE TABLE sessions (
+    id TEXT PRIMARY KEY,
+    user_id integer NOT NULL,
+    refresh_token_id TEXT NOT NULL,
+    created_at timestamp NOT NULL DEFAULT current_timestamp,
+    refreshed_at timestamp,
+    revoked_at timestamp
+);
+CREATE INDEX ON sessions(user_id);
+
+COMMIT;
@@ -0,0 +1,11 @@
+BEGIN;
+
+CREATE TABLE signing_keys (
+    id TEXT PRIMARY KEY,
+    algorithm TEXT NOT NULL,
+    private_key TEXT NOT NULL CONSTRAINT private_key_not_empty CHECK (private_key <> ''),
+    created_at timestamptz NOT NULL DEFAULT current_timestamp,
+    active_at timestamptz NOT NULL DEFAULT current_timestamp
+);
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+CREATE TABLE password_reset_tokens (
+    token_hash TEXT PRIMARY KEY,
+    user_id integer NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT current_timestamp,
+    expires_at timestamptz NOT NULL,
+    used_at timestamptz
+);
+CREATE INDEX ON password_reset_tokens(user_id);
+
+COMMIT;
@@ -0,0 +1,13 @@
+BEGIN;
+
+CREATE TABLE login_failures (
+    kind TEXT NOT NULL,
+    key TEXT NOT NULL,
+    failures INT NOT NULL,
+    last_failure_at TIMESTAMPTZ NOT NULL,
+    locked_until TIMESTAMPTZ,
+
+    PRIMARY KEY (kind, key)
+);
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+DROP TABLE mfa_recovery_codes;
+DROP TABLE mfa_secrets;
+
+COMMIT;
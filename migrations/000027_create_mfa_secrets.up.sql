@@ -0,0 +1,20 @@
+BEGIN;
+
+CREATE TABLE mfa_secrets (
+    user_id integer PRIMARY KEY,
+    secret TEXT NOT NULL,
+    created_at timestamptz NOT NULL DEFAULT current_timestamp,
+    confirmed_at timestamptz,
+    -- The TOTP period the last code was used for, codes for it or earlier
+    -- periods are rejected.
+    last_used_step BIGINT NOT NULL DEFAULT 0
+);
+
+CREATE TABLE mfa_recovery_codes (
+    code_hash TEXT PRIMARY KEY,
+    user_id integer NOT NULL REFERENCES mfa_secrets(user_id) ON DELETE CASCADE,
+    used_at timestamptz
+);
+CREATE INDEX ON mfa_recovery_codes(user_id);
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE users_to_roles
+    DROP CONSTRAINT users_to_roles_user_id_fkey,
+    ADD CONSTRAINT users_to_roles_user_id_fkey FOREIGN KEY (user_id) REFERENCES users;
+
+ALTER TABLE users DROP COLUMN disabled;
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+ALTER TABLE users ADD COLUMN disabled boolean NOT NULL DEFAULT false;
+
+-- Otherwise users with roles can't be deleted.
+ALTER TABLE users_to_roles
+    DROP CONSTRAINT users_to_roles_user_id_fkey,
+    ADD CONSTRAINT users_to_roles_user_id_fkey FOREIGN KEY (user_id) REFERENCES users ON DELETE CASCADE;
+
+COMMIT;
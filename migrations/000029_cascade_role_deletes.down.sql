@@ -0,0 +1,11 @@
+BEGIN;
+
+ALTER TABLE users_to_roles
+    DROP CONSTRAINT users_to_roles_role_id_fkey,
+    ADD CONSTRAINT users_to_roles_role_id_fkey FOREIGN KEY (role_id) REFERENCES roles;
+
+ALTER TABLE permissions_to_roles
+    DROP CONSTRAINT permissions_to_roles_role_id_fkey,
+    ADD CONSTRAINT permissions_to_roles_role_id_fkey FOREIGN KEY (role_id) REFERENCES roles;
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+-- Deleting a role removes its permissions and unassigns it from users.
+ALTER TABLE permissions_to_roles
+    DROP CONSTRAINT permissions_to_roles_role_id_fkey,
+    ADD CONSTRAINT permissions_to_roles_role_id_fkey FOREIGN KEY (role_id) REFERENCES roles ON DELETE CASCADE;
+
+ALTER TABLE users_to_roles
+    DROP CONSTRAINT users_to_roles_role_id_fkey,
+    ADD CONSTRAINT users_to_roles_role_id_fkey FOREIGN KEY (role_id) REFERENCES roles ON DELETE CASCADE;
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+DELETE FROM permissions_to_roles
+USING permissions
+WHERE permissions_to_roles.permission_id = permissions.id
+    AND permissions.name = 'CAN_VIEW_AUDIT_LOG';
+
+DELETE FROM permissions WHERE name = 'CAN_VIEW_AUDIT_LOG';
+
+DROP TABLE audit_log;
+
+COMMIT;
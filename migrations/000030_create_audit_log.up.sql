@@ -0,0 +1,31 @@
+BEGIN;
+
+-- Entries outlive the users and resources they refer to so nothing here is a
+-- foreign key.
+CREATE TABLE audit_log (
+    id BIGSERIAL PRIMARY KEY,
+    created_at timestamptz NOT NULL DEFAULT current_timestamp,
+    actor_id integer NOT NULL,
+    actor_email TEXT NOT NULL,
+    action TEXT NOT NULL,
+    target_kind TEXT NOT NULL,
+    target_id TEXT NOT NULL,
+    before jsonb,
+    after jsonb,
+    request_id TEXT NOT NULL
+);
+
+CREATE INDEX audit_log_created_at ON audit_log (created_at);
+CREATE INDEX audit_log_actor_id ON audit_log (actor_id, created_at);
+CREATE INDEX audit_log_target ON audit_log (target_kind, target_id, created_at);
+
+INSERT INTO permissions (name) VALUES ('CAN_VIEW_AUDIT_LOG');
+
+-- Administrator gets all permissions.
+INSERT INTO permissions_to_roles (permission_id, role_id)
+SELECT permissions.id, roles.id
+FROM roles
+JOIN permissions
+ON (roles.name = 'Administrator' AND permissions.name = 'CAN_VIEW_AUDIT_LOG');
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+DELETE FROM service_role_permissions
+USING permissions
+WHERE service_role_permissions.permission_id = permissions.id
+    AND service_role_permissions.role = 'OWNER'
+    AND permissions.name = 'CAN_REVEAL_SENSITIVE_VALUES';
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+-- Owners can reveal the sensitive values of their own service.
+INSERT INTO service_role_permissions (role, permission_id)
+SELECT 'OWNER', permissions.id
+FROM permissions
+WHERE permissions.name = 'CAN_REVEAL_SENSITIVE_VALUES'
+ON CONFLICT DO NOTHING;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE oidc_identities;
+
+COMMIT;
@@ -0,0 +1,15 @@
+BEGIN;
+
+-- Links the subject an identity provider asserts to a CDB user so that logins
+-- are matched on the provider's stable identifier rather than an email. A
+-- NULL subject is a pending link for a user created ahead of their first
+-- login which that login claims.
+CREATE TABLE oidc_identities (
+    user_id integer NOT NULL REFERENCES users ON DELETE CASCADE,
+    issuer TEXT NOT NULL,
+    subject TEXT,
+    PRIMARY KEY (user_id, issuer),
+    UNIQUE (issuer, subject)
+);
+
+COMMIT;
@@ -0,0 +1,12 @@
+BEGIN;
+
+DROP INDEX services_name_key;
+ALTER TABLE services ADD CONSTRAINT services_name_key UNIQUE (name);
+
+DROP INDEX config_keys_service_id_name_key;
+ALTER TABLE config_keys ADD CONSTRAINT config_keys_service_id_name_key UNIQUE (service_id, name);
+
+DROP INDEX environments_service_id_name_key;
+ALTER TABLE environments ADD CONSTRAINT environments_service_id_name_key UNIQUE (service_id, name);
+
+COMMIT;
@@ -0,0 +1,16 @@
+BEGIN;
+
+-- Items in the trash shouldn't stop their name from being reused.
+ALTER TABLE environments DROP CONSTRAINT environments_service_id_name_key;
+CREATE UNIQUE INDEX environments_service_id_name_key ON environments (service_id, name)
+WHERE deleted_at IS NULL;
+
+ALTER TABLE config_keys DROP CONSTRAINT config_keys_service_id_name_key;
+CREATE UNIQUE INDEX config_keys_service_id_name_key ON config_keys (service_id, name)
+WHERE deleted_at IS NULL;
+
+ALTER TABLE services DROP CONSTRAINT services_name_key;
+CREATE UNIQUE INDEX services_name_key ON services (name)
+WHERE deleted_at IS NULL;
+
+COMMIT;
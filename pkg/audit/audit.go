@@ -0,0 +1,282 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+var ErrInvalidFilter = errors.New("invalid audit log filter")
+
+const (
+	// DefaultLimit is how many entries List returns when the filter doesn't
+	// set a limit.
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// The kinds of things entries can target.
+const (
+	TargetConfigValue = "config_value"
+	TargetConfigKey   = "config_key"
+	TargetEnvironment = "environment"
+	TargetOverlay     = "overlay"
+	TargetService     = "service"
+	TargetUser        = "user"
+	TargetRole        = "role"
+	TargetAPIToken    = "api_token"
+	TargetLogin       = "login"
+
+	TargetScheduledChange = "scheduled_change"
+)
+
+// The actions which are recorded, they're prefixed with the kind of thing they
+// change.
+const (
+	ActionConfigValueSet          = "config_value.set"
+	ActionConfigValueCreate       = "config_value.create"
+	ActionConfigValueReveal       = "config_value.reveal"
+	ActionConfigValueRevealDenied = "config_value.reveal_denied"
+
+	ActionConfigKeyCreate  = "config_key.create"
+	ActionConfigKeyDelete  = "config_key.delete"
+	ActionConfigKeyRestore = "config_key.restore"
+
+	ActionEnvironmentCreate       = "environment.create"
+	ActionEnvironmentUpdate       = "environment.update"
+	ActionEnvironmentDelete       = "environment.delete"
+	ActionEnvironmentRestore      = "environment.restore"
+	ActionEnvironmentClone        = "environment.clone"
+	ActionEnvironmentGrantAccess  = "environment.grant_access"
+	ActionEnvironmentRevokeAccess = "environment.revoke_access"
+
+	ActionEphemeralEnvironmentCreate = "environment.create_ephemeral"
+	ActionEphemeralEnvironmentExtend = "environment.extend"
+	ActionEphemeralEnvironmentReap   = "environment.reap"
+
+	ActionScheduledChangeCreate     = "scheduled_change.create"
+	ActionScheduledChangeCancel     = "scheduled_change.cancel"
+	ActionScheduledChangeReschedule = "scheduled_change.reschedule"
+	ActionScheduledChangeApply      = "scheduled_change.apply"
+
+	ActionOverlayCreate = "overlay.create"
+	ActionOverlayDelete = "overlay.delete"
+
+	ActionServiceCreate     = "service.create"
+	ActionServiceDelete     = "service.delete"
+	ActionServiceRestore    = "service.restore"
+	ActionServiceAssignRole = "service.assign_role"
+	ActionServiceRemoveRole = "service.remove_role"
+
+	ActionUserRegister           = "user.register"
+	ActionUserCreate             = "user.create"
+	ActionUserDelete             = "user.delete"
+	ActionUserDisable            = "user.disable"
+	ActionUserEnable             = "user.enable"
+	ActionUserChangePassword     = "user.change_password"
+	ActionUserStartPasswordReset = "user.start_password_reset"
+	ActionUserResetPassword      = "user.reset_password"
+	ActionUserEnrolMFA           = "user.enrol_mfa"
+	ActionUserResetMFA           = "user.reset_mfa"
+	ActionUserAssignRole         = "user.assign_role"
+	ActionUserRemoveRole         = "user.remove_role"
+	ActionUserProvision          = "user.provision"
+	ActionUserLinkIdentity       = "user.link_identity"
+	ActionUserSyncRoles          = "user.sync_roles"
+
+	ActionRoleCreate            = "role.create"
+	ActionRoleDelete            = "role.delete"
+	ActionRoleGrantPermissions  = "role.grant_permissions"
+	ActionRoleRevokePermissions = "role.revoke_permissions"
+
+	ActionAPITokenIssue  = "api_token.issue"
+	ActionAPITokenRevoke = "api_token.revoke"
+
+	ActionLoginUnlock = "login.unlock"
+)
+
+// Actor is whoever made a change, auth.User implements it.
+type Actor interface {
+	AuditActor() (id int, email string)
+}
+
+type systemActor struct{}
+
+func (systemActor) AuditActor() (int, string) {
+	return 0, "system"
+}
+
+// System is the actor for changes CDB makes by itself, like reaping expired
+// environments.
+var System Actor = systemActor{}
+
+// Event is a change to record.
+type Event struct {
+	Action     string
+	TargetKind string
+	TargetID   string
+	// Before and After are the target's state either side of the change and
+	// are stored as JSON. Either can be nil, for example Before when the
+	// target was created. Secrets must be removed by the caller.
+	Before any
+	After  any
+}
+
+// Entry is a recorded Event.
+type Entry struct {
+	ID         int64           `db:"id"`
+	CreatedAt  time.Time       `db:"created_at"`
+	ActorID    int             `db:"actor_id"`
+	ActorEmail string          `db:"actor_email"`
+	Action     string          `db:"action"`
+	TargetKind string          `db:"target_kind"`
+	TargetID   string          `db:"target_id"`
+	Before     json.RawMessage `db:"before"`
+	After      json.RawMessage `db:"after"`
+	RequestID  string          `db:"request_id"`
+}
+
+// Filter narrows the entries returned by List, nil fields match everything.
+// Entries are returned newest first so BeforeID can be set to the last ID of
+// a page to get the next one.
+type Filter struct {
+	ActorID    *int
+	ActorEmail *string
+	Action     *string
+	TargetKind *string
+	TargetID   *string
+	Since      *time.Time
+	Until      *time.Time
+	BeforeID   *int64
+	Limit      int
+}
+
+func (f Filter) Valid() error {
+	if f.Limit < 0 || f.Limit > MaxLimit {
+		return fmt.Errorf("%w: limit must be between 0 and %d", ErrInvalidFilter, MaxLimit)
+	}
+
+	if f.Since != nil && f.Until != nil && !f.Since.Before(*f.Until) {
+		return fmt.Errorf("%w: since must be before until", ErrInvalidFilter)
+	}
+
+	return nil
+}
+
+//go:embed queries/create_entry.sql
+var createEntrySql string
+
+//go:embed queries/list_entries.sql
+var listEntriesSql string
+
+// Log is the durable audit log. A nil Log records nothing.
+type Log struct {
+	pool *pgxpool.Pool
+	log  zerolog.Logger
+}
+
+func NewLog(log zerolog.Logger, pool *pgxpool.Pool) *Log {
+	return &Log{
+		pool: pool,
+		log:  log,
+	}
+}
+
+func marshalState(state any) (json.RawMessage, error) {
+	if state == nil {
+		return nil, nil
+	}
+
+	marshalled, err := json.Marshal(state)
+	if err != nil || bytes.Equal(marshalled, []byte("null")) {
+		return nil, err
+	}
+
+	return marshalled, nil
+}
+
+// Record stores event along with the request ID from ctx. It's called once
+// the change has been made so failing to store the entry doesn't fail the
+// change, instead the entry is logged so that it isn't lost.
+func (l *Log) Record(ctx context.Context, actor Actor, event Event) {
+	if l == nil {
+		return
+	}
+
+	actorID, actorEmail := actor.AuditActor()
+	before, err := marshalState(event.Before)
+	if err == nil {
+		var after json.RawMessage
+		after, err = marshalState(event.After)
+		if err == nil {
+			// The change has been made even if the request has been
+			// cancelled since.
+			_, err = l.pool.Exec(
+				context.WithoutCancel(ctx),
+				createEntrySql,
+				actorID,
+				actorEmail,
+				event.Action,
+				event.TargetKind,
+				event.TargetID,
+				before,
+				after,
+				RequestID(ctx),
+			)
+		}
+	}
+
+	if err != nil {
+		l.log.Error().
+			Err(err).
+			Int("actorID", actorID).
+			Str("actorEmail", actorEmail).
+			Str("action", event.Action).
+			Str("targetKind", event.TargetKind).
+			Str("targetID", event.TargetID).
+			Interface("before", event.Before).
+			Interface("after", event.After).
+			Str("requestID", RequestID(ctx)).
+			Bool("audit", true).
+			Msg("failed to record audit log entry")
+	}
+}
+
+// List returns the entries matching filter, newest first.
+func (l *Log) List(ctx context.Context, filter Filter) ([]Entry, error) {
+	if err := filter.Valid(); err != nil {
+		return nil, err
+	}
+
+	if l == nil {
+		return []Entry{}, nil
+	}
+
+	limit := filter.Limit
+	if limit == 0 {
+		limit = DefaultLimit
+	}
+
+	return postgresutils.GetAll[Entry](
+		l.pool,
+		ctx,
+		listEntriesSql,
+		filter.ActorID,
+		filter.ActorEmail,
+		filter.Action,
+		filter.TargetKind,
+		filter.TargetID,
+		filter.Since,
+		filter.Until,
+		filter.BeforeID,
+		limit,
+	)
+}
@@ -0,0 +1,165 @@
+package audit_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/config-source/cdb/pkg/audit"
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/rs/zerolog"
+)
+
+type testActor struct {
+	id    int
+	email string
+}
+
+func (ta testActor) AuditActor() (int, string) {
+	return ta.id, ta.email
+}
+
+func TestFilterValid(t *testing.T) {
+	now := time.Now()
+	earlier := now.Add(-time.Hour)
+
+	invalid := map[string]audit.Filter{
+		"negative limit":       {Limit: -1},
+		"limit over maximum":   {Limit: audit.MaxLimit + 1},
+		"since after until":    {Since: &now, Until: &earlier},
+		"since equal to until": {Since: &now, Until: &now},
+	}
+
+	for name, filter := range invalid {
+		if err := filter.Valid(); !errors.Is(err, audit.ErrInvalidFilter) {
+			t.Errorf("%s: Expected %s got: %v", name, audit.ErrInvalidFilter, err)
+		}
+	}
+
+	if err := (audit.Filter{Since: &earlier, Until: &now, Limit: audit.MaxLimit}).Valid(); err != nil {
+		t.Errorf("Expected filter to be valid got: %s", err)
+	}
+}
+
+func TestRecordAndList(t *testing.T) {
+	pool := postgresutils.InitTestDB(t)
+	log := audit.NewLog(zerolog.New(nil).Level(zerolog.Disabled), pool)
+
+	alice := testActor{id: 1, email: "alice@example.com"}
+	bob := testActor{id: 2, email: "bob@example.com"}
+
+	ctx := audit.WithRequestID(context.Background(), "request-1")
+	log.Record(ctx, alice, audit.Event{
+		Action:     audit.ActionEnvironmentCreate,
+		TargetKind: audit.TargetEnvironment,
+		TargetID:   "1",
+		After:      map[string]string{"Name": "production"},
+	})
+	log.Record(ctx, bob, audit.Event{
+		Action:     audit.ActionEnvironmentDelete,
+		TargetKind: audit.TargetEnvironment,
+		TargetID:   "1",
+		Before:     map[string]string{"Name": "production"},
+	})
+	log.Record(ctx, alice, audit.Event{
+		Action:     audit.ActionRoleCreate,
+		TargetKind: audit.TargetRole,
+		TargetID:   "Auditor",
+	})
+
+	entries, err := log.List(context.Background(), audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries got: %d", len(entries))
+	}
+
+	if entries[0].Action != audit.ActionRoleCreate || entries[2].Action != audit.ActionEnvironmentCreate {
+		t.Errorf("Expected entries newest first got: %s, %s", entries[0].Action, entries[2].Action)
+	}
+
+	created := entries[2]
+	if created.ActorID != alice.id || created.ActorEmail != alice.email || created.RequestID != "request-1" {
+		t.Errorf("Expected the entry to record alice and the request ID got: %+v", created)
+	}
+
+	if created.Before != nil {
+		t.Errorf("Expected no before state got: %s", created.Before)
+	}
+
+	var after map[string]string
+	if err := json.Unmarshal(created.After, &after); err != nil {
+		t.Fatal(err)
+	}
+
+	if after["Name"] != "production" {
+		t.Errorf("Expected the after state to be recorded got: %s", created.After)
+	}
+
+	aliceID := alice.id
+	byActor, err := log.List(context.Background(), audit.Filter{ActorID: &aliceID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(byActor) != 2 {
+		t.Errorf("Expected 2 entries by alice got: %d", len(byActor))
+	}
+
+	kind, id := audit.TargetEnvironment, "1"
+	byTarget, err := log.List(context.Background(), audit.Filter{TargetKind: &kind, TargetID: &id})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(byTarget) != 2 {
+		t.Errorf("Expected 2 entries for the environment got: %d", len(byTarget))
+	}
+
+	action := audit.ActionEnvironmentDelete
+	byAction, err := log.List(context.Background(), audit.Filter{Action: &action})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(byAction) != 1 || byAction[0].ActorEmail != bob.email {
+		t.Errorf("Expected only bob's delete got: %+v", byAction)
+	}
+
+	future := time.Now().Add(time.Hour)
+	bySince, err := log.List(context.Background(), audit.Filter{Since: &future})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bySince) != 0 {
+		t.Errorf("Expected no entries in the future got: %d", len(bySince))
+	}
+
+	page, err := log.List(context.Background(), audit.Filter{BeforeID: &entries[0].ID, Limit: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(page) != 1 || page[0].ID != entries[1].ID {
+		t.Errorf("Expected the next page to start at entry %d got: %+v", entries[1].ID, page)
+	}
+}
+
+func TestNilLogRecordsNothing(t *testing.T) {
+	var log *audit.Log
+	log.Record(context.Background(), testActor{id: 1}, audit.Event{Action: audit.ActionUserCreate})
+
+	entries, err := log.List(context.Background(), audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("Expected no entries got: %d", len(entries))
+	}
+}
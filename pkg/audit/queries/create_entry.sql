@@ -0,0 +1,11 @@
+INSERT INTO audit_log (
+    actor_id,
+    actor_email,
+    action,
+    target_kind,
+    target_id,
+    before,
+    after,
+    request_id
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7, $8);
@@ -0,0 +1,17 @@
+package audit
+
+import "context"
+
+type contextRequestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the ID of the request being
+// served so that it's recorded with any changes the request makes.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, contextRequestIDKey{}, requestID)
+}
+
+// RequestID returns the request ID carried by ctx or an empty string.
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(contextRequestIDKey{}).(string)
+	return requestID
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"time"
+)
+
+var (
+	ErrACLEntryNotFound = errors.New("access control entry not found")
+	ErrACLEntryExists   = errors.New("an access control entry already exists for that user or role")
+	ErrInvalidACLEntry  = errors.New("access control entry is not valid")
+)
+
+// Access is the level of access an ACLEntry grants to an environment.
+type Access string
+
+const (
+	// AccessRead allows seeing the environment and reading its configuration.
+	AccessRead Access = "read"
+	// AccessWrite allows everything AccessRead does and setting config values.
+	AccessWrite Access = "write"
+)
+
+// Allows reports whether having a grants the needed access.
+func (a Access) Allows(needed Access) bool {
+	switch a {
+	case AccessWrite:
+		return needed == AccessRead || needed == AccessWrite
+	case AccessRead:
+		return needed == AccessRead
+	default:
+		return false
+	}
+}
+
+// ACLEntry grants a single user, or every holder of a role, access to one
+// environment regardless of their other permissions.
+type ACLEntry struct {
+	ID            int     `db:"id"`
+	EnvironmentID int     `db:"environment_id"`
+	UserID        *UserID `db:"user_id"`
+	Role          *string `db:"role"`
+	Access        Access  `db:"access"`
+
+	CreatedAt time.Time `db:"created_at"`
+}
+
+func (e ACLEntry) Valid() error {
+	if (e.UserID == nil) == (e.Role == nil) {
+		return fmt.Errorf("%w: exactly one of UserID or Role must be set", ErrInvalidACLEntry)
+	}
+
+	if e.Access != AccessRead && e.Access != AccessWrite {
+		return fmt.Errorf("%w: Access must be %s or %s", ErrInvalidACLEntry, AccessRead, AccessWrite)
+	}
+
+	return nil
+}
+
+func (e ACLEntry) String() string {
+	grantee := ""
+	if e.UserID != nil {
+		grantee = fmt.Sprintf("user=%d", *e.UserID)
+	} else if e.Role != nil {
+		grantee = fmt.Sprintf("role=%s", *e.Role)
+	}
+
+	return fmt.Sprintf(
+		"ACLEntry(id=%d, environment=%d, %s, access=%s)",
+		e.ID,
+		e.EnvironmentID,
+		grantee,
+		e.Access,
+	)
+}
@@ -12,6 +12,24 @@ var (
 	ErrEmailInUse      = errors.New("email is already in use")
 	ErrUnauthorized    = errors.New("you do not have permission to perform that action")
 	ErrUnauthenticated = errors.New("no authentication information provided")
+	ErrUserDisabled    = fmt.Errorf("%w: user is disabled", ErrUnauthenticated)
+	ErrDisableSelf     = errors.New("you cannot disable or delete yourself")
+	ErrRoleNotFound    = errors.New("no role with that name exists")
+	ErrRoleExists      = errors.New("a role with that name already exists")
+	ErrRoleNameEmpty   = errors.New("role name must not be empty")
+	// ErrDefaultRole is returned when deleting the role new users are given
+	// when they register.
+	ErrDefaultRole       = errors.New("the default role for new users cannot be deleted")
+	ErrUnknownPermission = errors.New("unknown permission")
+	// ErrPasswordLoginDisabled is returned by gateways which authenticate
+	// users through an external identity provider.
+	ErrPasswordLoginDisabled = errors.New("password login is disabled, log in through the identity provider")
+	// ErrRedirectLoginUnsupported is returned when starting a redirect login
+	// with a gateway which doesn't support it.
+	ErrRedirectLoginUnsupported = errors.New("the authentication gateway does not support logging in through an identity provider")
+	// ErrIdentityConflict is returned when linking a user who is already
+	// linked to another identity at the same identity provider.
+	ErrIdentityConflict = errors.New("the user is already linked to another identity")
 )
 
 // UserID is a custom type for user IDs to force some validation and to ease
@@ -27,30 +45,101 @@ type User struct {
 	// requests have a dedicated write-only type that's stored in the api
 	// package.
 	Password string `db:"password" json:"-"`
+	// Disabled users can't login and their sessions and API tokens are
+	// revoked when they're disabled.
+	Disabled bool `db:"disabled"`
+
+	// Scopes restrict what the user can do when they authenticate with a
+	// scoped API token, they are carried in the token's claims.
+	Scopes Scopes `db:"-" json:",omitempty"`
 }
 
 func (u User) String() string {
 	return fmt.Sprintf("User(email=%s)", u.Email)
 }
 
+// AuditActor implements audit.Actor.
+func (u User) AuditActor() (int, string) {
+	return int(u.ID), u.Email
+}
+
+// ServiceRole is a role a user holds on a single service. Unlike the roles
+// returned by GetRolesForUser its permissions only apply to that service's
+// environments and config keys.
+type ServiceRole struct {
+	UserID    UserID `db:"user_id"`
+	ServiceID int    `db:"service_id"`
+	Role      string `db:"role"`
+}
+
+// Role is a global role and the permissions it grants.
+type Role struct {
+	Name        string       `db:"name"`
+	Permissions []Permission `db:"permissions"`
+}
+
 // AuthenticationGateway must be implemented by any source of authentication in
 // CDB.
 //
-// Currently only internal DB-based authentication is supported. But this
-// interface is here so we can implement it for other backends later like LDAP
-// etc.
+// Gateways which authenticate against an external identity provider may also
+// implement RedirectAuthenticator and BearerTokenAuthenticator.
 type AuthenticationGateway interface {
 	Register(ctx context.Context, email, password string) (User, error)
 	Login(ctx context.Context, email, password string) (User, error)
 
 	CreateUser(ctx context.Context, newUser User) (User, error)
 	GetUser(ctx context.Context, userID UserID) (User, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
 	DeleteUser(ctx context.Context, userID UserID) error
 	ListUsers(ctx context.Context) ([]User, error)
+	SetUserDisabled(ctx context.Context, userID UserID, disabled bool) (User, error)
 
 	Healthy(context.Context) bool
 }
 
+// RedirectLogin is a login started with a RedirectAuthenticator. Everything
+// but the URL must be kept by the caller for the callback.
+type RedirectLogin struct {
+	// URL is where to send the user's browser.
+	URL string
+	// State must match the state the identity provider returns.
+	State string
+	// Nonce binds the ID token to this login.
+	Nonce        string
+	CodeVerifier string
+}
+
+// RedirectAuthenticator is implemented by AuthenticationGateways which log
+// users in by redirecting their browser to an identity provider.
+type RedirectAuthenticator interface {
+	StartLogin() (RedirectLogin, error)
+	// FinishLogin exchanges the code the identity provider returned for the
+	// user it identifies, checking it against the login's code verifier and
+	// nonce.
+	FinishLogin(ctx context.Context, code, codeVerifier, nonce string) (User, error)
+}
+
+// IdentityLinker stores which user the subject an identity provider asserts
+// belongs to.
+type IdentityLinker interface {
+	// GetIdentityUser returns the user linked to subject at issuer or
+	// ErrUserNotFound.
+	GetIdentityUser(ctx context.Context, issuer, subject string) (UserID, error)
+	// LinkIdentity links subject at issuer to the user, an empty subject is a
+	// pending link which the user's first login claims with ClaimIdentity.
+	// Returns ErrIdentityConflict if the user is already linked at issuer.
+	LinkIdentity(ctx context.Context, issuer, subject string, userID UserID) error
+	// ClaimIdentity completes the user's pending link at issuer, reporting
+	// whether there was one.
+	ClaimIdentity(ctx context.Context, issuer, subject string, userID UserID) (bool, error)
+}
+
+// BearerTokenAuthenticator is implemented by AuthenticationGateways which
+// accept tokens issued by someone other than CDB on API calls.
+type BearerTokenAuthenticator interface {
+	AuthenticateBearerToken(ctx context.Context, token string) (User, error)
+}
+
 // AuthorizationGateway must be implemented by any source of authorization in
 // CDB.
 //
@@ -58,9 +147,21 @@ type AuthenticationGateway interface {
 // interface is here so we can implement it for other backends later like LDAP
 // etc.
 type AuthorizationGateway interface {
+	// HasPermission reports whether the actor has any of the permissions
+	// through a global role.
 	HasPermission(ctx context.Context, actor User, permission Permission, additionalPermissions ...Permission) (bool, error)
+	// HasServicePermission is like HasPermission but also considers the
+	// actor's roles on the service with serviceID.
+	HasServicePermission(ctx context.Context, actor User, serviceID int, permission Permission, additionalPermissions ...Permission) (bool, error)
+	// HasPermissionOnAnyService reports whether the user has any of the
+	// permissions through a role on any service. Token scopes aren't
+	// considered since it's used for decisions about the user themselves.
+	HasPermissionOnAnyService(ctx context.Context, user User, permission Permission, additionalPermissions ...Permission) (bool, error)
 
+	ListRoles(ctx context.Context, actor User) ([]Role, error)
 	CreateRole(ctx context.Context, actor User, role string, permissions []Permission) error
+	// DeleteRole removes the role from every user it's assigned to.
+	DeleteRole(ctx context.Context, actor User, role string) error
 	AddPermissionsToRole(ctx context.Context, actor User, role string, permissions []Permission) error
 	RemovePermissionsFromRole(ctx context.Context, actor User, role string, permissions []Permission) error
 	GetPermissionsForRole(ctx context.Context, actor User, role string) ([]Permission, error)
@@ -68,7 +169,29 @@ type AuthorizationGateway interface {
 	GetRolesForUser(ctx context.Context, actor User, user User) ([]string, error)
 	AssignRoleToUser(ctx context.Context, actor User, user User, role string) error
 	AssignRoleToUserNoAuth(ctx context.Context, user User, role string) error
+	// SyncRolesNoAuth grants the user every role in granted and removes the
+	// roles in managed which aren't granted, other roles are left alone. It's
+	// used to apply roles mapped from an external identity provider. The
+	// user's sorted roles before and after the sync are returned so that
+	// changes can be audited.
+	SyncRolesNoAuth(ctx context.Context, user User, managed, granted []string) (before, after []string, err error)
 	RemoveRoleFromUser(ctx context.Context, actor User, user User, role string) error
 
+	ListServiceRoles(ctx context.Context, actor User, serviceID int) ([]ServiceRole, error)
+	AssignServiceRole(ctx context.Context, actor User, user User, serviceID int, role string) error
+	RemoveServiceRole(ctx context.Context, actor User, user User, serviceID int) error
+
+	// GetEnvironmentAccess returns the access the actor has been granted by
+	// environment ACL entries keyed by environment ID.
+	GetEnvironmentAccess(ctx context.Context, actor User) (map[int]Access, error)
+	// HasSensitiveWriteAccess reports whether an environment ACL entry grants
+	// the user write access to any sensitive environment.
+	HasSensitiveWriteAccess(ctx context.Context, user User) (bool, error)
+	// The environment ACL methods don't check permissions, callers must
+	// ensure the actor can manage the environment.
+	ListEnvironmentACL(ctx context.Context, environmentID int) ([]ACLEntry, error)
+	CreateEnvironmentACLEntry(ctx context.Context, entry ACLEntry) (ACLEntry, error)
+	DeleteEnvironmentACLEntry(ctx context.Context, environmentID, id int) error
+
 	Healthy(context.Context) bool
 }
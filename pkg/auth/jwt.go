@@ -2,6 +2,9 @@ package auth
 
 import (
 	"errors"
+	"fmt"
+	"slices"
+	"strconv"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -9,9 +12,20 @@ import (
 
 var (
 	TokenIssuer  = "cdb"
-	validMethods = []string{"HS512"}
+	validMethods = []string{"HS512", "RS256", "EdDSA"}
 )
 
+const (
+	// APITokenAudience is the audience of API tokens, it's what tells them
+	// apart from ID tokens so their revocation and expiry can be checked.
+	APITokenAudience = "cdb-api"
+	// RefreshTokenAudience stops refresh tokens being used as ID tokens and
+	// the other way around.
+	RefreshTokenAudience = "cdb-refresh"
+)
+
+var ErrRefreshTokenInvalid = fmt.Errorf("%w: refresh token is invalid", ErrUnauthenticated)
+
 // TokenSet is a set of JWT tokens for use as Authentication and Authorisation.
 type TokenSet struct {
 	IDToken      string
@@ -19,10 +33,12 @@ type TokenSet struct {
 	RefreshToken string
 }
 
-func GenerateTokens(signingKey []byte, user User) (TokenSet, error) {
-	idToken, idErr := GenerateIdToken(signingKey, user)
-	accessToken, accessErr := GenerateAccessToken(signingKey, user)
-	refreshToken, refreshErr := GenerateRefreshToken(signingKey)
+// GenerateTokens issues the tokens for a session, see
+// TokenRegistry.StartSession.
+func GenerateTokens(keys *KeySet, user User, session Session) (TokenSet, error) {
+	idToken, idErr := generateIdToken(keys, user, session.ID)
+	accessToken, accessErr := GenerateAccessToken(keys, user)
+	refreshToken, refreshErr := GenerateRefreshToken(keys, user, session)
 	return TokenSet{
 		IDToken:      idToken,
 		AccessToken:  accessToken,
@@ -30,34 +46,61 @@ func GenerateTokens(signingKey []byte, user User) (TokenSet, error) {
 	}, errors.Join(idErr, accessErr, refreshErr)
 }
 
-func ValidateRefreshToken(signingKey []byte, refreshToken string) (bool, error) {
-	_, err := jwt.ParseWithClaims(
+// RefreshClaims are the claims of a refresh token. The subject is the ID of
+// the user it was issued to and the token ID is the session's current refresh
+// token ID, which changes every time the session is refreshed.
+type RefreshClaims struct {
+	SessionID string
+	jwt.RegisteredClaims
+}
+
+// UserID returns the ID of the user the refresh token was issued to.
+func (c RefreshClaims) UserID() (UserID, error) {
+	id, err := strconv.Atoi(c.Subject)
+	if err != nil {
+		return 0, fmt.Errorf("%w: refresh token has no valid subject", ErrRefreshTokenInvalid)
+	}
+
+	return UserID(id), nil
+}
+
+func ValidateRefreshToken(keys *KeySet, refreshToken string) (RefreshClaims, error) {
+	token, err := jwt.ParseWithClaims(
 		refreshToken,
-		nil,
-		func(token *jwt.Token) (interface{}, error) {
-			return signingKey, nil
-		},
+		&RefreshClaims{},
+		keys.keyFunc,
 		jwt.WithValidMethods(validMethods),
 		jwt.WithIssuer(TokenIssuer),
+		jwt.WithAudience(RefreshTokenAudience),
 		jwt.WithExpirationRequired(),
 	)
-	return err == nil, err
-}
+	if err != nil {
+		return RefreshClaims{}, fmt.Errorf("%w: %w", ErrRefreshTokenInvalid, err)
+	}
 
-func GenerateRefreshToken(signingKey []byte) (string, error) {
-	claims := jwt.RegisteredClaims{
-		Issuer: TokenIssuer,
-		// Expires in two days
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * 2 * time.Hour)),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
+	claims, ok := token.Claims.(*RefreshClaims)
+	if !ok || claims.SessionID == "" || claims.ID == "" {
+		return RefreshClaims{}, ErrRefreshTokenInvalid
 	}
 
-	token := jwt.NewWithClaims(
-		jwt.SigningMethodHS512,
-		claims,
-	)
+	return *claims, nil
+}
+
+func GenerateRefreshToken(keys *KeySet, user User, session Session) (string, error) {
+	claims := RefreshClaims{
+		SessionID: session.ID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:   TokenIssuer,
+			Subject:  strconv.Itoa(int(user.ID)),
+			Audience: jwt.ClaimStrings{RefreshTokenAudience},
+			ID:       session.RefreshTokenID,
+			// Expires in two days
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * 2 * time.Hour)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+		},
+	}
 
-	return token.SignedString(signingKey)
+	return keys.sign(claims)
 }
 
 type AccessClaims struct {
@@ -65,13 +108,11 @@ type AccessClaims struct {
 	jwt.RegisteredClaims
 }
 
-func ValidateAccessToken(signingKey []byte, accessToken string) (string, error) {
+func ValidateAccessToken(keys *KeySet, accessToken string) (string, error) {
 	token, err := jwt.ParseWithClaims(
 		accessToken,
 		&IDClaims{},
-		func(token *jwt.Token) (interface{}, error) {
-			return signingKey, nil
-		},
+		keys.keyFunc,
 		jwt.WithValidMethods(validMethods),
 		jwt.WithIssuer(TokenIssuer),
 		jwt.WithExpirationRequired(),
@@ -87,7 +128,7 @@ func ValidateAccessToken(signingKey []byte, accessToken string) (string, error)
 	return "", errors.New("unrecognized token claims")
 }
 
-func GenerateAccessToken(signingKey []byte, user User) (string, error) {
+func GenerateAccessToken(keys *KeySet, user User) (string, error) {
 	claims := AccessClaims{
 		Email: user.Email,
 		RegisteredClaims: jwt.RegisteredClaims{
@@ -97,43 +138,59 @@ func GenerateAccessToken(signingKey []byte, user User) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(
-		jwt.SigningMethodHS512,
-		claims,
-	)
-
-	return token.SignedString(signingKey)
+	return keys.sign(claims)
 }
 
 type IDClaims struct {
 	User
+	// SessionID is the session the token was issued for, ID tokens are
+	// rejected once it's revoked. API tokens don't have one.
+	SessionID string `json:",omitempty"`
 	jwt.RegisteredClaims
 }
 
-func ValidateIdToken(signingKey []byte, idToken string) (User, error) {
+// IsAPIToken reports whether the claims are for an API token. API tokens
+// issued before they had an audience never expire, unlike ID tokens, so they
+// are recognised by that instead.
+func (c IDClaims) IsAPIToken() bool {
+	return slices.Contains(c.Audience, APITokenAudience) || c.ExpiresAt == nil
+}
+
+func ValidateIdToken(keys *KeySet, idToken string) (User, error) {
+	claims, err := ValidateIdTokenClaims(keys, idToken)
+	return claims.User, err
+}
+
+// ValidateIdTokenClaims validates an ID or API token returning all of its
+// claims.
+func ValidateIdTokenClaims(keys *KeySet, idToken string) (IDClaims, error) {
 	token, err := jwt.ParseWithClaims(
 		idToken,
 		&IDClaims{},
-		func(token *jwt.Token) (interface{}, error) {
-			return signingKey, nil
-		},
+		keys.keyFunc,
 		jwt.WithValidMethods(validMethods),
 		jwt.WithIssuer(TokenIssuer),
 	)
 	if err != nil {
-		return User{}, err
+		return IDClaims{}, err
 	}
 
-	if claims, ok := token.Claims.(*IDClaims); ok {
-		return claims.User, nil
+	claims, ok := token.Claims.(*IDClaims)
+	if !ok {
+		return IDClaims{}, errors.New("unrecognized token claims")
 	}
 
-	return User{}, errors.New("unrecognized token claims")
+	if slices.Contains(claims.Audience, RefreshTokenAudience) {
+		return IDClaims{}, fmt.Errorf("%w: refresh tokens cannot be used for authentication", ErrUnauthenticated)
+	}
+
+	return *claims, nil
 }
 
-func GenerateIdToken(signingKey []byte, user User) (string, error) {
+func generateIdToken(keys *KeySet, user User, sessionID string) (string, error) {
 	claims := IDClaims{
-		User: user,
+		User:      user,
+		SessionID: sessionID,
 		RegisteredClaims: jwt.RegisteredClaims{
 			Issuer:    TokenIssuer,
 			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
@@ -141,28 +198,24 @@ func GenerateIdToken(signingKey []byte, user User) (string, error) {
 		},
 	}
 
-	token := jwt.NewWithClaims(
-		jwt.SigningMethodHS512,
-		claims,
-	)
-
-	return token.SignedString(signingKey)
+	return keys.sign(claims)
 }
 
-func GenerateAPIToken(signingKey []byte, user User) (string, error) {
+// GenerateAPIToken mints an API token for user, when expiresAt is nil the
+// token lasts until it's revoked.
+func GenerateAPIToken(keys *KeySet, user User, expiresAt *time.Time) (string, error) {
 	claims := IDClaims{
 		User: user,
 		RegisteredClaims: jwt.RegisteredClaims{
-			Issuer: TokenIssuer,
-			// ExpiresAt omitted so the token is forever
+			Issuer:   TokenIssuer,
+			Audience: jwt.ClaimStrings{APITokenAudience},
 			IssuedAt: jwt.NewNumericDate(time.Now()),
 		},
 	}
 
-	token := jwt.NewWithClaims(
-		jwt.SigningMethodHS512,
-		claims,
-	)
+	if expiresAt != nil {
+		claims.ExpiresAt = jwt.NewNumericDate(*expiresAt)
+	}
 
-	return token.SignedString(signingKey)
+	return keys.sign(claims)
 }
@@ -1,29 +1,37 @@
 package auth_test
 
 import (
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/config-source/cdb/pkg/auth"
 )
 
 func TestCanIssueAndValidateIdToken(t *testing.T) {
-	signingKey := []byte("testing")
+	signingKey := auth.NewTestKeySet("testing")
 	user := auth.User{
 		ID:       1,
 		Email:    "test@example.com",
 		Password: "test123",
 	}
 
-	token, err := auth.GenerateIdToken(signingKey, user)
+	tokens, err := auth.GenerateTokens(signingKey, user, auth.Session{ID: "session"})
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	validatedUser, err := auth.ValidateIdToken(signingKey, token)
+	claims, err := auth.ValidateIdTokenClaims(signingKey, tokens.IDToken)
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	if claims.SessionID != "session" {
+		t.Errorf("Expected the token to be bound to the session got: %q", claims.SessionID)
+	}
+
+	validatedUser := claims.User
+
 	if validatedUser.ID != user.ID {
 		t.Errorf("Expected ID to match %d got: %d", user.ID, validatedUser.ID)
 	}
@@ -36,3 +44,87 @@ func TestCanIssueAndValidateIdToken(t *testing.T) {
 		t.Errorf("Expected password to be erased got: %s", validatedUser.Password)
 	}
 }
+
+func TestAPITokensAreToldApartFromIdTokens(t *testing.T) {
+	signingKey := auth.NewTestKeySet("testing")
+	user := auth.User{ID: 1, Email: "test@example.com"}
+
+	tokens, err := auth.GenerateTokens(signingKey, user, auth.Session{ID: "session"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := auth.ValidateIdTokenClaims(signingKey, tokens.IDToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.IsAPIToken() {
+		t.Error("Expected an ID token to not be an API token")
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	for _, expiry := range []*time.Time{nil, &expiresAt} {
+		apiToken, err := auth.GenerateAPIToken(signingKey, user, expiry)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		claims, err := auth.ValidateIdTokenClaims(signingKey, apiToken)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !claims.IsAPIToken() || claims.User.ID != user.ID {
+			t.Errorf("Expected an API token for %s got: %v", user, claims)
+		}
+	}
+
+	expired := time.Now().Add(-time.Hour)
+	apiToken, err := auth.GenerateAPIToken(signingKey, user, &expired)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := auth.ValidateIdTokenClaims(signingKey, apiToken); err == nil {
+		t.Error("Expected an expired API token to be rejected")
+	}
+}
+
+func TestRefreshTokensAreBoundToTheirUser(t *testing.T) {
+	signingKey := auth.NewTestKeySet("testing")
+	user := auth.User{ID: 7, Email: "test@example.com"}
+	session := auth.Session{ID: "session", RefreshTokenID: "refresh"}
+
+	tokens, err := auth.GenerateTokens(signingKey, user, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims, err := auth.ValidateRefreshToken(signingKey, tokens.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userID, err := claims.UserID()
+	if err != nil || userID != user.ID || claims.SessionID != session.ID || claims.ID != session.RefreshTokenID {
+		t.Errorf("Expected the refresh token to carry the user and session got: %v %v", claims, err)
+	}
+
+	idClaims, err := auth.ValidateIdTokenClaims(signingKey, tokens.IDToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if idClaims.SessionID != session.ID {
+		t.Errorf("Expected the ID token to carry the session got: %s", idClaims.SessionID)
+	}
+
+	if _, err := auth.ValidateIdTokenClaims(signingKey, tokens.RefreshToken); !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Errorf("Expected refresh tokens to not be usable as ID tokens got: %v", err)
+	}
+
+	if _, err := auth.ValidateRefreshToken(signingKey, tokens.IDToken); !errors.Is(err, auth.ErrRefreshTokenInvalid) {
+		t.Errorf("Expected ID tokens to not be usable as refresh tokens got: %v", err)
+	}
+}
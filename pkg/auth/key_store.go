@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"time"
+
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+// KeyStore stores signing keys so that every cdbd signs and verifies tokens
+// with the same keys.
+type KeyStore struct {
+	pool *pgxpool.Pool
+	log  zerolog.Logger
+}
+
+func NewKeyStore(log zerolog.Logger, pool *pgxpool.Pool) *KeyStore {
+	return &KeyStore{
+		pool: pool,
+		log:  log,
+	}
+}
+
+const signingKeyColumns = "id, algorithm, private_key, created_at, active_at"
+
+func (ks *KeyStore) ListSigningKeys(ctx context.Context) ([]SigningKey, error) {
+	return postgresutils.GetAll[SigningKey](
+		ks.pool,
+		ctx,
+		"SELECT "+signingKeyColumns+" FROM signing_keys ORDER BY active_at",
+	)
+}
+
+// AddSigningKey generates a new key for algorithm which tokens start being
+// signed with at activeAt. Keys already in the store keep being used to
+// verify tokens.
+func (ks *KeyStore) AddSigningKey(ctx context.Context, algorithm Algorithm, activeAt time.Time) (SigningKey, error) {
+	key, err := GenerateSigningKey(algorithm)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	added, err := postgresutils.GetOne[SigningKey](
+		ks.pool,
+		ctx,
+		"INSERT INTO signing_keys (id, algorithm, private_key, active_at) VALUES ($1, $2, $3, $4) RETURNING "+signingKeyColumns,
+		key.ID,
+		key.Algorithm,
+		key.PrivateKey,
+		activeAt,
+	)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	ks.log.Info().
+		Str("keyID", added.ID).
+		Str("algorithm", string(added.Algorithm)).
+		Time("activeAt", added.ActiveAt).
+		Bool("audit", true).
+		Msg("added signing key")
+
+	return added, nil
+}
+
+// Reload loads the stored keys into keys. When keys uses an asymmetric
+// algorithm and has no active key for it one is added.
+func (ks *KeyStore) Reload(ctx context.Context, keys *KeySet) error {
+	stored, err := ks.ListSigningKeys(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := keys.Load(stored); err != nil {
+		return err
+	}
+
+	if !keys.Algorithm().Asymmetric() || keys.SigningKeyID() != "" {
+		return nil
+	}
+
+	added, err := ks.AddSigningKey(ctx, keys.Algorithm(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	return keys.Load(append(stored, added))
+}
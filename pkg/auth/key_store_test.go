@@ -0,0 +1,65 @@
+package auth_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/rs/zerolog"
+)
+
+func TestKeyStoreRotation(t *testing.T) {
+	pool := postgresutils.InitTestDB(t)
+	store := auth.NewKeyStore(zerolog.New(nil).Level(zerolog.Disabled), pool)
+	ctx := context.Background()
+
+	keys, err := auth.NewKeySet(auth.AlgorithmEdDSA, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Reload(ctx, keys); err != nil {
+		t.Fatal(err)
+	}
+
+	first := keys.SigningKeyID()
+	if first == "" {
+		t.Fatal("Expected a signing key to be added when there was none")
+	}
+
+	token, err := auth.GenerateAPIToken(keys, auth.User{ID: 1}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pending, err := store.AddSigningKey(ctx, auth.AlgorithmEdDSA, time.Now().Add(time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Reload(ctx, keys); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys.SigningKeyID() != first || len(keys.JWKS().Keys) != 2 {
+		t.Errorf("Expected %s to be published but not used yet got: %s %+v", pending.ID, keys.SigningKeyID(), keys.JWKS())
+	}
+
+	if _, err := store.AddSigningKey(ctx, auth.AlgorithmEdDSA, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Reload(ctx, keys); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys.SigningKeyID() == first {
+		t.Error("Expected the newest active key to be used")
+	}
+
+	if _, err := auth.ValidateIdToken(keys, token); err != nil {
+		t.Errorf("Expected tokens signed with the first key to still be valid got: %s", err)
+	}
+}
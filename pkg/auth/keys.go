@@ -0,0 +1,326 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	ErrUnsupportedAlgorithm = errors.New("unsupported signing algorithm, must be one of HS512, RS256 or EdDSA")
+	// ErrNoSigningKey is returned when signing with an asymmetric algorithm
+	// before any key for it is active.
+	ErrNoSigningKey = errors.New("no active signing key for the configured algorithm")
+	ErrUnknownKey   = fmt.Errorf("%w: token was signed with an unknown key", ErrUnauthenticated)
+)
+
+type Algorithm string
+
+const (
+	// AlgorithmHS512 signs tokens with the shared JWT_SIGNING_KEY.
+	AlgorithmHS512 Algorithm = "HS512"
+	AlgorithmRS256 Algorithm = "RS256"
+	AlgorithmEdDSA Algorithm = "EdDSA"
+)
+
+func (a Algorithm) Asymmetric() bool {
+	return a == AlgorithmRS256 || a == AlgorithmEdDSA
+}
+
+func (a Algorithm) Valid() error {
+	if a != AlgorithmHS512 && !a.Asymmetric() {
+		return ErrUnsupportedAlgorithm
+	}
+
+	return nil
+}
+
+func (a Algorithm) method() jwt.SigningMethod {
+	switch a {
+	case AlgorithmRS256:
+		return jwt.SigningMethodRS256
+	case AlgorithmEdDSA:
+		return jwt.SigningMethodEdDSA
+	default:
+		return jwt.SigningMethodHS512
+	}
+}
+
+// SigningKey is an asymmetric key tokens are signed with. Its ID is sent as
+// the kid header of the tokens it signs so that they can still be verified
+// after a newer key takes over.
+type SigningKey struct {
+	ID        string    `db:"id"`
+	Algorithm Algorithm `db:"algorithm"`
+	// PrivateKey is PEM encoded PKCS #8.
+	PrivateKey string    `db:"private_key"`
+	CreatedAt  time.Time `db:"created_at"`
+	// ActiveAt is when tokens start being signed with the key. It's later
+	// than CreatedAt when rotating so that every cdbd has loaded the key
+	// before tokens signed with it are handed out.
+	ActiveAt time.Time `db:"active_at"`
+
+	signer crypto.Signer
+}
+
+// GenerateSigningKey generates a new key for the asymmetric algorithm.
+func GenerateSigningKey(algorithm Algorithm) (SigningKey, error) {
+	var private crypto.Signer
+	var err error
+	switch algorithm {
+	case AlgorithmRS256:
+		private, err = rsa.GenerateKey(rand.Reader, 2048)
+	case AlgorithmEdDSA:
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		return SigningKey{}, ErrUnsupportedAlgorithm
+	}
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	id, err := randomID()
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	now := time.Now()
+	return SigningKey{
+		ID:         id[:16],
+		Algorithm:  algorithm,
+		PrivateKey: string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})),
+		CreatedAt:  now,
+		ActiveAt:   now,
+		signer:     private,
+	}, nil
+}
+
+// parse decodes the private key so the key can be used.
+func (k SigningKey) parse() (SigningKey, error) {
+	if k.signer != nil {
+		return k, nil
+	}
+
+	block, _ := pem.Decode([]byte(k.PrivateKey))
+	if block == nil {
+		return k, fmt.Errorf("signing key %s is not PEM encoded", k.ID)
+	}
+
+	private, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return k, fmt.Errorf("unable to parse signing key %s: %w", k.ID, err)
+	}
+
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		if k.Algorithm != AlgorithmRS256 {
+			return k, fmt.Errorf("signing key %s is an RSA key but is for %s", k.ID, k.Algorithm)
+		}
+
+		k.signer = private
+	case ed25519.PrivateKey:
+		if k.Algorithm != AlgorithmEdDSA {
+			return k, fmt.Errorf("signing key %s is an Ed25519 key but is for %s", k.ID, k.Algorithm)
+		}
+
+		k.signer = private
+	default:
+		return k, fmt.Errorf("signing key %s is of an unsupported type %T", k.ID, private)
+	}
+
+	return k, nil
+}
+
+// JWK is the public half of a signing key as a JSON Web Key.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	// N and E are set for RSA keys.
+	N string `json:"n,omitempty"`
+	E string `json:"e,omitempty"`
+	// Curve and X are set for Ed25519 keys.
+	Curve string `json:"crv,omitempty"`
+	X     string `json:"x,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set as served by /.well-known/jwks.json.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func (k SigningKey) jwk() JWK {
+	jwk := JWK{KeyID: k.ID, Algorithm: string(k.Algorithm), Use: "sig"}
+	switch public := k.signer.Public().(type) {
+	case *rsa.PublicKey:
+		jwk.KeyType = "RSA"
+		jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+		jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+	case ed25519.PublicKey:
+		jwk.KeyType = "OKP"
+		jwk.Curve = "Ed25519"
+		jwk.X = base64.RawURLEncoding.EncodeToString(public)
+	}
+
+	return jwk
+}
+
+// KeySet holds the keys tokens are signed and verified with.
+//
+// Tokens are signed with the newest active key for the algorithm, or the
+// shared secret for HS512. Tokens are verified with the key named by their kid
+// header, tokens without one were signed with the shared secret.
+type KeySet struct {
+	algorithm Algorithm
+	secret    []byte
+
+	mu           sync.RWMutex
+	signing      *SigningKey
+	verification map[string]SigningKey
+}
+
+// NewKeySet returns a KeySet which signs tokens with algorithm. The secret is
+// required for HS512 and may be empty otherwise, in which case tokens without
+// a kid are rejected.
+func NewKeySet(algorithm Algorithm, secret []byte) (*KeySet, error) {
+	if err := algorithm.Valid(); err != nil {
+		return nil, err
+	}
+
+	if algorithm == AlgorithmHS512 && len(secret) == 0 {
+		return nil, errors.New("a secret is required to sign tokens with HS512")
+	}
+
+	return &KeySet{
+		algorithm:    algorithm,
+		secret:       secret,
+		verification: make(map[string]SigningKey),
+	}, nil
+}
+
+func (ks *KeySet) Algorithm() Algorithm {
+	return ks.algorithm
+}
+
+// Load replaces the asymmetric keys in the set. Every key is used for
+// verification and the newest active key for the set's algorithm is used for
+// signing.
+func (ks *KeySet) Load(keys []SigningKey) error {
+	verification := make(map[string]SigningKey, len(keys))
+	var signing *SigningKey
+	now := time.Now()
+	for _, key := range keys {
+		key, err := key.parse()
+		if err != nil {
+			return err
+		}
+
+		verification[key.ID] = key
+		if key.Algorithm != ks.algorithm || key.ActiveAt.After(now) {
+			continue
+		}
+
+		if signing == nil || key.ActiveAt.After(signing.ActiveAt) {
+			signing = &key
+		}
+	}
+
+	ks.mu.Lock()
+	defer ks.mu.Unlock()
+	ks.verification = verification
+	ks.signing = signing
+	return nil
+}
+
+// SigningKeyID returns the ID of the key tokens are signed with, which is
+// empty when they are signed with the shared secret.
+func (ks *KeySet) SigningKeyID() string {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if ks.signing == nil {
+		return ""
+	}
+
+	return ks.signing.ID
+}
+
+// JWKS returns the public keys tokens are verified with. The shared secret is
+// never included.
+func (ks *KeySet) JWKS() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	jwks := JWKS{Keys: make([]JWK, 0, len(ks.verification))}
+	for _, key := range ks.verification {
+		jwks.Keys = append(jwks.Keys, key.jwk())
+	}
+
+	slices.SortFunc(jwks.Keys, func(a, b JWK) int {
+		if a.KeyID < b.KeyID {
+			return -1
+		} else if a.KeyID > b.KeyID {
+			return 1
+		}
+
+		return 0
+	})
+
+	return jwks
+}
+
+func (ks *KeySet) sign(claims jwt.Claims) (string, error) {
+	if ks.algorithm == AlgorithmHS512 {
+		return jwt.NewWithClaims(jwt.SigningMethodHS512, claims).SignedString(ks.secret)
+	}
+
+	ks.mu.RLock()
+	signing := ks.signing
+	ks.mu.RUnlock()
+	if signing == nil {
+		return "", ErrNoSigningKey
+	}
+
+	token := jwt.NewWithClaims(signing.Algorithm.method(), claims)
+	token.Header["kid"] = signing.ID
+	return token.SignedString(signing.signer)
+}
+
+// keyFunc finds the key to verify token with, making sure it was signed with
+// the key's algorithm.
+func (ks *KeySet) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	if kid == "" {
+		if len(ks.secret) == 0 || token.Method != jwt.SigningMethodHS512 {
+			return nil, ErrUnknownKey
+		}
+
+		return ks.secret, nil
+	}
+
+	ks.mu.RLock()
+	key, ok := ks.verification[kid]
+	ks.mu.RUnlock()
+	if !ok || token.Method.Alg() != key.Algorithm.method().Alg() {
+		return nil, ErrUnknownKey
+	}
+
+	return key.signer.Public(), nil
+}
@@ -0,0 +1,217 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newKeySet(t *testing.T, algorithm auth.Algorithm, secret string, keys ...auth.SigningKey) *auth.KeySet {
+	t.Helper()
+
+	keySet, err := auth.NewKeySet(algorithm, []byte(secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := keySet.Load(keys); err != nil {
+		t.Fatal(err)
+	}
+
+	return keySet
+}
+
+func generateKey(t *testing.T, algorithm auth.Algorithm) auth.SigningKey {
+	t.Helper()
+
+	key, err := auth.GenerateSigningKey(algorithm)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func kid(t *testing.T, token string) string {
+	t.Helper()
+
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &jwt.RegisteredClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id, _ := parsed.Header["kid"].(string)
+	return id
+}
+
+// publicKey rebuilds the public key from its JWK like a third party would.
+func publicKey(t *testing.T, jwk auth.JWK) interface{} {
+	t.Helper()
+
+	decode := func(value string) []byte {
+		decoded, err := base64.RawURLEncoding.DecodeString(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return decoded
+	}
+
+	switch jwk.KeyType {
+	case "RSA":
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(decode(jwk.N)),
+			E: int(new(big.Int).SetBytes(decode(jwk.E)).Int64()),
+		}
+	case "OKP":
+		return ed25519.PublicKey(decode(jwk.X))
+	default:
+		t.Fatalf("Unexpected key type %s", jwk.KeyType)
+		return nil
+	}
+}
+
+func TestAsymmetricTokensCanBeVerifiedWithTheJWKS(t *testing.T) {
+	user := auth.User{ID: 1, Email: "test@example.com"}
+
+	for _, algorithm := range []auth.Algorithm{auth.AlgorithmRS256, auth.AlgorithmEdDSA} {
+		key := generateKey(t, algorithm)
+		keys := newKeySet(t, algorithm, "", key)
+
+		token, err := auth.GenerateAPIToken(keys, user, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if kid(t, token) != key.ID {
+			t.Errorf("Expected %s tokens to have the kid %s got: %s", algorithm, key.ID, kid(t, token))
+		}
+
+		validated, err := auth.ValidateIdToken(keys, token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if validated.ID != user.ID {
+			t.Errorf("Expected %s got: %s", user, validated)
+		}
+
+		jwks := keys.JWKS()
+		if len(jwks.Keys) != 1 || jwks.Keys[0].KeyID != key.ID || jwks.Keys[0].Algorithm != string(algorithm) {
+			t.Fatalf("Expected the JWKS to publish %s got: %+v", key.ID, jwks)
+		}
+
+		_, err = jwt.Parse(
+			token,
+			func(*jwt.Token) (interface{}, error) { return publicKey(t, jwks.Keys[0]), nil },
+			jwt.WithValidMethods([]string{string(algorithm)}),
+		)
+		if err != nil {
+			t.Errorf("Expected the %s token to be verifiable with the JWKS got: %s", algorithm, err)
+		}
+	}
+}
+
+func TestRotatingKeysKeepsOutstandingTokensValid(t *testing.T) {
+	user := auth.User{ID: 1, Email: "test@example.com"}
+	first := generateKey(t, auth.AlgorithmEdDSA)
+	keys := newKeySet(t, auth.AlgorithmEdDSA, "secret", first)
+
+	legacy, err := auth.GenerateAPIToken(auth.NewTestKeySet("secret"), user, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	outstanding, err := auth.GenerateAPIToken(keys, user, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	second := generateKey(t, auth.AlgorithmEdDSA)
+	second.ActiveAt = time.Now().Add(time.Hour)
+	if err := keys.Load([]auth.SigningKey{first, second}); err != nil {
+		t.Fatal(err)
+	}
+
+	if keys.SigningKeyID() != first.ID {
+		t.Errorf("Expected keys to not be used before they are active got: %s", keys.SigningKeyID())
+	}
+
+	second.ActiveAt = time.Now()
+	if err := keys.Load([]auth.SigningKey{first, second}); err != nil {
+		t.Fatal(err)
+	}
+
+	rotated, err := auth.GenerateAPIToken(keys, user, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if kid(t, rotated) != second.ID {
+		t.Errorf("Expected new tokens to be signed with %s got: %s", second.ID, kid(t, rotated))
+	}
+
+	for name, token := range map[string]string{"legacy": legacy, "outstanding": outstanding, "rotated": rotated} {
+		if _, err := auth.ValidateIdToken(keys, token); err != nil {
+			t.Errorf("Expected the %s token to be valid got: %s", name, err)
+		}
+	}
+}
+
+func TestTokensFromUnknownKeysAreRejected(t *testing.T) {
+	user := auth.User{ID: 1, Email: "test@example.com"}
+	key := generateKey(t, auth.AlgorithmRS256)
+	keys := newKeySet(t, auth.AlgorithmRS256, "", key)
+
+	other, err := auth.GenerateAPIToken(newKeySet(t, auth.AlgorithmRS256, "", generateKey(t, auth.AlgorithmRS256)), user, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy, err := auth.GenerateAPIToken(auth.NewTestKeySet("secret"), user, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// An HS512 token claiming to be signed by the RSA key must not be
+	// verified with it.
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS512, auth.IDClaims{
+		User:             user,
+		RegisteredClaims: jwt.RegisteredClaims{Issuer: auth.TokenIssuer},
+	})
+	forged.Header["kid"] = key.ID
+	confused, err := forged.SignedString([]byte(key.PrivateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, token := range map[string]string{"unknown key": other, "no secret": legacy, "algorithm confusion": confused} {
+		if _, err := auth.ValidateIdToken(keys, token); !errors.Is(err, auth.ErrUnknownKey) {
+			t.Errorf("Expected the %s token to be rejected with %s got: %v", name, auth.ErrUnknownKey, err)
+		}
+	}
+}
+
+func TestAsymmetricKeySetsRequireAnActiveKey(t *testing.T) {
+	keys := newKeySet(t, auth.AlgorithmRS256, "secret")
+
+	_, err := auth.GenerateAPIToken(keys, auth.User{ID: 1}, nil)
+	if !errors.Is(err, auth.ErrNoSigningKey) {
+		t.Errorf("Expected %s got: %v", auth.ErrNoSigningKey, err)
+	}
+
+	if _, err := auth.NewKeySet(auth.AlgorithmHS512, nil); err == nil {
+		t.Error("Expected HS512 to require a secret")
+	}
+
+	if _, err := auth.NewKeySet("none", nil); !errors.Is(err, auth.ErrUnsupportedAlgorithm) {
+		t.Errorf("Expected %s got: %v", auth.ErrUnsupportedAlgorithm, err)
+	}
+}
@@ -0,0 +1,290 @@
+package ldap_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"io"
+	"math/big"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const startTLSOID = "1.3.6.1.4.1.1466.20037"
+
+type testEntry struct {
+	dn       string
+	password string
+	attrs    map[string][]string
+}
+
+// testDirectory is a minimal in-process LDAP server supporting simple binds,
+// searches with and, or, not, equality and presence filters and StartTLS.
+type testDirectory struct {
+	listener net.Listener
+	tls      *tls.Config
+	// requireTLS refuses binds and searches on connections which haven't
+	// used StartTLS.
+	requireTLS bool
+
+	mu      sync.Mutex
+	entries []testEntry
+}
+
+func newTestDirectory(t *testing.T, entries ...testEntry) *testDirectory {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := &testDirectory{
+		listener: listener,
+		tls:      &tls.Config{Certificates: []tls.Certificate{selfSignedCertificate(t)}},
+		entries:  entries,
+	}
+
+	go dir.serve()
+	t.Cleanup(func() { listener.Close() })
+	return dir
+}
+
+func (dir *testDirectory) URL() string {
+	return "ldap://" + dir.listener.Addr().String()
+}
+
+// ClientTLSConfig trusts the directory's certificate.
+func (dir *testDirectory) ClientTLSConfig() *tls.Config {
+	cert, err := x509.ParseCertificate(dir.tls.Certificates[0].Certificate[0])
+	if err != nil {
+		panic(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}
+}
+
+// setAttribute replaces the values of attr on the entry with dn.
+func (dir *testDirectory) setAttribute(dn, attr string, values ...string) {
+	dir.mu.Lock()
+	defer dir.mu.Unlock()
+
+	for _, entry := range dir.entries {
+		if strings.EqualFold(entry.dn, dn) {
+			entry.attrs[attr] = values
+		}
+	}
+}
+
+func (dir *testDirectory) serve() {
+	for {
+		conn, err := dir.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go dir.handle(conn)
+	}
+}
+
+func (dir *testDirectory) handle(conn net.Conn) {
+	defer conn.Close()
+
+	secure := false
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+
+		if len(packet.Children) < 2 {
+			return
+		}
+
+		id, _ := packet.Children[0].Value.(int64)
+		request := packet.Children[1]
+		refused := dir.requireTLS && !secure
+
+		switch request.Tag {
+		case ldap.ApplicationBindRequest:
+			if refused {
+				writeResult(conn, id, ldap.ApplicationBindResponse, ldap.LDAPResultConfidentialityRequired)
+				continue
+			}
+
+			dir.bind(conn, id, request)
+		case ldap.ApplicationSearchRequest:
+			if refused {
+				writeResult(conn, id, ldap.ApplicationSearchResultDone, ldap.LDAPResultConfidentialityRequired)
+				continue
+			}
+
+			dir.search(conn, id, request)
+		case ldap.ApplicationExtendedRequest:
+			if request.Children[0].Data.String() != startTLSOID {
+				writeResult(conn, id, ldap.ApplicationExtendedResponse, ldap.LDAPResultProtocolError)
+				continue
+			}
+
+			writeResult(conn, id, ldap.ApplicationExtendedResponse, ldap.LDAPResultSuccess)
+			tlsConn := tls.Server(conn, dir.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+
+			conn = tlsConn
+			secure = true
+		default:
+			return
+		}
+	}
+}
+
+func (dir *testDirectory) bind(w io.Writer, id int64, request *ber.Packet) {
+	name, _ := request.Children[1].Value.(string)
+	password := request.Children[2].Data.String()
+
+	dir.mu.Lock()
+	defer dir.mu.Unlock()
+
+	for _, entry := range dir.entries {
+		if strings.EqualFold(entry.dn, name) && entry.password != "" && entry.password == password {
+			writeResult(w, id, ldap.ApplicationBindResponse, ldap.LDAPResultSuccess)
+			return
+		}
+	}
+
+	writeResult(w, id, ldap.ApplicationBindResponse, ldap.LDAPResultInvalidCredentials)
+}
+
+func (dir *testDirectory) search(w io.Writer, id int64, request *ber.Packet) {
+	base, _ := request.Children[0].Value.(string)
+	filter := request.Children[6]
+
+	dir.mu.Lock()
+	defer dir.mu.Unlock()
+
+	for _, entry := range dir.entries {
+		if !strings.HasSuffix(strings.ToLower(entry.dn), strings.ToLower(base)) || !matches(filter, entry) {
+			continue
+		}
+
+		attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+		for name, values := range entry.attrs {
+			attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+			attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+			set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+			for _, value := range values {
+				set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+			}
+
+			attribute.AppendChild(set)
+			attributes.AppendChild(attribute)
+		}
+
+		response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+		response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "DN"))
+		response.AppendChild(attributes)
+		writeMessage(w, id, response)
+	}
+
+	writeResult(w, id, ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)
+}
+
+func matches(filter *ber.Packet, entry testEntry) bool {
+	switch filter.Tag {
+	case ldap.FilterAnd:
+		for _, child := range filter.Children {
+			if !matches(child, entry) {
+				return false
+			}
+		}
+
+		return true
+	case ldap.FilterOr:
+		for _, child := range filter.Children {
+			if matches(child, entry) {
+				return true
+			}
+		}
+
+		return false
+	case ldap.FilterNot:
+		return !matches(filter.Children[0], entry)
+	case ldap.FilterEqualityMatch:
+		attr := filter.Children[0].Data.String()
+		value := filter.Children[1].Data.String()
+		return slices.ContainsFunc(attributeValues(entry, attr), func(candidate string) bool {
+			return strings.EqualFold(candidate, value)
+		})
+	case ldap.FilterPresent:
+		return len(attributeValues(entry, filter.Data.String())) > 0
+	default:
+		return false
+	}
+}
+
+func attributeValues(entry testEntry, attr string) []string {
+	for name, values := range entry.attrs {
+		if strings.EqualFold(name, attr) {
+			return values
+		}
+	}
+
+	return nil
+}
+
+func writeResult(w io.Writer, id int64, tag ber.Tag, code uint16) {
+	result := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	result.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	result.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	writeMessage(w, id, result)
+}
+
+func writeMessage(w io.Writer, id int64, op *ber.Packet) {
+	message := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	message.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "Message ID"))
+	message.AppendChild(op)
+	w.Write(message.Bytes()) // nolint:errcheck
+}
+
+func selfSignedCertificate(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "cdb test directory"},
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
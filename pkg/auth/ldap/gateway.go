@@ -0,0 +1,423 @@
+// Package ldap implements an auth.AuthenticationGateway which logs users in by
+// binding to an LDAP directory as them.
+package ldap
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/config-source/cdb/pkg/audit"
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/go-ldap/ldap/v3"
+	"github.com/rs/zerolog"
+)
+
+var (
+	// ErrRegistrationUnsupported is returned by Register, users must be
+	// added to the directory instead.
+	ErrRegistrationUnsupported = fmt.Errorf("%w: users are managed in the LDAP directory", auth.ErrPublicRegisterDisabled)
+	// ErrAmbiguousUser is returned when the user search filter matches more
+	// than one entry.
+	ErrAmbiguousUser = errors.New("the user search filter matched more than one entry")
+)
+
+const (
+	DefaultUserFilter     = "(mail=%s)"
+	DefaultEmailAttribute = "mail"
+	DefaultGroupFilter    = "(|(member=%s)(uniqueMember=%s))"
+)
+
+type Config struct {
+	// URL of the directory, ldap:// or ldaps://.
+	URL string
+	// StartTLS upgrades ldap:// connections to TLS before binding.
+	StartTLS bool
+	// TLSConfig is used for ldaps:// and StartTLS connections.
+	TLSConfig *tls.Config
+	// Timeout for connecting to and each request against the directory,
+	// defaults to 10 seconds.
+	Timeout time.Duration
+
+	// BindDN and BindPassword are the service account used to search for
+	// users and their groups. When empty searches are made anonymously.
+	BindDN       string
+	BindPassword string
+
+	// UserBaseDN is where users are searched for.
+	UserBaseDN string
+	// UserFilter finds the user logging in, %s is replaced with the escaped
+	// email they logged in with. Defaults to DefaultUserFilter.
+	UserFilter string
+	// EmailAttribute is the attribute holding the user's email which is
+	// used as their CDB email. Defaults to DefaultEmailAttribute.
+	EmailAttribute string
+
+	// GroupBaseDN is where groups are searched for, defaults to UserBaseDN.
+	GroupBaseDN string
+	// GroupFilter finds the groups a user is a member of, %s is replaced
+	// with the escaped DN of the user. Defaults to DefaultGroupFilter.
+	GroupFilter string
+	// GroupRoles maps group DNs to the CDB roles their members are given.
+	GroupRoles map[string]string
+}
+
+type groupRole struct {
+	dn   *ldap.DN
+	role string
+}
+
+// Gateway implements auth.AuthenticationGateway against an LDAP directory.
+// Users are stored by the wrapped AuthenticationGateway and are created the
+// first time they log in, their roles are synced from their groups on every
+// login.
+type Gateway struct {
+	cfg   Config
+	log   zerolog.Logger
+	users auth.AuthenticationGateway
+	authz auth.AuthorizationGateway
+	audit *audit.Log
+
+	groupRoles []groupRole
+}
+
+func NewGateway(
+	log zerolog.Logger,
+	cfg Config,
+	users auth.AuthenticationGateway,
+	authz auth.AuthorizationGateway,
+	auditLog *audit.Log,
+) (*Gateway, error) {
+	if cfg.URL == "" || cfg.UserBaseDN == "" {
+		return nil, errors.New("an LDAP URL and user base DN are required")
+	}
+
+	if cfg.TLSConfig == nil {
+		parsed, err := url.Parse(cfg.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid LDAP URL: %w", err)
+		}
+
+		cfg.TLSConfig = &tls.Config{ServerName: parsed.Hostname()}
+	}
+
+	if cfg.Timeout == 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = DefaultUserFilter
+	}
+
+	if cfg.EmailAttribute == "" {
+		cfg.EmailAttribute = DefaultEmailAttribute
+	}
+
+	if cfg.GroupBaseDN == "" {
+		cfg.GroupBaseDN = cfg.UserBaseDN
+	}
+
+	if cfg.GroupFilter == "" {
+		cfg.GroupFilter = DefaultGroupFilter
+	}
+
+	groupRoles := make([]groupRole, 0, len(cfg.GroupRoles))
+	for group, role := range cfg.GroupRoles {
+		dn, err := ldap.ParseDN(group)
+		if err != nil {
+			return nil, fmt.Errorf("invalid group DN %s: %w", group, err)
+		}
+
+		groupRoles = append(groupRoles, groupRole{dn: dn, role: role})
+	}
+
+	return &Gateway{
+		cfg:        cfg,
+		log:        log,
+		users:      users,
+		authz:      authz,
+		audit:      auditLog,
+		groupRoles: groupRoles,
+	}, nil
+}
+
+func (g *Gateway) Register(ctx context.Context, email, password string) (auth.User, error) {
+	return auth.User{}, ErrRegistrationUnsupported
+}
+
+// Login finds the user's entry with the user search filter then binds as it
+// with their password.
+func (g *Gateway) Login(ctx context.Context, email, password string) (auth.User, error) {
+	// An empty password would be an unauthenticated bind which most
+	// directories accept.
+	if email == "" || password == "" {
+		return auth.User{}, auth.ErrInvalidPassword
+	}
+
+	conn, err := g.connect()
+	if err != nil {
+		return auth.User{}, err
+	}
+	defer conn.Close()
+
+	if err := g.bindServiceAccount(conn); err != nil {
+		return auth.User{}, err
+	}
+
+	entry, err := g.findUser(conn, email)
+	if err != nil {
+		return auth.User{}, err
+	}
+
+	if err := conn.Bind(entry.DN, password); ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		return auth.User{}, auth.ErrInvalidPassword
+	} else if err != nil {
+		return auth.User{}, err
+	}
+
+	// Rebind so groups are searched with the same access regardless of
+	// what the user can see.
+	if err := g.bindServiceAccount(conn); err != nil {
+		return auth.User{}, err
+	}
+
+	groups, err := g.findGroups(conn, entry.DN)
+	if err != nil {
+		return auth.User{}, err
+	}
+
+	directoryEmail := entry.GetAttributeValue(g.cfg.EmailAttribute)
+	if directoryEmail == "" {
+		directoryEmail = email
+	}
+
+	return g.provision(ctx, directoryEmail, groups)
+}
+
+// CreateUser lets administrators create users ahead of their first login so
+// that they can be given service roles.
+func (g *Gateway) CreateUser(ctx context.Context, newUser auth.User) (auth.User, error) {
+	password, err := randomPassword()
+	if err != nil {
+		return auth.User{}, err
+	}
+
+	// The password can never be used but must be set.
+	newUser.Password = password
+	return g.users.CreateUser(ctx, newUser)
+}
+
+func (g *Gateway) GetUser(ctx context.Context, userID auth.UserID) (auth.User, error) {
+	return g.users.GetUser(ctx, userID)
+}
+
+func (g *Gateway) GetUserByEmail(ctx context.Context, email string) (auth.User, error) {
+	return g.users.GetUserByEmail(ctx, email)
+}
+
+func (g *Gateway) DeleteUser(ctx context.Context, userID auth.UserID) error {
+	return g.users.DeleteUser(ctx, userID)
+}
+
+func (g *Gateway) ListUsers(ctx context.Context) ([]auth.User, error) {
+	return g.users.ListUsers(ctx)
+}
+
+func (g *Gateway) SetUserDisabled(ctx context.Context, userID auth.UserID, disabled bool) (auth.User, error) {
+	return g.users.SetUserDisabled(ctx, userID, disabled)
+}
+
+func (g *Gateway) Healthy(ctx context.Context) bool {
+	conn, err := g.connect()
+	if err != nil {
+		g.log.Err(err).Msg("unable to connect to LDAP directory")
+		return false
+	}
+	conn.Close()
+
+	return g.users.Healthy(ctx)
+}
+
+func (g *Gateway) connect() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(
+		g.cfg.URL,
+		ldap.DialWithTLSConfig(g.cfg.TLSConfig),
+		ldap.DialWithDialer(&net.Dialer{Timeout: g.cfg.Timeout}),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	conn.SetTimeout(g.cfg.Timeout)
+	if g.cfg.StartTLS {
+		if err := conn.StartTLS(g.cfg.TLSConfig); err != nil {
+			conn.Close()
+			return nil, err
+		}
+	}
+
+	return conn, nil
+}
+
+func (g *Gateway) bindServiceAccount(conn *ldap.Conn) error {
+	if g.cfg.BindDN == "" {
+		return nil
+	}
+
+	if err := conn.Bind(g.cfg.BindDN, g.cfg.BindPassword); err != nil {
+		return fmt.Errorf("unable to bind as the LDAP service account: %w", err)
+	}
+
+	return nil
+}
+
+// withValue replaces every %s in filter with the escaped value.
+func withValue(filter, value string) string {
+	return strings.ReplaceAll(filter, "%s", ldap.EscapeFilter(value))
+}
+
+func (g *Gateway) findUser(conn *ldap.Conn, email string) (*ldap.Entry, error) {
+	result, err := conn.Search(ldap.NewSearchRequest(
+		g.cfg.UserBaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0,
+		int(g.cfg.Timeout.Seconds()),
+		false,
+		withValue(g.cfg.UserFilter, email),
+		[]string{g.cfg.EmailAttribute},
+		nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	switch len(result.Entries) {
+	case 0:
+		// Treated the same as a wrong password so the login form can't be
+		// used to find out who is in the directory.
+		return nil, auth.ErrInvalidPassword
+	case 1:
+		return result.Entries[0], nil
+	default:
+		return nil, ErrAmbiguousUser
+	}
+}
+
+func (g *Gateway) findGroups(conn *ldap.Conn, userDN string) ([]*ldap.DN, error) {
+	if len(g.groupRoles) == 0 {
+		return nil, nil
+	}
+
+	result, err := conn.Search(ldap.NewSearchRequest(
+		g.cfg.GroupBaseDN,
+		ldap.ScopeWholeSubtree,
+		ldap.NeverDerefAliases,
+		0,
+		int(g.cfg.Timeout.Seconds()),
+		false,
+		withValue(g.cfg.GroupFilter, userDN),
+		[]string{"dn"},
+		nil,
+	))
+	if err != nil {
+		return nil, err
+	}
+
+	groups := make([]*ldap.DN, 0, len(result.Entries))
+	for _, entry := range result.Entries {
+		dn, err := ldap.ParseDN(entry.DN)
+		if err != nil {
+			return nil, err
+		}
+
+		groups = append(groups, dn)
+	}
+
+	return groups, nil
+}
+
+// provision returns the user for email, creating them if this is their first
+// login, and syncs their roles from their groups.
+func (g *Gateway) provision(ctx context.Context, email string, groups []*ldap.DN) (auth.User, error) {
+	user, err := g.users.GetUserByEmail(ctx, email)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		user, err = g.CreateUser(ctx, auth.User{Email: email})
+		if err != nil {
+			return auth.User{}, err
+		}
+
+		g.audit.Record(ctx, audit.System, audit.Event{
+			Action:     audit.ActionUserProvision,
+			TargetKind: audit.TargetUser,
+			TargetID:   strconv.Itoa(int(user.ID)),
+			After:      user,
+		})
+	} else if err != nil {
+		return auth.User{}, err
+	}
+
+	if err := g.syncRoles(ctx, user, groups); err != nil {
+		return auth.User{}, err
+	}
+
+	return user, nil
+}
+
+// syncRoles grants the roles mapped from the user's groups and removes any
+// other mapped roles so that leaving a group revokes its role. Roles which
+// aren't mapped from a group are left alone.
+func (g *Gateway) syncRoles(ctx context.Context, user auth.User, groups []*ldap.DN) error {
+	if len(g.groupRoles) == 0 {
+		return nil
+	}
+
+	var managed, granted []string
+	for _, mapping := range g.groupRoles {
+		if !slices.Contains(managed, mapping.role) {
+			managed = append(managed, mapping.role)
+		}
+
+		isMember := slices.ContainsFunc(groups, mapping.dn.EqualFold)
+		if isMember && !slices.Contains(granted, mapping.role) {
+			granted = append(granted, mapping.role)
+		}
+	}
+
+	slices.Sort(granted)
+	before, after, err := g.authz.SyncRolesNoAuth(ctx, user, managed, granted)
+	if err != nil {
+		return err
+	}
+
+	// Roles are synced on every login so only changes are recorded.
+	if !slices.Equal(before, after) {
+		g.audit.Record(ctx, audit.System, audit.Event{
+			Action:     audit.ActionUserSyncRoles,
+			TargetKind: audit.TargetUser,
+			TargetID:   strconv.Itoa(int(user.ID)),
+			Before:     before,
+			After:      after,
+		})
+	}
+
+	return nil
+}
+
+func randomPassword() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
@@ -0,0 +1,187 @@
+package ldap_test
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/auth/ldap"
+	"github.com/rs/zerolog"
+)
+
+const (
+	baseDN     = "dc=example,dc=com"
+	serviceDN  = "cn=cdb,ou=services,dc=example,dc=com"
+	janeDN     = "uid=jane,ou=people,dc=example,dc=com"
+	sreDN      = "cn=sre,ou=groups,dc=example,dc=com"
+	platformDN = "cn=platform,ou=groups,dc=example,dc=com"
+)
+
+func newDirectory(t *testing.T) *testDirectory {
+	t.Helper()
+
+	return newTestDirectory(
+		t,
+		testEntry{
+			dn:       serviceDN,
+			password: "service-password",
+			attrs:    map[string][]string{"objectClass": {"applicationProcess"}},
+		},
+		testEntry{
+			dn:       janeDN,
+			password: "jane-password",
+			attrs: map[string][]string{
+				"objectClass": {"inetOrgPerson"},
+				"uid":         {"jane"},
+				"mail":        {"jane@example.com"},
+			},
+		},
+		testEntry{
+			dn: sreDN,
+			attrs: map[string][]string{
+				"objectClass": {"groupOfNames"},
+				"member":      {janeDN},
+			},
+		},
+		testEntry{
+			dn: platformDN,
+			attrs: map[string][]string{
+				"objectClass": {"groupOfNames"},
+				"member":      {serviceDN},
+			},
+		},
+	)
+}
+
+func newGateway(t *testing.T, cfg ldap.Config) (*ldap.Gateway, *auth.TestGateway) {
+	t.Helper()
+
+	cfg.BindDN = serviceDN
+	cfg.BindPassword = "service-password"
+	cfg.UserBaseDN = baseDN
+	cfg.GroupRoles = map[string]string{
+		// Group DNs are compared case insensitively.
+		"CN=platform,OU=groups,DC=example,DC=com": "Administrator",
+		sreDN: "Operator",
+	}
+
+	store := auth.NewTestGateway()
+	gateway, err := ldap.NewGateway(zerolog.New(nil).Level(zerolog.Disabled), cfg, store, store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return gateway, store
+}
+
+func TestLoginProvisionsUsersWithMappedRoles(t *testing.T) {
+	dir := newDirectory(t)
+	gateway, store := newGateway(t, ldap.Config{URL: dir.URL()})
+
+	user, err := gateway.Login(context.Background(), "jane@example.com", "jane-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Email != "jane@example.com" || user.ID == 0 {
+		t.Fatalf("Expected jane@example.com to be provisioned got: %s", user)
+	}
+
+	if roles := store.SyncedRoles[user.ID]; !reflect.DeepEqual(roles, []string{"Operator"}) {
+		t.Errorf("Expected the Operator role to be granted got: %v", roles)
+	}
+
+	dir.setAttribute(sreDN, "member")
+	dir.setAttribute(platformDN, "member", serviceDN, janeDN)
+
+	again, err := gateway.Login(context.Background(), "jane@example.com", "jane-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again.ID != user.ID {
+		t.Errorf("Expected the existing user to be logged in got: %d", again.ID)
+	}
+
+	if roles := store.SyncedRoles[user.ID]; !reflect.DeepEqual(roles, []string{"Administrator"}) {
+		t.Errorf("Expected roles to follow the user's groups got: %v", roles)
+	}
+}
+
+func TestLoginRejectsInvalidCredentials(t *testing.T) {
+	dir := newDirectory(t)
+	gateway, store := newGateway(t, ldap.Config{URL: dir.URL()})
+
+	tests := map[string][2]string{
+		"wrong password":   {"jane@example.com", "wrong"},
+		"empty password":   {"jane@example.com", ""},
+		"unknown user":     {"john@example.com", "jane-password"},
+		"filter wildcard":  {"*", "jane-password"},
+		"filter injection": {"*)(uid=jane", "jane-password"},
+	}
+
+	for name, credentials := range tests {
+		_, err := gateway.Login(context.Background(), credentials[0], credentials[1])
+		if !errors.Is(err, auth.ErrInvalidPassword) {
+			t.Errorf("Expected %s to be rejected with %s got: %v", name, auth.ErrInvalidPassword, err)
+		}
+	}
+
+	if users, _ := store.ListUsers(context.Background()); len(users) != 0 {
+		t.Errorf("Expected no users to be provisioned got: %v", users)
+	}
+}
+
+func TestLoginWithStartTLS(t *testing.T) {
+	dir := newDirectory(t)
+	dir.requireTLS = true
+
+	plain, _ := newGateway(t, ldap.Config{URL: dir.URL()})
+	if _, err := plain.Login(context.Background(), "jane@example.com", "jane-password"); err == nil {
+		t.Fatal("Expected the directory to refuse logins without StartTLS")
+	}
+
+	gateway, _ := newGateway(t, ldap.Config{
+		URL:       dir.URL(),
+		StartTLS:  true,
+		TLSConfig: dir.ClientTLSConfig(),
+	})
+
+	user, err := gateway.Login(context.Background(), "jane@example.com", "jane-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Email != "jane@example.com" {
+		t.Errorf("Expected jane@example.com got: %s", user)
+	}
+}
+
+func TestUserFilterIsConfigurable(t *testing.T) {
+	dir := newDirectory(t)
+	gateway, _ := newGateway(t, ldap.Config{
+		URL:        dir.URL(),
+		UserFilter: "(&(objectClass=inetOrgPerson)(uid=%s))",
+	})
+
+	user, err := gateway.Login(context.Background(), "jane", "jane-password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Email != "jane@example.com" {
+		t.Errorf("Expected the email to come from the directory got: %s", user)
+	}
+}
+
+func TestRegisterIsUnsupported(t *testing.T) {
+	dir := newDirectory(t)
+	gateway, _ := newGateway(t, ldap.Config{URL: dir.URL()})
+
+	_, err := gateway.Register(context.Background(), "john@example.com", "password")
+	if !errors.Is(err, auth.ErrPublicRegisterDisabled) {
+		t.Errorf("Expected %s got: %v", auth.ErrPublicRegisterDisabled, err)
+	}
+}
@@ -0,0 +1,252 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+var (
+	// ErrTooManyLoginAttempts is returned instead of checking the password
+	// when an account or IP address has failed to login too often recently.
+	ErrTooManyLoginAttempts    = errors.New("too many failed login attempts")
+	ErrUnknownLoginFailureKind = errors.New("unknown lockout kind, must be account or ip")
+)
+
+type LoginFailureKind string
+
+const (
+	LoginFailureAccount LoginFailureKind = "account"
+	LoginFailureIP      LoginFailureKind = "ip"
+)
+
+// LockoutPolicy decides how long logins are refused after failing. Every
+// failure doubles the time until the next attempt is allowed, starting at
+// BaseDelay and capped at MaxDelay, and reaching the maximum number of failures
+// locks the account or IP address for LockoutDuration. Failures are forgotten
+// once none have happened for LockoutDuration.
+type LockoutPolicy struct {
+	MaxAccountFailures int
+	// MaxIPFailures is usually higher than MaxAccountFailures since many
+	// users can share an address.
+	MaxIPFailures   int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutDuration time.Duration
+}
+
+// LoginFailures tracks the recent failed logins for an account, keyed by
+// email, or an IP address.
+type LoginFailures struct {
+	Kind          LoginFailureKind `db:"kind"`
+	Key           string           `db:"key"`
+	Failures      int              `db:"failures"`
+	LastFailureAt time.Time        `db:"last_failure_at"`
+	LockedUntil   *time.Time       `db:"locked_until"`
+}
+
+// RetryAt returns when the next login attempt is allowed.
+func (lf LoginFailures) RetryAt(policy LockoutPolicy) time.Time {
+	if lf.LockedUntil != nil && lf.LockedUntil.After(lf.LastFailureAt) {
+		return *lf.LockedUntil
+	}
+
+	if lf.Failures <= 0 {
+		return lf.LastFailureAt
+	}
+
+	delay := policy.MaxDelay
+	// Shifting by more than this would overflow.
+	if lf.Failures <= 32 {
+		delay = min(policy.BaseDelay<<(lf.Failures-1), policy.MaxDelay)
+	}
+
+	return lf.LastFailureAt.Add(delay)
+}
+
+func (lf LoginFailures) maxFailures(policy LockoutPolicy) int {
+	if lf.Kind == LoginFailureIP {
+		return policy.MaxIPFailures
+	}
+
+	return policy.MaxAccountFailures
+}
+
+// LoginThrottle stores failed logins so that every cdbd refuses logins for
+// accounts and IP addresses which are being guessed at. A nil LoginThrottle
+// never refuses a login.
+type LoginThrottle struct {
+	pool   *pgxpool.Pool
+	log    zerolog.Logger
+	policy LockoutPolicy
+}
+
+func NewLoginThrottle(log zerolog.Logger, pool *pgxpool.Pool, policy LockoutPolicy) *LoginThrottle {
+	return &LoginThrottle{
+		pool:   pool,
+		log:    log,
+		policy: policy,
+	}
+}
+
+const loginFailureColumns = "kind, key, failures, last_failure_at, locked_until"
+
+// accountKey normalises email since it's matched case insensitively at login.
+func accountKey(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// Check returns ErrTooManyLoginAttempts if a login for email from ip must be
+// refused. The IP address is ignored when empty.
+func (lt *LoginThrottle) Check(ctx context.Context, email, ip string) error {
+	if lt == nil {
+		return nil
+	}
+
+	failures, err := postgresutils.GetAll[LoginFailures](
+		lt.pool,
+		ctx,
+		"SELECT "+loginFailureColumns+" FROM login_failures WHERE (kind = $1 AND key = $2) OR (kind = $3 AND key = $4)",
+		LoginFailureAccount,
+		accountKey(email),
+		LoginFailureIP,
+		ip,
+	)
+	if err != nil {
+		return err
+	}
+
+	var retryAt time.Time
+	for _, failure := range failures {
+		if at := failure.RetryAt(lt.policy); at.After(retryAt) {
+			retryAt = at
+		}
+	}
+
+	if wait := time.Until(retryAt); wait > 0 {
+		return fmt.Errorf("%w, try again in %s", ErrTooManyLoginAttempts, wait.Round(time.Second))
+	}
+
+	return nil
+}
+
+// RecordFailure counts a failed login for email from ip, locking either when
+// they reach the policy's maximum failures.
+func (lt *LoginThrottle) RecordFailure(ctx context.Context, email, ip string) error {
+	if lt == nil {
+		return nil
+	}
+
+	keys := map[LoginFailureKind]string{LoginFailureAccount: accountKey(email)}
+	if ip != "" {
+		keys[LoginFailureIP] = ip
+	}
+
+	now := time.Now()
+	for kind, key := range keys {
+		failure, err := postgresutils.GetOne[LoginFailures](
+			lt.pool,
+			ctx,
+			`INSERT INTO login_failures (kind, key, failures, last_failure_at) VALUES ($1, $2, 1, $3)
+			ON CONFLICT (kind, key) DO UPDATE SET
+				failures = CASE WHEN login_failures.last_failure_at < $4 THEN 1 ELSE login_failures.failures + 1 END,
+				last_failure_at = EXCLUDED.last_failure_at
+			RETURNING `+loginFailureColumns,
+			kind,
+			key,
+			now,
+			now.Add(-lt.policy.LockoutDuration),
+		)
+		if err != nil {
+			return err
+		}
+
+		if failure.Failures < failure.maxFailures(lt.policy) {
+			continue
+		}
+
+		lockedUntil := now.Add(lt.policy.LockoutDuration)
+		_, err = lt.pool.Exec(
+			ctx,
+			"UPDATE login_failures SET locked_until = $3 WHERE kind = $1 AND key = $2",
+			kind,
+			key,
+			lockedUntil,
+		)
+		if err != nil {
+			return err
+		}
+
+		lt.log.Warn().
+			Str("kind", string(kind)).
+			Str("key", key).
+			Int("failures", failure.Failures).
+			Time("lockedUntil", lockedUntil).
+			Bool("audit", true).
+			Msg("login locked after too many failures")
+	}
+
+	return nil
+}
+
+// RecordSuccess forgets the failed logins for email. Failures from the IP
+// address are kept so that logging into one account can't be used to keep
+// guessing at others.
+func (lt *LoginThrottle) RecordSuccess(ctx context.Context, email string) error {
+	if lt == nil {
+		return nil
+	}
+
+	_, err := lt.pool.Exec(
+		ctx,
+		"DELETE FROM login_failures WHERE kind = $1 AND key = $2",
+		LoginFailureAccount,
+		accountKey(email),
+	)
+	return err
+}
+
+// ListLockouts returns the accounts and IP addresses which are locked.
+func (lt *LoginThrottle) ListLockouts(ctx context.Context) ([]LoginFailures, error) {
+	if lt == nil {
+		return []LoginFailures{}, nil
+	}
+
+	return postgresutils.GetAll[LoginFailures](
+		lt.pool,
+		ctx,
+		"SELECT "+loginFailureColumns+" FROM login_failures WHERE locked_until > $1 ORDER BY locked_until",
+		time.Now(),
+	)
+}
+
+// Unlock forgets the failed logins for an account or IP address, lifting any
+// lockout.
+func (lt *LoginThrottle) Unlock(ctx context.Context, actor User, kind LoginFailureKind, key string) error {
+	if lt == nil {
+		return nil
+	}
+
+	if kind == LoginFailureAccount {
+		key = accountKey(key)
+	}
+
+	_, err := lt.pool.Exec(ctx, "DELETE FROM login_failures WHERE kind = $1 AND key = $2", kind, key)
+	if err != nil {
+		return err
+	}
+
+	lt.log.Info().
+		Interface("actorID", actor.ID).
+		Str("kind", string(kind)).
+		Str("key", key).
+		Bool("audit", true).
+		Msg("login unlocked")
+	return nil
+}
@@ -0,0 +1,99 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/rs/zerolog"
+)
+
+func TestLoginBackoffDoublesUntilTheMaximum(t *testing.T) {
+	policy := auth.LockoutPolicy{BaseDelay: time.Second, MaxDelay: 5 * time.Second}
+	lastFailureAt := time.Now()
+
+	expected := map[int]time.Duration{
+		0:   0,
+		1:   time.Second,
+		2:   2 * time.Second,
+		3:   4 * time.Second,
+		4:   5 * time.Second,
+		100: 5 * time.Second,
+	}
+
+	for failures, delay := range expected {
+		failure := auth.LoginFailures{Failures: failures, LastFailureAt: lastFailureAt}
+		if got := failure.RetryAt(policy).Sub(lastFailureAt); got != delay {
+			t.Errorf("Expected a delay of %s after %d failures got: %s", delay, failures, got)
+		}
+	}
+
+	lockedUntil := lastFailureAt.Add(time.Hour)
+	locked := auth.LoginFailures{Failures: 1, LastFailureAt: lastFailureAt, LockedUntil: &lockedUntil}
+	if !locked.RetryAt(policy).Equal(lockedUntil) {
+		t.Errorf("Expected locked logins to be refused until %s got: %s", lockedUntil, locked.RetryAt(policy))
+	}
+}
+
+func TestLoginLockout(t *testing.T) {
+	pool := postgresutils.InitTestDB(t)
+	throttle := auth.NewLoginThrottle(zerolog.New(nil).Level(zerolog.Disabled), pool, auth.LockoutPolicy{
+		MaxAccountFailures: 2,
+		MaxIPFailures:      3,
+		LockoutDuration:    time.Hour,
+	})
+	gateway := auth.NewTestGateway()
+	svc := auth.NewUserService(gateway, gateway, &auth.TokenRegistry{}, throttle, nil, true, "", auth.PasswordPolicy{}, &auth.TestNotifier{}, nil)
+
+	ctx := context.Background()
+	if _, err := svc.Register(ctx, "test@example.com", "password"); err != nil {
+		t.Fatal(err)
+	}
+
+	for range 2 {
+		_, err := svc.Login(ctx, "test@example.com", "wrong", "", "192.0.2.1")
+		if !errors.Is(err, auth.ErrInvalidPassword) {
+			t.Fatalf("Expected %s got: %v", auth.ErrInvalidPassword, err)
+		}
+	}
+
+	_, err := svc.Login(ctx, "TEST@example.com", "password", "", "192.0.2.2")
+	if !errors.Is(err, auth.ErrTooManyLoginAttempts) {
+		t.Errorf("Expected the account to be locked got: %v", err)
+	}
+
+	_, err = svc.Login(ctx, "other@example.com", "wrong", "", "192.0.2.1")
+	if !errors.Is(err, auth.ErrUserNotFound) {
+		t.Fatalf("Expected %s got: %v", auth.ErrUserNotFound, err)
+	}
+
+	_, err = svc.Login(ctx, "another@example.com", "wrong", "", "192.0.2.1")
+	if !errors.Is(err, auth.ErrTooManyLoginAttempts) {
+		t.Errorf("Expected the IP address to be locked got: %v", err)
+	}
+
+	lockouts, err := svc.ListLoginLockouts(ctx, auth.User{ID: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lockouts) != 2 {
+		t.Errorf("Expected the account and IP address to be locked got: %v", lockouts)
+	}
+
+	if err := svc.UnlockLogin(ctx, auth.User{ID: 100}, auth.LoginFailureAccount, "test@example.com"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Login(ctx, "test@example.com", "password", "", "192.0.2.2"); err != nil {
+		t.Errorf("Expected unlocked accounts to be able to login got: %s", err)
+	}
+
+	err = svc.UnlockLogin(ctx, auth.User{ID: 100}, "device", "test")
+	if !errors.Is(err, auth.ErrUnknownLoginFailureKind) {
+		t.Errorf("Expected %s got: %v", auth.ErrUnknownLoginFailureKind, err)
+	}
+}
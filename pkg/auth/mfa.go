@@ -0,0 +1,279 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+var (
+	// ErrMFARequired is returned by Login when the user has enrolled in MFA
+	// but no code was given.
+	ErrMFARequired = fmt.Errorf("%w: an MFA code is required", ErrUnauthenticated)
+	// ErrMFAEnrolmentRequired is returned by Login when the user's
+	// permissions require MFA but they haven't enrolled yet.
+	ErrMFAEnrolmentRequired = fmt.Errorf("%w: MFA enrolment is required", ErrUnauthenticated)
+	ErrInvalidMFACode       = fmt.Errorf("%w: MFA code is invalid", ErrUnauthenticated)
+	ErrMFAAlreadyEnrolled   = errors.New("already enrolled in MFA")
+	ErrMFANotEnrolled       = errors.New("not enrolled in MFA")
+	ErrMFAUnsupported       = errors.New("MFA is not enabled")
+)
+
+// RecoveryCodeCount is how many recovery codes are issued when enrolling.
+const RecoveryCodeCount = 10
+
+// MFAEnrolment is returned when starting to enrol so that the user can add
+// the secret to their authenticator app.
+type MFAEnrolment struct {
+	Secret          string
+	ProvisioningURI string
+}
+
+type mfaSecret struct {
+	UserID       int        `db:"user_id"`
+	Secret       string     `db:"secret"`
+	ConfirmedAt  *time.Time `db:"confirmed_at"`
+	LastUsedStep int64      `db:"last_used_step"`
+}
+
+// MFAStore stores users' TOTP secrets and recovery codes. A nil MFAStore means
+// MFA is disabled.
+type MFAStore struct {
+	pool *pgxpool.Pool
+	log  zerolog.Logger
+}
+
+func NewMFAStore(log zerolog.Logger, pool *pgxpool.Pool) *MFAStore {
+	return &MFAStore{
+		pool: pool,
+		log:  log,
+	}
+}
+
+const mfaSecretColumns = "user_id, secret, confirmed_at, last_used_step"
+
+func (ms *MFAStore) getSecret(ctx context.Context, userID UserID) (mfaSecret, error) {
+	secret, err := postgresutils.GetOne[mfaSecret](
+		ms.pool,
+		ctx,
+		"SELECT "+mfaSecretColumns+" FROM mfa_secrets WHERE user_id = $1",
+		userID,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return mfaSecret{}, ErrMFANotEnrolled
+	}
+
+	return secret, err
+}
+
+// Enrolled returns whether the user has confirmed their MFA enrolment.
+func (ms *MFAStore) Enrolled(ctx context.Context, userID UserID) (bool, error) {
+	secret, err := ms.getSecret(ctx, userID)
+	if errors.Is(err, ErrMFANotEnrolled) {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+
+	return secret.ConfirmedAt != nil, nil
+}
+
+// Enrol generates a new secret for the user which must be confirmed with a
+// code before it's required at login. Enrolling again before confirming
+// replaces the secret.
+func (ms *MFAStore) Enrol(ctx context.Context, user User) (MFAEnrolment, error) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return MFAEnrolment{}, err
+	}
+
+	tag, err := ms.pool.Exec(
+		ctx,
+		`INSERT INTO mfa_secrets (user_id, secret) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET secret = EXCLUDED.secret, created_at = current_timestamp
+		WHERE mfa_secrets.confirmed_at IS NULL`,
+		user.ID,
+		secret,
+	)
+	if err != nil {
+		return MFAEnrolment{}, err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return MFAEnrolment{}, ErrMFAAlreadyEnrolled
+	}
+
+	return MFAEnrolment{
+		Secret:          secret,
+		ProvisioningURI: TOTPProvisioningURI(TokenIssuer, user.Email, secret),
+	}, nil
+}
+
+// Confirm checks code against the secret from Enrol and, when it matches,
+// requires MFA for the user from now on. The returned recovery codes can each
+// be used once instead of a code and are never shown again.
+func (ms *MFAStore) Confirm(ctx context.Context, user User, code string) ([]string, error) {
+	secret, err := ms.getSecret(ctx, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	if secret.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnrolled
+	}
+
+	step, ok := matchTOTPCode(secret.Secret, code, time.Now())
+	if !ok {
+		return nil, ErrInvalidMFACode
+	}
+
+	codes := make([]string, RecoveryCodeCount)
+	for i := range codes {
+		codes[i], err = generateRecoveryCode()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tx, err := ms.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer postgresutils.Rollback(ctx, tx, ms.log)
+
+	tag, err := tx.Exec(
+		ctx,
+		`UPDATE mfa_secrets SET confirmed_at = current_timestamp, last_used_step = $3
+		WHERE user_id = $1 AND secret = $2 AND confirmed_at IS NULL`,
+		user.ID,
+		secret.Secret,
+		step,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// The secret was replaced or confirmed by another request.
+	if tag.RowsAffected() == 0 {
+		return nil, ErrInvalidMFACode
+	}
+
+	for _, code := range codes {
+		_, err := tx.Exec(
+			ctx,
+			"INSERT INTO mfa_recovery_codes (code_hash, user_id) VALUES ($1, $2)",
+			HashAPIToken(normaliseRecoveryCode(code)),
+			user.ID,
+		)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return nil, err
+	}
+
+	ms.log.Info().
+		Interface("userID", user.ID).
+		Bool("audit", true).
+		Msg("enrolled in MFA")
+	return codes, nil
+}
+
+// Verify checks a TOTP or recovery code for the user. Each code can only be
+// used once.
+func (ms *MFAStore) Verify(ctx context.Context, userID UserID, code string) error {
+	secret, err := ms.getSecret(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if secret.ConfirmedAt == nil {
+		return ErrMFANotEnrolled
+	}
+
+	if step, ok := matchTOTPCode(secret.Secret, code, time.Now()); ok {
+		// Only moving forward stops a code being replayed while it's still
+		// valid.
+		tag, err := ms.pool.Exec(
+			ctx,
+			"UPDATE mfa_secrets SET last_used_step = $2 WHERE user_id = $1 AND last_used_step < $2",
+			userID,
+			step,
+		)
+		if err != nil {
+			return err
+		}
+
+		if tag.RowsAffected() == 0 {
+			return ErrInvalidMFACode
+		}
+
+		return nil
+	}
+
+	tag, err := ms.pool.Exec(
+		ctx,
+		`UPDATE mfa_recovery_codes SET used_at = current_timestamp
+		WHERE code_hash = $1 AND user_id = $2 AND used_at IS NULL`,
+		HashAPIToken(normaliseRecoveryCode(code)),
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrInvalidMFACode
+	}
+
+	ms.log.Info().
+		Interface("userID", userID).
+		Bool("audit", true).
+		Msg("used MFA recovery code")
+	return nil
+}
+
+// Reset removes the user's MFA secret and recovery codes so that they can
+// enrol again, for example after losing their device.
+func (ms *MFAStore) Reset(ctx context.Context, actor User, userID UserID) error {
+	tag, err := ms.pool.Exec(ctx, "DELETE FROM mfa_secrets WHERE user_id = $1", userID)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrMFANotEnrolled
+	}
+
+	ms.log.Info().
+		Interface("actorID", actor.ID).
+		Interface("userID", userID).
+		Bool("audit", true).
+		Msg("reset MFA")
+	return nil
+}
+
+// generateRecoveryCode returns a code like abcde-fghij.
+func generateRecoveryCode() (string, error) {
+	raw := make([]byte, 10)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+
+	code := strings.ToLower(totpEncoding.EncodeToString(raw))[:10]
+	return code[:5] + "-" + code[5:], nil
+}
+
+func normaliseRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
@@ -0,0 +1,99 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/rs/zerolog"
+)
+
+func TestMFA(t *testing.T) {
+	pool := postgresutils.InitTestDB(t)
+	mfa := auth.NewMFAStore(zerolog.New(nil).Level(zerolog.Disabled), pool)
+	gateway := auth.NewTestGateway()
+	svc := auth.NewUserService(gateway, gateway, &auth.TokenRegistry{}, nil, mfa, true, "", auth.PasswordPolicy{}, &auth.TestNotifier{}, nil)
+
+	ctx := context.Background()
+	user, err := svc.Register(ctx, "test@example.com", "password")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.Login(ctx, user.Email, "password", "", "")
+	if !errors.Is(err, auth.ErrMFAEnrolmentRequired) {
+		t.Fatalf("Expected users with sensitive permissions to have to enrol got: %v", err)
+	}
+
+	enrolment, err := svc.EnrolMFA(ctx, user.Email, "password", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uri, err := url.Parse(enrolment.ProvisioningURI)
+	if err != nil || uri.Query().Get("secret") != enrolment.Secret {
+		t.Fatalf("Expected the provisioning URI to contain the secret got: %s", enrolment.ProvisioningURI)
+	}
+
+	// Codes are for 30 second periods so use the previous one to confirm and
+	// the current one to login.
+	previous, err := auth.TOTPCode(enrolment.Secret, auth.TOTPStep(time.Now())-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	current, err := auth.TOTPCode(enrolment.Secret, auth.TOTPStep(time.Now()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.ConfirmMFA(ctx, user.Email, "password", "wrong", "")
+	if !errors.Is(err, auth.ErrInvalidMFACode) {
+		t.Errorf("Expected %s got: %v", auth.ErrInvalidMFACode, err)
+	}
+
+	recoveryCodes, err := svc.ConfirmMFA(ctx, user.Email, "password", previous, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(recoveryCodes) != auth.RecoveryCodeCount {
+		t.Errorf("Expected %d recovery codes got: %v", auth.RecoveryCodeCount, recoveryCodes)
+	}
+
+	if _, err := svc.EnrolMFA(ctx, user.Email, "password", ""); !errors.Is(err, auth.ErrMFAAlreadyEnrolled) {
+		t.Errorf("Expected %s got: %v", auth.ErrMFAAlreadyEnrolled, err)
+	}
+
+	if _, err := svc.Login(ctx, user.Email, "password", "", ""); !errors.Is(err, auth.ErrMFARequired) {
+		t.Errorf("Expected %s got: %v", auth.ErrMFARequired, err)
+	}
+
+	if _, err := svc.Login(ctx, user.Email, "password", previous, ""); !errors.Is(err, auth.ErrInvalidMFACode) {
+		t.Errorf("Expected used codes to be rejected got: %v", err)
+	}
+
+	if _, err := svc.Login(ctx, user.Email, "password", current, ""); err != nil {
+		t.Errorf("Expected to login with the current code got: %s", err)
+	}
+
+	if _, err := svc.Login(ctx, user.Email, "password", recoveryCodes[0], ""); err != nil {
+		t.Errorf("Expected to login with a recovery code got: %s", err)
+	}
+
+	if _, err := svc.Login(ctx, user.Email, "password", recoveryCodes[0], ""); !errors.Is(err, auth.ErrInvalidMFACode) {
+		t.Errorf("Expected recovery codes to only work once got: %v", err)
+	}
+
+	if err := svc.ResetMFA(ctx, auth.User{ID: 100}, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Login(ctx, user.Email, "password", "", ""); !errors.Is(err, auth.ErrMFAEnrolmentRequired) {
+		t.Errorf("Expected to have to enrol again after a reset got: %v", err)
+	}
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+// Notifier delivers messages to users which must not go through the API, like
+// password reset tokens.
+type Notifier interface {
+	NotifyPasswordReset(ctx context.Context, user User, token string, expiresAt time.Time) error
+}
+
+// LogNotifier writes notifications to the log for an operator to pass on. It's
+// meant for development and small installations since anyone who can read the
+// logs can reset passwords.
+type LogNotifier struct {
+	log zerolog.Logger
+}
+
+func NewLogNotifier(log zerolog.Logger) *LogNotifier {
+	return &LogNotifier{log: log}
+}
+
+func (n *LogNotifier) NotifyPasswordReset(ctx context.Context, user User, token string, expiresAt time.Time) error {
+	n.log.Info().
+		Interface("userID", user.ID).
+		Str("email", user.Email).
+		Str("token", token).
+		Time("expiresAt", expiresAt).
+		Msg("password reset requested")
+	return nil
+}
+
+// FileNotification is a line written by FileNotifier.
+type FileNotification struct {
+	Kind      string
+	UserID    UserID
+	Email     string
+	Token     string
+	ExpiresAt time.Time
+}
+
+// FileNotifier appends notifications as JSON lines to a file which only its
+// owner can read, for delivery by another process.
+type FileNotifier struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewFileNotifier(path string) *FileNotifier {
+	return &FileNotifier{path: path}
+}
+
+func (n *FileNotifier) NotifyPasswordReset(ctx context.Context, user User, token string, expiresAt time.Time) error {
+	line, err := json.Marshal(FileNotification{
+		Kind:      "password-reset",
+		UserID:    user.ID,
+		Email:     user.Email,
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	f, err := os.OpenFile(n.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
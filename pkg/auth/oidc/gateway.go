@@ -0,0 +1,487 @@
+// Package oidc implements an auth.AuthenticationGateway which logs users in
+// through an OpenID Connect identity provider.
+package oidc
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/config-source/cdb/pkg/audit"
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+)
+
+var (
+	ErrInvalidToken  = fmt.Errorf("%w: invalid identity token", auth.ErrUnauthenticated)
+	ErrEmailRequired = fmt.Errorf("%w: identity token does not contain a verified email", auth.ErrUnauthenticated)
+	// ErrAccountNotLinked is returned when a login's email belongs to a user
+	// who isn't linked to the identity logging in.
+	ErrAccountNotLinked = fmt.Errorf("%w: an account with this email exists but is not linked to this identity", auth.ErrUnauthenticated)
+)
+
+var validMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+	"EdDSA",
+}
+
+type Config struct {
+	IssuerURL    string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL is where the identity provider sends the user back to, it
+	// should be CDB's /api/v1/auth/oidc/callback.
+	RedirectURL string
+	// Scopes requested in addition to openid.
+	Scopes []string
+	// GroupsClaim is the name of the ID token claim listing the user's groups.
+	GroupsClaim string
+	// GroupRoles maps identity provider groups to the CDB roles their members
+	// are given.
+	GroupRoles map[string]string
+	// LinkExistingUsers links users who existed before they first logged in
+	// through the identity provider by their verified email. It's only
+	// intended for upgrading deployments whose users predate identity
+	// links, otherwise anyone who can assert an email at the identity
+	// provider can take over the account with it.
+	LinkExistingUsers bool
+
+	// HTTPClient is used to talk to the identity provider, defaults to
+	// http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+// identity is who an ID token says the user is.
+type identity struct {
+	Issuer  string
+	Subject string
+	Email   string
+	Groups  []string
+}
+
+// Gateway implements auth.AuthenticationGateway, auth.RedirectAuthenticator
+// and auth.BearerTokenAuthenticator against an OpenID Connect identity
+// provider. Users are stored by the wrapped AuthenticationGateway and are
+// created the first time they log in, afterwards they're matched by the
+// subject the identity provider asserts.
+type Gateway struct {
+	cfg        Config
+	log        zerolog.Logger
+	users      auth.AuthenticationGateway
+	identities auth.IdentityLinker
+	authz      auth.AuthorizationGateway
+	audit      *audit.Log
+
+	metadata providerMetadata
+	keys     *keySet
+}
+
+// NewGateway discovers the identity provider's endpoints from cfg.IssuerURL.
+func NewGateway(
+	ctx context.Context,
+	log zerolog.Logger,
+	cfg Config,
+	users auth.AuthenticationGateway,
+	identities auth.IdentityLinker,
+	authz auth.AuthorizationGateway,
+	auditLog *audit.Log,
+) (*Gateway, error) {
+	if cfg.IssuerURL == "" || cfg.ClientID == "" || cfg.RedirectURL == "" {
+		return nil, errors.New("an issuer URL, client ID and redirect URL are required")
+	}
+
+	if cfg.HTTPClient == nil {
+		cfg.HTTPClient = http.DefaultClient
+	}
+
+	if cfg.GroupsClaim == "" {
+		cfg.GroupsClaim = "groups"
+	}
+
+	metadata, err := discover(ctx, cfg.HTTPClient, cfg.IssuerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Gateway{
+		cfg:        cfg,
+		log:        log,
+		users:      users,
+		identities: identities,
+		authz:      authz,
+		audit:      auditLog,
+		metadata:   metadata,
+		keys:       newKeySet(cfg.HTTPClient, metadata.JWKSURI),
+	}, nil
+}
+
+func (g *Gateway) Register(ctx context.Context, email, password string) (auth.User, error) {
+	return auth.User{}, auth.ErrPasswordLoginDisabled
+}
+
+func (g *Gateway) Login(ctx context.Context, email, password string) (auth.User, error) {
+	return auth.User{}, auth.ErrPasswordLoginDisabled
+}
+
+// CreateUser lets administrators create users ahead of their first login so
+// that they can be given service roles. The first login with the user's email
+// is linked to them.
+func (g *Gateway) CreateUser(ctx context.Context, newUser auth.User) (auth.User, error) {
+	user, err := g.createUser(ctx, newUser.Email)
+	if err != nil {
+		return auth.User{}, err
+	}
+
+	return user, g.identities.LinkIdentity(ctx, g.metadata.Issuer, "", user.ID)
+}
+
+func (g *Gateway) createUser(ctx context.Context, email string) (auth.User, error) {
+	password, err := randomString(32)
+	if err != nil {
+		return auth.User{}, err
+	}
+
+	// The password can never be used but must be set and unique.
+	return g.users.CreateUser(ctx, auth.User{Email: email, Password: password})
+}
+
+func (g *Gateway) GetUser(ctx context.Context, userID auth.UserID) (auth.User, error) {
+	return g.users.GetUser(ctx, userID)
+}
+
+func (g *Gateway) GetUserByEmail(ctx context.Context, email string) (auth.User, error) {
+	return g.users.GetUserByEmail(ctx, email)
+}
+
+func (g *Gateway) DeleteUser(ctx context.Context, userID auth.UserID) error {
+	return g.users.DeleteUser(ctx, userID)
+}
+
+func (g *Gateway) ListUsers(ctx context.Context) ([]auth.User, error) {
+	return g.users.ListUsers(ctx)
+}
+
+func (g *Gateway) SetUserDisabled(ctx context.Context, userID auth.UserID, disabled bool) (auth.User, error) {
+	return g.users.SetUserDisabled(ctx, userID, disabled)
+}
+
+func (g *Gateway) Healthy(ctx context.Context) bool {
+	return g.users.Healthy(ctx)
+}
+
+func (g *Gateway) StartLogin() (auth.RedirectLogin, error) {
+	state, err := randomString(32)
+	if err != nil {
+		return auth.RedirectLogin{}, err
+	}
+
+	nonce, err := randomString(32)
+	if err != nil {
+		return auth.RedirectLogin{}, err
+	}
+
+	codeVerifier, err := randomString(32)
+	if err != nil {
+		return auth.RedirectLogin{}, err
+	}
+
+	redirect, err := url.Parse(g.metadata.AuthorizationEndpoint)
+	if err != nil {
+		return auth.RedirectLogin{}, err
+	}
+
+	query := redirect.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", g.cfg.ClientID)
+	query.Set("redirect_uri", g.cfg.RedirectURL)
+	query.Set("scope", strings.Join(append([]string{"openid"}, g.cfg.Scopes...), " "))
+	query.Set("state", state)
+	query.Set("nonce", nonce)
+	query.Set("code_challenge", codeChallenge(codeVerifier))
+	query.Set("code_challenge_method", "S256")
+	redirect.RawQuery = query.Encode()
+
+	return auth.RedirectLogin{
+		URL:          redirect.String(),
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: codeVerifier,
+	}, nil
+}
+
+type tokenResponse struct {
+	IDToken string `json:"id_token"`
+
+	Error            string `json:"error"`
+	ErrorDescription string `json:"error_description"`
+}
+
+func (g *Gateway) exchange(ctx context.Context, code, codeVerifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {g.cfg.RedirectURL},
+		"client_id":     {g.cfg.ClientID},
+		"code_verifier": {codeVerifier},
+	}
+	if g.cfg.ClientSecret != "" {
+		form.Set("client_secret", g.cfg.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.metadata.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := g.cfg.HTTPClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var tokens tokenResponse
+	if err := json.NewDecoder(resp.Body).Decode(&tokens); err != nil {
+		return "", fmt.Errorf("unable to decode token response: %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || tokens.Error != "" {
+		return "", fmt.Errorf("%w: %s %s", auth.ErrUnauthenticated, tokens.Error, tokens.ErrorDescription)
+	}
+
+	if tokens.IDToken == "" {
+		return "", fmt.Errorf("%w: token response did not include an ID token", ErrInvalidToken)
+	}
+
+	return tokens.IDToken, nil
+}
+
+func (g *Gateway) FinishLogin(ctx context.Context, code, codeVerifier, nonce string) (auth.User, error) {
+	if nonce == "" {
+		return auth.User{}, fmt.Errorf("%w: login has no nonce", ErrInvalidToken)
+	}
+
+	idToken, err := g.exchange(ctx, code, codeVerifier)
+	if err != nil {
+		return auth.User{}, err
+	}
+
+	id, err := g.validate(ctx, idToken, nonce)
+	if err != nil {
+		return auth.User{}, err
+	}
+
+	return g.provision(ctx, id, true)
+}
+
+// AuthenticateBearerToken accepts ID tokens issued by the identity provider
+// for CDB. Roles are only synced from the token's groups when the user is
+// first seen, interactive logins keep them up to date afterwards.
+func (g *Gateway) AuthenticateBearerToken(ctx context.Context, token string) (auth.User, error) {
+	id, err := g.validate(ctx, token, "")
+	if err != nil {
+		return auth.User{}, err
+	}
+
+	return g.provision(ctx, id, false)
+}
+
+// validate checks the token was issued by the identity provider for CDB. When
+// nonce is set the token must have been issued for the login it belongs to.
+func (g *Gateway) validate(ctx context.Context, rawToken, nonce string) (identity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(
+		rawToken,
+		claims,
+		func(token *jwt.Token) (interface{}, error) {
+			kid, _ := token.Header["kid"].(string)
+			return g.keys.key(ctx, kid)
+		},
+		jwt.WithValidMethods(validMethods),
+		jwt.WithIssuer(g.metadata.Issuer),
+		jwt.WithAudience(g.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		return identity{}, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	if nonce != "" {
+		tokenNonce, _ := claims["nonce"].(string)
+		if subtle.ConstantTimeCompare([]byte(tokenNonce), []byte(nonce)) != 1 {
+			return identity{}, fmt.Errorf("%w: nonce does not match the login", ErrInvalidToken)
+		}
+	}
+
+	subject, _ := claims["sub"].(string)
+	if subject == "" {
+		return identity{}, fmt.Errorf("%w: token has no subject", ErrInvalidToken)
+	}
+
+	// Identity providers which don't assert verification can't be trusted
+	// with the email.
+	email, _ := claims["email"].(string)
+	if verified, _ := claims["email_verified"].(bool); email == "" || !verified {
+		return identity{}, ErrEmailRequired
+	}
+
+	id := identity{Issuer: g.metadata.Issuer, Subject: subject, Email: email}
+	switch groups := claims[g.cfg.GroupsClaim].(type) {
+	case string:
+		id.Groups = []string{groups}
+	case []interface{}:
+		for _, group := range groups {
+			if name, ok := group.(string); ok {
+				id.Groups = append(id.Groups, name)
+			}
+		}
+	}
+
+	return id, nil
+}
+
+// provision returns the user linked to id, linking or creating them if this
+// is their first login.
+func (g *Gateway) provision(ctx context.Context, id identity, syncRoles bool) (auth.User, error) {
+	userID, err := g.identities.GetIdentityUser(ctx, id.Issuer, id.Subject)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		return g.link(ctx, id)
+	} else if err != nil {
+		return auth.User{}, err
+	}
+
+	user, err := g.users.GetUser(ctx, userID)
+	if err != nil {
+		return auth.User{}, err
+	}
+
+	if syncRoles {
+		if err := g.syncRoles(ctx, user, id.Groups); err != nil {
+			return auth.User{}, err
+		}
+	}
+
+	return user, nil
+}
+
+// link links id to the user with its email when they were created ahead of
+// their first login, or LinkExistingUsers is set, and otherwise creates a user
+// for it.
+func (g *Gateway) link(ctx context.Context, id identity) (auth.User, error) {
+	user, err := g.users.GetUserByEmail(ctx, id.Email)
+	if errors.Is(err, auth.ErrUserNotFound) {
+		user, err = g.createUser(ctx, id.Email)
+		if err != nil {
+			return auth.User{}, err
+		}
+
+		if err := g.identities.LinkIdentity(ctx, id.Issuer, id.Subject, user.ID); err != nil {
+			return auth.User{}, err
+		}
+
+		g.audit.Record(ctx, audit.System, audit.Event{
+			Action:     audit.ActionUserProvision,
+			TargetKind: audit.TargetUser,
+			TargetID:   strconv.Itoa(int(user.ID)),
+			After:      user,
+		})
+		g.recordLink(ctx, user, id)
+		return user, g.syncRoles(ctx, user, id.Groups)
+	} else if err != nil {
+		return auth.User{}, err
+	}
+
+	claimed, err := g.identities.ClaimIdentity(ctx, id.Issuer, id.Subject, user.ID)
+	if err != nil {
+		return auth.User{}, err
+	}
+
+	if !claimed && !g.cfg.LinkExistingUsers {
+		g.log.Warn().
+			Interface("user", user.ID).
+			Str("email", user.Email).
+			Str("subject", id.Subject).
+			Bool("denied", true).
+			Bool("audit", true).
+			Msg("identity attempted to log in as a user it isn't linked to")
+
+		return auth.User{}, ErrAccountNotLinked
+	}
+
+	if !claimed {
+		// Fails if the user is already linked to another subject.
+		if err := g.identities.LinkIdentity(ctx, id.Issuer, id.Subject, user.ID); errors.Is(err, auth.ErrIdentityConflict) {
+			return auth.User{}, ErrAccountNotLinked
+		} else if err != nil {
+			return auth.User{}, err
+		}
+	}
+
+	g.recordLink(ctx, user, id)
+	return user, g.syncRoles(ctx, user, id.Groups)
+}
+
+func (g *Gateway) recordLink(ctx context.Context, user auth.User, id identity) {
+	g.audit.Record(ctx, audit.System, audit.Event{
+		Action:     audit.ActionUserLinkIdentity,
+		TargetKind: audit.TargetUser,
+		TargetID:   strconv.Itoa(int(user.ID)),
+		After: struct {
+			Issuer  string
+			Subject string
+		}{id.Issuer, id.Subject},
+	})
+}
+
+// syncRoles grants the roles mapped from the user's groups and removes any
+// other mapped roles so that leaving a group revokes its role. Roles which
+// aren't mapped from a group are left alone.
+func (g *Gateway) syncRoles(ctx context.Context, user auth.User, groups []string) error {
+	if len(g.cfg.GroupRoles) == 0 {
+		return nil
+	}
+
+	var managed, granted []string
+	for group, role := range g.cfg.GroupRoles {
+		if !slices.Contains(managed, role) {
+			managed = append(managed, role)
+		}
+
+		if slices.Contains(groups, group) && !slices.Contains(granted, role) {
+			granted = append(granted, role)
+		}
+	}
+
+	slices.Sort(granted)
+	before, after, err := g.authz.SyncRolesNoAuth(ctx, user, managed, granted)
+	if err != nil {
+		return err
+	}
+
+	// Roles are synced on every login so only changes are recorded.
+	if !slices.Equal(before, after) {
+		g.audit.Record(ctx, audit.System, audit.Event{
+			Action:     audit.ActionUserSyncRoles,
+			TargetKind: audit.TargetUser,
+			TargetID:   strconv.Itoa(int(user.ID)),
+			Before:     before,
+			After:      after,
+		})
+	}
+
+	return nil
+}
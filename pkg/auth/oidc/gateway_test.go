@@ -0,0 +1,497 @@
+package oidc_test
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/config-source/cdb/pkg/audit"
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/auth/oidc"
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/rs/zerolog"
+)
+
+const (
+	clientID    = "cdb"
+	redirectURL = "http://cdb.example.com/api/v1/auth/oidc/callback"
+)
+
+// mockProvider is a minimal OpenID Connect identity provider which issues ID
+// tokens for authorization codes registered with it.
+type mockProvider struct {
+	*httptest.Server
+
+	key *rsa.PrivateKey
+	kid string
+
+	mu    sync.Mutex
+	codes map[string]mockCode
+}
+
+type mockCode struct {
+	challenge string
+	claims    jwt.MapClaims
+}
+
+func newMockProvider(t *testing.T) *mockProvider {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mp := &mockProvider{
+		key:   key,
+		kid:   "test-key",
+		codes: make(map[string]mockCode),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{ // nolint:errcheck
+			"issuer":                 mp.URL,
+			"authorization_endpoint": mp.URL + "/authorize",
+			"token_endpoint":         mp.URL + "/token",
+			"jwks_uri":               mp.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("GET /jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{ // nolint:errcheck
+			"keys": []map[string]string{
+				{
+					"kid": mp.kid,
+					"kty": "RSA",
+					"use": "sig",
+					"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+					"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+				},
+			},
+		})
+	})
+	mux.HandleFunc("POST /token", mp.token)
+
+	mp.Server = httptest.NewServer(mux)
+	t.Cleanup(mp.Close)
+	return mp
+}
+
+func (mp *mockProvider) token(w http.ResponseWriter, r *http.Request) {
+	mp.mu.Lock()
+	code, ok := mp.codes[r.FormValue("code")]
+	delete(mp.codes, r.FormValue("code"))
+	mp.mu.Unlock()
+
+	verifier := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+	if !ok ||
+		r.FormValue("client_id") != clientID ||
+		r.FormValue("redirect_uri") != redirectURL ||
+		base64.RawURLEncoding.EncodeToString(verifier[:]) != code.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"}) // nolint:errcheck
+		return
+	}
+
+	json.NewEncoder(w).Encode(map[string]string{"id_token": mp.sign(code.claims)}) // nolint:errcheck
+}
+
+// authorize registers a code for the login started at loginURL as if the user
+// had logged in to the provider and returns it. The login's nonce is added to
+// claims unless they already have one.
+func (mp *mockProvider) authorize(t *testing.T, loginURL string, claims jwt.MapClaims) string {
+	t.Helper()
+
+	parsed, err := url.Parse(loginURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := parsed.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("client_id") != clientID {
+		t.Fatalf("Expected a PKCE login for %s got: %s", clientID, loginURL)
+	}
+
+	if _, ok := claims["nonce"]; !ok {
+		claims["nonce"] = query.Get("nonce")
+	}
+
+	code := "code-" + query.Get("state")
+	mp.mu.Lock()
+	mp.codes[code] = mockCode{challenge: query.Get("code_challenge"), claims: claims}
+	mp.mu.Unlock()
+
+	return code
+}
+
+func (mp *mockProvider) claims(email string, groups ...string) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":            mp.URL,
+		"aud":            clientID,
+		"sub":            email,
+		"email":          email,
+		"email_verified": true,
+		"groups":         groups,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func (mp *mockProvider) sign(claims jwt.MapClaims) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = mp.kid
+	signed, err := token.SignedString(mp.key)
+	if err != nil {
+		panic(err)
+	}
+
+	return signed
+}
+
+func newGateway(t *testing.T, mp *mockProvider) (*oidc.Gateway, *auth.TestGateway) {
+	t.Helper()
+	return newGatewayWithConfig(t, mp, oidc.Config{}, nil)
+}
+
+func newGatewayWithConfig(t *testing.T, mp *mockProvider, cfg oidc.Config, auditLog *audit.Log) (*oidc.Gateway, *auth.TestGateway) {
+	t.Helper()
+
+	store := auth.NewTestGateway()
+	gateway, err := oidc.NewGateway(
+		context.Background(),
+		zerolog.New(nil).Level(zerolog.Disabled),
+		oidc.Config{
+			IssuerURL:   mp.URL,
+			ClientID:    clientID,
+			RedirectURL: redirectURL,
+			Scopes:      []string{"email"},
+			GroupRoles: map[string]string{
+				"platform": "Administrator",
+				"sre":      "Operator",
+			},
+			HTTPClient: mp.Client(),
+
+			LinkExistingUsers: cfg.LinkExistingUsers,
+		},
+		store,
+		store,
+		store,
+		auditLog,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return gateway, store
+}
+
+// login logs in to gateway as if the user had logged in to the provider with
+// claims.
+func login(t *testing.T, mp *mockProvider, gateway *oidc.Gateway, claims jwt.MapClaims) (auth.User, error) {
+	t.Helper()
+
+	started, err := gateway.StartLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := mp.authorize(t, started.URL, claims)
+	return gateway.FinishLogin(context.Background(), code, started.CodeVerifier, started.Nonce)
+}
+
+func TestLoginProvisionsUsersWithMappedRoles(t *testing.T) {
+	mp := newMockProvider(t)
+	gateway, store := newGateway(t, mp)
+
+	user, err := login(t, mp, gateway, mp.claims("jane@example.com", "sre", "unmapped"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Email != "jane@example.com" || user.ID == 0 {
+		t.Fatalf("Expected jane@example.com to be provisioned got: %s", user)
+	}
+
+	if _, err := store.GetUserByEmail(context.Background(), "jane@example.com"); err != nil {
+		t.Errorf("Expected the user to be stored got: %s", err)
+	}
+
+	if roles := store.SyncedRoles[user.ID]; !reflect.DeepEqual(roles, []string{"Operator"}) {
+		t.Errorf("Expected the Operator role to be granted got: %v", roles)
+	}
+
+	again, err := login(t, mp, gateway, mp.claims("jane@example.com", "platform"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again.ID != user.ID {
+		t.Errorf("Expected the existing user to be logged in got: %d", again.ID)
+	}
+
+	if roles := store.SyncedRoles[user.ID]; !reflect.DeepEqual(roles, []string{"Administrator"}) {
+		t.Errorf("Expected roles to follow the user's groups got: %v", roles)
+	}
+}
+
+func TestLoginIsAudited(t *testing.T) {
+	mp := newMockProvider(t)
+	auditLog := audit.NewLog(zerolog.New(nil).Level(zerolog.Disabled), postgresutils.InitTestDB(t))
+	gateway, _ := newGatewayWithConfig(t, mp, oidc.Config{}, auditLog)
+
+	for _, group := range []string{"sre", "sre", "platform"} {
+		if _, err := login(t, mp, gateway, mp.claims("jane@example.com", group)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := auditLog.List(context.Background(), audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Logging in again with the same groups doesn't change anything.
+	actions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+	}
+
+	expected := []string{
+		audit.ActionUserSyncRoles,
+		audit.ActionUserSyncRoles,
+		audit.ActionUserLinkIdentity,
+		audit.ActionUserProvision,
+	}
+	if !reflect.DeepEqual(actions, expected) {
+		t.Errorf("Expected %v to be audited got: %v", expected, actions)
+	}
+}
+
+func TestFinishLoginRequiresTheCodeVerifier(t *testing.T) {
+	mp := newMockProvider(t)
+	gateway, _ := newGateway(t, mp)
+
+	started, err := gateway.StartLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := gateway.StartLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := mp.authorize(t, started.URL, mp.claims("jane@example.com"))
+	_, err = gateway.FinishLogin(context.Background(), code, other.CodeVerifier, started.Nonce)
+	if !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Errorf("Expected %s got: %v", auth.ErrUnauthenticated, err)
+	}
+}
+
+func TestFinishLoginRequiresTheNonce(t *testing.T) {
+	mp := newMockProvider(t)
+	gateway, _ := newGateway(t, mp)
+
+	replayed := mp.claims("jane@example.com")
+	replayed["nonce"] = "from-another-login"
+
+	missing := mp.claims("jane@example.com")
+	missing["nonce"] = nil
+
+	for name, claims := range map[string]jwt.MapClaims{"replayed": replayed, "missing": missing} {
+		_, err := login(t, mp, gateway, claims)
+		if !errors.Is(err, auth.ErrUnauthenticated) {
+			t.Errorf("Expected token with %s nonce to be rejected got: %v", name, err)
+		}
+	}
+
+	started, err := gateway.StartLogin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	code := mp.authorize(t, started.URL, mp.claims("jane@example.com"))
+	_, err = gateway.FinishLogin(context.Background(), code, started.CodeVerifier, "")
+	if !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Errorf("Expected a login without a nonce to be rejected got: %v", err)
+	}
+}
+
+func TestLoginMatchesUsersBySubject(t *testing.T) {
+	mp := newMockProvider(t)
+	gateway, _ := newGateway(t, mp)
+
+	claims := mp.claims("jane@example.com")
+	claims["sub"] = "jane"
+	user, err := login(t, mp, gateway, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Jane's email has been given to someone else at the identity provider.
+	impostor := mp.claims("jane@example.com")
+	impostor["sub"] = "someone-else"
+	if _, err := login(t, mp, gateway, impostor); !errors.Is(err, oidc.ErrAccountNotLinked) {
+		t.Errorf("Expected %s got: %v", oidc.ErrAccountNotLinked, err)
+	}
+
+	// Jane has changed her email at the identity provider.
+	renamed := mp.claims("jane.doe@example.com")
+	renamed["sub"] = "jane"
+	again, err := login(t, mp, gateway, renamed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if again.ID != user.ID {
+		t.Errorf("Expected jane to be logged in as %d got: %d", user.ID, again.ID)
+	}
+}
+
+func TestLoginDoesNotLinkExistingUsers(t *testing.T) {
+	mp := newMockProvider(t)
+	gateway, store := newGateway(t, mp)
+
+	if _, err := store.CreateUser(context.Background(), auth.User{Email: "jane@example.com", Password: "password"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := login(t, mp, gateway, mp.claims("jane@example.com")); !errors.Is(err, oidc.ErrAccountNotLinked) {
+		t.Errorf("Expected %s got: %v", oidc.ErrAccountNotLinked, err)
+	}
+}
+
+func TestLoginLinksExistingUsersWhenConfigured(t *testing.T) {
+	mp := newMockProvider(t)
+	gateway, store := newGatewayWithConfig(t, mp, oidc.Config{LinkExistingUsers: true}, nil)
+
+	local, err := store.CreateUser(context.Background(), auth.User{Email: "jane@example.com", Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := mp.claims("jane@example.com")
+	claims["sub"] = "jane"
+	user, err := login(t, mp, gateway, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.ID != local.ID {
+		t.Errorf("Expected jane to be logged in as %d got: %d", local.ID, user.ID)
+	}
+
+	impostor := mp.claims("jane@example.com")
+	impostor["sub"] = "someone-else"
+	if _, err := login(t, mp, gateway, impostor); !errors.Is(err, oidc.ErrAccountNotLinked) {
+		t.Errorf("Expected %s got: %v", oidc.ErrAccountNotLinked, err)
+	}
+}
+
+func TestLoginClaimsUsersCreatedAheadOfTime(t *testing.T) {
+	mp := newMockProvider(t)
+	gateway, _ := newGateway(t, mp)
+
+	created, err := gateway.CreateUser(context.Background(), auth.User{Email: "jane@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := mp.claims("jane@example.com")
+	claims["sub"] = "jane"
+	user, err := login(t, mp, gateway, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.ID != created.ID {
+		t.Errorf("Expected jane to be logged in as %d got: %d", created.ID, user.ID)
+	}
+
+	impostor := mp.claims("jane@example.com")
+	impostor["sub"] = "someone-else"
+	if _, err := login(t, mp, gateway, impostor); !errors.Is(err, oidc.ErrAccountNotLinked) {
+		t.Errorf("Expected %s got: %v", oidc.ErrAccountNotLinked, err)
+	}
+}
+
+func TestAuthenticateBearerToken(t *testing.T) {
+	mp := newMockProvider(t)
+	gateway, _ := newGateway(t, mp)
+
+	user, err := gateway.AuthenticateBearerToken(context.Background(), mp.sign(mp.claims("ci@example.com")))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Email != "ci@example.com" {
+		t.Errorf("Expected ci@example.com got: %s", user)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	forged := jwt.NewWithClaims(jwt.SigningMethodRS256, mp.claims("ci@example.com"))
+	forged.Header["kid"] = mp.kid
+	forgedToken, err := forged.SignedString(otherKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired := mp.claims("ci@example.com")
+	expired["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	wrongAudience := mp.claims("ci@example.com")
+	wrongAudience["aud"] = "someone-else"
+
+	unverified := mp.claims("ci@example.com")
+	unverified["email_verified"] = false
+
+	unasserted := mp.claims("ci@example.com")
+	delete(unasserted, "email_verified")
+
+	noSubject := mp.claims("ci@example.com")
+	delete(noSubject, "sub")
+
+	tests := map[string]string{
+		"forged":         forgedToken,
+		"expired":        mp.sign(expired),
+		"wrong audience": mp.sign(wrongAudience),
+		"unverified":     mp.sign(unverified),
+		"unasserted":     mp.sign(unasserted),
+		"no subject":     mp.sign(noSubject),
+		"malformed":      "not-a-token",
+	}
+
+	for name, token := range tests {
+		_, err := gateway.AuthenticateBearerToken(context.Background(), token)
+		if !errors.Is(err, auth.ErrUnauthenticated) {
+			t.Errorf("Expected %s token to be rejected got: %v", name, err)
+		}
+	}
+}
+
+func TestPasswordLoginIsDisabled(t *testing.T) {
+	mp := newMockProvider(t)
+	gateway, _ := newGateway(t, mp)
+
+	_, err := gateway.Login(context.Background(), "jane@example.com", "password")
+	if !errors.Is(err, auth.ErrPasswordLoginDisabled) {
+		t.Errorf("Expected %s got: %v", auth.ErrPasswordLoginDisabled, err)
+	}
+}
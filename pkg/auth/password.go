@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"unicode/utf8"
+)
+
+var (
+	ErrWeakPassword = errors.New("password does not meet the password policy")
+	// ErrPasswordChangeUnsupported is returned by gateways which don't store
+	// passwords, users change them with their identity provider instead.
+	ErrPasswordChangeUnsupported = errors.New("the authentication gateway does not support changing passwords")
+	// ErrPasswordResetTokenInvalid is returned when a password reset token
+	// has been used, has expired or was never issued.
+	ErrPasswordResetTokenInvalid = errors.New("password reset token is invalid or has expired")
+)
+
+// PasswordManager is implemented by AuthenticationGateways which store users'
+// passwords.
+type PasswordManager interface {
+	SetPassword(ctx context.Context, userID UserID, password string) error
+}
+
+// PasswordPolicy is checked whenever a password is chosen. The zero value
+// accepts any password.
+type PasswordPolicy struct {
+	MinLength int
+	breached  map[string]struct{}
+}
+
+// NewPasswordPolicy returns a policy requiring minLength characters which
+// rejects any password in breachedPasswordsFile, a file with one password per
+// line. When breachedPasswordsFile is empty only the length is checked.
+func NewPasswordPolicy(minLength int, breachedPasswordsFile string) (PasswordPolicy, error) {
+	policy := PasswordPolicy{MinLength: minLength}
+	if breachedPasswordsFile == "" {
+		return policy, nil
+	}
+
+	f, err := os.Open(breachedPasswordsFile)
+	if err != nil {
+		return PasswordPolicy{}, fmt.Errorf("unable to load breached passwords: %w", err)
+	}
+	defer f.Close()
+
+	policy.breached = make(map[string]struct{})
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if password := strings.TrimSpace(scanner.Text()); password != "" {
+			policy.breached[strings.ToLower(password)] = struct{}{}
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return PasswordPolicy{}, fmt.Errorf("unable to load breached passwords: %w", err)
+	}
+
+	return policy, nil
+}
+
+func (p PasswordPolicy) Check(password string) error {
+	if utf8.RuneCountInString(password) < p.MinLength {
+		return fmt.Errorf("%w: must be at least %d characters", ErrWeakPassword, p.MinLength)
+	}
+
+	// Compared case insensitively since changing the case of a breached
+	// password doesn't make it much harder to guess.
+	if _, ok := p.breached[strings.ToLower(password)]; ok {
+		return fmt.Errorf("%w: it appears in a list of breached passwords", ErrWeakPassword)
+	}
+
+	return nil
+}
@@ -0,0 +1,158 @@
+package auth_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/rs/zerolog"
+)
+
+func TestPasswordPolicy(t *testing.T) {
+	breached := filepath.Join(t.TempDir(), "breached.txt")
+	if err := os.WriteFile(breached, []byte("password123456\n\nCorrectHorseBattery\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	policy, err := auth.NewPasswordPolicy(12, breached)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, password := range []string{"short", "Password123456", "correcthorsebattery"} {
+		if err := policy.Check(password); !errors.Is(err, auth.ErrWeakPassword) {
+			t.Errorf("Expected %s to be rejected got: %v", password, err)
+		}
+	}
+
+	if err := policy.Check("a perfectly fine password"); err != nil {
+		t.Errorf("Expected the password to be accepted got: %s", err)
+	}
+
+	if err := (auth.PasswordPolicy{}).Check(""); err != nil {
+		t.Errorf("Expected the zero policy to accept any password got: %s", err)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	gateway := auth.NewTestGateway()
+	policy, _ := auth.NewPasswordPolicy(12, "")
+	svc := auth.NewUserService(gateway, gateway, &auth.TokenRegistry{}, nil, nil, true, "", policy, &auth.TestNotifier{}, nil)
+
+	ctx := context.Background()
+	_, err := svc.Register(ctx, "test@example.com", "short")
+	if !errors.Is(err, auth.ErrWeakPassword) {
+		t.Errorf("Expected registering with a weak password to fail got: %v", err)
+	}
+
+	user, err := svc.Register(ctx, "test@example.com", "old password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.ChangePassword(ctx, user, "wrong password", "new password123")
+	if !errors.Is(err, auth.ErrInvalidPassword) {
+		t.Errorf("Expected %s got: %v", auth.ErrInvalidPassword, err)
+	}
+
+	err = svc.ChangePassword(ctx, user, "old password123", "short")
+	if !errors.Is(err, auth.ErrWeakPassword) {
+		t.Errorf("Expected %s got: %v", auth.ErrWeakPassword, err)
+	}
+
+	if err := svc.ChangePassword(ctx, user, "old password123", "new password123"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Login(ctx, "test@example.com", "new password123", "", ""); err != nil {
+		t.Errorf("Expected to be able to login with the new password got: %s", err)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	pool := postgresutils.InitTestDB(t)
+	registry := auth.NewTokenRegistry(zerolog.New(nil).Level(zerolog.Disabled), pool)
+	gateway := auth.NewTestGateway()
+	notifier := &auth.TestNotifier{}
+	svc := auth.NewUserService(gateway, gateway, registry, nil, nil, true, "", auth.PasswordPolicy{MinLength: 12}, notifier, nil)
+
+	ctx := context.Background()
+	admin := auth.User{ID: 100, Email: "admin@example.com"}
+	user, err := svc.Register(ctx, "test@example.com", "old password123")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := svc.StartPasswordReset(ctx, admin, user.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	token := notifier.PasswordResets[user.ID]
+	if token == "" {
+		t.Fatal("Expected the reset token to be sent to the user")
+	}
+
+	if err := svc.ResetPassword(ctx, token, "short"); !errors.Is(err, auth.ErrWeakPassword) {
+		t.Errorf("Expected %s got: %v", auth.ErrWeakPassword, err)
+	}
+
+	if err := svc.ResetPassword(ctx, token, "new password123"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.Login(ctx, user.Email, "new password123", "", ""); err != nil {
+		t.Errorf("Expected to be able to login with the new password got: %s", err)
+	}
+
+	err = svc.ResetPassword(ctx, token, "another password123")
+	if !errors.Is(err, auth.ErrPasswordResetTokenInvalid) {
+		t.Errorf("Expected reset tokens to be single use got: %v", err)
+	}
+}
+
+func TestFileNotifier(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "notifications.jsonl")
+	notifier := auth.NewFileNotifier(path)
+	user := auth.User{ID: 1, Email: "test@example.com"}
+
+	err := notifier.NotifyPasswordReset(context.Background(), user, "token", time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("Expected notifications to only be readable by their owner got: %s", info.Mode())
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	if !scanner.Scan() {
+		t.Fatal("Expected a notification to be written")
+	}
+
+	var notification auth.FileNotification
+	if err := json.Unmarshal(scanner.Bytes(), &notification); err != nil {
+		t.Fatal(err)
+	}
+
+	if notification.Kind != "password-reset" || notification.UserID != user.ID || notification.Token != "token" {
+		t.Errorf("Unexpected notification: %+v", notification)
+	}
+}
@@ -1,12 +1,44 @@
 package auth
 
+import "fmt"
+
 type Permission string
 
 const (
 	PermissionConfigureEnvironments          Permission = "CAN_CONFIGURE_ENVIRONMENTS"
 	PermissionConfigureSensitiveEnvironments Permission = "CAN_CONFIGURE_SENSITIVE_ENVIRONMENTS"
+	PermissionReadConfiguration              Permission = "CAN_READ_CONFIGURATION"
+	PermissionReadSensitiveConfiguration     Permission = "CAN_READ_SENSITIVE_CONFIGURATION"
+	PermissionRevealSensitiveValues          Permission = "CAN_REVEAL_SENSITIVE_VALUES"
 	PermissionManageEnvironments             Permission = "CAN_MANAGE_ENVIRONMENTS"
 	PermissionManageRoles                    Permission = "CAN_MANAGE_ROLES"
 	PermissionManageUsers                    Permission = "CAN_MANAGE_USERS"
 	PermissionManageConfigKeys               Permission = "CAN_MANAGE_CONFIG_KEYS"
+	PermissionManageEphemeralEnvironments    Permission = "CAN_MANAGE_EPHEMERAL_ENVIRONMENTS"
+	PermissionViewAuditLog                   Permission = "CAN_VIEW_AUDIT_LOG"
 )
+
+// AllPermissions is every permission a role can grant.
+var AllPermissions = []Permission{
+	PermissionConfigureEnvironments,
+	PermissionConfigureSensitiveEnvironments,
+	PermissionReadConfiguration,
+	PermissionReadSensitiveConfiguration,
+	PermissionRevealSensitiveValues,
+	PermissionManageEnvironments,
+	PermissionManageRoles,
+	PermissionManageUsers,
+	PermissionManageConfigKeys,
+	PermissionManageEphemeralEnvironments,
+	PermissionViewAuditLog,
+}
+
+func (p Permission) Valid() error {
+	for _, known := range AllPermissions {
+		if p == known {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: got %q", ErrUnknownPermission, p)
+}
@@ -0,0 +1,105 @@
+package postgres
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"fmt"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/jackc/pgx/v5"
+)
+
+//go:embed queries/authorization/get_environment_access.sql
+var getEnvironmentAccessSql string
+
+//go:embed queries/authorization/has_sensitive_write_access.sql
+var hasSensitiveWriteAccessSql string
+
+//go:embed queries/authorization/list_environment_acl_entries.sql
+var listEnvironmentACLEntriesSql string
+
+//go:embed queries/authorization/create_environment_acl_entry.sql
+var createEnvironmentACLEntrySql string
+
+//go:embed queries/authorization/delete_environment_acl_entry.sql
+var deleteEnvironmentACLEntrySql string
+
+func (g *Gateway) GetEnvironmentAccess(ctx context.Context, actor auth.User) (map[int]auth.Access, error) {
+	rows, err := g.pool.Query(ctx, getEnvironmentAccessSql, actor.ID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	access := make(map[int]auth.Access)
+	for rows.Next() {
+		var environmentID int
+		var granted auth.Access
+		if err := rows.Scan(&environmentID, &granted); err != nil {
+			return nil, err
+		}
+
+		access[environmentID] = granted
+	}
+
+	return access, rows.Err()
+}
+
+func (g *Gateway) HasSensitiveWriteAccess(ctx context.Context, user auth.User) (bool, error) {
+	var rowCount int
+	err := g.pool.QueryRow(ctx, hasSensitiveWriteAccessSql, user.ID).Scan(&rowCount)
+	return rowCount > 0, err
+}
+
+func (g *Gateway) ListEnvironmentACL(ctx context.Context, environmentID int) ([]auth.ACLEntry, error) {
+	return postgresutils.GetAll[auth.ACLEntry](g.pool, ctx, listEnvironmentACLEntriesSql, environmentID)
+}
+
+func (g *Gateway) CreateEnvironmentACLEntry(ctx context.Context, entry auth.ACLEntry) (auth.ACLEntry, error) {
+	if err := entry.Valid(); err != nil {
+		return auth.ACLEntry{}, err
+	}
+
+	var roleID *int
+	if entry.Role != nil {
+		var id int
+		err := g.pool.QueryRow(ctx, "SELECT id FROM roles WHERE name = $1", *entry.Role).Scan(&id)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return auth.ACLEntry{}, fmt.Errorf("%w: role %q does not exist", auth.ErrInvalidACLEntry, *entry.Role)
+		} else if err != nil {
+			return auth.ACLEntry{}, err
+		}
+
+		roleID = &id
+	}
+
+	created, err := postgresutils.GetOne[auth.ACLEntry](
+		g.pool,
+		ctx,
+		createEnvironmentACLEntrySql,
+		entry.EnvironmentID,
+		entry.UserID,
+		roleID,
+		entry.Access,
+	)
+	if err != nil && postgresutils.IsUniqueConstraintErr(err) {
+		return created, auth.ErrACLEntryExists
+	}
+
+	return created, err
+}
+
+func (g *Gateway) DeleteEnvironmentACLEntry(ctx context.Context, environmentID, id int) error {
+	tag, err := g.pool.Exec(ctx, deleteEnvironmentACLEntrySql, environmentID, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return auth.ErrACLEntryNotFound
+	}
+
+	return nil
+}
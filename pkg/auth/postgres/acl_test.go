@@ -0,0 +1,109 @@
+package postgres_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/environments"
+	"github.com/config-source/cdb/pkg/services"
+)
+
+func TestEnvironmentACLGrantsAccess(t *testing.T) {
+	gateway, svcRepo, envRepo := initTestDBWithServices(t)
+	ctx := context.Background()
+
+	svc, err := svcRepo.CreateService(ctx, services.Service{Name: "payments"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qa, err := envRepo.CreateEnvironment(ctx, environments.Environment{Name: "qa", ServiceID: svc.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	production, err := envRepo.CreateEnvironment(ctx, environments.Environment{Name: "production", ServiceID: svc.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contractor := userFixture(t, gateway, "contractor@example.com")
+	operator := operatorFixture(t, gateway)
+
+	_, err = gateway.CreateEnvironmentACLEntry(ctx, auth.ACLEntry{
+		EnvironmentID: qa.ID,
+		UserID:        &contractor.ID,
+		Access:        auth.AccessWrite,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operatorRole := "Operator"
+	_, err = gateway.CreateEnvironmentACLEntry(ctx, auth.ACLEntry{
+		EnvironmentID: production.ID,
+		Role:          &operatorRole,
+		Access:        auth.AccessRead,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	granted, err := gateway.GetEnvironmentAccess(ctx, contractor)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(granted) != 1 || granted[qa.ID] != auth.AccessWrite {
+		t.Errorf("Expected contractor to only have write access to qa got: %v", granted)
+	}
+
+	granted, err = gateway.GetEnvironmentAccess(ctx, operator)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(granted) != 1 || granted[production.ID] != auth.AccessRead {
+		t.Errorf("Expected operators to have read access to production got: %v", granted)
+	}
+
+	_, err = gateway.CreateEnvironmentACLEntry(ctx, auth.ACLEntry{
+		EnvironmentID: qa.ID,
+		UserID:        &contractor.ID,
+		Access:        auth.AccessRead,
+	})
+	if !errors.Is(err, auth.ErrACLEntryExists) {
+		t.Errorf("Expected %s got: %v", auth.ErrACLEntryExists, err)
+	}
+
+	entries, err := gateway.ListEnvironmentACL(ctx, qa.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("Expected one entry on qa got: %v", entries)
+	}
+
+	if err := gateway.DeleteEnvironmentACLEntry(ctx, production.ID, entries[0].ID); !errors.Is(err, auth.ErrACLEntryNotFound) {
+		t.Errorf("Expected deleting an entry through another environment to fail got: %v", err)
+	}
+
+	if err := gateway.DeleteEnvironmentACLEntry(ctx, qa.ID, entries[0].ID); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEnvironmentACLEntriesMustHaveOneGrantee(t *testing.T) {
+	gateway := initTestDB(t)
+
+	_, err := gateway.CreateEnvironmentACLEntry(context.Background(), auth.ACLEntry{
+		EnvironmentID: 1,
+		Access:        auth.AccessRead,
+	})
+	if !errors.Is(err, auth.ErrInvalidACLEntry) {
+		t.Errorf("Expected %s got: %v", auth.ErrInvalidACLEntry, err)
+	}
+}
@@ -27,8 +27,13 @@ var getUsersSql string
 //go:embed queries/authentication/delete_user.sql
 var deleteUserSql string
 
+//go:embed queries/authentication/update_password.sql
+var updatePasswordSql string
+
+//go:embed queries/authentication/set_user_disabled.sql
+var setUserDisabledSql string
+
 func (g *Gateway) Register(ctx context.Context, email, password string) (auth.User, error) {
-	// TODO: enforce some password strength
 	hashedPw, err := bcrypt.GenerateFromPassword([]byte(password), 12)
 	if err != nil {
 		return auth.User{}, err
@@ -57,6 +62,30 @@ func (g *Gateway) Login(ctx context.Context, email, password string) (auth.User,
 	return user, nil
 }
 
+// SetPassword implements auth.PasswordManager.
+func (g *Gateway) SetPassword(ctx context.Context, userID auth.UserID, password string) error {
+	hashedPw, err := bcrypt.GenerateFromPassword([]byte(password), 12)
+	if err != nil {
+		return err
+	}
+
+	result, err := g.pool.Exec(ctx, updatePasswordSql, userID, hashedPw)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return auth.ErrUserNotFound
+	}
+
+	g.log.Info().
+		Interface("userID", userID).
+		Bool("audit", true).
+		Msg("set password")
+
+	return nil
+}
+
 func (g *Gateway) CreateUser(ctx context.Context, newUser auth.User) (auth.User, error) {
 	// TODO: in this scenario we want to notify the new user. I don't really
 	// think that should live here though probably in a wrapping Service type.
@@ -73,6 +102,15 @@ func (g *Gateway) GetUser(ctx context.Context, userID auth.UserID) (auth.User, e
 	return user, err
 }
 
+func (g *Gateway) GetUserByEmail(ctx context.Context, email string) (auth.User, error) {
+	user, err := postgresutils.GetOne[auth.User](g.pool, ctx, getUserByEmailSql, email)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return auth.User{}, auth.ErrUserNotFound
+	}
+
+	return user, err
+}
+
 func (g *Gateway) DeleteUser(ctx context.Context, userID auth.UserID) error {
 	_, err := g.pool.Exec(ctx, deleteUserSql, userID)
 	return err
@@ -82,4 +120,21 @@ func (g *Gateway) ListUsers(ctx context.Context) ([]auth.User, error) {
 	return postgresutils.GetAll[auth.User](g.pool, ctx, getUsersSql)
 }
 
+func (g *Gateway) SetUserDisabled(ctx context.Context, userID auth.UserID, disabled bool) (auth.User, error) {
+	user, err := postgresutils.GetOne[auth.User](g.pool, ctx, setUserDisabledSql, userID, disabled)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return auth.User{}, auth.ErrUserNotFound
+	} else if err != nil {
+		return auth.User{}, err
+	}
+
+	g.log.Info().
+		Interface("userID", userID).
+		Bool("disabled", disabled).
+		Bool("audit", true).
+		Msg("set user disabled")
+
+	return user, nil
+}
+
 // TODO: update user
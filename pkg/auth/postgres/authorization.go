@@ -4,6 +4,7 @@ import (
 	"context"
 	_ "embed"
 	"errors"
+	"slices"
 
 	"github.com/config-source/cdb/pkg/auth"
 	"github.com/config-source/cdb/pkg/postgresutils"
@@ -13,21 +14,48 @@ import (
 //go:embed queries/authorization/has_permission.sql
 var hasPermissionSql string
 
+//go:embed queries/authorization/has_service_permission.sql
+var hasServicePermissionSql string
+
+//go:embed queries/authorization/has_any_service_permission.sql
+var hasAnyServicePermissionSql string
+
+//go:embed queries/authorization/list_service_roles.sql
+var listServiceRolesSql string
+
+//go:embed queries/authorization/assign_service_role.sql
+var assignServiceRoleSql string
+
+//go:embed queries/authorization/remove_service_role.sql
+var removeServiceRoleSql string
+
 //go:embed queries/authorization/assign_role_to_user.sql
 var assignRoleToUserSql string
 
 //go:embed queries/authorization/remove_role_from_user.sql
 var removeRoleFromUserSql string
 
+//go:embed queries/authorization/remove_unmapped_roles.sql
+var removeUnmappedRolesSql string
+
+//go:embed queries/authorization/grant_roles.sql
+var grantRolesSql string
+
 //go:embed queries/authorization/get_roles_for_user.sql
 var getRolesForUserSql string
 
 //go:embed queries/authorization/get_permissions_for_role.sql
 var getPermissionsForRoleSql string
 
+//go:embed queries/authorization/list_roles.sql
+var listRolesSql string
+
 //go:embed queries/authorization/create_role.sql
 var createRoleSql string
 
+//go:embed queries/authorization/delete_role.sql
+var deleteRoleSql string
+
 //go:embed queries/authorization/assign_permission_to_role.sql
 var assignPermissionToRoleSql string
 
@@ -40,6 +68,12 @@ func (g *Gateway) HasPermission(
 	permission auth.Permission,
 	additionalPermissions ...auth.Permission,
 ) (bool, error) {
+	// Scopes are always limited to services so they never allow global
+	// permissions.
+	if actor.Scopes.Restricted() {
+		return false, nil
+	}
+
 	var rowCount int
 	err := g.pool.QueryRow(
 		ctx,
@@ -51,6 +85,75 @@ func (g *Gateway) HasPermission(
 	return rowCount > 0, err
 }
 
+func (g *Gateway) HasServicePermission(
+	ctx context.Context,
+	actor auth.User,
+	serviceID int,
+	permission auth.Permission,
+	additionalPermissions ...auth.Permission,
+) (bool, error) {
+	permissions := actor.Scopes.ServicePermissions(
+		serviceID,
+		append([]auth.Permission{permission}, additionalPermissions...),
+	)
+	if len(permissions) == 0 {
+		return false, nil
+	}
+
+	var rowCount int
+	err := g.pool.QueryRow(
+		ctx,
+		hasServicePermissionSql,
+		actor.ID,
+		permissions[0],
+		permissions[1:],
+		serviceID,
+	).Scan(&rowCount)
+	return rowCount > 0, err
+}
+
+func (g *Gateway) HasPermissionOnAnyService(
+	ctx context.Context,
+	user auth.User,
+	permission auth.Permission,
+	additionalPermissions ...auth.Permission,
+) (bool, error) {
+	var rowCount int
+	err := g.pool.QueryRow(
+		ctx,
+		hasAnyServicePermissionSql,
+		user.ID,
+		permission,
+		additionalPermissions,
+	).Scan(&rowCount)
+	return rowCount > 0, err
+}
+
+func (g *Gateway) ListRoles(ctx context.Context, actor auth.User) ([]auth.Role, error) {
+	if isAuthorized, _ := g.HasPermission(ctx, actor, auth.PermissionManageRoles); !isAuthorized {
+		g.log.Warn().
+			Interface("actorID", actor.ID).
+			Bool("denied", true).
+			Bool("audit", true).
+			Msg("actor attempted to list roles")
+
+		return nil, auth.ErrUnauthorized
+	}
+
+	return postgresutils.GetAll[auth.Role](g.pool, ctx, listRolesSql)
+}
+
+// roleExists returns auth.ErrRoleNotFound if there's no role named role.
+func (g *Gateway) roleExists(ctx context.Context, role string) error {
+	var roleID int
+	err := g.pool.QueryRow(ctx, "SELECT id FROM roles WHERE name = $1", role).Scan(&roleID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return auth.ErrRoleNotFound
+	}
+
+	return err
+}
+
 func (g *Gateway) CreateRole(ctx context.Context, actor auth.User, role string, permissions []auth.Permission) error {
 	if isAuthorized, _ := g.HasPermission(ctx, actor, auth.PermissionManageRoles); !isAuthorized {
 		g.log.Warn().
@@ -64,11 +167,15 @@ func (g *Gateway) CreateRole(ctx context.Context, actor auth.User, role string,
 		return auth.ErrUnauthorized
 	}
 
-	_, err := g.pool.Exec(ctx, createRoleSql, role)
+	commandTag, err := g.pool.Exec(ctx, createRoleSql, role)
 	if err != nil {
 		return err
 	}
 
+	if commandTag.RowsAffected() == 0 {
+		return auth.ErrRoleExists
+	}
+
 	g.log.Info().
 		Interface("actorID", actor.ID).
 		Str("role", role).
@@ -80,6 +187,37 @@ func (g *Gateway) CreateRole(ctx context.Context, actor auth.User, role string,
 	return g.AddPermissionsToRole(ctx, actor, role, permissions)
 }
 
+func (g *Gateway) DeleteRole(ctx context.Context, actor auth.User, role string) error {
+	if isAuthorized, _ := g.HasPermission(ctx, actor, auth.PermissionManageRoles); !isAuthorized {
+		g.log.Warn().
+			Interface("actorID", actor.ID).
+			Str("role", role).
+			Bool("denied", true).
+			Bool("audit", true).
+			Msg("actor attempted to delete a role")
+
+		return auth.ErrUnauthorized
+	}
+
+	commandTag, err := g.pool.Exec(ctx, deleteRoleSql, role)
+	if err != nil {
+		return err
+	}
+
+	if commandTag.RowsAffected() == 0 {
+		return auth.ErrRoleNotFound
+	}
+
+	g.log.Info().
+		Interface("actorID", actor.ID).
+		Str("role", role).
+		Bool("denied", false).
+		Bool("audit", true).
+		Msg("actor deleted a role")
+
+	return nil
+}
+
 func (g *Gateway) AddPermissionsToRole(ctx context.Context, actor auth.User, role string, permissions []auth.Permission) error {
 	if isAuthorized, _ := g.HasPermission(ctx, actor, auth.PermissionManageRoles); !isAuthorized {
 		g.log.Warn().
@@ -93,6 +231,10 @@ func (g *Gateway) AddPermissionsToRole(ctx context.Context, actor auth.User, rol
 		return auth.ErrUnauthorized
 	}
 
+	if err := g.roleExists(ctx, role); err != nil {
+		return err
+	}
+
 	txn, err := g.pool.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		postgresutils.Rollback(ctx, txn, g.log)
@@ -131,6 +273,10 @@ func (g *Gateway) RemovePermissionsFromRole(ctx context.Context, actor auth.User
 		return auth.ErrUnauthorized
 	}
 
+	if err := g.roleExists(ctx, role); err != nil {
+		return err
+	}
+
 	txn, err := g.pool.BeginTx(ctx, pgx.TxOptions{})
 	if err != nil {
 		postgresutils.Rollback(ctx, txn, g.log)
@@ -199,11 +345,6 @@ func (g *Gateway) GetRolesForUser(ctx context.Context, actor auth.User, user aut
 		return nil, auth.ErrUnauthorized
 	}
 
-	rows, err := g.pool.Query(ctx, getRolesForUserSql, user.ID)
-	if err != nil {
-		return nil, err
-	}
-
 	g.log.Info().
 		Interface("actorID", actor.ID).
 		Interface("user", user.ID).
@@ -211,10 +352,27 @@ func (g *Gateway) GetRolesForUser(ctx context.Context, actor auth.User, user aut
 		Bool("audit", true).
 		Msg("actor got roles for user")
 
-	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (string, error) {
+	return getRolesForUser(ctx, g.pool, user)
+}
+
+// getRolesForUser returns the names of the user's roles sorted so that they
+// can be compared.
+func getRolesForUser(ctx context.Context, q postgresutils.Querier, user auth.User) ([]string, error) {
+	rows, err := q.Query(ctx, getRolesForUserSql, user.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	roles, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (string, error) {
 		var roleName string
 		return roleName, row.Scan(&roleName)
 	})
+	if err != nil {
+		return nil, err
+	}
+
+	slices.Sort(roles)
+	return roles, nil
 }
 
 func (g *Gateway) AssignRoleToUser(ctx context.Context, actor auth.User, user auth.User, role string) error {
@@ -249,7 +407,9 @@ func (g *Gateway) AssignRoleToUser(ctx context.Context, actor auth.User, user au
 func (g *Gateway) AssignRoleToUserNoAuth(ctx context.Context, user auth.User, role string) error {
 	var roleID int
 	err := g.pool.QueryRow(ctx, "SELECT id FROM roles WHERE name = $1", role).Scan(&roleID)
-	if err != nil {
+	if errors.Is(err, pgx.ErrNoRows) {
+		return auth.ErrRoleNotFound
+	} else if err != nil {
 		return err
 	}
 
@@ -257,6 +417,54 @@ func (g *Gateway) AssignRoleToUserNoAuth(ctx context.Context, user auth.User, ro
 	return err
 }
 
+func (g *Gateway) SyncRolesNoAuth(ctx context.Context, user auth.User, managed, granted []string) ([]string, []string, error) {
+	if granted == nil {
+		granted = []string{}
+	}
+
+	txn, err := g.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	before, err := getRolesForUser(ctx, txn, user)
+	if err != nil {
+		postgresutils.Rollback(ctx, txn, g.log)
+		return nil, nil, err
+	}
+
+	removed, err := txn.Exec(ctx, removeUnmappedRolesSql, user.ID, managed, granted)
+	if err != nil {
+		postgresutils.Rollback(ctx, txn, g.log)
+		return nil, nil, err
+	}
+
+	_, err = txn.Exec(ctx, grantRolesSql, user.ID, granted)
+	if err != nil {
+		postgresutils.Rollback(ctx, txn, g.log)
+		return nil, nil, err
+	}
+
+	after, err := getRolesForUser(ctx, txn, user)
+	if err != nil {
+		postgresutils.Rollback(ctx, txn, g.log)
+		return nil, nil, err
+	}
+
+	if err := txn.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	g.log.Info().
+		Interface("user", user.ID).
+		Strs("granted", granted).
+		Int64("removed", removed.RowsAffected()).
+		Bool("audit", true).
+		Msg("synced roles from identity provider")
+
+	return before, after, nil
+}
+
 func (g *Gateway) RemoveRoleFromUser(ctx context.Context, actor auth.User, user auth.User, role string) error {
 	if isAuthorized, _ := g.HasPermission(ctx, actor, auth.PermissionManageUsers); !isAuthorized {
 		g.log.Warn().
@@ -285,3 +493,88 @@ func (g *Gateway) RemoveRoleFromUser(ctx context.Context, actor auth.User, user
 
 	return err
 }
+
+func (g *Gateway) ListServiceRoles(ctx context.Context, actor auth.User, serviceID int) ([]auth.ServiceRole, error) {
+	// Owners can see who else has access to their service.
+	isAuthorized, _ := g.HasPermission(ctx, actor, auth.PermissionManageUsers)
+	if !isAuthorized {
+		isAuthorized, _ = g.HasServicePermission(ctx, actor, serviceID, auth.PermissionManageEnvironments)
+	}
+
+	if !isAuthorized {
+		g.log.Warn().
+			Interface("actorID", actor.ID).
+			Int("serviceID", serviceID).
+			Bool("denied", true).
+			Bool("audit", true).
+			Msg("actor attempted to list service roles")
+
+		return nil, auth.ErrUnauthorized
+	}
+
+	return postgresutils.GetAll[auth.ServiceRole](g.pool, ctx, listServiceRolesSql, serviceID)
+}
+
+func (g *Gateway) AssignServiceRole(ctx context.Context, actor auth.User, user auth.User, serviceID int, role string) error {
+	if isAuthorized, _ := g.HasPermission(ctx, actor, auth.PermissionManageUsers); !isAuthorized {
+		g.log.Warn().
+			Interface("actorID", actor.ID).
+			Interface("user", user.ID).
+			Int("serviceID", serviceID).
+			Str("role", role).
+			Bool("denied", true).
+			Bool("audit", true).
+			Msg("actor attempted to assign service role to user")
+
+		return auth.ErrUnauthorized
+	}
+
+	_, err := g.pool.Exec(ctx, assignServiceRoleSql, user.ID, serviceID, role)
+	if err != nil {
+		return err
+	}
+
+	g.log.Info().
+		Interface("actorID", actor.ID).
+		Interface("user", user.ID).
+		Int("serviceID", serviceID).
+		Str("role", role).
+		Bool("denied", false).
+		Bool("audit", true).
+		Msg("actor assigned service role to user")
+
+	return nil
+}
+
+func (g *Gateway) RemoveServiceRole(ctx context.Context, actor auth.User, user auth.User, serviceID int) error {
+	if isAuthorized, _ := g.HasPermission(ctx, actor, auth.PermissionManageUsers); !isAuthorized {
+		g.log.Warn().
+			Interface("actorID", actor.ID).
+			Interface("user", user.ID).
+			Int("serviceID", serviceID).
+			Bool("denied", true).
+			Bool("audit", true).
+			Msg("actor attempted to remove service role from user")
+
+		return auth.ErrUnauthorized
+	}
+
+	commandTag, err := g.pool.Exec(ctx, removeServiceRoleSql, user.ID, serviceID)
+	if err != nil {
+		return err
+	}
+
+	if commandTag.RowsAffected() <= 0 {
+		return errors.New("user does not have a role on the given service")
+	}
+
+	g.log.Info().
+		Interface("actorID", actor.ID).
+		Interface("user", user.ID).
+		Int("serviceID", serviceID).
+		Bool("denied", false).
+		Bool("audit", true).
+		Msg("actor removed service role from user")
+
+	return nil
+}
@@ -9,11 +9,15 @@ import (
 
 	"github.com/config-source/cdb/pkg/auth"
 	"github.com/config-source/cdb/pkg/auth/postgres"
+	"github.com/config-source/cdb/pkg/environments"
+	"github.com/config-source/cdb/pkg/services"
 )
 
 var allPermissions = []auth.Permission{
 	auth.PermissionConfigureEnvironments,
 	auth.PermissionConfigureSensitiveEnvironments,
+	auth.PermissionReadConfiguration,
+	auth.PermissionReadSensitiveConfiguration,
 	auth.PermissionManageConfigKeys,
 	auth.PermissionManageEnvironments,
 	auth.PermissionManageUsers,
@@ -135,6 +139,47 @@ func TestAssignRoleToUser(t *testing.T) {
 	}
 }
 
+func TestSyncRolesOnlyTouchesManagedRoles(t *testing.T) {
+	gateway := initTestDB(t)
+
+	admin := adminFixture(t, gateway)
+	user := userFixtureWithRole(t, gateway, "user@example.com", "Operator")
+	ctx := context.Background()
+
+	before, after, err := gateway.SyncRolesNoAuth(ctx, user, []string{"Administrator"}, []string{"Administrator"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(before, []string{"Operator"}) || !reflect.DeepEqual(after, []string{"Administrator", "Operator"}) {
+		t.Errorf("Expected the roles before and after the sync got: %s %s", before, after)
+	}
+
+	roles, err := gateway.GetRolesForUser(ctx, admin, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sort.Strings(roles)
+	if !reflect.DeepEqual(roles, []string{"Administrator", "Operator"}) {
+		t.Errorf("Expected the Administrator role to be added got: %s", roles)
+	}
+
+	_, after, err = gateway.SyncRolesNoAuth(ctx, user, []string{"Administrator", "Operator"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles, err = gateway.GetRolesForUser(ctx, admin, user)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(roles) != 0 || len(after) != 0 {
+		t.Errorf("Expected managed roles to be removed got: %s %s", roles, after)
+	}
+}
+
 func TestAssignNonExistentRoleToUser(t *testing.T) {
 	gateway := initTestDB(t)
 
@@ -244,6 +289,9 @@ func TestGetPermissionsForOperatorRole(t *testing.T) {
 	expectedPerms := []auth.Permission{
 		auth.PermissionConfigureEnvironments,
 		auth.PermissionConfigureSensitiveEnvironments,
+		auth.PermissionManageEphemeralEnvironments,
+		auth.PermissionReadConfiguration,
+		auth.PermissionReadSensitiveConfiguration,
 	}
 	if !reflect.DeepEqual(perms, expectedPerms) {
 		t.Errorf("Expected %s permissions but got: %s", expectedPerms, perms)
@@ -319,6 +367,9 @@ func TestAddPermissionsToRole(t *testing.T) {
 	expectedPerms := []auth.Permission{
 		auth.PermissionConfigureEnvironments,
 		auth.PermissionConfigureSensitiveEnvironments,
+		auth.PermissionManageEphemeralEnvironments,
+		auth.PermissionReadConfiguration,
+		auth.PermissionReadSensitiveConfiguration,
 	}
 	if !reflect.DeepEqual(perms, expectedPerms) {
 		t.Errorf("Expected %s permissions but got: %s", expectedPerms, perms)
@@ -352,3 +403,272 @@ func TestCreateRole(t *testing.T) {
 		t.Errorf("Expected %s roles but got: %s", []string{newRoleName}, roles)
 	}
 }
+
+func TestServiceRolesAreScopedToTheirService(t *testing.T) {
+	gateway, svcRepo, _ := initTestDBWithServices(t)
+	ctx := context.Background()
+
+	owned, err := svcRepo.CreateService(ctx, services.Service{Name: "owned"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := svcRepo.CreateService(ctx, services.Service{Name: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := adminFixture(t, gateway)
+	owner := userFixture(t, gateway, "owner@example.com")
+	developer := userFixture(t, gateway, "developer@example.com")
+
+	if err := gateway.AssignServiceRole(ctx, admin, owner, owned.ID, string(services.Owner)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gateway.AssignServiceRole(ctx, admin, developer, owned.ID, string(services.Developer)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		user       auth.User
+		serviceID  int
+		permission auth.Permission
+		expected   bool
+	}{
+		{owner, owned.ID, auth.PermissionManageConfigKeys, true},
+		{owner, owned.ID, auth.PermissionConfigureSensitiveEnvironments, true},
+		{owner, other.ID, auth.PermissionConfigureEnvironments, false},
+		{developer, owned.ID, auth.PermissionConfigureEnvironments, true},
+		{developer, owned.ID, auth.PermissionConfigureSensitiveEnvironments, false},
+		{developer, owned.ID, auth.PermissionManageEnvironments, false},
+		{admin, other.ID, auth.PermissionManageEnvironments, true},
+	}
+
+	for _, tc := range tests {
+		hasPerm, err := gateway.HasServicePermission(ctx, tc.user, tc.serviceID, tc.permission)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if hasPerm != tc.expected {
+			t.Errorf("Expected %s to have %s on service %d: %t", tc.user, tc.permission, tc.serviceID, tc.expected)
+		}
+	}
+
+	// Service roles never grant global permissions.
+	hasPerm, err := gateway.HasPermission(ctx, owner, auth.PermissionManageConfigKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if hasPerm {
+		t.Errorf("Expected service owner to not have global %s", auth.PermissionManageConfigKeys)
+	}
+}
+
+func TestMFAIsRequiredForServiceOwnersAndSensitiveWriters(t *testing.T) {
+	gateway, svcRepo, envRepo := initTestDBWithServices(t)
+	ctx := context.Background()
+	userService := auth.NewUserService(
+		gateway,
+		gateway,
+		&auth.TokenRegistry{},
+		nil,
+		nil,
+		false,
+		"",
+		auth.PasswordPolicy{},
+		&auth.TestNotifier{},
+		nil,
+	)
+
+	svc, err := svcRepo.CreateService(ctx, services.Service{Name: "payments"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	production, err := envRepo.CreateEnvironment(ctx, environments.Environment{
+		Name:      "production",
+		ServiceID: svc.ID,
+		Sensitive: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qa, err := envRepo.CreateEnvironment(ctx, environments.Environment{Name: "qa", ServiceID: svc.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	admin := adminFixture(t, gateway)
+	owner := userFixture(t, gateway, "owner@example.com")
+	developer := userFixture(t, gateway, "developer@example.com")
+	writer := userFixture(t, gateway, "writer@example.com")
+	reader := userFixture(t, gateway, "reader@example.com")
+
+	if err := gateway.AssignServiceRole(ctx, admin, owner, svc.ID, string(services.Owner)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := gateway.AssignServiceRole(ctx, admin, developer, svc.ID, string(services.Developer)); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := []auth.ACLEntry{
+		{EnvironmentID: production.ID, UserID: &writer.ID, Access: auth.AccessWrite},
+		{EnvironmentID: production.ID, UserID: &reader.ID, Access: auth.AccessRead},
+		{EnvironmentID: qa.ID, UserID: &reader.ID, Access: auth.AccessWrite},
+	}
+	for _, entry := range entries {
+		if _, err := gateway.CreateEnvironmentACLEntry(ctx, entry); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		user     auth.User
+		expected bool
+	}{
+		{admin, true},
+		{owner, true},
+		{developer, false},
+		{writer, true},
+		{reader, false},
+	}
+
+	for _, tc := range tests {
+		required, err := userService.MFARequired(ctx, tc.user)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if required != tc.expected {
+			t.Errorf("Expected MFA to be required for %s: %t", tc.user, tc.expected)
+		}
+	}
+}
+
+func TestAssignServiceRoleRequiresManageUserPermission(t *testing.T) {
+	gateway, svcRepo, _ := initTestDBWithServices(t)
+	ctx := context.Background()
+
+	svc, err := svcRepo.CreateService(ctx, services.Service{Name: "svc1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	operator := operatorFixture(t, gateway)
+	user := userFixture(t, gateway, "user@example.com")
+
+	err = gateway.AssignServiceRole(ctx, operator, user, svc.ID, string(services.Owner))
+	if !errors.Is(err, auth.ErrUnauthorized) {
+		t.Errorf("Expected %s got: %v", auth.ErrUnauthorized, err)
+	}
+}
+
+func TestCreateRoleWithExistingNameFails(t *testing.T) {
+	gateway := initTestDB(t)
+
+	admin := adminFixture(t, gateway)
+	ctx := context.Background()
+
+	err := gateway.CreateRole(ctx, admin, "Operator", []auth.Permission{})
+	if !errors.Is(err, auth.ErrRoleExists) {
+		t.Errorf("Expected %s got: %v", auth.ErrRoleExists, err)
+	}
+}
+
+func TestListRoles(t *testing.T) {
+	gateway := initTestDB(t)
+
+	admin := adminFixture(t, gateway)
+	ctx := context.Background()
+
+	err := gateway.CreateRole(ctx, admin, "Minion", []auth.Permission{auth.PermissionReadConfiguration})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = gateway.CreateRole(ctx, admin, "Nobody", []auth.Permission{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles, err := gateway.ListRoles(ctx, admin)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	found := map[string][]auth.Permission{}
+	for _, role := range roles {
+		found[role.Name] = role.Permissions
+	}
+
+	if !reflect.DeepEqual(found["Minion"], []auth.Permission{auth.PermissionReadConfiguration}) {
+		t.Errorf("Expected Minion to have %s got: %s", auth.PermissionReadConfiguration, found["Minion"])
+	}
+
+	if perms, ok := found["Nobody"]; !ok || len(perms) != 0 {
+		t.Errorf("Expected Nobody to be listed without permissions got: %s %t", perms, ok)
+	}
+
+	if _, ok := found["Administrator"]; !ok {
+		t.Errorf("Expected Administrator to be listed got: %v", roles)
+	}
+}
+
+func TestDeleteRole(t *testing.T) {
+	gateway := initTestDB(t)
+
+	admin := adminFixture(t, gateway)
+	unassignedUser := userFixture(t, gateway, "user@example.com")
+	ctx := context.Background()
+
+	err := gateway.CreateRole(ctx, admin, "Minion", []auth.Permission{auth.PermissionReadConfiguration})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = gateway.AssignRoleToUser(ctx, admin, unassignedUser, "Minion")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = gateway.DeleteRole(ctx, admin, "Minion")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roles, err := gateway.GetRolesForUser(ctx, admin, unassignedUser)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(roles) != 0 {
+		t.Errorf("Expected the deleted role to be unassigned got: %s", roles)
+	}
+
+	err = gateway.DeleteRole(ctx, admin, "Minion")
+	if !errors.Is(err, auth.ErrRoleNotFound) {
+		t.Errorf("Expected %s got: %v", auth.ErrRoleNotFound, err)
+	}
+
+	err = gateway.AddPermissionsToRole(ctx, admin, "Minion", []auth.Permission{auth.PermissionReadConfiguration})
+	if !errors.Is(err, auth.ErrRoleNotFound) {
+		t.Errorf("Expected %s got: %v", auth.ErrRoleNotFound, err)
+	}
+}
+
+func TestDeleteRoleRequiresManageRolesPermission(t *testing.T) {
+	gateway := initTestDB(t)
+
+	operator := operatorFixture(t, gateway)
+	ctx := context.Background()
+
+	err := gateway.DeleteRole(ctx, operator, "Operator")
+	if !errors.Is(err, auth.ErrUnauthorized) {
+		t.Errorf("Expected %s got: %v", auth.ErrUnauthorized, err)
+	}
+}
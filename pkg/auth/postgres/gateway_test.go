@@ -4,7 +4,9 @@ import (
 	"testing"
 
 	pg "github.com/config-source/cdb/pkg/auth/postgres"
+	"github.com/config-source/cdb/pkg/environments"
 	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/config-source/cdb/pkg/services"
 	"github.com/rs/zerolog"
 )
 
@@ -19,3 +21,15 @@ func initTestDB(t *testing.T) *pg.Gateway {
 
 	return repo
 }
+
+// initTestDBWithServices also returns services and environments repositories
+// so that tests can create services and environments to grant access to.
+func initTestDBWithServices(t *testing.T) (*pg.Gateway, *services.Repository, *environments.Repository) {
+	t.Helper()
+
+	logger := zerolog.New(nil).Level(zerolog.Disabled)
+	pool := postgresutils.InitTestDB(t)
+	return pg.NewGateway(logger, pool),
+		services.NewRepository(logger, pool),
+		environments.NewRepository(logger, pool)
+}
@@ -0,0 +1,53 @@
+package postgres
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/jackc/pgx/v5"
+)
+
+//go:embed queries/identities/get_identity_user.sql
+var getIdentityUserSql string
+
+//go:embed queries/identities/link_identity.sql
+var linkIdentitySql string
+
+//go:embed queries/identities/claim_identity.sql
+var claimIdentitySql string
+
+// GetIdentityUser implements auth.IdentityLinker.
+func (g *Gateway) GetIdentityUser(ctx context.Context, issuer, subject string) (auth.UserID, error) {
+	var userID auth.UserID
+	err := g.pool.QueryRow(ctx, getIdentityUserSql, issuer, subject).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, auth.ErrUserNotFound
+	}
+
+	return userID, err
+}
+
+// LinkIdentity implements auth.IdentityLinker.
+func (g *Gateway) LinkIdentity(ctx context.Context, issuer, subject string, userID auth.UserID) error {
+	_, err := g.pool.Exec(ctx, linkIdentitySql, userID, issuer, subject)
+	if err != nil && postgresutils.IsUniqueConstraintErr(err) {
+		return auth.ErrIdentityConflict
+	}
+
+	return err
+}
+
+// ClaimIdentity implements auth.IdentityLinker.
+func (g *Gateway) ClaimIdentity(ctx context.Context, issuer, subject string, userID auth.UserID) (bool, error) {
+	result, err := g.pool.Exec(ctx, claimIdentitySql, userID, issuer, subject)
+	if err != nil && postgresutils.IsUniqueConstraintErr(err) {
+		return false, auth.ErrIdentityConflict
+	} else if err != nil {
+		return false, err
+	}
+
+	return result.RowsAffected() == 1, nil
+}
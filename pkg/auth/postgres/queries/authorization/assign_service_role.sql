@@ -0,0 +1,3 @@
+INSERT INTO users_to_service_roles (user_id, service_id, role)
+VALUES ($1, $2, $3)
+ON CONFLICT (user_id, service_id) DO UPDATE SET role = EXCLUDED.role;
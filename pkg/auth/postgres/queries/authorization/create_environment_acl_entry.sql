@@ -0,0 +1,14 @@
+WITH created AS (
+    INSERT INTO environment_acl_entries (environment_id, user_id, role_id, access)
+    VALUES ($1, $2, $3, $4)
+    RETURNING *
+)
+SELECT
+    created.id,
+    created.environment_id,
+    created.user_id,
+    roles.name AS role,
+    created.access,
+    created.created_at
+FROM created
+LEFT JOIN roles ON (roles.id = created.role_id);
@@ -1,3 +1,3 @@
 INSERT INTO roles (name)
 VALUES ($1)
-RETURNING *;
+ON CONFLICT (name) DO NOTHING;
@@ -0,0 +1,2 @@
+DELETE FROM environment_acl_entries
+WHERE environment_id = $1 AND id = $2;
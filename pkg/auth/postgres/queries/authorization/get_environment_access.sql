@@ -0,0 +1,7 @@
+-- Returns the strongest access the user has on each environment either
+-- directly or through one of their roles. 'write' sorts after 'read'.
+SELECT environment_acl_entries.environment_id, MAX(environment_acl_entries.access) AS access
+FROM environment_acl_entries
+LEFT JOIN users_to_roles ON (users_to_roles.role_id = environment_acl_entries.role_id)
+WHERE environment_acl_entries.user_id = $1 OR users_to_roles.user_id = $1
+GROUP BY environment_acl_entries.environment_id;
@@ -0,0 +1,5 @@
+INSERT INTO users_to_roles (user_id, role_id)
+SELECT $1, roles.id
+FROM roles
+WHERE roles.name = ANY($2)
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,5 @@
+SELECT COUNT(*)
+FROM users_to_service_roles
+INNER JOIN service_role_permissions ON (service_role_permissions.role = users_to_service_roles.role)
+INNER JOIN permissions ON (permissions.id = service_role_permissions.permission_id)
+WHERE users_to_service_roles.user_id = $1 AND (permissions.name = $2 OR permissions.name = ANY($3));
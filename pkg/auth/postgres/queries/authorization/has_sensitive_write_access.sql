@@ -0,0 +1,8 @@
+SELECT COUNT(*)
+FROM environment_acl_entries
+INNER JOIN environments ON (environments.id = environment_acl_entries.environment_id)
+LEFT JOIN users_to_roles ON (users_to_roles.role_id = environment_acl_entries.role_id)
+WHERE (environment_acl_entries.user_id = $1 OR users_to_roles.user_id = $1)
+    AND environment_acl_entries.access = 'write'
+    AND environments.sensitive
+    AND environments.deleted_at IS NULL;
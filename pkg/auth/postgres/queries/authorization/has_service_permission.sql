@@ -0,0 +1,18 @@
+SELECT COUNT(*)
+FROM (
+    SELECT 1
+    FROM users_to_roles
+    INNER JOIN permissions_to_roles ON (permissions_to_roles.role_id = users_to_roles.role_id)
+    INNER JOIN permissions ON (permissions.id = permissions_to_roles.permission_id)
+    WHERE users_to_roles.user_id = $1 AND (permissions.name = $2 OR permissions.name = ANY($3))
+
+    UNION ALL
+
+    SELECT 1
+    FROM users_to_service_roles
+    INNER JOIN service_role_permissions ON (service_role_permissions.role = users_to_service_roles.role)
+    INNER JOIN permissions ON (permissions.id = service_role_permissions.permission_id)
+    WHERE users_to_service_roles.user_id = $1
+        AND users_to_service_roles.service_id = $4
+        AND (permissions.name = $2 OR permissions.name = ANY($3))
+) AS granted;
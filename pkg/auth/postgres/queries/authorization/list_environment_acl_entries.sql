@@ -0,0 +1,11 @@
+SELECT
+    environment_acl_entries.id,
+    environment_acl_entries.environment_id,
+    environment_acl_entries.user_id,
+    roles.name AS role,
+    environment_acl_entries.access,
+    environment_acl_entries.created_at
+FROM environment_acl_entries
+LEFT JOIN roles ON (roles.id = environment_acl_entries.role_id)
+WHERE environment_acl_entries.environment_id = $1
+ORDER BY environment_acl_entries.id;
@@ -0,0 +1,11 @@
+SELECT
+    roles.name,
+    COALESCE(
+        array_agg(permissions.name ORDER BY permissions.name) FILTER (WHERE permissions.name IS NOT NULL),
+        '{}'
+    ) AS permissions
+FROM roles
+LEFT JOIN permissions_to_roles ON (roles.id = permissions_to_roles.role_id)
+LEFT JOIN permissions ON (permissions_to_roles.permission_id = permissions.id)
+GROUP BY roles.name
+ORDER BY roles.name;
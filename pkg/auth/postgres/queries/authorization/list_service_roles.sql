@@ -0,0 +1,4 @@
+SELECT user_id, service_id, role
+FROM users_to_service_roles
+WHERE service_id = $1
+ORDER BY user_id;
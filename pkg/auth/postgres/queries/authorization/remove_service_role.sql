@@ -0,0 +1,2 @@
+DELETE FROM users_to_service_roles
+WHERE user_id = $1 AND service_id = $2;
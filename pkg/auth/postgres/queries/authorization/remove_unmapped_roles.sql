@@ -0,0 +1,6 @@
+DELETE FROM users_to_roles
+USING roles
+WHERE users_to_roles.role_id = roles.id
+    AND users_to_roles.user_id = $1
+    AND roles.name = ANY($2)
+    AND NOT roles.name = ANY($3);
@@ -0,0 +1,3 @@
+UPDATE oidc_identities
+SET subject = $3
+WHERE user_id = $1 AND issuer = $2 AND subject IS NULL;
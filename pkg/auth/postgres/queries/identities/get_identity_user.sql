@@ -0,0 +1,3 @@
+SELECT user_id
+FROM oidc_identities
+WHERE issuer = $1 AND subject = $2;
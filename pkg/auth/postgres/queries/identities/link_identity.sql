@@ -0,0 +1,2 @@
+INSERT INTO oidc_identities (user_id, issuer, subject)
+VALUES ($1, $2, NULLIF($3, ''));
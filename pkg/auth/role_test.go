@@ -0,0 +1,50 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/config-source/cdb/pkg/auth"
+)
+
+func TestDeleteDefaultRole(t *testing.T) {
+	gateway := auth.NewTestGateway()
+	svc := auth.NewUserService(gateway, gateway, &auth.TokenRegistry{}, nil, nil, true, "Operator", auth.PasswordPolicy{}, &auth.TestNotifier{}, nil)
+
+	err := svc.DeleteRole(context.Background(), auth.User{ID: 1}, "Operator")
+	if !errors.Is(err, auth.ErrDefaultRole) {
+		t.Errorf("Expected %s got: %v", auth.ErrDefaultRole, err)
+	}
+}
+
+func TestRolePermissionsMustBeKnown(t *testing.T) {
+	gateway := auth.NewTestGateway()
+	svc := auth.NewUserService(gateway, gateway, &auth.TokenRegistry{}, nil, nil, true, "Operator", auth.PasswordPolicy{}, &auth.TestNotifier{}, nil)
+	ctx := context.Background()
+	actor := auth.User{ID: 1}
+
+	_, err := svc.CreateRole(ctx, actor, auth.Role{Name: "Minion", Permissions: []auth.Permission{"CAN_DO_ANYTHING"}})
+	if !errors.Is(err, auth.ErrUnknownPermission) {
+		t.Errorf("Expected %s got: %v", auth.ErrUnknownPermission, err)
+	}
+
+	_, err = svc.CreateRole(ctx, actor, auth.Role{Name: " "})
+	if !errors.Is(err, auth.ErrRoleNameEmpty) {
+		t.Errorf("Expected %s got: %v", auth.ErrRoleNameEmpty, err)
+	}
+
+	err = svc.GrantPermissions(ctx, actor, "Minion", []auth.Permission{"can_read_configuration"})
+	if !errors.Is(err, auth.ErrUnknownPermission) {
+		t.Errorf("Expected %s got: %v", auth.ErrUnknownPermission, err)
+	}
+
+	role, err := svc.CreateRole(ctx, actor, auth.Role{Name: "Minion"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if role.Permissions == nil {
+		t.Error("Expected a role without permissions to have an empty list")
+	}
+}
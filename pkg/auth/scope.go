@@ -0,0 +1,160 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+var ErrInvalidScope = errors.New("API token scope is not valid")
+
+// Scope limits an API token to part of its creator's permissions. Scopes never
+// grant anything, the creator must still have the permissions themselves.
+type Scope struct {
+	ServiceID int
+	// EnvironmentID restricts the scope to one environment of the service,
+	// when nil it covers all of them.
+	EnvironmentID *int `json:",omitempty"`
+	// Access is read to read configuration or write to also set it.
+	Access Access
+}
+
+func (s Scope) String() string {
+	env := "*"
+	if s.EnvironmentID != nil {
+		env = fmt.Sprint(*s.EnvironmentID)
+	}
+
+	return fmt.Sprintf("Scope(service=%d, environment=%s, access=%s)", s.ServiceID, env, s.Access)
+}
+
+// Scopes is the set of scopes carried by a User. An empty set means the user
+// isn't restricted, which is the case for everything but scoped API tokens.
+type Scopes []Scope
+
+// scopePermissions are the only permissions a scope can allow.
+var scopePermissions = map[Access][]Permission{
+	AccessRead: {
+		PermissionReadConfiguration,
+		PermissionReadSensitiveConfiguration,
+	},
+	AccessWrite: {
+		PermissionReadConfiguration,
+		PermissionReadSensitiveConfiguration,
+		PermissionConfigureEnvironments,
+		PermissionConfigureSensitiveEnvironments,
+	},
+}
+
+func (s Scopes) Valid() error {
+	for _, scope := range s {
+		if scope.ServiceID <= 0 {
+			return fmt.Errorf("%w: a service is required", ErrInvalidScope)
+		}
+
+		if _, ok := scopePermissions[scope.Access]; !ok {
+			return fmt.Errorf("%w: access must be %s or %s", ErrInvalidScope, AccessRead, AccessWrite)
+		}
+	}
+
+	return nil
+}
+
+// Restricted reports whether the holder is limited to these scopes.
+func (s Scopes) Restricted() bool {
+	return len(s) > 0
+}
+
+// ServicePermissions returns those of permissions which the scopes allow on
+// the service with serviceID. Scopes limited to one environment allow their
+// permissions on the service, AllowsEnvironment must be used to check the
+// environment itself.
+func (s Scopes) ServicePermissions(serviceID int, permissions []Permission) []Permission {
+	if !s.Restricted() {
+		return permissions
+	}
+
+	allowed := make([]Permission, 0, len(permissions))
+	for _, scope := range s {
+		if scope.ServiceID != serviceID {
+			continue
+		}
+
+		for _, permission := range permissions {
+			if slices.Contains(scopePermissions[scope.Access], permission) && !slices.Contains(allowed, permission) {
+				allowed = append(allowed, permission)
+			}
+		}
+	}
+
+	return allowed
+}
+
+// AllowsEnvironment reports whether the scopes allow the needed access to the
+// environment with envID of the service with serviceID.
+func (s Scopes) AllowsEnvironment(serviceID, envID int, needed Access) bool {
+	if !s.Restricted() {
+		return true
+	}
+
+	for _, scope := range s {
+		if scope.ServiceID != serviceID {
+			continue
+		}
+
+		if scope.EnvironmentID != nil && *scope.EnvironmentID != envID {
+			continue
+		}
+
+		if scope.Access.Allows(needed) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ScopeSpec is a scope as requested by a user, using names instead of IDs.
+type ScopeSpec struct {
+	Service string
+	// Environment is the name of a single environment or * for all of the
+	// service's environments.
+	Environment string
+	Access      Access
+}
+
+func (s ScopeSpec) String() string {
+	return fmt.Sprintf("%s/%s:%s", s.Service, s.Environment, s.Access)
+}
+
+// AllEnvironments reports whether the spec covers every environment of the
+// service.
+func (s ScopeSpec) AllEnvironments() bool {
+	return s.Environment == "" || s.Environment == "*"
+}
+
+// ParseScopeSpec parses a scope in the form service/environment:access, for
+// example payments/*:read or payments/staging:write.
+func ParseScopeSpec(raw string) (ScopeSpec, error) {
+	target, access, ok := strings.Cut(raw, ":")
+	if !ok {
+		return ScopeSpec{}, fmt.Errorf("%w: %q must be in the form service/environment:access", ErrInvalidScope, raw)
+	}
+
+	service, environment, ok := strings.Cut(target, "/")
+	if !ok || service == "" || environment == "" {
+		return ScopeSpec{}, fmt.Errorf("%w: %q must be in the form service/environment:access", ErrInvalidScope, raw)
+	}
+
+	spec := ScopeSpec{
+		Service:     service,
+		Environment: environment,
+		Access:      Access(access),
+	}
+	if _, ok := scopePermissions[spec.Access]; !ok {
+		return ScopeSpec{}, fmt.Errorf("%w: access must be %s or %s", ErrInvalidScope, AccessRead, AccessWrite)
+	}
+
+	return spec, nil
+}
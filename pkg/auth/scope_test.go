@@ -0,0 +1,100 @@
+package auth_test
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/config-source/cdb/pkg/auth"
+)
+
+func TestParseScopeSpec(t *testing.T) {
+	spec, err := auth.ParseScopeSpec("payments/*:read")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := auth.ScopeSpec{Service: "payments", Environment: "*", Access: auth.AccessRead}
+	if spec != expected || !spec.AllEnvironments() {
+		t.Errorf("Expected %s got: %s", expected, spec)
+	}
+
+	for _, raw := range []string{"payments", "payments:read", "/staging:read", "payments/staging:admin"} {
+		if _, err := auth.ParseScopeSpec(raw); !errors.Is(err, auth.ErrInvalidScope) {
+			t.Errorf("Expected %q to be rejected got: %v", raw, err)
+		}
+	}
+}
+
+func TestScopesLimitPermissions(t *testing.T) {
+	staging := 2
+	scopes := auth.Scopes{
+		{ServiceID: 1, Access: auth.AccessRead},
+		{ServiceID: 2, EnvironmentID: &staging, Access: auth.AccessWrite},
+	}
+
+	requested := []auth.Permission{
+		auth.PermissionReadConfiguration,
+		auth.PermissionConfigureEnvironments,
+		auth.PermissionManageEnvironments,
+	}
+
+	if allowed := scopes.ServicePermissions(1, requested); !reflect.DeepEqual(allowed, requested[:1]) {
+		t.Errorf("Expected only read permissions on service 1 got: %v", allowed)
+	}
+
+	if allowed := scopes.ServicePermissions(2, requested); !reflect.DeepEqual(allowed, requested[:2]) {
+		t.Errorf("Expected read and configure permissions on service 2 got: %v", allowed)
+	}
+
+	if allowed := scopes.ServicePermissions(3, requested); len(allowed) != 0 {
+		t.Errorf("Expected no permissions on service 3 got: %v", allowed)
+	}
+
+	if allowed := auth.Scopes(nil).ServicePermissions(3, requested); !reflect.DeepEqual(allowed, requested) {
+		t.Errorf("Expected unscoped users to keep their permissions got: %v", allowed)
+	}
+
+	tests := []struct {
+		serviceID, envID int
+		needed           auth.Access
+		expected         bool
+	}{
+		{1, 1, auth.AccessRead, true},
+		{1, 1, auth.AccessWrite, false},
+		{2, 2, auth.AccessWrite, true},
+		{2, 3, auth.AccessRead, false},
+		{3, 2, auth.AccessRead, false},
+	}
+
+	for _, tc := range tests {
+		if allowed := scopes.AllowsEnvironment(tc.serviceID, tc.envID, tc.needed); allowed != tc.expected {
+			t.Errorf("Expected %s on service %d environment %d to be %t", tc.needed, tc.serviceID, tc.envID, tc.expected)
+		}
+	}
+}
+
+func TestScopesAreCarriedByAPITokens(t *testing.T) {
+	signingKey := auth.NewTestKeySet("testing")
+	user := auth.User{
+		ID:     1,
+		Email:  "test@example.com",
+		Scopes: auth.Scopes{{ServiceID: 1, Access: auth.AccessRead}},
+	}
+
+	expiresAt := time.Now().Add(time.Hour)
+	token, err := auth.GenerateAPIToken(signingKey, user, &expiresAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	validated, err := auth.ValidateIdToken(signingKey, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(validated.Scopes, user.Scopes) {
+		t.Errorf("Expected scopes %v got: %v", user.Scopes, validated.Scopes)
+	}
+}
@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/jackc/pgx/v5"
+)
+
+var (
+	// ErrSessionRevoked is returned for tokens of a session which has been
+	// logged out of or revoked because its refresh token was reused.
+	ErrSessionRevoked = fmt.Errorf("%w: session has been revoked", ErrUnauthenticated)
+	// ErrRefreshTokenReused is returned when a refresh token which has
+	// already been exchanged is used again. Only one of the two holders can
+	// be the user so the whole session is revoked.
+	ErrRefreshTokenReused = fmt.Errorf("%w: refresh token has already been used", ErrSessionRevoked)
+)
+
+// Session is a login. Its refresh token ID changes every time it's refreshed
+// so only the most recently issued refresh token can be exchanged.
+type Session struct {
+	ID             string     `db:"id"`
+	UserID         UserID     `db:"user_id"`
+	RefreshTokenID string     `db:"refresh_token_id"`
+	CreatedAt      time.Time  `db:"created_at"`
+	RefreshedAt    *time.Time `db:"refreshed_at"`
+	RevokedAt      *time.Time `db:"revoked_at"`
+}
+
+const sessionColumns = "id, user_id, refresh_token_id, created_at, refreshed_at, revoked_at"
+
+func randomID() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+func (tr *TokenRegistry) StartSession(ctx context.Context, user User) (Session, error) {
+	id, err := randomID()
+	if err != nil {
+		return Session{}, err
+	}
+
+	refreshTokenID, err := randomID()
+	if err != nil {
+		return Session{}, err
+	}
+
+	return postgresutils.GetOne[Session](
+		tr.pool,
+		ctx,
+		"INSERT INTO sessions (id, user_id, refresh_token_id) VALUES ($1, $2, $3) RETURNING "+sessionColumns,
+		id,
+		user.ID,
+		refreshTokenID,
+	)
+}
+
+// RotateSession exchanges the refresh token with claims for a new refresh
+// token ID. If the refresh token has already been exchanged the session is
+// revoked and ErrRefreshTokenReused is returned.
+func (tr *TokenRegistry) RotateSession(ctx context.Context, claims RefreshClaims) (Session, error) {
+	userID, err := claims.UserID()
+	if err != nil {
+		return Session{}, err
+	}
+
+	refreshTokenID, err := randomID()
+	if err != nil {
+		return Session{}, err
+	}
+
+	session, err := postgresutils.GetOne[Session](
+		tr.pool,
+		ctx,
+		`UPDATE sessions SET refresh_token_id = $1, refreshed_at = current_timestamp
+		WHERE id = $2
+		AND user_id = $3
+		AND refresh_token_id = $4
+		AND revoked_at IS NULL
+		RETURNING `+sessionColumns,
+		refreshTokenID,
+		claims.SessionID,
+		userID,
+		claims.ID,
+	)
+	if err == nil {
+		return session, nil
+	}
+
+	if !errors.Is(err, pgx.ErrNoRows) {
+		return Session{}, err
+	}
+
+	// The session either doesn't exist, isn't the user's, was already
+	// revoked or the refresh token was already exchanged. Only the last is
+	// left active and must be revoked.
+	result, err := tr.pool.Exec(
+		ctx,
+		"UPDATE sessions SET revoked_at = current_timestamp WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL",
+		claims.SessionID,
+		userID,
+	)
+	if err != nil {
+		return Session{}, err
+	}
+
+	if result.RowsAffected() == 0 {
+		return Session{}, ErrSessionRevoked
+	}
+
+	tr.log.Warn().
+		Interface("actorID", userID).
+		Str("sessionID", claims.SessionID).
+		Bool("audit", true).
+		Msg("refresh token reused, revoked session")
+
+	return Session{}, ErrRefreshTokenReused
+}
+
+func (tr *TokenRegistry) RevokeSession(ctx context.Context, claims RefreshClaims) error {
+	userID, err := claims.UserID()
+	if err != nil {
+		return err
+	}
+
+	_, err = tr.pool.Exec(
+		ctx,
+		"UPDATE sessions SET revoked_at = current_timestamp WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL",
+		claims.SessionID,
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	tr.log.Info().
+		Interface("actorID", userID).
+		Str("sessionID", claims.SessionID).
+		Bool("audit", true).
+		Msg("revoked session")
+
+	return nil
+}
+
+// IsSessionActive reports whether the user's session with sessionID exists
+// and hasn't been revoked.
+func (tr *TokenRegistry) IsSessionActive(ctx context.Context, user User, sessionID string) (bool, error) {
+	var count int
+	err := tr.pool.QueryRow(
+		ctx,
+		"SELECT count(*) FROM sessions WHERE id = $1 AND user_id = $2 AND revoked_at IS NULL",
+		sessionID,
+		user.ID,
+	).Scan(&count)
+	return count > 0, err
+}
+
+// RevokeUserSessions revokes every session of the user, logging them out
+// everywhere.
+func (tr *TokenRegistry) RevokeUserSessions(ctx context.Context, userID UserID) error {
+	result, err := tr.pool.Exec(
+		ctx,
+		"UPDATE sessions SET revoked_at = current_timestamp WHERE user_id = $1 AND revoked_at IS NULL",
+		userID,
+	)
+	if err != nil {
+		return err
+	}
+
+	tr.log.Info().
+		Interface("userID", userID).
+		Int64("sessions", result.RowsAffected()).
+		Bool("audit", true).
+		Msg("revoked all sessions of user")
+
+	return nil
+}
+
+// RevokeUserCredentials revokes all of the user's sessions and API tokens.
+func (tr *TokenRegistry) RevokeUserCredentials(ctx context.Context, userID UserID) error {
+	if err := tr.RevokeUserSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	result, err := tr.pool.Exec(
+		ctx,
+		"UPDATE api_tokens SET revoked_at = current_timestamp WHERE user_id = $1 AND revoked_at IS NULL",
+		strconv.Itoa(int(userID)),
+	)
+	if err != nil {
+		return err
+	}
+
+	tr.log.Info().
+		Interface("userID", userID).
+		Int64("apiTokens", result.RowsAffected()).
+		Bool("audit", true).
+		Msg("revoked all API tokens of user")
+
+	return nil
+}
@@ -2,6 +2,8 @@ package auth
 
 import (
 	"context"
+	"slices"
+	"time"
 )
 
 // TestGateway is an in-memory Authn/z Gateway used for tests only.
@@ -10,14 +12,38 @@ type TestGateway struct {
 	Error               error
 	DenyPermissionCheck bool
 
+	// ServicePermissions are granted by HasServicePermission even when
+	// DenyPermissionCheck is set, keyed by service ID.
+	ServicePermissions map[int][]Permission
+	// EnvironmentAccess is returned by GetEnvironmentAccess for every actor.
+	EnvironmentAccess map[int]Access
+	// SensitiveWriteAccess is returned by HasSensitiveWriteAccess for every
+	// user.
+	SensitiveWriteAccess bool
+	// SyncedRoles records the roles granted by SyncRolesNoAuth keyed by user.
+	SyncedRoles map[UserID][]string
+	// Identities records the links made by LinkIdentity, pending links have
+	// an empty subject.
+	Identities map[TestIdentity]string
+
 	Users     map[UserID]User
 	EmailToID map[string]UserID
 }
 
+// TestIdentity keys TestGateway.Identities.
+type TestIdentity struct {
+	UserID UserID
+	Issuer string
+}
+
 func NewTestGateway() *TestGateway {
 	return &TestGateway{
 		IsHealthy:           true,
 		DenyPermissionCheck: false,
+		ServicePermissions:  make(map[int][]Permission),
+		EnvironmentAccess:   make(map[int]Access),
+		SyncedRoles:         make(map[UserID][]string),
+		Identities:          make(map[TestIdentity]string),
 		Error:               nil,
 		Users:               make(map[UserID]User),
 		EmailToID:           make(map[string]UserID),
@@ -29,8 +55,13 @@ func NewTestServiceWithGateway(testgw *TestGateway) *UserService {
 		testgw,
 		testgw,
 		&TokenRegistry{},
+		nil,
+		nil,
 		true,
 		"Operator",
+		PasswordPolicy{},
+		&TestNotifier{},
+		nil,
 	)
 }
 
@@ -86,6 +117,17 @@ func (tg *TestGateway) CreateUser(ctx context.Context, newUser User) (User, erro
 	return tg.Register(ctx, newUser.Email, newUser.Password)
 }
 
+func (tg *TestGateway) SetPassword(ctx context.Context, userID UserID, password string) error {
+	user, ok := tg.Users[userID]
+	if !ok {
+		return ErrUserNotFound
+	}
+
+	user.Password = password
+	tg.Users[userID] = user
+	return tg.Error
+}
+
 func (tg *TestGateway) GetUser(ctx context.Context, userID UserID) (User, error) {
 	user, ok := tg.Users[userID]
 	if !ok {
@@ -95,11 +137,31 @@ func (tg *TestGateway) GetUser(ctx context.Context, userID UserID) (User, error)
 	return user, tg.Error
 }
 
+func (tg *TestGateway) GetUserByEmail(ctx context.Context, email string) (User, error) {
+	userID, ok := tg.EmailToID[email]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+
+	return tg.GetUser(ctx, userID)
+}
+
 func (tg *TestGateway) DeleteUser(ctx context.Context, userID UserID) error {
 	delete(tg.Users, userID)
 	return tg.Error
 }
 
+func (tg *TestGateway) SetUserDisabled(ctx context.Context, userID UserID, disabled bool) (User, error) {
+	user, ok := tg.Users[userID]
+	if !ok {
+		return User{}, ErrUserNotFound
+	}
+
+	user.Disabled = disabled
+	tg.Users[userID] = user
+	return user, tg.Error
+}
+
 func (tg *TestGateway) ListUsers(ctx context.Context) ([]User, error) {
 	users := make([]User, len(tg.Users))
 	for id, user := range tg.Users {
@@ -109,6 +171,42 @@ func (tg *TestGateway) ListUsers(ctx context.Context) ([]User, error) {
 	return users, tg.Error
 }
 
+// IdentityLinker
+
+func (tg *TestGateway) GetIdentityUser(ctx context.Context, issuer, subject string) (UserID, error) {
+	for identity, linked := range tg.Identities {
+		if identity.Issuer == issuer && linked == subject && subject != "" {
+			return identity.UserID, tg.Error
+		}
+	}
+
+	return 0, ErrUserNotFound
+}
+
+func (tg *TestGateway) LinkIdentity(ctx context.Context, issuer, subject string, userID UserID) error {
+	key := TestIdentity{UserID: userID, Issuer: issuer}
+	if _, ok := tg.Identities[key]; ok {
+		return ErrIdentityConflict
+	}
+
+	if _, err := tg.GetIdentityUser(ctx, issuer, subject); err == nil {
+		return ErrIdentityConflict
+	}
+
+	tg.Identities[key] = subject
+	return tg.Error
+}
+
+func (tg *TestGateway) ClaimIdentity(ctx context.Context, issuer, subject string, userID UserID) (bool, error) {
+	key := TestIdentity{UserID: userID, Issuer: issuer}
+	if linked, ok := tg.Identities[key]; !ok || linked != "" {
+		return false, tg.Error
+	}
+
+	tg.Identities[key] = subject
+	return true, tg.Error
+}
+
 // AuthorizationGateway
 
 func (tg *TestGateway) HasPermission(
@@ -117,13 +215,68 @@ func (tg *TestGateway) HasPermission(
 	permission Permission,
 	additionalPermissions ...Permission,
 ) (bool, error) {
+	if actor.Scopes.Restricted() {
+		return false, tg.Error
+	}
+
 	return !tg.DenyPermissionCheck, tg.Error
 }
 
+func (tg *TestGateway) HasServicePermission(
+	ctx context.Context,
+	actor User,
+	serviceID int,
+	permission Permission,
+	additionalPermissions ...Permission,
+) (bool, error) {
+	permissions := actor.Scopes.ServicePermissions(serviceID, append([]Permission{permission}, additionalPermissions...))
+	if len(permissions) == 0 {
+		return false, tg.Error
+	}
+
+	if !tg.DenyPermissionCheck {
+		return true, tg.Error
+	}
+
+	for _, granted := range tg.ServicePermissions[serviceID] {
+		if slices.Contains(permissions, granted) {
+			return true, tg.Error
+		}
+	}
+
+	return false, tg.Error
+}
+
+func (tg *TestGateway) HasPermissionOnAnyService(
+	ctx context.Context,
+	user User,
+	permission Permission,
+	additionalPermissions ...Permission,
+) (bool, error) {
+	permissions := append([]Permission{permission}, additionalPermissions...)
+	for _, granted := range tg.ServicePermissions {
+		for _, p := range granted {
+			if slices.Contains(permissions, p) {
+				return true, tg.Error
+			}
+		}
+	}
+
+	return false, tg.Error
+}
+
+func (tg *TestGateway) ListRoles(ctx context.Context, actor User) ([]Role, error) {
+	return []Role{{Name: "test", Permissions: []Permission{PermissionConfigureEnvironments}}}, tg.Error
+}
+
 func (tg *TestGateway) CreateRole(ctx context.Context, actor User, role string, permissions []Permission) error {
 	return tg.Error
 }
 
+func (tg *TestGateway) DeleteRole(ctx context.Context, actor User, role string) error {
+	return tg.Error
+}
+
 func (tg *TestGateway) AddPermissionsToRole(ctx context.Context, actor User, role string, permissions []Permission) error {
 	return tg.Error
 }
@@ -148,6 +301,69 @@ func (tg *TestGateway) AssignRoleToUserNoAuth(ctx context.Context, user User, ro
 	return tg.Error
 }
 
+func (tg *TestGateway) SyncRolesNoAuth(ctx context.Context, user User, managed, granted []string) ([]string, []string, error) {
+	before := tg.SyncedRoles[user.ID]
+	tg.SyncedRoles[user.ID] = granted
+	return before, granted, tg.Error
+}
+
 func (tg *TestGateway) RemoveRoleFromUser(ctx context.Context, actor User, user User, role string) error {
 	return tg.Error
 }
+
+func (tg *TestGateway) ListServiceRoles(ctx context.Context, actor User, serviceID int) ([]ServiceRole, error) {
+	return []ServiceRole{}, tg.Error
+}
+
+func (tg *TestGateway) AssignServiceRole(ctx context.Context, actor User, user User, serviceID int, role string) error {
+	return tg.Error
+}
+
+func (tg *TestGateway) RemoveServiceRole(ctx context.Context, actor User, user User, serviceID int) error {
+	return tg.Error
+}
+
+func (tg *TestGateway) GetEnvironmentAccess(ctx context.Context, actor User) (map[int]Access, error) {
+	return tg.EnvironmentAccess, tg.Error
+}
+
+func (tg *TestGateway) HasSensitiveWriteAccess(ctx context.Context, user User) (bool, error) {
+	return tg.SensitiveWriteAccess, tg.Error
+}
+
+func (tg *TestGateway) ListEnvironmentACL(ctx context.Context, environmentID int) ([]ACLEntry, error) {
+	return []ACLEntry{}, tg.Error
+}
+
+func (tg *TestGateway) CreateEnvironmentACLEntry(ctx context.Context, entry ACLEntry) (ACLEntry, error) {
+	return entry, tg.Error
+}
+
+func (tg *TestGateway) DeleteEnvironmentACLEntry(ctx context.Context, environmentID, id int) error {
+	return tg.Error
+}
+
+// NewTestKeySet returns a KeySet which signs tokens with HS512 and secret.
+func NewTestKeySet(secret string) *KeySet {
+	keys, err := NewKeySet(AlgorithmHS512, []byte(secret))
+	if err != nil {
+		panic(err)
+	}
+
+	return keys
+}
+
+// TestNotifier records the password reset tokens it's asked to deliver keyed
+// by user.
+type TestNotifier struct {
+	PasswordResets map[UserID]string
+}
+
+func (tn *TestNotifier) NotifyPasswordReset(ctx context.Context, user User, token string, expiresAt time.Time) error {
+	if tn.PasswordResets == nil {
+		tn.PasswordResets = make(map[UserID]string)
+	}
+
+	tn.PasswordResets[user.ID] = token
+	return nil
+}
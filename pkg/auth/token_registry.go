@@ -2,14 +2,26 @@ package auth
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
 	"strconv"
 	"time"
 
 	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/rs/zerolog"
 )
 
+var (
+	ErrAPITokenNotFound = errors.New("no API token with that ID exists")
+	// ErrAPITokenInvalid is returned when an API token has been revoked, has
+	// expired or was never issued.
+	ErrAPITokenInvalid = fmt.Errorf("%w: API token is revoked or expired", ErrUnauthenticated)
+)
+
 type TokenRegistry struct {
 	pool *pgxpool.Pool
 	log  zerolog.Logger
@@ -23,35 +35,146 @@ func NewTokenRegistry(log zerolog.Logger, pool *pgxpool.Pool) *TokenRegistry {
 }
 
 type APIToken struct {
-	UserID    string    `db:"user_id" json:"-"`
-	CreatedAt time.Time `db:"created_at"`
-	Token     string    `db:"token"`
+	ID         int        `db:"id"`
+	UserID     string     `db:"user_id" json:"-"`
+	Name       string     `db:"name"`
+	CreatedAt  time.Time  `db:"created_at"`
+	ExpiresAt  *time.Time `db:"expires_at"`
+	LastUsedAt *time.Time `db:"last_used_at"`
+	Scopes     Scopes     `db:"scopes"`
+
+	// Token is only returned when the token is issued, afterwards only its
+	// hash is kept.
+	Token string `db:"-" json:",omitempty"`
+}
+
+// APITokenRequest is the body of a request to issue an API token, when
+// ExpiresAt is nil the token lasts until it's revoked and when Scopes is empty
+// it has all of its creator's permissions.
+type APITokenRequest struct {
+	Name      string
+	ExpiresAt *time.Time
+	Scopes    []ScopeSpec
 }
 
-func (tr *TokenRegistry) IssueAPIToken(ctx context.Context, signingKey []byte, user User) (APIToken, error) {
-	tokenString, err := GenerateAPIToken(signingKey, user)
+// HashAPIToken returns the hash API tokens are stored and looked up by.
+func HashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+const apiTokenColumns = "id, user_id, name, created_at, expires_at, last_used_at, scopes"
+
+func (tr *TokenRegistry) IssueAPIToken(
+	ctx context.Context,
+	keys *KeySet,
+	user User,
+	name string,
+	expiresAt *time.Time,
+) (APIToken, error) {
+	tokenString, err := GenerateAPIToken(keys, user, expiresAt)
 	if err != nil {
 		return APIToken{}, err
 	}
 
-	return postgresutils.GetOne[APIToken](
+	scopes := user.Scopes
+	if scopes == nil {
+		scopes = Scopes{}
+	}
+
+	token, err := postgresutils.GetOne[APIToken](
 		tr.pool,
 		ctx,
-		"INSERT INTO api_tokens (user_id, token) VALUES ($1, $2) RETURNING *",
+		"INSERT INTO api_tokens (user_id, name, token_hash, expires_at, scopes) VALUES ($1, $2, $3, $4, $5) RETURNING "+apiTokenColumns,
 		strconv.Itoa(int(user.ID)),
-		tokenString,
+		name,
+		HashAPIToken(tokenString),
+		expiresAt,
+		scopes,
 	)
+	if err != nil {
+		return APIToken{}, err
+	}
+
+	tr.log.Info().
+		Interface("actorID", user.ID).
+		Int("tokenID", token.ID).
+		Str("name", name).
+		Interface("scopes", user.Scopes).
+		Bool("audit", true).
+		Msg("issued API token")
+
+	token.Token = tokenString
+	return token, nil
 }
 
+// ListAPITokens returns the user's tokens which haven't been revoked.
 func (tr *TokenRegistry) ListAPITokens(ctx context.Context, user User) ([]APIToken, error) {
 	return postgresutils.GetAll[APIToken](
 		tr.pool,
 		ctx,
-		"SELECT created_at,token FROM api_tokens WHERE user_id = $1",
+		"SELECT "+apiTokenColumns+" FROM api_tokens WHERE user_id = $1 AND revoked_at IS NULL ORDER BY id",
 		strconv.Itoa(int(user.ID)),
 	)
 }
 
+func (tr *TokenRegistry) GetAPIToken(ctx context.Context, id int) (APIToken, error) {
+	token, err := postgresutils.GetOne[APIToken](
+		tr.pool,
+		ctx,
+		"SELECT "+apiTokenColumns+" FROM api_tokens WHERE id = $1 AND revoked_at IS NULL",
+		id,
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return APIToken{}, ErrAPITokenNotFound
+	}
+
+	return token, err
+}
+
+func (tr *TokenRegistry) RevokeAPIToken(ctx context.Context, actor User, id int) error {
+	result, err := tr.pool.Exec(
+		ctx,
+		"UPDATE api_tokens SET revoked_at = current_timestamp WHERE id = $1 AND revoked_at IS NULL",
+		id,
+	)
+	if err != nil {
+		return err
+	}
+
+	if result.RowsAffected() == 0 {
+		return ErrAPITokenNotFound
+	}
+
+	tr.log.Info().
+		Interface("actorID", actor.ID).
+		Int("tokenID", id).
+		Bool("audit", true).
+		Msg("revoked API token")
+
+	return nil
+}
+
+// UseAPIToken records that the token was used returning ErrAPITokenInvalid if
+// it has been revoked or has expired.
+func (tr *TokenRegistry) UseAPIToken(ctx context.Context, token string) (APIToken, error) {
+	used, err := postgresutils.GetOne[APIToken](
+		tr.pool,
+		ctx,
+		`UPDATE api_tokens SET last_used_at = current_timestamp
+		WHERE token_hash = $1
+		AND revoked_at IS NULL
+		AND (expires_at IS NULL OR expires_at > current_timestamp)
+		RETURNING `+apiTokenColumns,
+		HashAPIToken(token),
+	)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return APIToken{}, ErrAPITokenInvalid
+	}
+
+	return used, err
+}
+
 func (tr *TokenRegistry) Revoke(ctx context.Context, token string) error {
 	_, err := tr.pool.Query(ctx, "INSERT INTO revoked_tokens (token) VALUES ($1)", token)
 	// Means the token is already revoked so there isn't really any error worth
@@ -72,3 +195,79 @@ func (tr *TokenRegistry) IsRevoked(ctx context.Context, token string) (bool, err
 	).Scan(&count)
 	return count > 0, err
 }
+
+// IssuePasswordResetToken issues a one time token which lets the user set a
+// new password until expiresAt. Any token previously issued to the user can no
+// longer be used.
+func (tr *TokenRegistry) IssuePasswordResetToken(ctx context.Context, actor, user User, expiresAt time.Time) (string, error) {
+	token, err := randomID()
+	if err != nil {
+		return "", err
+	}
+
+	tx, err := tr.pool.Begin(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer tx.Rollback(ctx) // nolint:errcheck
+
+	_, err = tx.Exec(
+		ctx,
+		"UPDATE password_reset_tokens SET used_at = current_timestamp WHERE user_id = $1 AND used_at IS NULL",
+		user.ID,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	_, err = tx.Exec(
+		ctx,
+		"INSERT INTO password_reset_tokens (token_hash, user_id, expires_at) VALUES ($1, $2, $3)",
+		HashAPIToken(token),
+		user.ID,
+		expiresAt,
+	)
+	if err != nil {
+		return "", err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return "", err
+	}
+
+	tr.log.Info().
+		Interface("actorID", actor.ID).
+		Interface("userID", user.ID).
+		Time("expiresAt", expiresAt).
+		Bool("audit", true).
+		Msg("issued password reset token")
+
+	return token, nil
+}
+
+// UsePasswordResetToken marks the token as used returning the user it was
+// issued to, or ErrPasswordResetTokenInvalid if it can't be used.
+func (tr *TokenRegistry) UsePasswordResetToken(ctx context.Context, token string) (UserID, error) {
+	var userID UserID
+	err := tr.pool.QueryRow(
+		ctx,
+		`UPDATE password_reset_tokens SET used_at = current_timestamp
+		WHERE token_hash = $1
+		AND used_at IS NULL
+		AND expires_at > current_timestamp
+		RETURNING user_id`,
+		HashAPIToken(token),
+	).Scan(&userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, ErrPasswordResetTokenInvalid
+	} else if err != nil {
+		return 0, err
+	}
+
+	tr.log.Info().
+		Interface("actorID", userID).
+		Bool("audit", true).
+		Msg("used password reset token")
+
+	return userID, nil
+}
@@ -0,0 +1,157 @@
+package auth_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/rs/zerolog"
+)
+
+func TestAPITokenLifecycle(t *testing.T) {
+	pool := postgresutils.InitTestDB(t)
+	registry := auth.NewTokenRegistry(zerolog.New(nil).Level(zerolog.Disabled), pool)
+	gateway := auth.NewTestGateway()
+	gateway.DenyPermissionCheck = true
+	svc := auth.NewUserService(gateway, gateway, registry, nil, nil, false, "", auth.PasswordPolicy{}, &auth.TestNotifier{}, nil)
+
+	ctx := context.Background()
+	signingKey := auth.NewTestKeySet("testing")
+	owner := auth.User{ID: 1, Email: "owner@example.com"}
+	other := auth.User{ID: 2, Email: "other@example.com"}
+
+	token, err := svc.IssueAPIToken(ctx, signingKey, owner, "ci", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token.Token == "" || token.Name != "ci" {
+		t.Fatalf("Expected the issued token to be returned got: %v", token)
+	}
+
+	if err := svc.AuthenticateAPIToken(ctx, token.Token); err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := svc.ListAPITokens(ctx, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tokens) != 1 || tokens[0].LastUsedAt == nil || tokens[0].Token != "" {
+		t.Fatalf("Expected one used token without its value got: %v", tokens)
+	}
+
+	err = svc.RevokeAPIToken(ctx, other, token.ID)
+	if !errors.Is(err, auth.ErrAPITokenNotFound) {
+		t.Errorf("Expected other users to not be able to revoke the token got: %v", err)
+	}
+
+	if err := svc.RevokeAPIToken(ctx, owner, token.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.AuthenticateAPIToken(ctx, token.Token)
+	if !errors.Is(err, auth.ErrAPITokenInvalid) || !errors.Is(err, auth.ErrUnauthenticated) {
+		t.Errorf("Expected a revoked token to be rejected got: %v", err)
+	}
+
+	tokens, err = svc.ListAPITokens(ctx, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tokens) != 0 {
+		t.Errorf("Expected revoked tokens to not be listed got: %v", tokens)
+	}
+}
+
+func TestExpiredAPITokensAreRejected(t *testing.T) {
+	pool := postgresutils.InitTestDB(t)
+	registry := auth.NewTokenRegistry(zerolog.New(nil).Level(zerolog.Disabled), pool)
+	svc := auth.NewUserService(auth.NewTestGateway(), auth.NewTestGateway(), registry, nil, nil, false, "", auth.PasswordPolicy{}, &auth.TestNotifier{}, nil)
+
+	ctx := context.Background()
+	owner := auth.User{ID: 1, Email: "owner@example.com"}
+
+	past := time.Now().Add(-time.Minute)
+	_, err := svc.IssueAPIToken(ctx, auth.NewTestKeySet("testing"), owner, "ci", &past, nil)
+	if !errors.Is(err, auth.ErrAPITokenExpiresAtInPast) {
+		t.Errorf("Expected %s got: %v", auth.ErrAPITokenExpiresAtInPast, err)
+	}
+
+	soon := time.Now().Add(time.Minute)
+	token, err := svc.IssueAPIToken(ctx, auth.NewTestKeySet("testing"), owner, "ci", &soon, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = pool.Exec(ctx, "UPDATE api_tokens SET expires_at = current_timestamp - interval '1 second' WHERE id = $1", token.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = svc.AuthenticateAPIToken(ctx, token.Token)
+	if !errors.Is(err, auth.ErrAPITokenInvalid) {
+		t.Errorf("Expected an expired token to be rejected got: %v", err)
+	}
+}
+
+func TestScopedAPITokens(t *testing.T) {
+	pool := postgresutils.InitTestDB(t)
+	registry := auth.NewTokenRegistry(zerolog.New(nil).Level(zerolog.Disabled), pool)
+	svc := auth.NewUserService(auth.NewTestGateway(), auth.NewTestGateway(), registry, nil, nil, false, "", auth.PasswordPolicy{}, &auth.TestNotifier{}, nil)
+
+	ctx := context.Background()
+	owner := auth.User{ID: 1, Email: "owner@example.com"}
+	scopes := auth.Scopes{{ServiceID: 1, Access: auth.AccessRead}}
+
+	_, err := svc.IssueAPIToken(ctx, auth.NewTestKeySet("testing"), owner, "ci", nil, auth.Scopes{{Access: auth.AccessRead}})
+	if !errors.Is(err, auth.ErrInvalidScope) {
+		t.Errorf("Expected %s got: %v", auth.ErrInvalidScope, err)
+	}
+
+	token, err := svc.IssueAPIToken(ctx, auth.NewTestKeySet("testing"), owner, "ci", nil, scopes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tokens, err := svc.ListAPITokens(ctx, owner)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tokens) != 1 || len(tokens[0].Scopes) != 1 || tokens[0].Scopes[0].ServiceID != 1 {
+		t.Fatalf("Expected the token to be listed with its scopes got: %v", tokens)
+	}
+
+	holder, err := auth.ValidateIdToken(auth.NewTestKeySet("testing"), token.Token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = svc.IssueAPIToken(ctx, auth.NewTestKeySet("testing"), holder, "escalated", nil, nil)
+	if !errors.Is(err, auth.ErrUnauthorized) {
+		t.Errorf("Expected scoped tokens to not be able to issue tokens got: %v", err)
+	}
+
+	unscoped, err := svc.IssueAPIToken(ctx, auth.NewTestKeySet("testing"), owner, "deploy", nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := svc.ListAPITokens(ctx, holder); !errors.Is(err, auth.ErrUnauthorized) {
+		t.Errorf("Expected scoped tokens to not be able to list tokens got: %v", err)
+	}
+
+	if err := svc.RevokeAPIToken(ctx, holder, unscoped.ID); !errors.Is(err, auth.ErrUnauthorized) {
+		t.Errorf("Expected scoped tokens to not be able to revoke tokens got: %v", err)
+	}
+
+	if err := svc.AuthenticateAPIToken(ctx, unscoped.Token); err != nil {
+		t.Errorf("Expected the unscoped token to still be valid got: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package auth_test
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/config-source/cdb/pkg/auth"
+)
+
+func TestTOTPCodesMatchTheRFCTestVectors(t *testing.T) {
+	// The SHA-1 secret from RFC 6238 appendix B, "12345678901234567890".
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+	expected := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+
+	for unix, code := range expected {
+		got, err := auth.TOTPCode(secret, auth.TOTPStep(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got != code {
+			t.Errorf("Expected %s at %d got: %s", code, unix, got)
+		}
+	}
+}
+
+func TestTOTPProvisioningURI(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	uri, err := url.Parse(auth.TOTPProvisioningURI("cdb", "test@example.com", secret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/cdb:test@example.com" {
+		t.Errorf("Unexpected provisioning URI: %s", uri)
+	}
+
+	if uri.Query().Get("secret") != secret || uri.Query().Get("issuer") != "cdb" {
+		t.Errorf("Expected the secret and issuer in the provisioning URI got: %s", uri)
+	}
+}
@@ -4,16 +4,30 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/config-source/cdb/pkg/audit"
 )
 
 var (
-	ErrPublicRegisterDisabled = errors.New("public registration is disabled")
+	ErrPublicRegisterDisabled  = errors.New("public registration is disabled")
+	ErrAPITokenExpiresAtInPast = errors.New("API token expiry must be in the future")
 )
 
+// PasswordResetTokenTTL is how long users have to use a password reset token.
+const PasswordResetTokenTTL = time.Hour
+
 type UserService struct {
 	authn    AuthenticationGateway
 	authz    AuthorizationGateway
 	registry *TokenRegistry
+	throttle *LoginThrottle
+	mfa      *MFAStore
+	policy   PasswordPolicy
+	notifier Notifier
+	audit    *audit.Log
 
 	publicRegisterAllowed bool
 	defaultRegisterRole   string
@@ -23,13 +37,23 @@ func NewUserService(
 	authn AuthenticationGateway,
 	authz AuthorizationGateway,
 	registry *TokenRegistry,
+	throttle *LoginThrottle,
+	mfa *MFAStore,
 	allowPublicRegistration bool,
 	defaultRegisterRole string,
+	policy PasswordPolicy,
+	notifier Notifier,
+	auditLog *audit.Log,
 ) *UserService {
 	return &UserService{
 		authn:                 authn,
 		authz:                 authz,
 		registry:              registry,
+		throttle:              throttle,
+		mfa:                   mfa,
+		policy:                policy,
+		notifier:              notifier,
+		audit:                 auditLog,
 		publicRegisterAllowed: allowPublicRegistration,
 		defaultRegisterRole:   defaultRegisterRole,
 	}
@@ -39,22 +63,374 @@ func (us *UserService) Healthy(ctx context.Context) bool {
 	return us.authn.Healthy(ctx)
 }
 
-func (us *UserService) Login(ctx context.Context, email, password string) (User, error) {
-	return us.authn.Login(ctx, email, password)
+// Login checks the user's password, and MFA code when they have enrolled,
+// refusing to when there have been too many failed logins for the account or
+// from ip recently.
+func (us *UserService) Login(ctx context.Context, email, password, mfaCode, ip string) (User, error) {
+	user, err := us.checkCredentials(ctx, email, password, ip)
+	if err != nil {
+		return User{}, err
+	}
+
+	if err := us.verifyMFA(ctx, user, mfaCode); err != nil {
+		if errors.Is(err, ErrInvalidMFACode) {
+			if recordErr := us.throttle.RecordFailure(ctx, email, ip); recordErr != nil {
+				return User{}, recordErr
+			}
+		}
+
+		return User{}, err
+	}
+
+	return user, us.throttle.RecordSuccess(ctx, email)
+}
+
+// checkCredentials checks the user's password, counting failures towards the
+// login throttle.
+func (us *UserService) checkCredentials(ctx context.Context, email, password, ip string) (User, error) {
+	if err := us.throttle.Check(ctx, email, ip); err != nil {
+		return User{}, err
+	}
+
+	user, err := us.authn.Login(ctx, email, password)
+	if errors.Is(err, ErrInvalidPassword) || errors.Is(err, ErrUserNotFound) {
+		if recordErr := us.throttle.RecordFailure(ctx, email, ip); recordErr != nil {
+			return User{}, recordErr
+		}
+
+		return User{}, err
+	} else if err != nil {
+		return User{}, err
+	}
+
+	return checkEnabled(user)
+}
+
+func checkEnabled(user User) (User, error) {
+	if user.Disabled {
+		return User{}, ErrUserDisabled
+	}
+
+	return user, nil
+}
+
+// MFARequired returns whether the user's permissions require them to enrol in
+// MFA before they can login. That's anyone who can manage users or change
+// sensitive configuration, whether globally, through a service role or
+// through an environment ACL entry.
+func (us *UserService) MFARequired(ctx context.Context, user User) (bool, error) {
+	required, err := us.authz.HasPermission(
+		ctx,
+		user,
+		PermissionConfigureSensitiveEnvironments,
+		PermissionManageUsers,
+	)
+	if err != nil || required {
+		return required, err
+	}
+
+	required, err = us.authz.HasPermissionOnAnyService(ctx, user, PermissionConfigureSensitiveEnvironments)
+	if err != nil || required {
+		return required, err
+	}
+
+	return us.authz.HasSensitiveWriteAccess(ctx, user)
+}
+
+func (us *UserService) verifyMFA(ctx context.Context, user User, code string) error {
+	if us.mfa == nil {
+		return nil
+	}
+
+	enrolled, err := us.mfa.Enrolled(ctx, user.ID)
+	if err != nil {
+		return err
+	}
+
+	if !enrolled {
+		required, err := us.MFARequired(ctx, user)
+		if err != nil {
+			return err
+		}
+
+		if required {
+			return ErrMFAEnrolmentRequired
+		}
+
+		return nil
+	}
+
+	if code == "" {
+		return ErrMFARequired
+	}
+
+	return us.mfa.Verify(ctx, user.ID, code)
+}
+
+// EnrolMFA starts enrolling the user in MFA. It takes their credentials
+// rather than a session so that users who must enrol before they can login
+// are able to.
+func (us *UserService) EnrolMFA(ctx context.Context, email, password, ip string) (MFAEnrolment, error) {
+	if us.mfa == nil {
+		return MFAEnrolment{}, ErrMFAUnsupported
+	}
+
+	user, err := us.checkCredentials(ctx, email, password, ip)
+	if err != nil {
+		return MFAEnrolment{}, err
+	}
+
+	return us.mfa.Enrol(ctx, user)
+}
+
+// ConfirmMFA finishes enrolling the user in MFA, returning their recovery
+// codes.
+func (us *UserService) ConfirmMFA(ctx context.Context, email, password, code, ip string) ([]string, error) {
+	if us.mfa == nil {
+		return nil, ErrMFAUnsupported
+	}
+
+	user, err := us.checkCredentials(ctx, email, password, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := us.mfa.Confirm(ctx, user, code)
+	if errors.Is(err, ErrInvalidMFACode) {
+		if recordErr := us.throttle.RecordFailure(ctx, email, ip); recordErr != nil {
+			return nil, recordErr
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	us.audit.Record(ctx, user, audit.Event{
+		Action:     audit.ActionUserEnrolMFA,
+		TargetKind: audit.TargetUser,
+		TargetID:   strconv.Itoa(int(user.ID)),
+	})
+	return codes, nil
+}
+
+// ResetMFA removes a user's MFA enrolment so that they can enrol again.
+func (us *UserService) ResetMFA(ctx context.Context, actor User, userID UserID) error {
+	isAuthorized, err := us.authz.HasPermission(ctx, actor, PermissionManageUsers)
+	if err != nil {
+		return err
+	}
+
+	if !isAuthorized {
+		return fmt.Errorf("%w: not permitted to manage users", ErrUnauthorized)
+	}
+
+	if us.mfa == nil {
+		return ErrMFAUnsupported
+	}
+
+	if err := us.mfa.Reset(ctx, actor, userID); err != nil {
+		return err
+	}
+
+	us.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionUserResetMFA,
+		TargetKind: audit.TargetUser,
+		TargetID:   strconv.Itoa(int(userID)),
+	})
+	return nil
+}
+
+func (us *UserService) ListLoginLockouts(ctx context.Context, actor User) ([]LoginFailures, error) {
+	isAuthorized, err := us.authz.HasPermission(ctx, actor, PermissionManageUsers)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isAuthorized {
+		return nil, fmt.Errorf("%w: not permitted to manage users", ErrUnauthorized)
+	}
+
+	return us.throttle.ListLockouts(ctx)
+}
+
+// UnlockLogin lifts the lockout of an account, keyed by email, or an IP
+// address.
+func (us *UserService) UnlockLogin(ctx context.Context, actor User, kind LoginFailureKind, key string) error {
+	isAuthorized, err := us.authz.HasPermission(ctx, actor, PermissionManageUsers)
+	if err != nil {
+		return err
+	}
+
+	if !isAuthorized {
+		return fmt.Errorf("%w: not permitted to manage users", ErrUnauthorized)
+	}
+
+	if kind != LoginFailureAccount && kind != LoginFailureIP {
+		return fmt.Errorf("%w: %s", ErrUnknownLoginFailureKind, kind)
+	}
+
+	if err := us.throttle.Unlock(ctx, actor, kind, key); err != nil {
+		return err
+	}
+
+	us.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionLoginUnlock,
+		TargetKind: audit.TargetLogin,
+		TargetID:   fmt.Sprintf("%s:%s", kind, key),
+	})
+	return nil
+}
+
+// StartRedirectLogin begins logging in through the gateway's identity
+// provider, see RedirectAuthenticator.
+func (us *UserService) StartRedirectLogin() (RedirectLogin, error) {
+	redirector, ok := us.authn.(RedirectAuthenticator)
+	if !ok {
+		return RedirectLogin{}, ErrRedirectLoginUnsupported
+	}
+
+	return redirector.StartLogin()
+}
+
+func (us *UserService) FinishRedirectLogin(ctx context.Context, code, codeVerifier, nonce string) (User, error) {
+	redirector, ok := us.authn.(RedirectAuthenticator)
+	if !ok {
+		return User{}, ErrRedirectLoginUnsupported
+	}
+
+	user, err := redirector.FinishLogin(ctx, code, codeVerifier, nonce)
+	if err != nil {
+		return User{}, err
+	}
+
+	return checkEnabled(user)
+}
+
+// AuthenticateBearerToken authenticates a token which wasn't issued by CDB
+// if the gateway supports it.
+func (us *UserService) AuthenticateBearerToken(ctx context.Context, token string) (User, error) {
+	authenticator, ok := us.authn.(BearerTokenAuthenticator)
+	if !ok {
+		return User{}, ErrUnauthenticated
+	}
+
+	user, err := authenticator.AuthenticateBearerToken(ctx, token)
+	if err != nil {
+		return User{}, err
+	}
+
+	return checkEnabled(user)
+}
+
+// IssueTokens starts a new session for the user returning its tokens.
+func (us *UserService) IssueTokens(ctx context.Context, keys *KeySet, user User) (TokenSet, error) {
+	session, err := us.registry.StartSession(ctx, user)
+	if err != nil {
+		return TokenSet{}, err
+	}
+
+	return GenerateTokens(keys, user, session)
+}
+
+// RefreshTokens exchanges a refresh token for a new set of tokens for the same
+// session. Each refresh token can only be exchanged once, reusing one revokes
+// the session.
+func (us *UserService) RefreshTokens(ctx context.Context, keys *KeySet, refreshToken string) (TokenSet, error) {
+	claims, err := ValidateRefreshToken(keys, refreshToken)
+	if err != nil {
+		return TokenSet{}, err
+	}
+
+	revoked, err := us.registry.IsRevoked(ctx, refreshToken)
+	if err != nil {
+		return TokenSet{}, err
+	}
+
+	if revoked {
+		return TokenSet{}, ErrSessionRevoked
+	}
+
+	session, err := us.registry.RotateSession(ctx, claims)
+	if err != nil {
+		return TokenSet{}, err
+	}
+
+	// Looked up again so that deleted users can't refresh and changes to
+	// the user are picked up.
+	user, err := us.authn.GetUser(ctx, session.UserID)
+	if errors.Is(err, ErrUserNotFound) {
+		return TokenSet{}, ErrRefreshTokenInvalid
+	} else if err != nil {
+		return TokenSet{}, err
+	}
+
+	if user.Disabled {
+		return TokenSet{}, ErrUserDisabled
+	}
+
+	return GenerateTokens(keys, user, session)
+}
+
+// AuthenticateSession checks that the session an ID token was issued for
+// hasn't been revoked. ID tokens issued before sessions were tracked have no
+// session and are accepted until they expire.
+func (us *UserService) AuthenticateSession(ctx context.Context, claims IDClaims) error {
+	// TODO(2026-11-01): reject ID tokens without a session. They last a day
+	// so every one issued before sessions were tracked has expired by then.
+	if claims.SessionID == "" {
+		return nil
+	}
+
+	active, err := us.registry.IsSessionActive(ctx, claims.User, claims.SessionID)
+	if err != nil {
+		return err
+	}
+
+	if !active {
+		return ErrSessionRevoked
+	}
+
+	return nil
 }
 
-func (us *UserService) Logout(ctx context.Context, refreshToken string) error {
+// Logout revokes the refresh token and the session it was issued for so
+// neither it nor the session's ID tokens can be used again.
+func (us *UserService) Logout(ctx context.Context, keys *KeySet, refreshToken string) error {
+	// Refresh tokens issued before sessions were tracked can only be
+	// revoked themselves.
+	if claims, err := ValidateRefreshToken(keys, refreshToken); err == nil {
+		if err := us.registry.RevokeSession(ctx, claims); err != nil {
+			return err
+		}
+	}
+
 	return us.registry.Revoke(ctx, refreshToken)
 }
 
 func (us *UserService) Register(ctx context.Context, email, password string) (User, error) {
 	if us.publicRegisterAllowed {
+		if err := us.checkPassword(password); err != nil {
+			return User{}, err
+		}
+
 		user, err := us.authn.Register(ctx, email, password)
 		if err != nil {
 			return user, err
 		}
 
-		return user, us.authz.AssignRoleToUserNoAuth(ctx, user, us.defaultRegisterRole)
+		if err := us.authz.AssignRoleToUserNoAuth(ctx, user, us.defaultRegisterRole); err != nil {
+			return user, err
+		}
+
+		// Users register themselves so they're the actor.
+		us.audit.Record(ctx, user, audit.Event{
+			Action:     audit.ActionUserRegister,
+			TargetKind: audit.TargetUser,
+			TargetID:   strconv.Itoa(int(user.ID)),
+			After:      user,
+		})
+		return user, nil
 	}
 
 	return User{}, ErrPublicRegisterDisabled
@@ -70,7 +446,157 @@ func (us *UserService) CreateUser(ctx context.Context, actor User, newUser User)
 		return User{}, fmt.Errorf("%w: not permitted to manage users", ErrUnauthorized)
 	}
 
-	return us.authn.CreateUser(ctx, newUser)
+	if err := us.checkPassword(newUser.Password); err != nil {
+		return User{}, err
+	}
+
+	user, err := us.authn.CreateUser(ctx, newUser)
+	if err != nil {
+		return User{}, err
+	}
+
+	us.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionUserCreate,
+		TargetKind: audit.TargetUser,
+		TargetID:   strconv.Itoa(int(user.ID)),
+		After:      user,
+	})
+	return user, nil
+}
+
+// checkPassword checks the password against the policy when the gateway
+// stores passwords, other gateways ignore the passwords they're given.
+func (us *UserService) checkPassword(password string) error {
+	if _, ok := us.authn.(PasswordManager); !ok {
+		return nil
+	}
+
+	return us.policy.Check(password)
+}
+
+func (us *UserService) passwordManager() (PasswordManager, error) {
+	manager, ok := us.authn.(PasswordManager)
+	if !ok {
+		return nil, ErrPasswordChangeUnsupported
+	}
+
+	return manager, nil
+}
+
+// ChangePassword changes the actor's password after checking their current
+// one.
+func (us *UserService) ChangePassword(ctx context.Context, actor User, currentPassword, newPassword string) error {
+	// A leaked API token must not be enough to take over the account.
+	if actor.Scopes.Restricted() {
+		return fmt.Errorf("%w: scoped API tokens cannot change passwords", ErrUnauthorized)
+	}
+
+	manager, err := us.passwordManager()
+	if err != nil {
+		return err
+	}
+
+	// Throttled like logins since a stolen session could otherwise be used
+	// to guess the password.
+	if _, err := us.checkCredentials(ctx, actor.Email, currentPassword, ""); errors.Is(err, ErrUserNotFound) {
+		return ErrInvalidPassword
+	} else if err != nil {
+		return err
+	}
+
+	if err := us.policy.Check(newPassword); err != nil {
+		return err
+	}
+
+	if err := manager.SetPassword(ctx, actor.ID, newPassword); err != nil {
+		return err
+	}
+
+	us.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionUserChangePassword,
+		TargetKind: audit.TargetUser,
+		TargetID:   strconv.Itoa(int(actor.ID)),
+	})
+	return nil
+}
+
+// StartPasswordReset sends the user a one time token they can set a new
+// password with through the notifier.
+func (us *UserService) StartPasswordReset(ctx context.Context, actor User, userID UserID) error {
+	isAuthorized, err := us.authz.HasPermission(ctx, actor, PermissionManageUsers)
+	if err != nil {
+		return err
+	}
+
+	if !isAuthorized {
+		return fmt.Errorf("%w: not permitted to manage users", ErrUnauthorized)
+	}
+
+	if _, err := us.passwordManager(); err != nil {
+		return err
+	}
+
+	user, err := us.authn.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	expiresAt := time.Now().Add(PasswordResetTokenTTL)
+	token, err := us.registry.IssuePasswordResetToken(ctx, actor, user, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	if err := us.notifier.NotifyPasswordReset(ctx, user, token, expiresAt); err != nil {
+		return fmt.Errorf("unable to send password reset: %w", err)
+	}
+
+	us.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionUserStartPasswordReset,
+		TargetKind: audit.TargetUser,
+		TargetID:   strconv.Itoa(int(user.ID)),
+	})
+	return nil
+}
+
+// ResetPassword sets the password of the user the reset token was issued to
+// and logs them out everywhere.
+func (us *UserService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	manager, err := us.passwordManager()
+	if err != nil {
+		return err
+	}
+
+	// Checked first so that a rejected password doesn't use up the token.
+	if err := us.policy.Check(newPassword); err != nil {
+		return err
+	}
+
+	userID, err := us.registry.UsePasswordResetToken(ctx, token)
+	if err != nil {
+		return err
+	}
+
+	if err := manager.SetPassword(ctx, userID, newPassword); err != nil {
+		return err
+	}
+
+	if err := us.registry.RevokeUserSessions(ctx, userID); err != nil {
+		return err
+	}
+
+	// The token stands in for a session so its holder is the actor.
+	user, err := us.authn.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	us.audit.Record(ctx, user, audit.Event{
+		Action:     audit.ActionUserResetPassword,
+		TargetKind: audit.TargetUser,
+		TargetID:   strconv.Itoa(int(userID)),
+	})
+	return nil
 }
 
 func (us *UserService) GetUser(ctx context.Context, actor User, userID UserID) (User, error) {
@@ -96,7 +622,82 @@ func (us *UserService) DeleteUser(ctx context.Context, actor User, userID UserID
 		return fmt.Errorf("%w: not permitted to manage users", ErrUnauthorized)
 	}
 
-	return us.authn.DeleteUser(ctx, userID)
+	if actor.ID == userID {
+		return ErrDisableSelf
+	}
+
+	user, err := us.authn.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := us.authn.DeleteUser(ctx, userID); err != nil {
+		return err
+	}
+
+	if err := us.registry.RevokeUserCredentials(ctx, userID); err != nil {
+		return err
+	}
+
+	us.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionUserDelete,
+		TargetKind: audit.TargetUser,
+		TargetID:   strconv.Itoa(int(userID)),
+		Before:     user,
+	})
+	return nil
+}
+
+// DisableUser stops the user from logging in and revokes their sessions and
+// API tokens. Enabling them again doesn't restore what was revoked.
+func (us *UserService) DisableUser(ctx context.Context, actor User, userID UserID) (User, error) {
+	return us.setUserDisabled(ctx, actor, userID, true)
+}
+
+func (us *UserService) EnableUser(ctx context.Context, actor User, userID UserID) (User, error) {
+	return us.setUserDisabled(ctx, actor, userID, false)
+}
+
+func (us *UserService) setUserDisabled(ctx context.Context, actor User, userID UserID, disabled bool) (User, error) {
+	isAuthorized, err := us.authz.HasPermission(ctx, actor, PermissionManageUsers)
+	if err != nil {
+		return User{}, err
+	}
+
+	if !isAuthorized {
+		return User{}, fmt.Errorf("%w: not permitted to manage users", ErrUnauthorized)
+	}
+
+	if actor.ID == userID {
+		return User{}, ErrDisableSelf
+	}
+
+	before, err := us.authn.GetUser(ctx, userID)
+	if err != nil {
+		return User{}, err
+	}
+
+	user, err := us.authn.SetUserDisabled(ctx, userID, disabled)
+	if err != nil {
+		return User{}, err
+	}
+
+	action := audit.ActionUserEnable
+	if disabled {
+		action = audit.ActionUserDisable
+		if err := us.registry.RevokeUserCredentials(ctx, userID); err != nil {
+			return user, err
+		}
+	}
+
+	us.audit.Record(ctx, actor, audit.Event{
+		Action:     action,
+		TargetKind: audit.TargetUser,
+		TargetID:   strconv.Itoa(int(userID)),
+		Before:     before,
+		After:      user,
+	})
+	return user, nil
 }
 
 func (us *UserService) ListUsers(ctx context.Context, actor User) ([]User, error) {
@@ -112,10 +713,297 @@ func (us *UserService) ListUsers(ctx context.Context, actor User) ([]User, error
 	return us.authn.ListUsers(ctx)
 }
 
-func (us *UserService) IssueAPIToken(ctx context.Context, signingKey []byte, actor User) (APIToken, error) {
-	return us.registry.IssueAPIToken(ctx, signingKey, actor)
+// IssueAPIToken issues the actor a named API token, when expiresAt is nil the
+// token lasts until it's revoked. When scopes are given the token can only
+// use the actor's permissions which they allow.
+func (us *UserService) IssueAPIToken(
+	ctx context.Context,
+	keys *KeySet,
+	actor User,
+	name string,
+	expiresAt *time.Time,
+	scopes Scopes,
+) (APIToken, error) {
+	// Otherwise a scoped token could be used to mint one without its
+	// restrictions.
+	if actor.Scopes.Restricted() {
+		return APIToken{}, fmt.Errorf("%w: scoped API tokens cannot issue API tokens", ErrUnauthorized)
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return APIToken{}, ErrAPITokenExpiresAtInPast
+	}
+
+	if err := scopes.Valid(); err != nil {
+		return APIToken{}, err
+	}
+
+	holder := actor
+	holder.Scopes = scopes
+	token, err := us.registry.IssueAPIToken(ctx, keys, holder, name, expiresAt)
+	if err != nil {
+		return APIToken{}, err
+	}
+
+	// The token itself must never be written to the audit log.
+	audited := token
+	audited.Token = ""
+	us.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionAPITokenIssue,
+		TargetKind: audit.TargetAPIToken,
+		TargetID:   strconv.Itoa(token.ID),
+		After:      audited,
+	})
+	return token, nil
 }
 
 func (us *UserService) ListAPITokens(ctx context.Context, actor User) ([]APIToken, error) {
+	if actor.Scopes.Restricted() {
+		return nil, fmt.Errorf("%w: scoped API tokens cannot list API tokens", ErrUnauthorized)
+	}
+
 	return us.registry.ListAPITokens(ctx, actor)
 }
+
+// RevokeAPIToken revokes one of the actor's API tokens. Users who can manage
+// users can revoke anyone's tokens.
+func (us *UserService) RevokeAPIToken(ctx context.Context, actor User, tokenID int) error {
+	// Otherwise a scoped token could revoke the owner's other credentials.
+	if actor.Scopes.Restricted() {
+		return fmt.Errorf("%w: scoped API tokens cannot revoke API tokens", ErrUnauthorized)
+	}
+
+	token, err := us.registry.GetAPIToken(ctx, tokenID)
+	if err != nil {
+		return err
+	}
+
+	if token.UserID != strconv.Itoa(int(actor.ID)) {
+		isAuthorized, err := us.authz.HasPermission(ctx, actor, PermissionManageUsers)
+		if err != nil {
+			return err
+		}
+
+		// Don't reveal that the token exists.
+		if !isAuthorized {
+			return ErrAPITokenNotFound
+		}
+	}
+
+	if err := us.registry.RevokeAPIToken(ctx, actor, tokenID); err != nil {
+		return err
+	}
+
+	us.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionAPITokenRevoke,
+		TargetKind: audit.TargetAPIToken,
+		TargetID:   strconv.Itoa(tokenID),
+		Before:     token,
+	})
+	return nil
+}
+
+// AuthenticateAPIToken checks that an API token, whose signature has already
+// been validated, hasn't been revoked or expired and records its use.
+func (us *UserService) AuthenticateAPIToken(ctx context.Context, token string) error {
+	_, err := us.registry.UseAPIToken(ctx, token)
+	return err
+}
+
+func validatePermissions(permissions []Permission) error {
+	for _, permission := range permissions {
+		if err := permission.Valid(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ListRoles returns every global role and the permissions it grants.
+func (us *UserService) ListRoles(ctx context.Context, actor User) ([]Role, error) {
+	return us.authz.ListRoles(ctx, actor)
+}
+
+func (us *UserService) CreateRole(ctx context.Context, actor User, role Role) (Role, error) {
+	if strings.TrimSpace(role.Name) == "" {
+		return Role{}, ErrRoleNameEmpty
+	}
+
+	if err := validatePermissions(role.Permissions); err != nil {
+		return Role{}, err
+	}
+
+	if role.Permissions == nil {
+		role.Permissions = []Permission{}
+	}
+
+	if err := us.authz.CreateRole(ctx, actor, role.Name, role.Permissions); err != nil {
+		return Role{}, err
+	}
+
+	us.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionRoleCreate,
+		TargetKind: audit.TargetRole,
+		TargetID:   role.Name,
+		After:      role,
+	})
+	return role, nil
+}
+
+// DeleteRole deletes the role and unassigns it from every user. The role new
+// users are given when they register can't be deleted.
+func (us *UserService) DeleteRole(ctx context.Context, actor User, role string) error {
+	if role == us.defaultRegisterRole {
+		return ErrDefaultRole
+	}
+
+	permissions, err := us.authz.GetPermissionsForRole(ctx, actor, role)
+	if err != nil {
+		return err
+	}
+
+	if err := us.authz.DeleteRole(ctx, actor, role); err != nil {
+		return err
+	}
+
+	us.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionRoleDelete,
+		TargetKind: audit.TargetRole,
+		TargetID:   role,
+		Before:     Role{Name: role, Permissions: permissions},
+	})
+	return nil
+}
+
+func (us *UserService) GrantPermissions(ctx context.Context, actor User, role string, permissions []Permission) error {
+	if err := validatePermissions(permissions); err != nil {
+		return err
+	}
+
+	return us.changeRolePermissions(ctx, actor, role, audit.ActionRoleGrantPermissions, func() error {
+		return us.authz.AddPermissionsToRole(ctx, actor, role, permissions)
+	})
+}
+
+func (us *UserService) RevokePermissions(ctx context.Context, actor User, role string, permissions []Permission) error {
+	if err := validatePermissions(permissions); err != nil {
+		return err
+	}
+
+	return us.changeRolePermissions(ctx, actor, role, audit.ActionRoleRevokePermissions, func() error {
+		return us.authz.RemovePermissionsFromRole(ctx, actor, role, permissions)
+	})
+}
+
+// changeRolePermissions applies change to the role and audits the permissions
+// it grants before and after.
+func (us *UserService) changeRolePermissions(
+	ctx context.Context,
+	actor User,
+	role string,
+	action string,
+	change func() error,
+) error {
+	before, err := us.authz.GetPermissionsForRole(ctx, actor, role)
+	if err != nil {
+		return err
+	}
+
+	if err := change(); err != nil {
+		return err
+	}
+
+	after, err := us.authz.GetPermissionsForRole(ctx, actor, role)
+	if err != nil {
+		return err
+	}
+
+	us.audit.Record(ctx, actor, audit.Event{
+		Action:     action,
+		TargetKind: audit.TargetRole,
+		TargetID:   role,
+		Before:     Role{Name: role, Permissions: before},
+		After:      Role{Name: role, Permissions: after},
+	})
+	return nil
+}
+
+func (us *UserService) GetUserRoles(ctx context.Context, actor User, userID UserID) ([]string, error) {
+	user, err := us.GetUser(ctx, actor, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return us.authz.GetRolesForUser(ctx, actor, user)
+}
+
+func (us *UserService) AssignRole(ctx context.Context, actor User, userID UserID, role string) error {
+	user, err := us.GetUser(ctx, actor, userID)
+	if err != nil {
+		return err
+	}
+
+	return us.changeUserRoles(ctx, actor, user, audit.ActionUserAssignRole, func() error {
+		return us.authz.AssignRoleToUser(ctx, actor, user, role)
+	})
+}
+
+func (us *UserService) RemoveRole(ctx context.Context, actor User, userID UserID, role string) error {
+	user, err := us.GetUser(ctx, actor, userID)
+	if err != nil {
+		return err
+	}
+
+	return us.changeUserRoles(ctx, actor, user, audit.ActionUserRemoveRole, func() error {
+		return us.authz.RemoveRoleFromUser(ctx, actor, user, role)
+	})
+}
+
+// changeUserRoles applies change to the user's roles and audits the roles they
+// have before and after.
+func (us *UserService) changeUserRoles(
+	ctx context.Context,
+	actor User,
+	user User,
+	action string,
+	change func() error,
+) error {
+	before, err := us.authz.GetRolesForUser(ctx, actor, user)
+	if err != nil {
+		return err
+	}
+
+	if err := change(); err != nil {
+		return err
+	}
+
+	after, err := us.authz.GetRolesForUser(ctx, actor, user)
+	if err != nil {
+		return err
+	}
+
+	us.audit.Record(ctx, actor, audit.Event{
+		Action:     action,
+		TargetKind: audit.TargetUser,
+		TargetID:   strconv.Itoa(int(user.ID)),
+		Before:     before,
+		After:      after,
+	})
+	return nil
+}
+
+// ListAuditLog returns the audit log entries matching the filter, newest
+// first.
+func (us *UserService) ListAuditLog(ctx context.Context, actor User, filter audit.Filter) ([]audit.Entry, error) {
+	isAuthorized, err := us.authz.HasPermission(ctx, actor, PermissionViewAuditLog)
+	if err != nil {
+		return nil, err
+	}
+
+	if !isAuthorized {
+		return nil, fmt.Errorf("%w: not permitted to view the audit log", ErrUnauthorized)
+	}
+
+	return us.audit.List(ctx, filter)
+}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/config-source/cdb/pkg/audit"
+)
+
+func (ec *Client) ListAuditLog(ctx context.Context, filter audit.Filter) ([]audit.Entry, error) {
+	params := make(map[string]string)
+	if filter.ActorID != nil {
+		params["actorID"] = strconv.Itoa(*filter.ActorID)
+	}
+
+	if filter.ActorEmail != nil {
+		params["actorEmail"] = *filter.ActorEmail
+	}
+
+	if filter.Action != nil {
+		params["action"] = *filter.Action
+	}
+
+	if filter.TargetKind != nil {
+		params["targetKind"] = *filter.TargetKind
+	}
+
+	if filter.TargetID != nil {
+		params["targetID"] = *filter.TargetID
+	}
+
+	if filter.Since != nil {
+		params["since"] = filter.Since.Format(time.RFC3339)
+	}
+
+	if filter.Until != nil {
+		params["until"] = filter.Until.Format(time.RFC3339)
+	}
+
+	if filter.BeforeID != nil {
+		params["beforeID"] = strconv.FormatInt(*filter.BeforeID, 10)
+	}
+
+	if filter.Limit != 0 {
+		params["limit"] = strconv.Itoa(filter.Limit)
+	}
+
+	var data []audit.Entry
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "GET",
+		url:    "/api/v1/audit",
+		params: params,
+	}, &data)
+
+	return data, err
+}
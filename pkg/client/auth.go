@@ -2,34 +2,117 @@ package client
 
 import (
 	"context"
+	"fmt"
+	"strings"
 
 	"github.com/config-source/cdb/pkg/auth"
 )
 
-func (ec *Client) Login(ctx context.Context, email, password string) (auth.TokenSet, error) {
+type credentials struct {
+	Email    string
+	Password string
+	MFACode  string `json:",omitempty"`
+}
+
+// mfaError returns the MFA error the API responded with so that callers can
+// check for it with errors.Is.
+func mfaError(err error) error {
+	for _, known := range []error{auth.ErrMFARequired, auth.ErrMFAEnrolmentRequired, auth.ErrInvalidMFACode} {
+		if err != nil && strings.Contains(err.Error(), known.Error()) {
+			return known
+		}
+	}
+
+	return err
+}
+
+// Login logs in, mfaCode is only required when the user has enrolled in MFA
+// and ErrMFARequired is returned when it's missing.
+func (ec *Client) Login(ctx context.Context, email, password, mfaCode string) (auth.TokenSet, error) {
 	var data auth.TokenSet
 	_, err := ec.Do(ctx, requestSpec{
 		url:    "/api/v1/auth/login",
 		method: "POST",
-		body: struct {
-			Email    string
-			Password string
-		}{
+		body: credentials{
 			Email:    email,
 			Password: password,
+			MFACode:  mfaCode,
 		},
 	}, &data)
 	ec.token = data.IDToken
+	return data, mfaError(err)
+}
+
+func (ec *Client) EnrolMFA(ctx context.Context, email, password string) (auth.MFAEnrolment, error) {
+	var data auth.MFAEnrolment
+	_, err := ec.Do(ctx, requestSpec{
+		url:    "/api/v1/auth/mfa/enrol",
+		method: "POST",
+		body: credentials{
+			Email:    email,
+			Password: password,
+		},
+	}, &data)
 	return data, err
 }
 
-func (ec *Client) IssueAPIToken(ctx context.Context, email, password string) (auth.APIToken, error) {
+// ConfirmMFA finishes enrolling in MFA and returns the recovery codes.
+func (ec *Client) ConfirmMFA(ctx context.Context, email, password, code string) ([]string, error) {
+	var data struct {
+		RecoveryCodes []string
+	}
+
+	_, err := ec.Do(ctx, requestSpec{
+		url:    "/api/v1/auth/mfa/confirm",
+		method: "POST",
+		body: credentials{
+			Email:    email,
+			Password: password,
+			MFACode:  code,
+		},
+	}, &data)
+	return data.RecoveryCodes, mfaError(err)
+}
+
+func (ec *Client) ResetMFA(ctx context.Context, userID auth.UserID) error {
+	_, err := ec.Do(ctx, requestSpec{
+		method: "DELETE",
+		url:    fmt.Sprintf("/api/v1/users/%d/mfa", userID),
+	}, nil)
+
+	return err
+}
+
+var baseAPITokenURL = "/api/v1/auth/api-tokens"
+
+func (ec *Client) IssueAPIToken(ctx context.Context, req auth.APITokenRequest) (auth.APIToken, error) {
 	var data auth.APIToken
 
 	_, err := ec.Do(ctx, requestSpec{
 		method: "POST",
-		url:    "/api/v1/auth/api-tokens",
+		url:    baseAPITokenURL,
+		body:   req,
 	}, &data)
 
 	return data, err
 }
+
+func (ec *Client) ListAPITokens(ctx context.Context) ([]auth.APIToken, error) {
+	var data []auth.APIToken
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "GET",
+		url:    baseAPITokenURL,
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) RevokeAPIToken(ctx context.Context, id int) error {
+	_, err := ec.Do(ctx, requestSpec{
+		method: "DELETE",
+		url:    fmt.Sprintf("%s/%d", baseAPITokenURL, id),
+	}, nil)
+
+	return err
+}
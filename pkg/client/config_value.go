@@ -3,28 +3,66 @@ package client
 import (
 	"context"
 	"fmt"
+	"strconv"
 
 	"github.com/config-source/cdb/pkg/configvalues"
 )
 
-func (ec *Client) GetConfigurationValue(ctx context.Context, environmentName, key string) (*configvalues.ConfigValue, error) {
+func readParams(reveal bool, overlays []string) map[string][]string {
+	params := map[string][]string{"overlay": overlays}
+	if reveal {
+		params["reveal"] = []string{"true"}
+	}
+
+	return params
+}
+
+func (ec *Client) getConfigurationValue(ctx context.Context, environmentName, key string, reveal bool, overlays []string) (*configvalues.ConfigValue, error) {
 	var cv *configvalues.ConfigValue
 	_, err := ec.Do(ctx, requestSpec{
-		method: "GET",
-		url:    fmt.Sprintf("/api/v1/config-values/%s/%s", environmentName, key),
+		method:      "GET",
+		url:         fmt.Sprintf("/api/v1/config-values/%s/%s", environmentName, key),
+		multiParams: readParams(reveal, overlays),
 	}, &cv)
 	return cv, err
 }
 
-func (ec *Client) GetConfiguration(ctx context.Context, environmentName string) ([]configvalues.ConfigValue, error) {
+func (ec *Client) getConfiguration(ctx context.Context, environmentName string, reveal bool, overlays []string) ([]configvalues.ConfigValue, error) {
 	var values []configvalues.ConfigValue
 	_, err := ec.Do(ctx, requestSpec{
-		method: "GET",
-		url:    fmt.Sprintf("/api/v1/config-values/%s", environmentName),
+		method:      "GET",
+		url:         fmt.Sprintf("/api/v1/config-values/%s", environmentName),
+		multiParams: readParams(reveal, overlays),
 	}, &values)
 	return values, err
 }
 
+// GetConfigurationValue returns the value of key, values of sensitive keys are
+// redacted.
+func (ec *Client) GetConfigurationValue(ctx context.Context, environmentName, key string, overlays ...string) (*configvalues.ConfigValue, error) {
+	return ec.getConfigurationValue(ctx, environmentName, key, false, overlays)
+}
+
+// RevealConfigurationValue is like GetConfigurationValue but reveals the value
+// of a sensitive key. This requires auth.PermissionRevealSensitiveValues and
+// is audited.
+func (ec *Client) RevealConfigurationValue(ctx context.Context, environmentName, key string, overlays ...string) (*configvalues.ConfigValue, error) {
+	return ec.getConfigurationValue(ctx, environmentName, key, true, overlays)
+}
+
+// GetConfiguration returns the configuration of the environment, values of
+// sensitive keys are redacted.
+func (ec *Client) GetConfiguration(ctx context.Context, environmentName string, overlays ...string) ([]configvalues.ConfigValue, error) {
+	return ec.getConfiguration(ctx, environmentName, false, overlays)
+}
+
+// RevealConfiguration is like GetConfiguration but reveals the values of
+// sensitive keys. This requires auth.PermissionRevealSensitiveValues and every
+// revealed value is audited.
+func (ec *Client) RevealConfiguration(ctx context.Context, environmentName string, overlays ...string) ([]configvalues.ConfigValue, error) {
+	return ec.getConfiguration(ctx, environmentName, true, overlays)
+}
+
 func (ec *Client) SetConfiguration(ctx context.Context, value *configvalues.ConfigValue) (*configvalues.ConfigValue, error) {
 	var setValue *configvalues.ConfigValue
 	_, err := ec.Do(ctx, requestSpec{
@@ -35,12 +73,39 @@ func (ec *Client) SetConfiguration(ctx context.Context, value *configvalues.Conf
 	return setValue, err
 }
 
-func (ec *Client) SetConfigurationValue(ctx context.Context, env string, key string, value *configvalues.ConfigValue) (*configvalues.ConfigValue, error) {
+// SetConfigurationValue sets the value of key for env, or for the env and
+// overlay if an overlay selector is given.
+func (ec *Client) SetConfigurationValue(ctx context.Context, env string, key string, value *configvalues.ConfigValue, overlay ...string) (*configvalues.ConfigValue, error) {
 	var setValue *configvalues.ConfigValue
 	_, err := ec.Do(ctx, requestSpec{
-		method: "POST",
-		url:    fmt.Sprintf("/api/v1/config-values/%s/%s", env, key),
-		body:   value,
+		method:      "POST",
+		url:         fmt.Sprintf("/api/v1/config-values/%s/%s", env, key),
+		body:        value,
+		multiParams: map[string][]string{"overlay": overlay},
 	}, &setValue)
 	return setValue, err
 }
+
+func (ec *Client) EvaluateConfiguration(ctx context.Context, environment string, evalCtx configvalues.EvaluationContext, overlays ...string) ([]configvalues.ConfigValue, error) {
+	var values []configvalues.ConfigValue
+	_, err := ec.Do(ctx, requestSpec{
+		method:      "POST",
+		url:         fmt.Sprintf("/api/v1/evaluate/%s", environment),
+		body:        evalCtx,
+		multiParams: map[string][]string{"overlay": overlays},
+	}, &values)
+	return values, err
+}
+
+// ExportConfiguration returns the resolved configuration of every environment
+// matching the label selector. Values of sensitive keys are redacted unless
+// reveal is set.
+func (ec *Client) ExportConfiguration(ctx context.Context, selector string, reveal bool) ([]configvalues.EnvironmentConfiguration, error) {
+	var exported []configvalues.EnvironmentConfiguration
+	_, err := ec.Do(ctx, requestSpec{
+		method: "GET",
+		url:    "/api/v1/export",
+		params: map[string]string{"selector": selector, "reveal": strconv.FormatBool(reveal)},
+	}, &exported)
+	return exported, err
+}
@@ -3,8 +3,12 @@ package client
 import (
 	"context"
 	"fmt"
+	"time"
 
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/configvalues"
 	"github.com/config-source/cdb/pkg/environments"
+	"github.com/config-source/cdb/pkg/ephemeral"
 )
 
 var baseEnvURL = "/api/v1/environments"
@@ -43,12 +47,20 @@ func (ec *Client) CreateEnvironment(ctx context.Context, env environments.Enviro
 	return data, err
 }
 
-func (ec *Client) ListEnvironments(ctx context.Context) ([]environments.Environment, error) {
+// ListEnvironments returns the environments matching the label selector, an
+// empty selector returns every environment.
+func (ec *Client) ListEnvironments(ctx context.Context, selector string) ([]environments.Environment, error) {
 	var data []environments.Environment
 
+	params := make(map[string]string)
+	if selector != "" {
+		params["selector"] = selector
+	}
+
 	_, err := ec.Do(ctx, requestSpec{
 		method: "GET",
 		url:    baseEnvURL,
+		params: params,
 	}, &data)
 
 	return data, err
@@ -64,3 +76,130 @@ func (ec *Client) GetEnvironmentTree(ctx context.Context) ([]environments.Tree,
 
 	return data, err
 }
+
+func (ec *Client) CreateEphemeralEnvironment(ctx context.Context, req ephemeral.Request) (environments.Environment, error) {
+	var data environments.Environment
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "POST",
+		url:    baseEnvURL + "/ephemeral",
+		body:   req,
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) ExtendEphemeralEnvironment(ctx context.Context, id int, expiresAt time.Time) (environments.Environment, error) {
+	var data environments.Environment
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "POST",
+		url:    fmt.Sprintf("%s/ephemeral/%d/extend", baseEnvURL, id),
+		body:   ephemeral.ExtendRequest{ExpiresAt: expiresAt},
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) DeleteEphemeralEnvironment(ctx context.Context, id int) error {
+	_, err := ec.Do(ctx, requestSpec{
+		method: "DELETE",
+		url:    fmt.Sprintf("%s/ephemeral/%d", baseEnvURL, id),
+	}, nil)
+
+	return err
+}
+
+func (ec *Client) CloneEnvironment(ctx context.Context, sourceID int, req configvalues.CloneRequest) (environments.Environment, error) {
+	var data environments.Environment
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "POST",
+		url:    fmt.Sprintf("%s/%d/clone", baseEnvURL, sourceID),
+		body:   req,
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) UpdateEnvironment(ctx context.Context, env environments.Environment) (environments.Environment, error) {
+	var data environments.Environment
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "PUT",
+		url:    fmt.Sprintf("%s/%d", baseEnvURL, env.ID),
+		body:   env,
+	}, &data)
+
+	return data, err
+}
+
+// PreviewUpdateEnvironment returns the changes to resolved configuration that
+// updating env would cause without updating it.
+func (ec *Client) PreviewUpdateEnvironment(ctx context.Context, env environments.Environment) (configvalues.UpdatePreview, error) {
+	var data configvalues.UpdatePreview
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "PUT",
+		url:    fmt.Sprintf("%s/%d", baseEnvURL, env.ID),
+		body:   env,
+		params: map[string]string{"preview": "true"},
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) DeleteEnvironment(ctx context.Context, id int) error {
+	_, err := ec.Do(ctx, requestSpec{
+		method: "DELETE",
+		url:    fmt.Sprintf("%s/%d", baseEnvURL, id),
+	}, nil)
+
+	return err
+}
+
+// PreviewDeleteEnvironment returns what deleting the environment would destroy
+// without deleting it.
+func (ec *Client) PreviewDeleteEnvironment(ctx context.Context, id int) (configvalues.DeletePreview, error) {
+	var data configvalues.DeletePreview
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "DELETE",
+		url:    fmt.Sprintf("%s/%d", baseEnvURL, id),
+		params: map[string]string{"preview": "true"},
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) ListEnvironmentACL(ctx context.Context, id int) ([]auth.ACLEntry, error) {
+	var data []auth.ACLEntry
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "GET",
+		url:    fmt.Sprintf("%s/%d/acl", baseEnvURL, id),
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) CreateEnvironmentACLEntry(ctx context.Context, entry auth.ACLEntry) (auth.ACLEntry, error) {
+	var data auth.ACLEntry
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "POST",
+		url:    fmt.Sprintf("%s/%d/acl", baseEnvURL, entry.EnvironmentID),
+		body:   entry,
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) DeleteEnvironmentACLEntry(ctx context.Context, id, entryID int) error {
+	_, err := ec.Do(ctx, requestSpec{
+		method: "DELETE",
+		url:    fmt.Sprintf("%s/%d/acl/%d", baseEnvURL, id, entryID),
+	}, nil)
+
+	return err
+}
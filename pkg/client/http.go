@@ -22,6 +22,8 @@ type requestSpec struct {
 	url    string
 	body   interface{}
 	params map[string]string
+	// multiParams are query parameters which may be repeated.
+	multiParams map[string][]string
 }
 
 func New(token, baseURL string) *Client {
@@ -29,7 +31,7 @@ func New(token, baseURL string) *Client {
 }
 
 func (c *Client) Do(ctx context.Context, spec requestSpec, output interface{}) (*http.Response, error) {
-	if c.token == "" && !strings.HasPrefix(spec.url, "/auth") {
+	if c.token == "" && !strings.HasPrefix(spec.url, "/api/v1/auth/") {
 		return nil, errors.New(
 			"Unable to determine auth token for CDB instance." +
 				"Try setting $CDB_TOKEN or setting up a config file.",
@@ -61,6 +63,12 @@ func (c *Client) Do(ctx context.Context, spec requestSpec, output interface{}) (
 	for key, value := range spec.params {
 		query.Add(key, value)
 	}
+
+	for key, values := range spec.multiParams {
+		for _, value := range values {
+			query.Add(key, value)
+		}
+	}
 	req.URL.RawQuery = query.Encode()
 
 	httpResp, err := c.client.Do(req)
@@ -83,7 +91,7 @@ func (c *Client) Do(ctx context.Context, spec requestSpec, output interface{}) (
 		err = fmt.Errorf("failure response from API: %s", errResponse.Message)
 	}
 
-	if output != nil {
+	if output != nil && err == nil {
 		err = decoder.Decode(&output)
 		if err != nil {
 			err = fmt.Errorf("error decoding response to output: %w", err)
@@ -0,0 +1,94 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/config-source/cdb/pkg/auth"
+)
+
+var baseRolesURL = "/api/v1/roles"
+
+func roleURL(role string) string {
+	return fmt.Sprintf("%s/%s", baseRolesURL, url.PathEscape(role))
+}
+
+func (ec *Client) ListRoles(ctx context.Context) ([]auth.Role, error) {
+	var data []auth.Role
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "GET",
+		url:    baseRolesURL,
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) CreateRole(ctx context.Context, role auth.Role) (auth.Role, error) {
+	var data auth.Role
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "POST",
+		url:    baseRolesURL,
+		body:   role,
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) DeleteRole(ctx context.Context, role string) error {
+	_, err := ec.Do(ctx, requestSpec{
+		method: "DELETE",
+		url:    roleURL(role),
+	}, nil)
+
+	return err
+}
+
+func (ec *Client) GrantPermission(ctx context.Context, role string, permission auth.Permission) error {
+	_, err := ec.Do(ctx, requestSpec{
+		method: "PUT",
+		url:    fmt.Sprintf("%s/permissions/%s", roleURL(role), url.PathEscape(string(permission))),
+	}, nil)
+
+	return err
+}
+
+func (ec *Client) RevokePermission(ctx context.Context, role string, permission auth.Permission) error {
+	_, err := ec.Do(ctx, requestSpec{
+		method: "DELETE",
+		url:    fmt.Sprintf("%s/permissions/%s", roleURL(role), url.PathEscape(string(permission))),
+	}, nil)
+
+	return err
+}
+
+func (ec *Client) GetUserRoles(ctx context.Context, id auth.UserID) ([]string, error) {
+	var data []string
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "GET",
+		url:    fmt.Sprintf("%s/%d/roles", baseUsersURL, id),
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) AssignRole(ctx context.Context, id auth.UserID, role string) error {
+	_, err := ec.Do(ctx, requestSpec{
+		method: "PUT",
+		url:    fmt.Sprintf("%s/%d/roles/%s", baseUsersURL, id, url.PathEscape(role)),
+	}, nil)
+
+	return err
+}
+
+func (ec *Client) RemoveRole(ctx context.Context, id auth.UserID, role string) error {
+	_, err := ec.Do(ctx, requestSpec{
+		method: "DELETE",
+		url:    fmt.Sprintf("%s/%d/roles/%s", baseUsersURL, id, url.PathEscape(role)),
+	}, nil)
+
+	return err
+}
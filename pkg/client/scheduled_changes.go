@@ -0,0 +1,70 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/config-source/cdb/pkg/scheduledchanges"
+)
+
+var baseScheduledChangesURL = "/api/v1/scheduled-changes"
+
+func (ec *Client) ScheduleChange(ctx context.Context, change scheduledchanges.ScheduledChange) (scheduledchanges.ScheduledChange, error) {
+	var data scheduledchanges.ScheduledChange
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "POST",
+		url:    baseScheduledChangesURL,
+		body:   change,
+	}, &data)
+
+	return data, err
+}
+
+// ListScheduledChanges lists scheduled changes, environmentID and status are
+// optional filters and ignored when zero valued.
+func (ec *Client) ListScheduledChanges(ctx context.Context, environmentID int, status scheduledchanges.Status) ([]scheduledchanges.ScheduledChange, error) {
+	var data []scheduledchanges.ScheduledChange
+
+	params := make(map[string]string)
+	if environmentID != 0 {
+		params["environment"] = strconv.Itoa(environmentID)
+	}
+
+	if status != "" {
+		params["status"] = string(status)
+	}
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "GET",
+		url:    baseScheduledChangesURL,
+		params: params,
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) CancelScheduledChange(ctx context.Context, id int) (scheduledchanges.ScheduledChange, error) {
+	var data scheduledchanges.ScheduledChange
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "DELETE",
+		url:    fmt.Sprintf("%s/%d", baseScheduledChangesURL, id),
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) RescheduleChange(ctx context.Context, id int, applyAt time.Time) (scheduledchanges.ScheduledChange, error) {
+	var data scheduledchanges.ScheduledChange
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "POST",
+		url:    fmt.Sprintf("%s/%d/reschedule", baseScheduledChangesURL, id),
+		body:   map[string]time.Time{"ApplyAt": applyAt},
+	}, &data)
+
+	return data, err
+}
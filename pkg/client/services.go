@@ -0,0 +1,52 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/services"
+)
+
+var baseServicesURL = "/api/v1/services"
+
+func (ec *Client) GetServiceByName(ctx context.Context, name string) (services.Service, error) {
+	var data services.Service
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "GET",
+		url:    fmt.Sprintf("%s/by-name/%s", baseServicesURL, name),
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) ListServiceRoles(ctx context.Context, serviceID int) ([]auth.ServiceRole, error) {
+	var data []auth.ServiceRole
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "GET",
+		url:    fmt.Sprintf("%s/%d/roles", baseServicesURL, serviceID),
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) AssignServiceRole(ctx context.Context, serviceID int, userID auth.UserID, role services.RoleName) error {
+	_, err := ec.Do(ctx, requestSpec{
+		method: "PUT",
+		url:    fmt.Sprintf("%s/%d/roles/%d", baseServicesURL, serviceID, userID),
+		body:   map[string]services.RoleName{"Role": role},
+	}, nil)
+
+	return err
+}
+
+func (ec *Client) RemoveServiceRole(ctx context.Context, serviceID int, userID auth.UserID) error {
+	_, err := ec.Do(ctx, requestSpec{
+		method: "DELETE",
+		url:    fmt.Sprintf("%s/%d/roles/%d", baseServicesURL, serviceID, userID),
+	}, nil)
+
+	return err
+}
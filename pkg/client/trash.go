@@ -0,0 +1,30 @@
+package client
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/config-source/cdb/pkg/trash"
+)
+
+var baseTrashURL = "/api/v1/trash"
+
+func (ec *Client) ListTrash(ctx context.Context) (trash.Trash, error) {
+	var data trash.Trash
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "GET",
+		url:    baseTrashURL,
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) RestoreFromTrash(ctx context.Context, kind trash.Kind, id int) error {
+	_, err := ec.Do(ctx, requestSpec{
+		method: "POST",
+		url:    fmt.Sprintf("%s/%s/%d/restore", baseTrashURL, kind, id),
+	}, nil)
+
+	return err
+}
@@ -0,0 +1,110 @@
+package client
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+
+	"github.com/config-source/cdb/pkg/auth"
+)
+
+var baseUsersURL = "/api/v1/users"
+
+func (ec *Client) ListUsers(ctx context.Context) ([]auth.User, error) {
+	var data []auth.User
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "GET",
+		url:    baseUsersURL,
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) GetUser(ctx context.Context, id auth.UserID) (auth.User, error) {
+	var data auth.User
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "GET",
+		url:    fmt.Sprintf("%s/%d", baseUsersURL, id),
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) CreateUser(ctx context.Context, email, password string) (auth.User, error) {
+	var data auth.User
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "POST",
+		url:    baseUsersURL,
+		body: credentials{
+			Email:    email,
+			Password: password,
+		},
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) DeleteUser(ctx context.Context, id auth.UserID) error {
+	_, err := ec.Do(ctx, requestSpec{
+		method: "DELETE",
+		url:    fmt.Sprintf("%s/%d", baseUsersURL, id),
+	}, nil)
+
+	return err
+}
+
+func (ec *Client) DisableUser(ctx context.Context, id auth.UserID) (auth.User, error) {
+	var data auth.User
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "POST",
+		url:    fmt.Sprintf("%s/%d/disable", baseUsersURL, id),
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) EnableUser(ctx context.Context, id auth.UserID) (auth.User, error) {
+	var data auth.User
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "POST",
+		url:    fmt.Sprintf("%s/%d/enable", baseUsersURL, id),
+	}, &data)
+
+	return data, err
+}
+
+// StartPasswordReset sends the user a password reset token through the
+// notifier cdbd is configured with.
+func (ec *Client) StartPasswordReset(ctx context.Context, id auth.UserID) error {
+	_, err := ec.Do(ctx, requestSpec{
+		method: "POST",
+		url:    fmt.Sprintf("%s/%d/password-reset", baseUsersURL, id),
+	}, nil)
+
+	return err
+}
+
+func (ec *Client) ListLoginLockouts(ctx context.Context) ([]auth.LoginFailures, error) {
+	var data []auth.LoginFailures
+
+	_, err := ec.Do(ctx, requestSpec{
+		method: "GET",
+		url:    "/api/v1/auth/lockouts",
+	}, &data)
+
+	return data, err
+}
+
+func (ec *Client) UnlockLogin(ctx context.Context, kind auth.LoginFailureKind, key string) error {
+	_, err := ec.Do(ctx, requestSpec{
+		method: "DELETE",
+		url:    fmt.Sprintf("/api/v1/auth/lockouts/%s/%s", kind, url.PathEscape(key)),
+	}, nil)
+
+	return err
+}
@@ -40,11 +40,16 @@ type ConfigKey struct {
 	Name         string    `db:"name"`
 	ValueType    ValueType `db:"value_type"`
 	CanPropagate *bool     `db:"can_propagate"`
+	// Sensitive keys have their values redacted unless they are explicitly
+	// revealed by someone with auth.PermissionRevealSensitiveValues.
+	Sensitive bool `db:"sensitive"`
 
 	ServiceID int    `db:"service_id"`
 	Service   string `db:"service_name"`
 
 	CreatedAt time.Time `db:"created_at"`
+	// DeletedAt is set when the config key is in the trash.
+	DeletedAt *time.Time `db:"deleted_at"`
 }
 
 func New(serviceID int, name string, valueType ValueType) ConfigKey {
@@ -74,10 +79,11 @@ func (ck ConfigKey) Propagates() bool {
 func (ck ConfigKey) String() string {
 
 	return fmt.Sprintf(
-		"ConfigKey(id=%d, name=%s, serviceID=%d, canPropagate=%t)",
+		"ConfigKey(id=%d, name=%s, serviceID=%d, canPropagate=%t, sensitive=%t)",
 		ck.ID,
 		ck.Name,
 		ck.ServiceID,
 		ck.Propagates(),
+		ck.Sensitive,
 	)
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	_ "embed"
 	"errors"
+	"time"
 
 	"github.com/config-source/cdb/pkg/postgresutils"
 	"github.com/jackc/pgx/v5"
@@ -38,7 +39,32 @@ var getAllConfigKeys string
 //go:embed queries/get_all_config_keys_by_service.sql
 var getAllConfigKeysByService string
 
+//go:embed queries/soft_delete_config_key.sql
+var softDeleteConfigKeySql string
+
+//go:embed queries/restore_config_key.sql
+var restoreConfigKeySql string
+
+//go:embed queries/get_deleted_config_key_by_id.sql
+var getDeletedConfigKeyByIDSql string
+
+//go:embed queries/list_deleted_config_keys.sql
+var listDeletedConfigKeysSql string
+
+//go:embed queries/purge_deleted_config_keys.sql
+var purgeDeletedConfigKeysSql string
+
 func (r *Repository) CreateConfigKey(ctx context.Context, ck ConfigKey) (ConfigKey, error) {
+	return createConfigKey(ctx, r.pool, ck)
+}
+
+// CreateConfigKeyInTx creates the config key as part of a larger transaction,
+// the caller is responsible for committing it.
+func (r *Repository) CreateConfigKeyInTx(ctx context.Context, txn pgx.Tx, ck ConfigKey) (ConfigKey, error) {
+	return createConfigKey(ctx, txn, ck)
+}
+
+func createConfigKey(ctx context.Context, q postgresutils.Querier, ck ConfigKey) (ConfigKey, error) {
 	var canPropagate bool
 	if ck.CanPropagate == nil {
 		canPropagate = true
@@ -47,13 +73,14 @@ func (r *Repository) CreateConfigKey(ctx context.Context, ck ConfigKey) (ConfigK
 	}
 
 	return postgresutils.GetOneLax[ConfigKey](
-		r.pool,
+		q,
 		ctx,
 		createConfigKeySql,
 		ck.Name,
 		ck.ValueType,
 		canPropagate,
 		ck.ServiceID,
+		ck.Sensitive,
 	)
 }
 
@@ -83,3 +110,54 @@ func (r *Repository) ListConfigKeys(ctx context.Context, serviceIDs ...int) ([]C
 		return postgresutils.GetAll[ConfigKey](r.pool, ctx, getAllConfigKeys)
 	}
 }
+
+// SoftDeleteConfigKey moves the config key to the trash, hiding it from every
+// other read until it is restored or purged.
+func (r *Repository) SoftDeleteConfigKey(ctx context.Context, id int) error {
+	tag, err := r.pool.Exec(ctx, softDeleteConfigKeySql, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RestoreConfigKey takes the config key back out of the trash.
+func (r *Repository) RestoreConfigKey(ctx context.Context, id int) error {
+	tag, err := r.pool.Exec(ctx, restoreConfigKeySql, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) GetDeletedConfigKey(ctx context.Context, id int) (ConfigKey, error) {
+	configKey, err := postgresutils.GetOne[ConfigKey](r.pool, ctx, getDeletedConfigKeyByIDSql, id)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return configKey, ErrNotFound
+	}
+
+	return configKey, err
+}
+
+// ListDeletedConfigKeys returns the config keys in the trash, most recently
+// deleted first.
+func (r *Repository) ListDeletedConfigKeys(ctx context.Context) ([]ConfigKey, error) {
+	return postgresutils.GetAll[ConfigKey](r.pool, ctx, listDeletedConfigKeysSql)
+}
+
+// PurgeDeletedConfigKeys permanently deletes config keys which were moved to the
+// trash before the given time and returns how many were removed.
+func (r *Repository) PurgeDeletedConfigKeys(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	tag, err := r.pool.Exec(ctx, purgeDeletedConfigKeysSql, deletedBefore)
+	return tag.RowsAffected(), err
+}
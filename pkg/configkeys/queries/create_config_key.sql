@@ -2,12 +2,14 @@ INSERT INTO config_keys (
     name,
     value_type,
     can_propagate,
-    service_id
+    service_id,
+    sensitive
 ) 
 VALUES (
     $1, 
     $2,
     $3,
-    $4
+    $4,
+    $5
 )
 RETURNING *;
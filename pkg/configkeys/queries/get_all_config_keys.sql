@@ -2,4 +2,5 @@ SELECT
     config_keys.*,
     services.name as service_name 
 FROM config_keys
-INNER JOIN services ON services.id = config_keys.service_id;
+INNER JOIN services ON services.id = config_keys.service_id
+WHERE config_keys.deleted_at IS NULL AND services.deleted_at IS NULL;
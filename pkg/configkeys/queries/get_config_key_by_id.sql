@@ -3,4 +3,6 @@ SELECT
     services.name as service_name 
 FROM config_keys
 INNER JOIN services ON services.id = config_keys.service_id
-WHERE config_keys.id = $1;
+WHERE config_keys.id = $1
+    AND config_keys.deleted_at IS NULL
+    AND services.deleted_at IS NULL;
@@ -3,4 +3,6 @@ SELECT
     services.name as service_name 
 FROM config_keys
 INNER JOIN services ON services.id = config_keys.service_id
-WHERE services.name = $1 AND config_keys.name = $2;
+WHERE services.name = $1 AND config_keys.name = $2
+    AND config_keys.deleted_at IS NULL
+    AND services.deleted_at IS NULL;
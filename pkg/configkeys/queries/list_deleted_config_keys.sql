@@ -0,0 +1,7 @@
+SELECT 
+    config_keys.*,
+    services.name as service_name 
+FROM config_keys
+INNER JOIN services ON services.id = config_keys.service_id
+WHERE config_keys.deleted_at IS NOT NULL
+ORDER BY config_keys.deleted_at DESC;
@@ -0,0 +1,2 @@
+DELETE FROM config_keys
+WHERE deleted_at <= $1;
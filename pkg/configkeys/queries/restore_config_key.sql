@@ -0,0 +1,3 @@
+UPDATE config_keys
+SET deleted_at = NULL
+WHERE id = $1 AND deleted_at IS NOT NULL;
@@ -2,89 +2,171 @@ package configkeys
 
 import (
 	"context"
+	"errors"
+	"strconv"
 
+	"github.com/config-source/cdb/pkg/audit"
 	"github.com/config-source/cdb/pkg/auth"
 )
 
 type Service struct {
-	auth auth.AuthorizationGateway
-	repo *Repository
+	auth  auth.AuthorizationGateway
+	repo  *Repository
+	audit *audit.Log
 }
 
-func NewService(repo *Repository, auth auth.AuthorizationGateway) *Service {
+func NewService(repo *Repository, auth auth.AuthorizationGateway, auditLog *audit.Log) *Service {
 	return &Service{
-		auth: auth,
-		repo: repo,
+		auth:  auth,
+		repo:  repo,
+		audit: auditLog,
 	}
 }
 
-func (svc *Service) CreateConfigKey(ctx context.Context, actor auth.User, configKey ConfigKey) (ConfigKey, error) {
-	canManageConfigKeys, err := svc.auth.HasPermission(ctx, actor, auth.PermissionManageConfigKeys)
+func (svc *Service) canManageConfigKeys(ctx context.Context, actor auth.User, serviceID int) error {
+	canManageConfigKeys, err := svc.auth.HasServicePermission(ctx, actor, serviceID, auth.PermissionManageConfigKeys)
 	if err != nil {
-		return ConfigKey{}, err
+		return err
 	}
 
 	if !canManageConfigKeys {
-		return ConfigKey{}, auth.ErrUnauthorized
+		return auth.ErrUnauthorized
 	}
 
-	return svc.repo.CreateConfigKey(ctx, configKey)
+	return nil
 }
 
-func (svc *Service) hasReadPermissions(ctx context.Context, actor auth.User) error {
-	canManageConfigKeys, err := svc.auth.HasPermission(ctx, actor, auth.PermissionManageConfigKeys)
+func (svc *Service) CreateConfigKey(ctx context.Context, actor auth.User, configKey ConfigKey) (ConfigKey, error) {
+	if err := svc.canManageConfigKeys(ctx, actor, configKey.ServiceID); err != nil {
+		return ConfigKey{}, err
+	}
+
+	created, err := svc.repo.CreateConfigKey(ctx, configKey)
 	if err != nil {
-		return err
+		return ConfigKey{}, err
 	}
 
-	canConfigureSensitiveconfigKeyiroments, err := svc.auth.HasPermission(
-		ctx,
-		actor,
-		auth.PermissionConfigureSensitiveEnvironments,
-	)
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionConfigKeyCreate,
+		TargetKind: audit.TargetConfigKey,
+		TargetID:   strconv.Itoa(created.ID),
+		After:      created,
+	})
+	return created, nil
+}
+
+// DeleteConfigKey moves the config key to the trash, its values are hidden
+// until it is restored.
+func (svc *Service) DeleteConfigKey(ctx context.Context, actor auth.User, id int) error {
+	configKey, err := svc.repo.GetConfigKey(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	canConfigureEnvironments, err := svc.auth.HasPermission(
+	if err := svc.canManageConfigKeys(ctx, actor, configKey.ServiceID); err != nil {
+		return err
+	}
+
+	if err := svc.repo.SoftDeleteConfigKey(ctx, id); err != nil {
+		return err
+	}
+
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionConfigKeyDelete,
+		TargetKind: audit.TargetConfigKey,
+		TargetID:   strconv.Itoa(id),
+		Before:     configKey,
+	})
+	return nil
+}
+
+func (svc *Service) hasReadPermissions(ctx context.Context, actor auth.User, serviceID int) error {
+	canRead, err := svc.auth.HasServicePermission(
 		ctx,
 		actor,
+		serviceID,
+		auth.PermissionManageConfigKeys,
+		auth.PermissionConfigureSensitiveEnvironments,
 		auth.PermissionConfigureEnvironments,
+		auth.PermissionReadConfiguration,
+		auth.PermissionReadSensitiveConfiguration,
 	)
 	if err != nil {
 		return err
 	}
 
-	if !canConfigureEnvironments && !canConfigureSensitiveconfigKeyiroments && !canManageConfigKeys {
+	if !canRead {
 		return auth.ErrUnauthorized
 	}
 
 	return nil
 }
 
-func (svc *Service) GetConfigKeyByName(ctx context.Context, actor auth.User, serviceName, name string) (ConfigKey, error) {
-	authErr := svc.hasReadPermissions(ctx, actor)
-	if authErr != nil {
+func (svc *Service) singleRetrievalPermissionChecks(ctx context.Context, actor auth.User, configKey ConfigKey, retrievalErr error) (ConfigKey, error) {
+	if retrievalErr != nil {
+		return ConfigKey{}, retrievalErr
+	}
+
+	if authErr := svc.hasReadPermissions(ctx, actor, configKey.ServiceID); authErr != nil {
 		return ConfigKey{}, authErr
 	}
 
-	return svc.repo.GetConfigKeyByName(ctx, serviceName, name)
+	return configKey, nil
 }
 
-func (svc *Service) GetConfigKeyByID(ctx context.Context, actor auth.User, id int) (ConfigKey, error) {
-	authErr := svc.hasReadPermissions(ctx, actor)
-	if authErr != nil {
-		return ConfigKey{}, authErr
-	}
+func (svc *Service) GetConfigKeyByName(ctx context.Context, actor auth.User, serviceName, name string) (ConfigKey, error) {
+	configKey, err := svc.repo.GetConfigKeyByName(ctx, serviceName, name)
+	return svc.singleRetrievalPermissionChecks(ctx, actor, configKey, err)
+}
 
-	return svc.repo.GetConfigKey(ctx, id)
+func (svc *Service) GetConfigKeyByID(ctx context.Context, actor auth.User, id int) (ConfigKey, error) {
+	configKey, err := svc.repo.GetConfigKey(ctx, id)
+	return svc.singleRetrievalPermissionChecks(ctx, actor, configKey, err)
 }
 
+// ListConfigKeys returns the config keys of the given services, or all
+// services, which the actor is able to read.
 func (svc *Service) ListConfigKeys(ctx context.Context, actor auth.User, serviceIDs ...int) ([]ConfigKey, error) {
-	authErr := svc.hasReadPermissions(ctx, actor)
-	if authErr != nil {
-		return nil, authErr
+	canReadAll, err := svc.auth.HasPermission(
+		ctx,
+		actor,
+		auth.PermissionManageConfigKeys,
+		auth.PermissionConfigureSensitiveEnvironments,
+		auth.PermissionConfigureEnvironments,
+		auth.PermissionReadConfiguration,
+		auth.PermissionReadSensitiveConfiguration,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	configKeys, err := svc.repo.ListConfigKeys(ctx, serviceIDs...)
+	if err != nil || canReadAll {
+		return configKeys, err
+	}
+
+	permitted := make(map[int]bool)
+	visible := make([]ConfigKey, 0, len(configKeys))
+	for _, configKey := range configKeys {
+		canRead, checked := permitted[configKey.ServiceID]
+		if !checked {
+			authErr := svc.hasReadPermissions(ctx, actor, configKey.ServiceID)
+			if authErr != nil && !errors.Is(authErr, auth.ErrUnauthorized) {
+				return nil, authErr
+			}
+
+			canRead = authErr == nil
+			permitted[configKey.ServiceID] = canRead
+		}
+
+		if canRead {
+			visible = append(visible, configKey)
+		}
+	}
+
+	if len(visible) == 0 {
+		return nil, auth.ErrUnauthorized
 	}
 
-	return svc.repo.ListConfigKeys(ctx, serviceIDs...)
+	return visible, nil
 }
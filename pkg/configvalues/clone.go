@@ -0,0 +1,102 @@
+package configvalues
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/config-source/cdb/pkg/audit"
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/environments"
+)
+
+// CloneRequest describes the environment to create from an existing one.
+type CloneRequest struct {
+	Name         string
+	PromotesToID *int
+	// FlattenInherited copies values the source inherits as direct values on
+	// the clone so it keeps the source's resolved configuration regardless of
+	// what it promotes to.
+	FlattenInherited bool
+}
+
+// CloneEnvironment creates a new environment in the same service as the
+// source with the source's directly set values.
+func (svc *Service) CloneEnvironment(
+	ctx context.Context,
+	actor auth.User,
+	sourceID int,
+	req CloneRequest,
+) (environments.Environment, error) {
+	if req.Name == "" {
+		return environments.Environment{}, fmt.Errorf("%w: clones must have a Name", ErrNotValid)
+	}
+
+	source, err := svc.environRepo.GetEnvironment(ctx, sourceID)
+	if err != nil {
+		return environments.Environment{}, err
+	}
+
+	if err := svc.canManageEnvironments(ctx, actor, source.ServiceID); err != nil {
+		return environments.Environment{}, err
+	}
+
+	// The clone has the same values as the source so the actor must be able to
+	// configure it.
+	if err := svc.CanConfigureEnvironment(ctx, actor, source); err != nil {
+		return environments.Environment{}, err
+	}
+
+	if req.PromotesToID != nil {
+		promotesTo, err := svc.environRepo.GetEnvironment(ctx, *req.PromotesToID)
+		if err != nil {
+			return environments.Environment{}, err
+		}
+
+		if promotesTo.ServiceID != source.ServiceID {
+			return environments.Environment{}, fmt.Errorf(
+				"%w: clones must promote to an environment in service %s",
+				ErrNotValid,
+				source.Service,
+			)
+		}
+	}
+
+	var flattened []ConfigValue
+	if req.FlattenInherited {
+		resolved, err := svc.repo.GetConfiguration(ctx, source.ID)
+		if err != nil {
+			return environments.Environment{}, err
+		}
+
+		for _, cv := range resolved {
+			if cv.Inherited {
+				flattened = append(flattened, cv)
+			}
+		}
+	}
+
+	clone, err := svc.repo.CloneEnvironment(ctx, source.ID, environments.Environment{
+		Name:            req.Name,
+		PromotesToID:    req.PromotesToID,
+		ServiceID:       source.ServiceID,
+		Sensitive:       source.Sensitive,
+		InheritanceMode: source.InheritanceMode,
+		InheritanceKeys: source.InheritanceKeys,
+	}, flattened)
+	if err != nil {
+		return environments.Environment{}, err
+	}
+
+	clone.Service = source.Service
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionEnvironmentClone,
+		TargetKind: audit.TargetEnvironment,
+		TargetID:   strconv.Itoa(clone.ID),
+		After: struct {
+			environments.Environment
+			ClonedFromID int
+		}{clone, source.ID},
+	})
+	return clone, nil
+}
@@ -29,11 +29,21 @@ type ConfigValue struct {
 	FloatValue *float64 `db:"float_value"`
 	BoolValue  *bool    `db:"bool_value"`
 
+	// TargetingRules are evaluated in order against an EvaluationContext, the
+	// first matching rule's value is used instead of the plain value.
+	TargetingRules []TargetingRule `db:"targeting_rules"`
+	// OverlayID is set when the value only applies when the overlay is
+	// selected.
+	OverlayID *int `db:"overlay_id"`
+
 	CreatedAt time.Time `db:"created_at"`
 	// Inherited indicates that the value was inherited
 	Inherited bool `db:"-"`
 	// InheritedFrom is the name of the Enviroment that the value was inherited from.
 	InheritedFrom string `db:"-"`
+	// Redacted indicates that the value belongs to a sensitive config key and
+	// has been removed.
+	Redacted bool `db:"-"`
 }
 
 func New(environmentID, configKeyID int) *ConfigValue {
@@ -115,6 +125,15 @@ func (cv *ConfigValue) resetValues() *ConfigValue {
 	return cv
 }
 
+// Redact removes the value and targeting rules so that the value of a
+// sensitive config key isn't leaked.
+func (cv *ConfigValue) Redact() *ConfigValue {
+	cv.resetValues()
+	cv.TargetingRules = nil
+	cv.Redacted = true
+	return cv
+}
+
 func (cv *ConfigValue) SetStrValue(val string) *ConfigValue {
 	cv.resetValues()
 	storage := val
@@ -148,6 +167,20 @@ func (cv *ConfigValue) SetBoolValue(val bool) *ConfigValue {
 }
 
 func (cv *ConfigValue) Valid() error {
+	if err := cv.validateValue(); err != nil {
+		return err
+	}
+
+	for _, rule := range cv.TargetingRules {
+		if err := rule.valid(cv.ValueType); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (cv *ConfigValue) validateValue() error {
 	switch cv.ValueType {
 	case configkeys.TypeBoolean:
 		return cv.validateBoolean()
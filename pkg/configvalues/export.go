@@ -0,0 +1,81 @@
+package configvalues
+
+import (
+	"context"
+	"errors"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/environments"
+)
+
+// EnvironmentConfiguration is the resolved configuration of a single
+// environment.
+type EnvironmentConfiguration struct {
+	Environment environments.Environment
+	Values      []ConfigValue
+}
+
+// ExportConfiguration returns the resolved configuration of every environment
+// matching selector that the actor is able to read. Environments the actor
+// can't read are left out rather than failing the export. Values of sensitive
+// keys are redacted unless reveal is set and the actor can reveal them.
+func (svc *Service) ExportConfiguration(
+	ctx context.Context,
+	actor auth.User,
+	selector environments.LabelSelector,
+	reveal bool,
+) ([]EnvironmentConfiguration, error) {
+	envs, err := svc.environRepo.ListEnvironmentsBySelector(ctx, true, selector)
+	if err != nil {
+		return nil, err
+	}
+
+	exported := make([]EnvironmentConfiguration, 0, len(envs))
+	for _, env := range envs {
+		err := svc.CanReadEnvironment(ctx, actor, env)
+		if errors.Is(err, auth.ErrUnauthorized) || errors.Is(err, environments.ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+
+		values, err := svc.repo.GetConfiguration(ctx, env.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		err = svc.protectSensitiveValues(ctx, actor, env, reveal, values)
+		if reveal && errors.Is(err, auth.ErrUnauthorized) {
+			// Services the actor can't reveal values in are exported
+			// redacted rather than failing the whole export.
+			err = svc.protectSensitiveValues(ctx, actor, env, false, values)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		exported = append(exported, EnvironmentConfiguration{
+			Environment: env,
+			Values:      values,
+		})
+	}
+
+	if len(exported) == 0 {
+		canReadAny, err := svc.auth.HasPermission(
+			ctx,
+			actor,
+			auth.PermissionReadConfiguration,
+			auth.PermissionReadSensitiveConfiguration,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if !canReadAny {
+			return nil, auth.ErrUnauthorized
+		}
+	}
+
+	return exported, nil
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	_ "embed"
 	"errors"
+	"fmt"
+	"slices"
 	"strings"
 
 	"github.com/config-source/cdb/pkg/configkeys"
@@ -18,13 +20,20 @@ type Repository struct {
 	pool    *pgxpool.Pool
 	log     zerolog.Logger
 	envRepo *environments.Repository
+	keyRepo *configkeys.Repository
 }
 
-func NewRepository(log zerolog.Logger, pool *pgxpool.Pool, envRepo *environments.Repository) *Repository {
+func NewRepository(
+	log zerolog.Logger,
+	pool *pgxpool.Pool,
+	envRepo *environments.Repository,
+	keyRepo *configkeys.Repository,
+) *Repository {
 	return &Repository{
 		log:     log,
 		pool:    pool,
 		envRepo: envRepo,
+		keyRepo: keyRepo,
 	}
 }
 
@@ -46,9 +55,19 @@ var getAllConfigValuesForEnvironmentSql string
 //go:embed queries/get_all_config_values_except_matching_keys.sql
 var getAllConfigValuesForEnvironmentExceptKeysSql string
 
+//go:embed queries/copy_config_values.sql
+var copyConfigValuesSql string
+
+//go:embed queries/list_direct_config_values.sql
+var listDirectConfigValuesSql string
+
 func (r *Repository) CreateConfigValue(ctx context.Context, cv *ConfigValue) (*ConfigValue, error) {
+	return createConfigValue(ctx, r.pool, cv)
+}
+
+func createConfigValue(ctx context.Context, q postgresutils.Querier, cv *ConfigValue) (*ConfigValue, error) {
 	created, err := postgresutils.GetOneLax[ConfigValue](
-		r.pool,
+		q,
 		ctx,
 		createConfigValueSql,
 		cv.EnvironmentID,
@@ -57,6 +76,8 @@ func (r *Repository) CreateConfigValue(ctx context.Context, cv *ConfigValue) (*C
 		cv.IntValue,
 		cv.FloatValue,
 		cv.BoolValue,
+		cv.TargetingRules,
+		cv.OverlayID,
 	)
 	if err != nil && postgresutils.IsUniqueConstraintErr(err) {
 		return nil, ErrAlreadySet
@@ -71,8 +92,12 @@ func (r *Repository) CreateConfigValue(ctx context.Context, cv *ConfigValue) (*C
 }
 
 func (r *Repository) UpdateConfigurationValue(ctx context.Context, cv *ConfigValue) (*ConfigValue, error) {
+	return updateConfigValue(ctx, r.pool, cv)
+}
+
+func updateConfigValue(ctx context.Context, q postgresutils.Querier, cv *ConfigValue) (*ConfigValue, error) {
 	updated, err := postgresutils.GetOneLax[ConfigValue](
-		r.pool,
+		q,
 		ctx,
 		updateConfigValueSql,
 		cv.EnvironmentID,
@@ -81,6 +106,8 @@ func (r *Repository) UpdateConfigurationValue(ctx context.Context, cv *ConfigVal
 		cv.IntValue,
 		cv.FloatValue,
 		cv.BoolValue,
+		cv.TargetingRules,
+		cv.OverlayID,
 		cv.ID,
 	)
 
@@ -102,13 +129,67 @@ func (r *Repository) UpdateConfigurationValue(ctx context.Context, cv *ConfigVal
 	return &updated, err
 }
 
-func (r *Repository) GetConfigValueByEnvAndKey(ctx context.Context, environmentID int, key string) (*ConfigValue, error) {
+// SetConfigValues creates newKeys and then updates the values which have an
+// ID and creates the rest. Values without a ConfigKeyID are for the new key
+// with the same Name. Everything happens in one transaction so either every
+// key and value is created or none are.
+func (r *Repository) SetConfigValues(
+	ctx context.Context,
+	newKeys []configkeys.ConfigKey,
+	values []*ConfigValue,
+) ([]configkeys.ConfigKey, []*ConfigValue, error) {
+	txn, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	created := make([]configkeys.ConfigKey, len(newKeys))
+	keyIDs := make(map[string]int, len(newKeys))
+	for idx, ck := range newKeys {
+		created[idx], err = r.keyRepo.CreateConfigKeyInTx(ctx, txn, ck)
+		if err != nil {
+			postgresutils.Rollback(ctx, txn, r.log)
+			return nil, nil, fmt.Errorf("failed to create new config key: %w", err)
+		}
+
+		keyIDs[ck.Name] = created[idx].ID
+	}
+
+	results := make([]*ConfigValue, len(values))
+	for idx, cv := range values {
+		if cv.ConfigKeyID == 0 {
+			cv.ConfigKeyID = keyIDs[cv.Name]
+		}
+
+		if cv.ID != 0 {
+			results[idx], err = updateConfigValue(ctx, txn, cv)
+		} else {
+			results[idx], err = createConfigValue(ctx, txn, cv)
+		}
+
+		if err != nil {
+			postgresutils.Rollback(ctx, txn, r.log)
+			return nil, nil, err
+		}
+	}
+
+	if err := txn.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	return created, results, nil
+}
+
+// GetConfigValueByEnvAndKey returns the value set directly on the environment
+// for key, for the given overlay if overlayID is not nil.
+func (r *Repository) GetConfigValueByEnvAndKey(ctx context.Context, environmentID int, key string, overlayID *int) (*ConfigValue, error) {
 	cv, err := postgresutils.GetOne[ConfigValue](
 		r.pool,
 		ctx,
 		getConfigValueByEnvironmentAndKeySql,
 		environmentID,
 		key,
+		overlayID,
 	)
 	if errors.Is(err, pgx.ErrNoRows) {
 		return &cv, ErrNotFound
@@ -125,13 +206,70 @@ func getAllKeys(values []ConfigValue) []string {
 	return keys
 }
 
-func getConfigurationRecursively(ctx context.Context, r *Repository, environmentID int, excludedKeys []string) ([]ConfigValue, error) {
-	env, err := r.envRepo.GetEnvironment(ctx, environmentID)
+// inheritedValues filters values resolved for the parent of env down to those
+// env inherits according to its InheritanceMode.
+func inheritedValues(env environments.Environment, values []ConfigValue) []ConfigValue {
+	inherited := make([]ConfigValue, 0, len(values))
+	for _, cv := range values {
+		if env.Inherits(cv.Name) {
+			inherited = append(inherited, cv)
+		}
+	}
+
+	return inherited
+}
+
+// resolver resolves configuration through the promotion chain. Environments
+// in replacements are used in place of the stored environment with the same
+// ID so that the effect of changing an environment can be previewed.
+type resolver struct {
+	repo         *Repository
+	replacements map[int]environments.Environment
+}
+
+func (res resolver) getEnvironment(ctx context.Context, id int) (environments.Environment, error) {
+	if env, ok := res.replacements[id]; ok {
+		return env, nil
+	}
+
+	return res.repo.envRepo.GetEnvironment(ctx, id)
+}
+
+// getParentConfiguration returns the values env inherits from the environment
+// it promotes to, if any.
+func (res resolver) getParentConfiguration(
+	ctx context.Context,
+	env environments.Environment,
+	excludedKeys []string,
+	overlayID *int,
+) ([]ConfigValue, error) {
+	if env.PromotesToID == nil || env.InheritanceMode == environments.InheritNone {
+		return nil, nil
+	}
+
+	parentValues, err := res.getConfigurationRecursively(ctx, *env.PromotesToID, excludedKeys, overlayID)
+	return inheritedValues(env, parentValues), err
+}
+
+func (res resolver) getConfigurationRecursively(
+	ctx context.Context,
+	environmentID int,
+	excludedKeys []string,
+	overlayID *int,
+) ([]ConfigValue, error) {
+	env, err := res.getEnvironment(ctx, environmentID)
 	if err != nil {
 		return nil, err
 	}
 
-	immediateValues, err := postgresutils.GetAll[ConfigValue](r.pool, ctx, getAllConfigValuesForEnvironmentExceptKeysSql, environmentID, excludedKeys)
+	immediateValues, err := postgresutils.GetAll[ConfigValue](
+		res.repo.pool,
+		ctx,
+		getAllConfigValuesForEnvironmentExceptKeysSql,
+		environmentID,
+		excludedKeys,
+		overlayID,
+	)
 	if err != nil {
 		return immediateValues, err
 	}
@@ -141,51 +279,105 @@ func getConfigurationRecursively(ctx context.Context, r *Repository, environment
 		immediateValues[idx].InheritedFrom = env.Name
 	}
 
-	if env.PromotesToID != nil {
-		parentValues, err := getConfigurationRecursively(ctx, r, *env.PromotesToID, append(excludedKeys, getAllKeys(immediateValues)...))
-		return append(immediateValues, parentValues...), err
-	}
-
-	return immediateValues, nil
+	parentValues, err := res.getParentConfiguration(ctx, env, append(excludedKeys, getAllKeys(immediateValues)...), overlayID)
+	return append(immediateValues, parentValues...), err
 }
 
-func (r *Repository) GetConfiguration(ctx context.Context, environmentID int) ([]ConfigValue, error) {
-	immediateValues, err := postgresutils.GetAll[ConfigValue](r.pool, ctx, getAllConfigValuesForEnvironmentSql, environmentID)
+func (res resolver) getConfiguration(ctx context.Context, env environments.Environment, overlayID *int) ([]ConfigValue, error) {
+	immediateValues, err := postgresutils.GetAll[ConfigValue](res.repo.pool, ctx, getAllConfigValuesForEnvironmentSql, env.ID, overlayID)
 	if err != nil {
 		return immediateValues, err
 	}
 
+	parentValues, err := res.getParentConfiguration(ctx, env, getAllKeys(immediateValues), overlayID)
+	return append(immediateValues, parentValues...), err
+}
+
+// markOverlaid records that cv came from overlay. Overlay values are always
+// marked Inherited so they are never written back to the environment itself.
+func markOverlaid(cv *ConfigValue, env environments.Environment, overlay environments.Overlay) {
+	source := env.Name
+	if cv.Inherited {
+		source = cv.InheritedFrom
+	}
+
+	cv.Inherited = true
+	cv.InheritedFrom = fmt.Sprintf("%s (%s)", source, overlay.Selector())
+}
+
+// GetConfiguration returns the resolved configuration for the environment.
+//
+// Values are resolved in order of increasing precedence:
+//
+//  1. Values inherited from the environments this one promotes to, nearest
+//     environment first, subject to CanPropagate and the InheritanceMode.
+//  2. Values set directly on the environment.
+//  3. Each overlay in the order given, with later overlays taking precedence.
+//     An overlay's values are themselves resolved through the promotion chain
+//     in the same way as 1 and 2.
+//
+// Overlay values have InheritedFrom set to "<environment> (<dimension>:<name>)"
+// where environment is where the overlay value was set.
+func (r *Repository) GetConfiguration(ctx context.Context, environmentID int, overlays ...environments.Overlay) ([]ConfigValue, error) {
 	env, err := r.envRepo.GetEnvironment(ctx, environmentID)
 	if err != nil {
 		return nil, err
 	}
 
-	if env.PromotesToID != nil {
-		parentValues, err := getConfigurationRecursively(ctx, r, *env.PromotesToID, getAllKeys(immediateValues))
-		return append(immediateValues, parentValues...), err
+	res := resolver{repo: r}
+	values, err := res.getConfiguration(ctx, env, nil)
+	if err != nil {
+		return values, err
+	}
+
+	for _, overlay := range overlays {
+		overlayValues, err := res.getConfiguration(ctx, env, &overlay.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, overlaid := range overlayValues {
+			markOverlaid(&overlaid, env, overlay)
+
+			idx := slices.IndexFunc(values, func(cv ConfigValue) bool { return cv.Name == overlaid.Name })
+			if idx == -1 {
+				values = append(values, overlaid)
+			} else {
+				values[idx] = overlaid
+			}
+		}
 	}
 
-	return immediateValues, nil
+	return values, nil
 }
 
-func (r *Repository) GetConfigurationValue(ctx context.Context, environmentID int, key string) (*ConfigValue, error) {
-	cv, err := r.GetConfigValueByEnvAndKey(ctx, environmentID, key)
+func getConfigurationValue(ctx context.Context, r *Repository, environmentID int, key string, overlayID *int) (*ConfigValue, error) {
+	cv, err := r.GetConfigValueByEnvAndKey(ctx, environmentID, key, overlayID)
 	if errors.Is(err, ErrNotFound) {
 		env, err := r.envRepo.GetEnvironment(ctx, environmentID)
 		if err != nil {
 			return nil, err
 		}
 
-		if env.PromotesToID != nil {
+		if env.PromotesToID != nil && env.Inherits(key) {
 			parent, err := r.envRepo.GetEnvironment(ctx, *env.PromotesToID)
 			if err != nil {
 				return nil, err
 			}
 
-			cv, err := r.GetConfigurationValue(ctx, parent.ID, key)
-			cv.Inherited = true
-			cv.InheritedFrom = parent.Name
-			return cv, err
+			cv, err := getConfigurationValue(ctx, r, parent.ID, key, overlayID)
+			if err != nil {
+				return cv, err
+			}
+
+			// Values inherited from further up the chain already record
+			// where they came from.
+			if !cv.Inherited {
+				cv.Inherited = true
+				cv.InheritedFrom = parent.Name
+			}
+
+			return cv, nil
 		}
 
 		return cv, ErrNotFound
@@ -194,7 +386,110 @@ func (r *Repository) GetConfigurationValue(ctx context.Context, environmentID in
 	return cv, err
 }
 
+// GetConfigurationValue returns the resolved value of key for the environment
+// using the same precedence as GetConfiguration.
+func (r *Repository) GetConfigurationValue(ctx context.Context, environmentID int, key string, overlays ...environments.Overlay) (*ConfigValue, error) {
+	for idx := len(overlays) - 1; idx >= 0; idx-- {
+		overlay := overlays[idx]
+		cv, err := getConfigurationValue(ctx, r, environmentID, key, &overlay.ID)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return cv, err
+		}
+
+		env, err := r.envRepo.GetEnvironment(ctx, environmentID)
+		if err != nil {
+			return nil, err
+		}
+
+		markOverlaid(cv, env, overlay)
+		return cv, nil
+	}
+
+	return getConfigurationValue(ctx, r, environmentID, key, nil)
+}
+
 func (r *Repository) GetConfigurationValueByID(ctx context.Context, configValueID int) (*ConfigValue, error) {
 	cv, err := postgresutils.GetOne[ConfigValue](r.pool, ctx, getConfigValueByIDSql, configValueID)
 	return &cv, err
 }
+
+// ListDirectValues returns every value set directly on the environment
+// including those set for overlays.
+func (r *Repository) ListDirectValues(ctx context.Context, environmentID int) ([]ConfigValue, error) {
+	return postgresutils.GetAll[ConfigValue](r.pool, ctx, listDirectConfigValuesSql, environmentID)
+}
+
+// PreviewConfiguration returns the resolved configuration, without overlays,
+// that the environment would have if the given environments replaced their
+// stored versions.
+func (r *Repository) PreviewConfiguration(
+	ctx context.Context,
+	environmentID int,
+	replacements ...environments.Environment,
+) ([]ConfigValue, error) {
+	res := resolver{
+		repo:         r,
+		replacements: make(map[int]environments.Environment, len(replacements)),
+	}
+	for _, env := range replacements {
+		res.replacements[env.ID] = env
+	}
+
+	env, err := res.getEnvironment(ctx, environmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.getConfiguration(ctx, env, nil)
+}
+
+// CloneEnvironment creates clone and copies every value set directly on the
+// source environment, including overlay values, to it. The flattened values
+// are created on the clone as well. Everything happens in one transaction so
+// a failure never leaves a partial clone behind.
+func (r *Repository) CloneEnvironment(
+	ctx context.Context,
+	sourceID int,
+	clone environments.Environment,
+	flattened []ConfigValue,
+) (environments.Environment, error) {
+	txn, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return environments.Environment{}, err
+	}
+
+	created, err := r.envRepo.CreateEnvironmentInTx(ctx, txn, clone)
+	if err != nil {
+		postgresutils.Rollback(ctx, txn, r.log)
+		return environments.Environment{}, err
+	}
+
+	_, err = txn.Exec(ctx, copyConfigValuesSql, sourceID, created.ID)
+	if err != nil {
+		postgresutils.Rollback(ctx, txn, r.log)
+		return environments.Environment{}, err
+	}
+
+	for _, cv := range flattened {
+		_, err = txn.Exec(
+			ctx,
+			createConfigValueSql,
+			created.ID,
+			cv.ConfigKeyID,
+			cv.StrValue,
+			cv.IntValue,
+			cv.FloatValue,
+			cv.BoolValue,
+			cv.TargetingRules,
+			nil,
+		)
+		if err != nil {
+			postgresutils.Rollback(ctx, txn, r.log)
+			return environments.Environment{}, err
+		}
+	}
+
+	return created, txn.Commit(ctx)
+}
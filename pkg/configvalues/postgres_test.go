@@ -5,8 +5,10 @@ import (
 	_ "embed"
 	"errors"
 	"reflect"
+	"slices"
 	"testing"
 
+	"github.com/config-source/cdb/pkg/audit"
 	"github.com/config-source/cdb/pkg/configkeys"
 	"github.com/config-source/cdb/pkg/configvalues"
 	"github.com/config-source/cdb/pkg/environments"
@@ -15,11 +17,14 @@ import (
 	"github.com/rs/zerolog"
 )
 
+var testLogger = zerolog.New(nil).Level(zerolog.Disabled)
+
 type TestContext struct {
 	valueRepo       *configvalues.Repository
 	environmentRepo *environments.Repository
 	keyRepo         *configkeys.Repository
 	serviceRepo     *services.Repository
+	auditLog        *audit.Log
 }
 
 func initTestDB(t *testing.T) TestContext {
@@ -31,13 +36,14 @@ func initTestDB(t *testing.T) TestContext {
 	envRepo := environments.NewRepository(logger, pool)
 	keyRepo := configkeys.NewRepository(logger, pool)
 	svcRepo := services.NewRepository(logger, pool)
-	repo := configvalues.NewRepository(logger, pool, envRepo)
+	repo := configvalues.NewRepository(logger, pool, envRepo, keyRepo)
 
 	return TestContext{
 		valueRepo:       repo,
 		environmentRepo: envRepo,
 		keyRepo:         keyRepo,
 		serviceRepo:     svcRepo,
+		auditLog:        audit.NewLog(logger, pool),
 	}
 }
 
@@ -234,6 +240,33 @@ func TestUpdateConfigValueReturnsErrConfigKeyNotFound(t *testing.T) {
 	}
 }
 
+func TestSetConfigValuesRollsBackNewKeys(t *testing.T) {
+	tc := initTestDB(t)
+
+	svc := svcFixture(t, tc.serviceRepo, "svc1")
+	env := envFixture(t, tc.environmentRepo, "cdb", nil, svc.ID)
+	newKey := configkeys.New(svc.ID, "minReplicas", configkeys.TypeInteger)
+
+	created := configvalues.NewInt(env.ID, 0, 1)
+	created.Name = newKey.Name
+	// The key doesn't exist so writing this value fails after minReplicas has
+	// been created.
+	missing := configvalues.NewString(env.ID, 1000, "test")
+
+	_, _, err := tc.valueRepo.SetConfigValues(
+		context.Background(),
+		[]configkeys.ConfigKey{newKey},
+		[]*configvalues.ConfigValue{created, missing},
+	)
+	if err == nil {
+		t.Fatal("Expected an error writing a value for a missing key")
+	}
+
+	if _, err := tc.keyRepo.GetConfigKeyByName(context.Background(), svc.Name, newKey.Name); !errors.Is(err, configkeys.ErrNotFound) {
+		t.Fatalf("Expected %s not to be created got: %v", newKey.Name, err)
+	}
+}
+
 func TestUpdateConfigValueReturnsErrEnvironmentNotFound(t *testing.T) {
 	tc := initTestDB(t)
 
@@ -616,3 +649,166 @@ func TestGetConfigurationMarksInheritedValuesAsSuch(t *testing.T) {
 		t.Fatalf("\n\tExpected\n\t\t%+v\n\tGot\n\t\t%+v", expectedValues, retrieved)
 	}
 }
+
+func TestGetConfigurationRespectsInheritanceMode(t *testing.T) {
+	tc := initTestDB(t)
+
+	svc := svcFixture(t, tc.serviceRepo, "svc1")
+	production := envFixture(t, tc.environmentRepo, "production", nil, svc.ID)
+
+	owner := configKeyFixture(t, tc.keyRepo, svc.ID, "owner", configkeys.TypeString, true)
+	minReplicas := configKeyFixture(t, tc.keyRepo, svc.ID, "minReplicas", configkeys.TypeInteger, true)
+	maxReplicas := configKeyFixture(t, tc.keyRepo, svc.ID, "maxReplicas", configkeys.TypeInteger, true)
+
+	createConfigValue(t, tc.valueRepo, configvalues.NewString(production.ID, owner.ID, "SRE"))
+	createConfigValue(t, tc.valueRepo, configvalues.NewInt(production.ID, minReplicas.ID, 10))
+	createConfigValue(t, tc.valueRepo, configvalues.NewInt(production.ID, maxReplicas.ID, 100))
+
+	tests := []struct {
+		mode     environments.InheritanceMode
+		keys     []string
+		expected []string
+	}{
+		{mode: environments.InheritFull, expected: []string{"maxReplicas", "minReplicas", "owner"}},
+		{mode: environments.InheritNone, expected: []string{}},
+		{mode: environments.InheritAllowlist, keys: []string{"owner"}, expected: []string{"owner"}},
+		{mode: environments.InheritDenylist, keys: []string{"owner"}, expected: []string{"maxReplicas", "minReplicas"}},
+	}
+
+	for _, test := range tests {
+		env, err := tc.environmentRepo.CreateEnvironment(context.Background(), environments.Environment{
+			Name:            string(test.mode),
+			PromotesToID:    &production.ID,
+			ServiceID:       svc.ID,
+			InheritanceMode: test.mode,
+			InheritanceKeys: test.keys,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		retrieved, err := tc.valueRepo.GetConfiguration(context.Background(), env.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		names := getNames(retrieved)
+		if !reflect.DeepEqual(test.expected, names) {
+			t.Errorf("Expected %s to inherit %v got: %v", test.mode, test.expected, names)
+		}
+
+		for _, key := range []string{"owner", "minReplicas", "maxReplicas"} {
+			_, err := tc.valueRepo.GetConfigurationValue(context.Background(), env.ID, key)
+			shouldInherit := slices.Contains(test.expected, key)
+			if shouldInherit && err != nil {
+				t.Errorf("Expected %s to inherit %s got: %s", test.mode, key, err)
+			} else if !shouldInherit && !errors.Is(err, configvalues.ErrNotFound) {
+				t.Errorf("Expected %s not to inherit %s got: %v", test.mode, key, err)
+			}
+		}
+	}
+}
+
+func TestGetConfigurationValueRecordsOriginalEnvironment(t *testing.T) {
+	tc := initTestDB(t)
+
+	svc := svcFixture(t, tc.serviceRepo, "svc1")
+	production := envFixture(t, tc.environmentRepo, "production", nil, svc.ID)
+	staging := envFixture(t, tc.environmentRepo, "staging", &production.ID, svc.ID)
+	dev := envFixture(t, tc.environmentRepo, "dev", &staging.ID, svc.ID)
+
+	owner := configKeyFixture(t, tc.keyRepo, svc.ID, "owner", configkeys.TypeString, true)
+	createConfigValue(t, tc.valueRepo, configvalues.NewString(production.ID, owner.ID, "SRE"))
+
+	cv, err := tc.valueRepo.GetConfigurationValue(context.Background(), dev.ID, "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cv.InheritedFrom != production.Name {
+		t.Fatalf("Expected value to be inherited from %s got: %s", production.Name, cv.InheritedFrom)
+	}
+}
+
+func getNames(values []configvalues.ConfigValue) []string {
+	names := make([]string, len(values))
+	for idx, cv := range values {
+		names[idx] = cv.Name
+	}
+
+	slices.Sort(names)
+	return names
+}
+
+func TestGetConfigurationAppliesOverlaysInOrder(t *testing.T) {
+	tc := initTestDB(t)
+
+	svc := svcFixture(t, tc.serviceRepo, "svc1")
+	production := envFixture(t, tc.environmentRepo, "production", nil, svc.ID)
+	staging := envFixture(t, tc.environmentRepo, "staging", &production.ID, svc.ID)
+
+	region, err := tc.environmentRepo.CreateOverlay(context.Background(), environments.Overlay{ServiceID: svc.ID, Dimension: "region", Name: "eu"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tenant, err := tc.environmentRepo.CreateOverlay(context.Background(), environments.Overlay{ServiceID: svc.ID, Dimension: "tenant", Name: "acme"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner := configKeyFixture(t, tc.keyRepo, svc.ID, "owner", configkeys.TypeString, true)
+	endpoint := configKeyFixture(t, tc.keyRepo, svc.ID, "endpoint", configkeys.TypeString, true)
+
+	createConfigValue(t, tc.valueRepo, configvalues.NewString(production.ID, owner.ID, "SRE"))
+	createConfigValue(t, tc.valueRepo, configvalues.NewString(staging.ID, endpoint.ID, "staging.example.com"))
+
+	regionValue := configvalues.NewString(production.ID, endpoint.ID, "eu.example.com")
+	regionValue.OverlayID = &region.ID
+	createConfigValue(t, tc.valueRepo, regionValue)
+
+	tenantValue := configvalues.NewString(staging.ID, endpoint.ID, "acme.example.com")
+	tenantValue.OverlayID = &tenant.ID
+	createConfigValue(t, tc.valueRepo, tenantValue)
+
+	tests := []struct {
+		overlays     []environments.Overlay
+		endpoint     string
+		endpointFrom string
+	}{
+		{overlays: nil, endpoint: "staging.example.com", endpointFrom: ""},
+		{overlays: []environments.Overlay{region}, endpoint: "eu.example.com", endpointFrom: "production (region:eu)"},
+		{overlays: []environments.Overlay{region, tenant}, endpoint: "acme.example.com", endpointFrom: "staging (tenant:acme)"},
+		{overlays: []environments.Overlay{tenant, region}, endpoint: "eu.example.com", endpointFrom: "production (region:eu)"},
+	}
+
+	for _, test := range tests {
+		values, err := tc.valueRepo.GetConfiguration(context.Background(), staging.ID, test.overlays...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(values) != 2 {
+			t.Fatalf("Expected 2 values got: %v", values)
+		}
+
+		for _, cv := range values {
+			if cv.Name != "endpoint" {
+				continue
+			}
+
+			if *cv.StrValue != test.endpoint || cv.InheritedFrom != test.endpointFrom {
+				t.Errorf("Expected %s from %q got: %s from %q", test.endpoint, test.endpointFrom, *cv.StrValue, cv.InheritedFrom)
+			}
+		}
+
+		cv, err := tc.valueRepo.GetConfigurationValue(context.Background(), staging.ID, "endpoint", test.overlays...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if *cv.StrValue != test.endpoint || cv.InheritedFrom != test.endpointFrom {
+			t.Errorf("Expected %s from %q got: %s from %q", test.endpoint, test.endpointFrom, *cv.StrValue, cv.InheritedFrom)
+		}
+	}
+}
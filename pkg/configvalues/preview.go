@@ -0,0 +1,198 @@
+package configvalues
+
+import (
+	"context"
+	"reflect"
+	"sort"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/environments"
+)
+
+// ValueChange is a difference in the resolved value of Key. Before is nil when
+// the key becomes resolvable and After is nil when it stops being so.
+type ValueChange struct {
+	Key    string
+	Before *ConfigValue
+	After  *ConfigValue
+}
+
+// EnvironmentDiff is the change to an environment's resolved configuration.
+type EnvironmentDiff struct {
+	Environment environments.Environment
+	Changes     []ValueChange
+}
+
+// UpdatePreview describes the effect of updating an environment on it and
+// every environment which inherits from it.
+type UpdatePreview struct {
+	Environment environments.Environment
+	Diffs       []EnvironmentDiff
+}
+
+// DeletePreview describes what is lost by deleting an environment.
+type DeletePreview struct {
+	Environment environments.Environment
+	// Descendants are the environments which directly or indirectly promote
+	// to Environment and so inherit from it.
+	Descendants []environments.Environment
+	// Values are the values set directly on Environment, including overlay
+	// values, which are destroyed with it once it is purged from the trash.
+	Values []ConfigValue
+}
+
+func sameValue(a, b ConfigValue) bool {
+	return a.ValueType == b.ValueType &&
+		reflect.DeepEqual(a.Value(), b.Value()) &&
+		reflect.DeepEqual(a.TargetingRules, b.TargetingRules)
+}
+
+// diffConfiguration returns the changes between two resolved configurations
+// ordered by key. Changes in where a value is inherited from are ignored if
+// the value itself is the same.
+func diffConfiguration(before, after []ConfigValue) []ValueChange {
+	beforeByKey := make(map[string]ConfigValue, len(before))
+	for _, cv := range before {
+		beforeByKey[cv.Name] = cv
+	}
+
+	afterByKey := make(map[string]ConfigValue, len(after))
+	for _, cv := range after {
+		afterByKey[cv.Name] = cv
+	}
+
+	changes := []ValueChange{}
+	for key, previous := range beforeByKey {
+		updated, ok := afterByKey[key]
+		if !ok {
+			changes = append(changes, ValueChange{Key: key, Before: &previous})
+		} else if !sameValue(previous, updated) {
+			changes = append(changes, ValueChange{Key: key, Before: &previous, After: &updated})
+		}
+	}
+
+	for key, added := range afterByKey {
+		if _, ok := beforeByKey[key]; !ok {
+			changes = append(changes, ValueChange{Key: key, After: &added})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Key < changes[j].Key })
+	return changes
+}
+
+func (svc *Service) canManageEnvironments(ctx context.Context, actor auth.User, serviceID int) error {
+	canManageEnvironments, err := svc.auth.HasServicePermission(ctx, actor, serviceID, auth.PermissionManageEnvironments)
+	if err != nil {
+		return err
+	}
+
+	if !canManageEnvironments {
+		return auth.ErrUnauthorized
+	}
+
+	return nil
+}
+
+// PreviewEnvironmentUpdate returns how the resolved configuration of env and
+// its descendants would change if env was updated. Nothing is modified.
+func (svc *Service) PreviewEnvironmentUpdate(
+	ctx context.Context,
+	actor auth.User,
+	env environments.Environment,
+) (UpdatePreview, error) {
+	current, err := svc.environRepo.GetEnvironment(ctx, env.ID)
+	if err != nil {
+		return UpdatePreview{}, err
+	}
+
+	if err := svc.canManageEnvironments(ctx, actor, current.ServiceID); err != nil {
+		return UpdatePreview{}, err
+	}
+
+	if err := env.ValidInheritance(); err != nil {
+		return UpdatePreview{}, err
+	}
+
+	// Only these fields can be changed by an update.
+	proposed := current
+	proposed.Name = env.Name
+	proposed.PromotesToID = env.PromotesToID
+	proposed.Sensitive = env.Sensitive
+	proposed.InheritanceMode = env.InheritanceMode
+	proposed.InheritanceKeys = env.InheritanceKeys
+	proposed.Labels = env.Labels
+
+	if err := svc.environRepo.CheckPromotion(ctx, proposed); err != nil {
+		return UpdatePreview{}, err
+	}
+
+	descendants, err := svc.environRepo.ListDescendants(ctx, env.ID)
+	if err != nil {
+		return UpdatePreview{}, err
+	}
+
+	preview := UpdatePreview{
+		Environment: proposed,
+		Diffs:       make([]EnvironmentDiff, 0, len(descendants)+1),
+	}
+
+	for _, affected := range append([]environments.Environment{proposed}, descendants...) {
+		before, err := svc.repo.GetConfiguration(ctx, affected.ID)
+		if err != nil {
+			return UpdatePreview{}, err
+		}
+
+		after, err := svc.repo.PreviewConfiguration(ctx, affected.ID, proposed)
+		if err != nil {
+			return UpdatePreview{}, err
+		}
+
+		// Diffed before redacting so that changes to sensitive values still
+		// show up, just without what they changed to.
+		changes := diffConfiguration(before, after)
+		if err := svc.protectChanges(ctx, actor, affected, changes); err != nil {
+			return UpdatePreview{}, err
+		}
+
+		preview.Diffs = append(preview.Diffs, EnvironmentDiff{
+			Environment: affected,
+			Changes:     changes,
+		})
+	}
+
+	return preview, nil
+}
+
+// PreviewEnvironmentDelete returns the environments and values affected by
+// deleting the environment. Nothing is modified.
+func (svc *Service) PreviewEnvironmentDelete(ctx context.Context, actor auth.User, id int) (DeletePreview, error) {
+	env, err := svc.environRepo.GetEnvironment(ctx, id)
+	if err != nil {
+		return DeletePreview{}, err
+	}
+
+	if err := svc.canManageEnvironments(ctx, actor, env.ServiceID); err != nil {
+		return DeletePreview{}, err
+	}
+
+	descendants, err := svc.environRepo.ListDescendants(ctx, id)
+	if err != nil {
+		return DeletePreview{}, err
+	}
+
+	values, err := svc.repo.ListDirectValues(ctx, id)
+	if err != nil {
+		return DeletePreview{}, err
+	}
+
+	if err := svc.protectSensitiveValues(ctx, actor, env, false, values); err != nil {
+		return DeletePreview{}, err
+	}
+
+	return DeletePreview{
+		Environment: env,
+		Descendants: descendants,
+		Values:      values,
+	}, nil
+}
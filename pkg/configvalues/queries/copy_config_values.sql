@@ -0,0 +1,22 @@
+INSERT INTO config_values (
+    environment_id,
+    config_key_id,
+    str_value,
+    int_value,
+    float_value,
+    bool_value,
+    targeting_rules,
+    overlay_id
+)
+SELECT
+    $2,
+    config_key_id,
+    str_value,
+    int_value,
+    float_value,
+    bool_value,
+    targeting_rules,
+    overlay_id
+FROM config_values
+WHERE environment_id = $1
+    AND config_key_id IN (SELECT id FROM config_keys WHERE deleted_at IS NULL);
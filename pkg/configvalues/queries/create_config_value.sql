@@ -4,7 +4,9 @@ INSERT INTO config_values (
     str_value,
     int_value,
     float_value,
-    bool_value
+    bool_value,
+    targeting_rules,
+    overlay_id
 ) 
 VALUES (
     $1, 
@@ -12,6 +14,8 @@ VALUES (
     $3,
     $4,
     $5,
-    $6
+    $6,
+    $7,
+    $8
 )
 RETURNING *;
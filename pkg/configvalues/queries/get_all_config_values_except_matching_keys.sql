@@ -8,6 +8,8 @@ SELECT
     cv.int_value,
     cv.float_value,
     cv.bool_value,
+    cv.targeting_rules,
+    cv.overlay_id,
     cv.created_at
 FROM config_values AS cv 
 INNER JOIN environments AS e ON cv.environment_id = e.id
@@ -15,4 +17,6 @@ INNER JOIN config_keys AS ck ON cv.config_key_id = ck.id
 WHERE 
     e.id = $1 
     AND NOT (ck.name = ANY ($2))
-    AND ck.can_propagate = true;
+    AND ck.can_propagate = true
+    AND ck.deleted_at IS NULL
+    AND cv.overlay_id IS NOT DISTINCT FROM $3;
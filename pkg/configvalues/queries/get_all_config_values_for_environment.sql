@@ -8,7 +8,11 @@ SELECT
     cv.int_value,
     cv.float_value,
     cv.bool_value,
+    cv.targeting_rules,
+    cv.overlay_id,
     cv.created_at
 FROM config_values AS cv 
 INNER JOIN config_keys AS ck ON cv.config_key_id = ck.id
-WHERE cv.environment_id = $1;
+WHERE cv.environment_id = $1
+    AND cv.overlay_id IS NOT DISTINCT FROM $2
+    AND ck.deleted_at IS NULL;
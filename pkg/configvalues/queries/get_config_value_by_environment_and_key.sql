@@ -8,8 +8,12 @@ SELECT
     cv.int_value,
     cv.float_value,
     cv.bool_value,
+    cv.targeting_rules,
+    cv.overlay_id,
     cv.created_at
 FROM config_values AS cv 
 INNER JOIN environments AS e ON cv.environment_id = e.id
 INNER JOIN config_keys AS ck ON cv.config_key_id = ck.id
-WHERE e.id = $1 AND ck.name = $2;
+WHERE e.id = $1 AND ck.name = $2
+    AND cv.overlay_id IS NOT DISTINCT FROM $3
+    AND ck.deleted_at IS NULL;
@@ -0,0 +1,17 @@
+SELECT
+    cv.id,
+    cv.environment_id,
+    cv.config_key_id,
+    ck.name,
+    ck.value_type,
+    cv.str_value,
+    cv.int_value,
+    cv.float_value,
+    cv.bool_value,
+    cv.targeting_rules,
+    cv.overlay_id,
+    cv.created_at
+FROM config_values AS cv 
+INNER JOIN config_keys AS ck ON cv.config_key_id = ck.id
+WHERE cv.environment_id = $1 AND ck.deleted_at IS NULL
+ORDER BY cv.overlay_id NULLS FIRST, ck.name;
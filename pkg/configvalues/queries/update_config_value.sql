@@ -4,6 +4,8 @@ SET environment_id = $1,
     str_value      = $3,
     int_value      = $4,
     float_value    = $5,
-    bool_value     = $6
-WHERE id = $7
+    bool_value     = $6,
+    targeting_rules = $7,
+    overlay_id     = $8
+WHERE id = $9
 RETURNING *;
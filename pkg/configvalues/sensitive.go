@@ -0,0 +1,149 @@
+package configvalues
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/config-source/cdb/pkg/audit"
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/environments"
+)
+
+// protectSensitiveValues redacts the values of sensitive config keys in place.
+// When reveal is set the actor must instead hold
+// auth.PermissionRevealSensitiveValues on the environment's service and every
+// value revealed is audited individually, as are denied attempts.
+func (svc *Service) protectSensitiveValues(
+	ctx context.Context,
+	actor auth.User,
+	env environments.Environment,
+	reveal bool,
+	values []ConfigValue,
+) error {
+	keys, err := svc.configKeyRepo.ListConfigKeys(ctx, env.ServiceID)
+	if err != nil {
+		return err
+	}
+
+	// Values which haven't been written yet, like those of scheduled changes,
+	// only have the name of their key.
+	sensitive := make(map[int]bool)
+	sensitiveNames := make(map[string]bool)
+	for _, key := range keys {
+		if key.Sensitive {
+			sensitive[key.ID] = true
+			sensitiveNames[key.Name] = true
+		}
+	}
+
+	if len(sensitive) == 0 {
+		return nil
+	}
+
+	if reveal {
+		canReveal, err := svc.auth.HasServicePermission(ctx, actor, env.ServiceID, auth.PermissionRevealSensitiveValues)
+		if err != nil {
+			return err
+		}
+
+		if !canReveal {
+			svc.audit.Record(ctx, actor, audit.Event{
+				Action:     audit.ActionConfigValueRevealDenied,
+				TargetKind: audit.TargetEnvironment,
+				TargetID:   strconv.Itoa(env.ID),
+			})
+			return auth.ErrUnauthorized
+		}
+	}
+
+	for idx := range values {
+		cv := &values[idx]
+		if !sensitive[cv.ConfigKeyID] && !sensitiveNames[cv.Name] {
+			continue
+		}
+
+		if !reveal {
+			cv.Redact()
+			continue
+		}
+
+		svc.audit.Record(ctx, actor, audit.Event{
+			Action:     audit.ActionConfigValueReveal,
+			TargetKind: audit.TargetConfigValue,
+			TargetID:   strconv.Itoa(cv.ID),
+			After:      revealedValue{EnvironmentID: env.ID, Key: cv.Name},
+		})
+	}
+
+	return nil
+}
+
+// revealedValue is recorded when a sensitive value is revealed, the value
+// itself must never be written to the audit log.
+type revealedValue struct {
+	EnvironmentID int
+	Key           string
+}
+
+// RedactSensitiveValues redacts the values of sensitive config keys in place
+// for packages which return values outside of this one.
+func (svc *Service) RedactSensitiveValues(
+	ctx context.Context,
+	actor auth.User,
+	env environments.Environment,
+	values []*ConfigValue,
+) error {
+	copies := make([]ConfigValue, 0, len(values))
+	for _, cv := range values {
+		if cv != nil {
+			copies = append(copies, *cv)
+		}
+	}
+
+	if err := svc.protectSensitiveValues(ctx, actor, env, false, copies); err != nil {
+		return err
+	}
+
+	idx := 0
+	for _, cv := range values {
+		if cv != nil {
+			*cv = copies[idx]
+			idx++
+		}
+	}
+
+	return nil
+}
+
+// RedactForAudit redacts values in place before they're recorded in the audit
+// log. Like auditedValue every value in a sensitive environment is redacted
+// as well as the values of sensitive config keys.
+func (svc *Service) RedactForAudit(ctx context.Context, env environments.Environment, values []*ConfigValue) error {
+	if !env.Sensitive {
+		return svc.RedactSensitiveValues(ctx, auth.User{}, env, values)
+	}
+
+	for _, cv := range values {
+		if cv != nil {
+			cv.Redact()
+		}
+	}
+
+	return nil
+}
+
+// protectChanges redacts the before and after values of changes to sensitive
+// config keys in place.
+func (svc *Service) protectChanges(
+	ctx context.Context,
+	actor auth.User,
+	env environments.Environment,
+	changes []ValueChange,
+) error {
+	values := make([]*ConfigValue, 0, 2*len(changes))
+	for _, change := range changes {
+		values = append(values, change.Before, change.After)
+	}
+
+	return svc.RedactSensitiveValues(ctx, actor, env, values)
+}
@@ -4,96 +4,292 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strconv"
 
+	"github.com/config-source/cdb/pkg/audit"
 	"github.com/config-source/cdb/pkg/auth"
 	"github.com/config-source/cdb/pkg/configkeys"
 	"github.com/config-source/cdb/pkg/environments"
+	"github.com/rs/zerolog"
 )
 
 type Service struct {
 	DynamicConfigKeys bool
 
+	log           zerolog.Logger
 	repo          *Repository
 	environRepo   *environments.Repository
 	configKeyRepo *configkeys.Repository
 	auth          auth.AuthorizationGateway
+	audit         *audit.Log
 }
 
 func NewService(
+	log zerolog.Logger,
 	repo *Repository,
 	environRepo *environments.Repository,
 	configKeyRepo *configkeys.Repository,
 	auth auth.AuthorizationGateway,
 	dynamicConfigKeys bool,
+	auditLog *audit.Log,
 ) *Service {
 	return &Service{
+		log:               log,
 		repo:              repo,
 		environRepo:       environRepo,
 		configKeyRepo:     configKeyRepo,
 		auth:              auth,
+		audit:             auditLog,
 		DynamicConfigKeys: dynamicConfigKeys,
 	}
 }
 
-func (svc *Service) canConfigureEnvironment(
+// auditedValue returns a copy of cv to record in the audit log. Values of
+// sensitive keys and in sensitive environments are redacted since viewing the
+// audit log doesn't require access to them.
+func auditedValue(cv *ConfigValue, env environments.Environment, ck configkeys.ConfigKey) *ConfigValue {
+	if cv == nil {
+		return nil
+	}
+
+	audited := *cv
+	if ck.Sensitive || env.Sensitive {
+		audited.Redact()
+	}
+
+	return &audited
+}
+
+func (svc *Service) recordValueChange(
+	ctx context.Context,
+	actor auth.User,
+	action string,
+	env environments.Environment,
+	ck configkeys.ConfigKey,
+	before, after *ConfigValue,
+) {
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     action,
+		TargetKind: audit.TargetConfigValue,
+		TargetID:   strconv.Itoa(after.ID),
+		Before:     auditedValue(before, env, ck),
+		After:      auditedValue(after, env, ck),
+	})
+}
+
+// CanConfigureEnvironment returns nil if the actor is allowed to set config
+// values on env and auth.ErrUnauthorized otherwise. Permissions are checked
+// against env's service so service roles are taken into account, as are
+// write ACL entries on env.
+func (svc *Service) CanConfigureEnvironment(
 	ctx context.Context,
 	actor auth.User,
 	env environments.Environment,
 ) error {
-	canConfigure, err := svc.auth.HasPermission(ctx, actor, auth.PermissionConfigureEnvironments)
+	return svc.checkEnvironmentAccess(ctx, actor, env, auth.AccessWrite)
+}
+
+// CanReadEnvironment returns nil if the actor is allowed to read the config
+// values of env. Like environments.Service, sensitive environments are
+// reported as environments.ErrNotFound to actors who can read the service's
+// other environments so that their existence isn't leaked.
+func (svc *Service) CanReadEnvironment(
+	ctx context.Context,
+	actor auth.User,
+	env environments.Environment,
+) error {
+	return svc.checkEnvironmentAccess(ctx, actor, env, auth.AccessRead)
+}
+
+func (svc *Service) checkEnvironmentAccess(
+	ctx context.Context,
+	actor auth.User,
+	env environments.Environment,
+	needed auth.Access,
+) error {
+	// Scoped API tokens are limited to their environments whatever else the
+	// actor could do.
+	if !actor.Scopes.AllowsEnvironment(env.ServiceID, env.ID, needed) {
+		return auth.ErrUnauthorized
+	}
+
+	permission := auth.PermissionConfigureEnvironments
+	sensitivePermission := auth.PermissionConfigureSensitiveEnvironments
+	if needed == auth.AccessRead {
+		permission = auth.PermissionReadConfiguration
+		sensitivePermission = auth.PermissionReadSensitiveConfiguration
+	}
+
+	hasPermission, err := svc.auth.HasServicePermission(ctx, actor, env.ServiceID, permission)
 	if err != nil {
 		return err
 	}
 
-	canConfigureSensitive, err := svc.auth.HasPermission(ctx, actor, auth.PermissionConfigureEnvironments)
+	hasSensitivePermission, err := svc.auth.HasServicePermission(ctx, actor, env.ServiceID, sensitivePermission)
 	if err != nil {
 		return err
 	}
 
-	if !canConfigureSensitive && env.Sensitive {
-		return auth.ErrUnauthorized
+	if hasSensitivePermission || (hasPermission && !env.Sensitive) {
+		return nil
 	}
 
-	if canConfigure || canConfigureSensitive {
+	granted, err := svc.auth.GetEnvironmentAccess(ctx, actor)
+	if err != nil {
+		return err
+	}
+
+	if granted[env.ID].Allows(needed) {
 		return nil
 	}
 
+	if needed == auth.AccessRead && hasPermission {
+		return environments.ErrNotFound
+	}
+
 	return auth.ErrUnauthorized
 }
 
-func (svc *Service) SetConfigurationValue(
-	ctx context.Context,
-	actor auth.User,
-	envID int,
-	key string,
-	cv *ConfigValue,
-) (*ConfigValue, error) {
+// getReadableEnvironment looks up the environment and verifies that the actor
+// can read its configuration.
+func (svc *Service) getReadableEnvironment(ctx context.Context, actor auth.User, envID int) (environments.Environment, error) {
 	env, err := svc.environRepo.GetEnvironment(ctx, envID)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get environment by id: %s", err)
+		return environments.Environment{}, err
 	}
 
-	if authErr := svc.canConfigureEnvironment(ctx, actor, env); authErr != nil {
-		return nil, authErr
+	if err := svc.CanReadEnvironment(ctx, actor, env); err != nil {
+		return environments.Environment{}, err
 	}
 
-	cv.EnvironmentID = env.ID
+	return env, nil
+}
 
-	ck, err := svc.configKeyRepo.GetConfigKeyByName(ctx, env.Service, key)
-	shouldCreate := errors.Is(err, configkeys.ErrNotFound) && svc.DynamicConfigKeys
-	if shouldCreate {
-		if cv.ValueType == 0 {
-			return cv, ErrValueTypeMustBeSet
+// checkOverlay verifies that the overlay, if any, exists and belongs to the
+// same service as env.
+func (svc *Service) checkOverlay(ctx context.Context, env environments.Environment, overlayID *int) error {
+	if overlayID == nil {
+		return nil
+	}
+
+	overlay, err := svc.environRepo.GetOverlay(ctx, *overlayID)
+	if err != nil {
+		return err
+	}
+
+	if overlay.ServiceID != env.ServiceID {
+		return fmt.Errorf("%w: %s does not belong to service %s", environments.ErrInvalidOverlay, overlay.Selector(), env.Service)
+	}
+
+	return nil
+}
+
+// ResolveOverlays looks up the overlays for the environment's service from
+// their dimension:name selectors.
+func (svc *Service) ResolveOverlays(ctx context.Context, envID int, selectors ...string) ([]environments.Overlay, error) {
+	if len(selectors) == 0 {
+		return nil, nil
+	}
+
+	env, err := svc.environRepo.GetEnvironment(ctx, envID)
+	if err != nil {
+		return nil, err
+	}
+
+	overlays := make([]environments.Overlay, len(selectors))
+	for idx, selector := range selectors {
+		parsed, err := environments.ParseOverlaySelector(selector)
+		if err != nil {
+			return nil, err
 		}
 
-		ck = configkeys.New(env.ServiceID, key, cv.ValueType)
-		ck, err = svc.configKeyRepo.CreateConfigKey(ctx, ck)
+		overlays[idx], err = svc.environRepo.GetOverlayByName(ctx, env.ServiceID, parsed.Dimension, parsed.Name)
 		if err != nil {
-			return cv, fmt.Errorf("failed to create new config key: %w", err)
+			return nil, fmt.Errorf("%w: %s", err, selector)
 		}
+	}
+
+	return overlays, nil
+}
+
+// pendingValue is a value which has been validated but not yet written.
+type pendingValue struct {
+	cv *ConfigValue
+	// key has no ID when it will be created for DynamicConfigKeys.
+	key configkeys.ConfigKey
+	// before is the value being replaced, if there is one.
+	before *ConfigValue
+}
+
+// valueSlot identifies where a value is set on an environment.
+type valueSlot struct {
+	key       string
+	overlayID int
+}
+
+// valueBatch tracks the values being set together so that they can be
+// checked against each other before any of them are written.
+type valueBatch struct {
+	// newKeys are the config keys to create for DynamicConfigKeys.
+	newKeys []configkeys.ConfigKey
+	set     map[valueSlot]bool
+}
+
+func newValueBatch() *valueBatch {
+	return &valueBatch{set: make(map[valueSlot]bool)}
+}
+
+func (b *valueBatch) newKey(name string) (configkeys.ConfigKey, bool) {
+	for _, ck := range b.newKeys {
+		if ck.Name == name {
+			return ck, true
+		}
+	}
+
+	return configkeys.ConfigKey{}, false
+}
 
+// prepareValue validates cv as the value of key on env without writing
+// anything. The actor must already have been authorized to configure env.
+func (svc *Service) prepareValue(
+	ctx context.Context,
+	env environments.Environment,
+	batch *valueBatch,
+	key string,
+	cv *ConfigValue,
+) (pendingValue, error) {
+	cv.EnvironmentID = env.ID
+	cv.Name = key
+
+	slot := valueSlot{key: key}
+	if cv.OverlayID != nil {
+		slot.overlayID = *cv.OverlayID
+	}
+
+	if batch.set[slot] {
+		return pendingValue{}, fmt.Errorf("%w: %s is set more than once", ErrNotValid, key)
+	}
+
+	batch.set[slot] = true
+	if err := svc.checkOverlay(ctx, env, cv.OverlayID); err != nil {
+		return pendingValue{}, err
+	}
+
+	ck, err := svc.configKeyRepo.GetConfigKeyByName(ctx, env.Service, key)
+	shouldCreate := errors.Is(err, configkeys.ErrNotFound) && svc.DynamicConfigKeys
+	if shouldCreate {
+		var ok bool
+		ck, ok = batch.newKey(key)
+		if ok && cv.ValueType != 0 && cv.ValueType != ck.ValueType {
+			return pendingValue{}, fmt.Errorf("%w: %s is set with more than one ValueType", ErrNotValid, key)
+		} else if !ok && cv.ValueType == 0 {
+			return pendingValue{}, ErrValueTypeMustBeSet
+		} else if !ok {
+			ck = configkeys.New(env.ServiceID, key, cv.ValueType)
+			batch.newKeys = append(batch.newKeys, ck)
+		}
 	} else if err != nil {
-		return nil, fmt.Errorf("unable to retrieve config key: %w", err)
+		return pendingValue{}, fmt.Errorf("unable to retrieve config key: %w", err)
 	}
 
 	cv.ConfigKeyID = ck.ID
@@ -103,57 +299,185 @@ func (svc *Service) SetConfigurationValue(
 	// the validity check.
 	cv.ValueType = ck.ValueType
 	if err := cv.Valid(); err != nil {
+		return pendingValue{}, err
+	}
+
+	// Values are updated when they have an ID so it mustn't come from the
+	// client.
+	cv.ID = 0
+	pending := pendingValue{cv: cv, key: ck}
+	if shouldCreate {
+		return pending, nil
+	}
+
+	alreadySet, err := svc.repo.GetConfigValueByEnvAndKey(ctx, env.ID, key, cv.OverlayID)
+	if err == nil {
+		pending.before = alreadySet
+		cv.ID = alreadySet.ID
+	} else if !errors.Is(err, ErrNotFound) {
+		return pendingValue{}, err
+	}
+
+	return pending, nil
+}
+
+// writeValues creates the batch's new config keys and writes all of the
+// values in one transaction so that either all of them are set or none are.
+// Nothing is audited until it has been committed.
+func (svc *Service) writeValues(
+	ctx context.Context,
+	actor auth.User,
+	env environments.Environment,
+	batch *valueBatch,
+	pending []pendingValue,
+) ([]*ConfigValue, error) {
+	values := make([]*ConfigValue, len(pending))
+	for idx, value := range pending {
+		values[idx] = value.cv
+	}
+
+	created, results, err := svc.repo.SetConfigValues(ctx, batch.newKeys, values)
+	if err != nil {
 		return nil, err
 	}
 
-	var result *ConfigValue
-	alreadySet, err := svc.repo.GetConfigValueByEnvAndKey(ctx, envID, key)
+	createdByName := make(map[string]configkeys.ConfigKey, len(created))
+	for _, ck := range created {
+		createdByName[ck.Name] = ck
+		svc.audit.Record(ctx, actor, audit.Event{
+			Action:     audit.ActionConfigKeyCreate,
+			TargetKind: audit.TargetConfigKey,
+			TargetID:   strconv.Itoa(ck.ID),
+			After:      ck,
+		})
+	}
+
+	for idx, result := range results {
+		ck := pending[idx].key
+		if ck.ID == 0 {
+			ck = createdByName[ck.Name]
+		}
+
+		// Creating and updating values doesn't populate these.
+		result.ValueType = ck.ValueType
+		result.Name = ck.Name
+
+		svc.recordValueChange(ctx, actor, audit.ActionConfigValueSet, env, ck, pending[idx].before, result)
+	}
+
+	return results, nil
+}
+
+func (svc *Service) SetConfigurationValue(
+	ctx context.Context,
+	actor auth.User,
+	envID int,
+	key string,
+	cv *ConfigValue,
+) (*ConfigValue, error) {
+	env, err := svc.environRepo.GetEnvironment(ctx, envID)
 	if err != nil {
-		result, err = svc.repo.CreateConfigValue(ctx, cv)
-	} else {
-		cv.ID = alreadySet.ID
-		result, err = svc.repo.UpdateConfigurationValue(ctx, cv)
+		return nil, fmt.Errorf("failed to get environment by id: %s", err)
 	}
 
-	// Create and Update ConfigValue do not always populate these.
-	result.ValueType = ck.ValueType
-	result.Name = ck.Name
+	if authErr := svc.CanConfigureEnvironment(ctx, actor, env); authErr != nil {
+		return nil, authErr
+	}
 
-	return result, err
+	batch := newValueBatch()
+	pending, err := svc.prepareValue(ctx, env, batch, key, cv)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := svc.writeValues(ctx, actor, env, batch, []pendingValue{pending})
+	if err != nil {
+		return nil, err
+	}
+
+	return results[0], nil
 }
 
+// SetConfigurationValues sets every value on the environment. All of the
+// values are validated before any are written and they're written in one
+// transaction so a failure never leaves some of them set.
 func (svc *Service) SetConfigurationValues(
 	ctx context.Context,
 	actor auth.User,
 	envID int,
 	values []*ConfigValue,
 ) ([]*ConfigValue, error) {
-	results := make([]*ConfigValue, 0, len(values))
+	env, err := svc.environRepo.GetEnvironment(ctx, envID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get environment by id: %s", err)
+	}
 
-	for _, value := range values {
-		// Inherited values shouldn't be updated this way but should be returned
-		// to the client.
-		if value.Inherited {
-			results = append(results, value)
+	if authErr := svc.CanConfigureEnvironment(ctx, actor, env); authErr != nil {
+		return nil, authErr
+	}
+
+	batch := newValueBatch()
+	results := make([]*ConfigValue, len(values))
+	pending := make([]pendingValue, 0, len(values))
+	positions := make([]int, 0, len(values))
+	for idx, value := range values {
+		// Inherited and redacted values shouldn't be updated this way but
+		// should be returned to the client.
+		if value.Inherited || value.Redacted {
+			results[idx] = value
 			continue
 		}
 
-		cv, err := svc.SetConfigurationValue(
-			ctx,
-			actor,
-			envID,
-			value.Name,
-			value,
-		)
+		prepared, err := svc.prepareValue(ctx, env, batch, value.Name, value)
 		if err != nil {
-			return results, err
+			return nil, err
 		}
 
-		results = append(results, cv)
+		pending = append(pending, prepared)
+		positions = append(positions, idx)
+	}
+
+	written, err := svc.writeValues(ctx, actor, env, batch, pending)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx, cv := range written {
+		results[positions[idx]] = cv
 	}
 
 	return results, nil
+}
 
+// ValidateConfigurationValues checks that SetConfigurationValues would accept
+// the values for the environment without writing anything or changing the
+// values. It's used to reject scheduled changes up front, the caller must
+// authorize the actor. Unknown keys and overlays are reported as ErrNotValid
+// since they're mistakes in the values.
+func (svc *Service) ValidateConfigurationValues(ctx context.Context, envID int, values []*ConfigValue) error {
+	env, err := svc.environRepo.GetEnvironment(ctx, envID)
+	if err != nil {
+		return err
+	}
+
+	batch := newValueBatch()
+	for _, value := range values {
+		if value.Inherited || value.Redacted {
+			continue
+		}
+
+		copied := *value
+		_, err := svc.prepareValue(ctx, env, batch, value.Name, &copied)
+		if errors.Is(err, configkeys.ErrNotFound) ||
+			errors.Is(err, environments.ErrOverlayNotFound) ||
+			errors.Is(err, ErrValueTypeMustBeSet) {
+			return fmt.Errorf("%w: %s", ErrNotValid, err)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
 func (svc *Service) CreateConfigValue(
@@ -166,10 +490,14 @@ func (svc *Service) CreateConfigValue(
 		return ConfigValue{}, err
 	}
 
-	if authErr := svc.canConfigureEnvironment(ctx, actor, env); authErr != nil {
+	if authErr := svc.CanConfigureEnvironment(ctx, actor, env); authErr != nil {
 		return ConfigValue{}, authErr
 	}
 
+	if err := svc.checkOverlay(ctx, env, cv.OverlayID); err != nil {
+		return ConfigValue{}, err
+	}
+
 	ck, err := svc.configKeyRepo.GetConfigKey(ctx, cv.ConfigKeyID)
 	if err != nil {
 		return ConfigValue{}, err
@@ -186,18 +514,92 @@ func (svc *Service) CreateConfigValue(
 		return ConfigValue{}, err
 	}
 
+	svc.recordValueChange(ctx, actor, audit.ActionConfigValueCreate, env, ck, nil, created)
 	return *created, nil
 }
 
-// TODO: there isn't really a concept of permissions for reading configuration
-// yet and it's unclear if there ever will be. But these methods exist to future
-// proof for it and to simplify things so that the API struct never needs to
-// talk to a repository directly.
+// GetConfiguration returns the resolved configuration for envID if the actor
+// can read it. Values of sensitive keys are redacted unless reveal is set, see
+// protectSensitiveValues.
+func (svc *Service) GetConfiguration(
+	ctx context.Context,
+	actor auth.User,
+	envID int,
+	reveal bool,
+	overlaySelectors ...string,
+) ([]ConfigValue, error) {
+	env, err := svc.getReadableEnvironment(ctx, actor, envID)
+	if err != nil {
+		return nil, err
+	}
+
+	overlays, err := svc.ResolveOverlays(ctx, envID, overlaySelectors...)
+	if err != nil {
+		return nil, err
+	}
+
+	values, err := svc.repo.GetConfiguration(ctx, envID, overlays...)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.protectSensitiveValues(ctx, actor, env, reveal, values); err != nil {
+		return nil, err
+	}
+
+	return values, nil
+}
+
+func (svc *Service) GetConfigurationValue(
+	ctx context.Context,
+	actor auth.User,
+	envID int,
+	key string,
+	reveal bool,
+	overlaySelectors ...string,
+) (*ConfigValue, error) {
+	env, err := svc.getReadableEnvironment(ctx, actor, envID)
+	if err != nil {
+		return nil, err
+	}
+
+	overlays, err := svc.ResolveOverlays(ctx, envID, overlaySelectors...)
+	if err != nil {
+		return nil, err
+	}
+
+	cv, err := svc.repo.GetConfigurationValue(ctx, envID, key, overlays...)
+	if err != nil {
+		return nil, err
+	}
 
-func (svc *Service) GetConfiguration(ctx context.Context, actor auth.User, envID int) ([]ConfigValue, error) {
-	return svc.repo.GetConfiguration(ctx, envID)
+	values := []ConfigValue{*cv}
+	if err := svc.protectSensitiveValues(ctx, actor, env, reveal, values); err != nil {
+		return nil, err
+	}
+
+	return &values[0], nil
 }
 
-func (svc *Service) GetConfigurationValue(ctx context.Context, actor auth.User, envID int, key string) (*ConfigValue, error) {
-	return svc.repo.GetConfigurationValue(ctx, envID, key)
+// EvaluateConfiguration returns the configuration for envID with targeting
+// rules resolved against evalCtx.
+func (svc *Service) EvaluateConfiguration(
+	ctx context.Context,
+	actor auth.User,
+	envID int,
+	evalCtx EvaluationContext,
+	reveal bool,
+	overlaySelectors ...string,
+) ([]ConfigValue, error) {
+	values, err := svc.GetConfiguration(ctx, actor, envID, reveal, overlaySelectors...)
+	if err != nil {
+		return nil, err
+	}
+
+	evaluated := make([]ConfigValue, len(values))
+	for idx := range values {
+		evaluated[idx] = values[idx].Evaluate(evalCtx)
+	}
+
+	return evaluated, nil
 }
@@ -3,8 +3,10 @@ package configvalues_test
 import (
 	"context"
 	"errors"
+	"strings"
 	"testing"
 
+	"github.com/config-source/cdb/pkg/audit"
 	"github.com/config-source/cdb/pkg/auth"
 	"github.com/config-source/cdb/pkg/configkeys"
 	"github.com/config-source/cdb/pkg/configvalues"
@@ -61,7 +63,7 @@ func TestServiceCreatesConfigKeyWhenDynamicConfigKeysIsTrue(t *testing.T) {
 	setupBasicService(t, tc)
 
 	gateway := auth.NewTestGateway()
-	service := configvalues.NewService(tc.valueRepo, tc.environmentRepo, tc.keyRepo, gateway, true)
+	service := configvalues.NewService(testLogger, tc.valueRepo, tc.environmentRepo, tc.keyRepo, gateway, true, nil)
 	val := 10
 	cv, err := service.SetConfigurationValue(
 		context.Background(),
@@ -91,16 +93,52 @@ func TestServiceCreatesConfigKeyWhenDynamicConfigKeysIsTrue(t *testing.T) {
 	}
 }
 
+func TestServiceRecreatesConfigKeysInTheTrashWhenDynamicConfigKeysIsTrue(t *testing.T) {
+	tc := initTestDB(t)
+	setupBasicService(t, tc)
+
+	owner, err := tc.keyRepo.GetConfigKeyByName(context.Background(), "test", "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tc.keyRepo.SoftDeleteConfigKey(context.Background(), owner.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	service := configvalues.NewService(testLogger, tc.valueRepo, tc.environmentRepo, tc.keyRepo, auth.NewTestGateway(), true, nil)
+	val := "jane"
+	cv, err := service.SetConfigurationValue(
+		context.Background(),
+		auth.User{},
+		2,
+		"owner",
+		&configvalues.ConfigValue{
+			ValueType: configkeys.TypeString,
+			StrValue:  &val,
+		},
+	)
+	if err != nil {
+		t.Fatalf("Failed to set configuration value: %s", err)
+	}
+
+	if cv.ConfigKeyID == owner.ID {
+		t.Fatalf("Expected a new owner key to be created got: %s", cv)
+	}
+}
+
 func TestServiceReturnsErrorWhenDynamicConfigKeysIsFalse(t *testing.T) {
 	tc := initTestDB(t)
 	setupBasicService(t, tc)
 
 	service := configvalues.NewService(
+		testLogger,
 		tc.valueRepo,
 		tc.environmentRepo,
 		tc.keyRepo,
 		auth.NewTestGateway(),
 		false,
+		nil,
 	)
 	val := 10
 	_, err := service.SetConfigurationValue(
@@ -123,11 +161,13 @@ func TestServiceReturnsErrorWhenValueTypeIsNotValid(t *testing.T) {
 	setupBasicService(t, tc)
 
 	service := configvalues.NewService(
+		testLogger,
 		tc.valueRepo,
 		tc.environmentRepo,
 		tc.keyRepo,
 		auth.NewTestGateway(),
 		false,
+		nil,
 	)
 	val := "test"
 	_, err := service.SetConfigurationValue(
@@ -144,3 +184,632 @@ func TestServiceReturnsErrorWhenValueTypeIsNotValid(t *testing.T) {
 		t.Fatalf("Expected %s got: %s", configvalues.ErrNotValid, err)
 	}
 }
+
+func TestSetConfigurationValuesSetsAllOrNothing(t *testing.T) {
+	tc := initTestDB(t)
+	setupBasicService(t, tc)
+
+	service := configvalues.NewService(testLogger, tc.valueRepo, tc.environmentRepo, tc.keyRepo, auth.NewTestGateway(), true, nil)
+	owner := &configvalues.ConfigValue{Name: "owner"}
+	owner.SetStrValue("platform")
+	replicas := &configvalues.ConfigValue{Name: "maxReplicas"}
+	replicas.SetStrValue("ten")
+	dynamic := &configvalues.ConfigValue{Name: "minReplicas"}
+	dynamic.SetIntValue(1)
+
+	_, err := service.SetConfigurationValues(
+		context.Background(),
+		auth.User{},
+		2,
+		[]*configvalues.ConfigValue{owner, dynamic, replicas},
+	)
+	if !errors.Is(err, configvalues.ErrNotValid) {
+		t.Fatalf("Expected %s got: %v", configvalues.ErrNotValid, err)
+	}
+
+	if _, err := tc.valueRepo.GetConfigValueByEnvAndKey(context.Background(), 2, "owner", nil); !errors.Is(err, configvalues.ErrNotFound) {
+		t.Errorf("Expected owner not to be set got: %v", err)
+	}
+
+	if _, err := tc.keyRepo.GetConfigKeyByName(context.Background(), "test", "minReplicas"); !errors.Is(err, configkeys.ErrNotFound) {
+		t.Errorf("Expected minReplicas not to be created got: %v", err)
+	}
+}
+
+func TestSetConfigurationValuesRejectsConflictingValues(t *testing.T) {
+	tc := initTestDB(t)
+	setupBasicService(t, tc)
+
+	staging, err := tc.environmentRepo.GetEnvironment(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	overlay, err := tc.environmentRepo.CreateOverlay(context.Background(), environments.Overlay{
+		ServiceID: staging.ServiceID,
+		Dimension: "region",
+		Name:      "eu",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := configvalues.NewService(testLogger, tc.valueRepo, tc.environmentRepo, tc.keyRepo, auth.NewTestGateway(), true, nil)
+	tests := map[string]func() []*configvalues.ConfigValue{
+		"same key": func() []*configvalues.ConfigValue {
+			first := &configvalues.ConfigValue{Name: "owner"}
+			first.SetStrValue("platform")
+			second := &configvalues.ConfigValue{Name: "owner"}
+			second.SetStrValue("payments")
+			return []*configvalues.ConfigValue{first, second}
+		},
+		"new key with different types": func() []*configvalues.ConfigValue {
+			first := &configvalues.ConfigValue{Name: "minReplicas"}
+			first.SetIntValue(1)
+			overlaid := &configvalues.ConfigValue{Name: "minReplicas"}
+			overlaid.SetStrValue("one")
+			overlaid.OverlayID = &overlay.ID
+			return []*configvalues.ConfigValue{first, overlaid}
+		},
+	}
+
+	for name, values := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := service.SetConfigurationValues(context.Background(), auth.User{}, 2, values())
+			if !errors.Is(err, configvalues.ErrNotValid) {
+				t.Fatalf("Expected %s got: %v", configvalues.ErrNotValid, err)
+			}
+
+			if _, err := tc.valueRepo.GetConfigValueByEnvAndKey(context.Background(), 2, "owner", nil); !errors.Is(err, configvalues.ErrNotFound) {
+				t.Errorf("Expected owner not to be set got: %v", err)
+			}
+
+			if _, err := tc.keyRepo.GetConfigKeyByName(context.Background(), "test", "minReplicas"); !errors.Is(err, configkeys.ErrNotFound) {
+				t.Errorf("Expected minReplicas not to be created got: %v", err)
+			}
+		})
+	}
+}
+
+func TestValuesInSensitiveEnvironmentsAreAuditedRedacted(t *testing.T) {
+	tc := initTestDB(t)
+
+	svc := svcFixture(t, tc.serviceRepo, "svc1")
+	production, err := tc.environmentRepo.CreateEnvironment(context.Background(), environments.Environment{
+		Name:      "production",
+		ServiceID: svc.ID,
+		Sensitive: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	configKeyFixture(t, tc.keyRepo, svc.ID, "password", configkeys.TypeString, true)
+	service := configvalues.NewService(testLogger, tc.valueRepo, tc.environmentRepo, tc.keyRepo, auth.NewTestGateway(), false, tc.auditLog)
+
+	for _, password := range []string{"hunter2", "hunter3"} {
+		cv := &configvalues.ConfigValue{}
+		cv.SetStrValue(password)
+		if _, err := service.SetConfigurationValue(context.Background(), auth.User{}, production.ID, "password", cv); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	entries, err := tc.auditLog.List(context.Background(), audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 2 {
+		t.Fatalf("Expected both values to be audited got: %d entries", len(entries))
+	}
+
+	for _, entry := range entries {
+		if strings.Contains(string(entry.Before)+string(entry.After), "hunter") {
+			t.Errorf("Expected %s entry to be redacted got: %s %s", entry.Action, entry.Before, entry.After)
+		}
+	}
+}
+
+func TestCloneEnvironment(t *testing.T) {
+	for _, flatten := range []bool{false, true} {
+		tc := initTestDB(t)
+
+		svc := svcFixture(t, tc.serviceRepo, "svc1")
+		production := envFixture(t, tc.environmentRepo, "production", nil, svc.ID)
+		staging := envFixture(t, tc.environmentRepo, "staging", &production.ID, svc.ID)
+
+		owner := configKeyFixture(t, tc.keyRepo, svc.ID, "owner", configkeys.TypeString, true)
+		maxReplicas := configKeyFixture(t, tc.keyRepo, svc.ID, "maxReplicas", configkeys.TypeInteger, true)
+
+		createConfigValue(t, tc.valueRepo, configvalues.NewString(production.ID, owner.ID, "SRE"))
+		createConfigValue(t, tc.valueRepo, configvalues.NewInt(staging.ID, maxReplicas.ID, 50))
+
+		service := configvalues.NewService(testLogger, tc.valueRepo, tc.environmentRepo, tc.keyRepo, auth.NewTestGateway(), false, nil)
+		clone, err := service.CloneEnvironment(context.Background(), auth.User{}, staging.ID, configvalues.CloneRequest{
+			Name:             "qa",
+			FlattenInherited: flatten,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if clone.ServiceID != svc.ID || clone.PromotesToID != nil {
+			t.Fatalf("Expected a root environment in service %d got: %+v", svc.ID, clone)
+		}
+
+		replicas, err := tc.valueRepo.GetConfigValueByEnvAndKey(context.Background(), clone.ID, "maxReplicas", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if *replicas.IntValue != 50 {
+			t.Fatalf("Expected maxReplicas to be copied got: %d", *replicas.IntValue)
+		}
+
+		_, err = tc.valueRepo.GetConfigValueByEnvAndKey(context.Background(), clone.ID, "owner", nil)
+		if flatten && err != nil {
+			t.Fatalf("Expected inherited owner to be flattened got: %s", err)
+		} else if !flatten && !errors.Is(err, configvalues.ErrNotFound) {
+			t.Fatalf("Expected %s got: %s", configvalues.ErrNotFound, err)
+		}
+	}
+}
+
+func TestCloneEnvironmentRejectsDuplicateNames(t *testing.T) {
+	tc := initTestDB(t)
+
+	svc := svcFixture(t, tc.serviceRepo, "svc1")
+	production := envFixture(t, tc.environmentRepo, "production", nil, svc.ID)
+
+	service := configvalues.NewService(testLogger, tc.valueRepo, tc.environmentRepo, tc.keyRepo, auth.NewTestGateway(), false, nil)
+	_, err := service.CloneEnvironment(context.Background(), auth.User{}, production.ID, configvalues.CloneRequest{
+		Name: "production",
+	})
+	if err == nil {
+		t.Fatal("Expected cloning to an existing name to fail")
+	}
+
+	envs, err := tc.environmentRepo.ListEnvironments(context.Background(), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(envs) != 1 {
+		t.Fatalf("Expected only the source environment to exist got: %+v", envs)
+	}
+}
+
+func TestPreviewEnvironmentUpdateDiffsSubtree(t *testing.T) {
+	tc := initTestDB(t)
+
+	svc := svcFixture(t, tc.serviceRepo, "svc1")
+	production := envFixture(t, tc.environmentRepo, "production", nil, svc.ID)
+	sandbox := envFixture(t, tc.environmentRepo, "sandbox", nil, svc.ID)
+	staging := envFixture(t, tc.environmentRepo, "staging", &production.ID, svc.ID)
+	dev := envFixture(t, tc.environmentRepo, "dev", &staging.ID, svc.ID)
+
+	owner := configKeyFixture(t, tc.keyRepo, svc.ID, "owner", configkeys.TypeString, true)
+	maxReplicas := configKeyFixture(t, tc.keyRepo, svc.ID, "maxReplicas", configkeys.TypeInteger, true)
+
+	createConfigValue(t, tc.valueRepo, configvalues.NewString(production.ID, owner.ID, "SRE"))
+	createConfigValue(t, tc.valueRepo, configvalues.NewInt(production.ID, maxReplicas.ID, 100))
+	createConfigValue(t, tc.valueRepo, configvalues.NewInt(sandbox.ID, maxReplicas.ID, 1))
+
+	service := configvalues.NewService(testLogger, tc.valueRepo, tc.environmentRepo, tc.keyRepo, auth.NewTestGateway(), false, nil)
+
+	reparented := staging
+	reparented.PromotesToID = &sandbox.ID
+	preview, err := service.PreviewEnvironmentUpdate(context.Background(), auth.User{}, reparented)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(preview.Diffs) != 2 || preview.Diffs[0].Environment.ID != staging.ID || preview.Diffs[1].Environment.ID != dev.ID {
+		t.Fatalf("Expected diffs for staging and dev got: %+v", preview.Diffs)
+	}
+
+	for _, diff := range preview.Diffs {
+		if len(diff.Changes) != 2 {
+			t.Fatalf("Expected 2 changes for %s got: %+v", diff.Environment.Name, diff.Changes)
+		}
+
+		replicas, removedOwner := diff.Changes[0], diff.Changes[1]
+		if replicas.Key != "maxReplicas" || *replicas.Before.IntValue != 100 || *replicas.After.IntValue != 1 {
+			t.Fatalf("Expected maxReplicas to change from 100 to 1 got: %+v", replicas)
+		}
+
+		if removedOwner.Key != "owner" || removedOwner.After != nil {
+			t.Fatalf("Expected owner to be removed got: %+v", removedOwner)
+		}
+	}
+
+	// Nothing should actually have changed.
+	stored, err := tc.environmentRepo.GetEnvironment(context.Background(), staging.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *stored.PromotesToID != production.ID {
+		t.Fatalf("Expected preview not to update the environment got: %+v", stored)
+	}
+
+	cyclic := production
+	cyclic.PromotesToID = &dev.ID
+	_, err = service.PreviewEnvironmentUpdate(context.Background(), auth.User{}, cyclic)
+	if !errors.Is(err, environments.ErrPromotionCycle) {
+		t.Fatalf("Expected %s got: %s", environments.ErrPromotionCycle, err)
+	}
+}
+
+func TestPreviewEnvironmentDelete(t *testing.T) {
+	tc := initTestDB(t)
+
+	svc := svcFixture(t, tc.serviceRepo, "svc1")
+	production := envFixture(t, tc.environmentRepo, "production", nil, svc.ID)
+	staging := envFixture(t, tc.environmentRepo, "staging", &production.ID, svc.ID)
+	dev := envFixture(t, tc.environmentRepo, "dev", &staging.ID, svc.ID)
+
+	owner := configKeyFixture(t, tc.keyRepo, svc.ID, "owner", configkeys.TypeString, true)
+	createConfigValue(t, tc.valueRepo, configvalues.NewString(production.ID, owner.ID, "SRE"))
+
+	service := configvalues.NewService(testLogger, tc.valueRepo, tc.environmentRepo, tc.keyRepo, auth.NewTestGateway(), false, nil)
+	preview, err := service.PreviewEnvironmentDelete(context.Background(), auth.User{}, production.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(preview.Descendants) != 2 || preview.Descendants[0].ID != staging.ID || preview.Descendants[1].ID != dev.ID {
+		t.Fatalf("Expected staging and dev to be affected got: %+v", preview.Descendants)
+	}
+
+	if len(preview.Values) != 1 || preview.Values[0].Name != "owner" {
+		t.Fatalf("Expected owner to be destroyed got: %+v", preview.Values)
+	}
+}
+
+func TestExportConfigurationBySelector(t *testing.T) {
+	tc := initTestDB(t)
+
+	svc := svcFixture(t, tc.serviceRepo, "svc1")
+	production, err := tc.environmentRepo.CreateEnvironment(context.Background(), environments.Environment{
+		Name:      "production",
+		ServiceID: svc.ID,
+		Labels:    environments.Labels{"tier": "prod"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	envFixture(t, tc.environmentRepo, "staging", &production.ID, svc.ID)
+
+	owner := configKeyFixture(t, tc.keyRepo, svc.ID, "owner", configkeys.TypeString, true)
+	createConfigValue(t, tc.valueRepo, configvalues.NewString(production.ID, owner.ID, "SRE"))
+
+	selector, err := environments.ParseLabelSelector("tier=prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	service := configvalues.NewService(testLogger, tc.valueRepo, tc.environmentRepo, tc.keyRepo, auth.NewTestGateway(), false, nil)
+	exported, err := service.ExportConfiguration(context.Background(), auth.User{}, selector, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(exported) != 1 || exported[0].Environment.ID != production.ID {
+		t.Fatalf("Expected only production to be exported got: %+v", exported)
+	}
+
+	if len(exported[0].Values) != 1 || *exported[0].Values[0].StrValue != "SRE" {
+		t.Fatalf("Expected production's values to be exported got: %+v", exported[0].Values)
+	}
+}
+
+func TestCanConfigureEnvironmentUsesServiceRoles(t *testing.T) {
+	gateway := auth.NewTestGateway()
+	gateway.DenyPermissionCheck = true
+	gateway.ServicePermissions[1] = []auth.Permission{auth.PermissionConfigureEnvironments}
+	gateway.ServicePermissions[2] = []auth.Permission{auth.PermissionConfigureSensitiveEnvironments}
+
+	service := configvalues.NewService(testLogger, nil, nil, nil, gateway, false, nil)
+
+	tests := []struct {
+		env      environments.Environment
+		expected error
+	}{
+		{environments.Environment{ServiceID: 1}, nil},
+		{environments.Environment{ServiceID: 1, Sensitive: true}, auth.ErrUnauthorized},
+		{environments.Environment{ServiceID: 2, Sensitive: true}, nil},
+		{environments.Environment{ServiceID: 3}, auth.ErrUnauthorized},
+	}
+
+	for _, tc := range tests {
+		err := service.CanConfigureEnvironment(context.Background(), auth.User{}, tc.env)
+		if !errors.Is(err, tc.expected) {
+			t.Errorf("Expected %v for service %d (sensitive=%t) got: %v", tc.expected, tc.env.ServiceID, tc.env.Sensitive, err)
+		}
+	}
+}
+
+func TestCanConfigureEnvironmentUsesACLEntries(t *testing.T) {
+	gateway := auth.NewTestGateway()
+	gateway.DenyPermissionCheck = true
+	gateway.EnvironmentAccess[1] = auth.AccessWrite
+	gateway.EnvironmentAccess[2] = auth.AccessRead
+
+	service := configvalues.NewService(testLogger, nil, nil, nil, gateway, false, nil)
+
+	tests := []struct {
+		env      environments.Environment
+		expected error
+	}{
+		{environments.Environment{ID: 1, ServiceID: 1, Sensitive: true}, nil},
+		{environments.Environment{ID: 2, ServiceID: 1}, auth.ErrUnauthorized},
+		{environments.Environment{ID: 3, ServiceID: 1}, auth.ErrUnauthorized},
+	}
+
+	for _, tc := range tests {
+		err := service.CanConfigureEnvironment(context.Background(), auth.User{}, tc.env)
+		if !errors.Is(err, tc.expected) {
+			t.Errorf("Expected %v for environment %d got: %v", tc.expected, tc.env.ID, err)
+		}
+	}
+}
+
+func TestCanConfigureEnvironmentIsLimitedByScopes(t *testing.T) {
+	gateway := auth.NewTestGateway()
+	gateway.EnvironmentAccess[3] = auth.AccessWrite
+
+	service := configvalues.NewService(testLogger, nil, nil, nil, gateway, false, nil)
+
+	staging := 1
+	actor := auth.User{Scopes: auth.Scopes{
+		{ServiceID: 1, EnvironmentID: &staging, Access: auth.AccessWrite},
+		{ServiceID: 2, Access: auth.AccessRead},
+	}}
+
+	tests := []struct {
+		env      environments.Environment
+		expected error
+	}{
+		{environments.Environment{ID: 1, ServiceID: 1}, nil},
+		{environments.Environment{ID: 2, ServiceID: 1}, auth.ErrUnauthorized},
+		{environments.Environment{ID: 3, ServiceID: 1}, auth.ErrUnauthorized},
+		{environments.Environment{ID: 4, ServiceID: 2}, auth.ErrUnauthorized},
+	}
+
+	for _, tc := range tests {
+		err := service.CanConfigureEnvironment(context.Background(), actor, tc.env)
+		if !errors.Is(err, tc.expected) {
+			t.Errorf("Expected %v for environment %d got: %v", tc.expected, tc.env.ID, err)
+		}
+	}
+}
+
+func TestGetConfigurationRequiresReadPermission(t *testing.T) {
+	tc := initTestDB(t)
+
+	svc := svcFixture(t, tc.serviceRepo, "svc1")
+	staging := envFixture(t, tc.environmentRepo, "staging", nil, svc.ID)
+	production, err := tc.environmentRepo.CreateEnvironment(context.Background(), environments.Environment{
+		Name:      "production",
+		ServiceID: svc.ID,
+		Sensitive: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner := configKeyFixture(t, tc.keyRepo, svc.ID, "owner", configkeys.TypeString, true)
+	createConfigValue(t, tc.valueRepo, configvalues.NewString(staging.ID, owner.ID, "SRE"))
+	createConfigValue(t, tc.valueRepo, configvalues.NewString(production.ID, owner.ID, "SRE"))
+
+	gateway := auth.NewTestGateway()
+	gateway.DenyPermissionCheck = true
+	service := configvalues.NewService(testLogger, tc.valueRepo, tc.environmentRepo, tc.keyRepo, gateway, false, nil)
+
+	_, err = service.GetConfiguration(context.Background(), auth.User{}, staging.ID, false)
+	if !errors.Is(err, auth.ErrUnauthorized) {
+		t.Errorf("Expected %s without read permission got: %v", auth.ErrUnauthorized, err)
+	}
+
+	gateway.ServicePermissions[svc.ID] = []auth.Permission{auth.PermissionReadConfiguration}
+
+	values, err := service.GetConfiguration(context.Background(), auth.User{}, staging.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(values) != 1 {
+		t.Errorf("Expected staging's values to be readable got: %+v", values)
+	}
+
+	_, err = service.GetConfigurationValue(context.Background(), auth.User{}, production.ID, "owner", false)
+	if !errors.Is(err, environments.ErrNotFound) {
+		t.Errorf("Expected %s for a sensitive environment got: %v", environments.ErrNotFound, err)
+	}
+
+	gateway.ServicePermissions[svc.ID] = append(gateway.ServicePermissions[svc.ID], auth.PermissionReadSensitiveConfiguration)
+
+	cv, err := service.GetConfigurationValue(context.Background(), auth.User{}, production.ID, "owner", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *cv.StrValue != "SRE" {
+		t.Errorf("Expected SRE got: %s", cv)
+	}
+}
+
+func TestSensitiveValuesAreRedactedUnlessRevealed(t *testing.T) {
+	tc := initTestDB(t)
+
+	svc := svcFixture(t, tc.serviceRepo, "svc1")
+	production := envFixture(t, tc.environmentRepo, "production", nil, svc.ID)
+
+	owner := configKeyFixture(t, tc.keyRepo, svc.ID, "owner", configkeys.TypeString, true)
+	customerIDKey := configkeys.New(svc.ID, "partnerCustomerID", configkeys.TypeString)
+	customerIDKey.Sensitive = true
+	customerIDKey, err := tc.keyRepo.CreateConfigKey(context.Background(), customerIDKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createConfigValue(t, tc.valueRepo, configvalues.NewString(production.ID, owner.ID, "SRE"))
+	createConfigValue(t, tc.valueRepo, configvalues.NewString(production.ID, customerIDKey.ID, "cust-1234"))
+
+	gateway := auth.NewTestGateway()
+	service := configvalues.NewService(testLogger, tc.valueRepo, tc.environmentRepo, tc.keyRepo, gateway, false, tc.auditLog)
+
+	values, err := service.GetConfiguration(context.Background(), auth.User{}, production.ID, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, cv := range values {
+		switch cv.Name {
+		case "owner":
+			if cv.Redacted || *cv.StrValue != "SRE" {
+				t.Errorf("Expected owner not to be redacted got: %+v", cv)
+			}
+		case "partnerCustomerID":
+			if !cv.Redacted || cv.StrValue != nil {
+				t.Errorf("Expected partnerCustomerID to be redacted got: %+v", cv)
+			}
+		}
+	}
+
+	cv, err := service.GetConfigurationValue(context.Background(), auth.User{}, production.ID, "partnerCustomerID", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cv.Redacted || *cv.StrValue != "cust-1234" {
+		t.Errorf("Expected partnerCustomerID to be revealed got: %+v", cv)
+	}
+
+	gateway.DenyPermissionCheck = true
+	gateway.ServicePermissions[svc.ID] = []auth.Permission{auth.PermissionReadConfiguration}
+
+	_, err = service.GetConfigurationValue(context.Background(), auth.User{}, production.ID, "partnerCustomerID", true)
+	if !errors.Is(err, auth.ErrUnauthorized) {
+		t.Errorf("Expected %s revealing without permission got: %v", auth.ErrUnauthorized, err)
+	}
+
+	entries, err := tc.auditLog.List(context.Background(), audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+		if strings.Contains(string(entry.After), "cust-1234") {
+			t.Errorf("Expected the revealed value not to be audited got: %s", entry.After)
+		}
+	}
+
+	expected := []string{audit.ActionConfigValueRevealDenied, audit.ActionConfigValueReveal}
+	if strings.Join(actions, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v to be audited got: %v", expected, actions)
+	}
+}
+
+func TestExportRedactsServicesValuesCannotBeRevealedIn(t *testing.T) {
+	tc := initTestDB(t)
+	selector, err := environments.ParseLabelSelector("tier=prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gateway := auth.NewTestGateway()
+	gateway.DenyPermissionCheck = true
+	for _, name := range []string{"revealable", "redacted"} {
+		svc := svcFixture(t, tc.serviceRepo, name)
+		production, err := tc.environmentRepo.CreateEnvironment(context.Background(), environments.Environment{
+			Name:      "production",
+			ServiceID: svc.ID,
+			Labels:    environments.Labels{"tier": "prod"},
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		password := configkeys.New(svc.ID, "password", configkeys.TypeString)
+		password.Sensitive = true
+		password, err = tc.keyRepo.CreateConfigKey(context.Background(), password)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		createConfigValue(t, tc.valueRepo, configvalues.NewString(production.ID, password.ID, "hunter2"))
+		gateway.ServicePermissions[svc.ID] = []auth.Permission{auth.PermissionReadConfiguration}
+		if name == "revealable" {
+			gateway.ServicePermissions[svc.ID] = append(gateway.ServicePermissions[svc.ID], auth.PermissionRevealSensitiveValues)
+		}
+	}
+
+	service := configvalues.NewService(testLogger, tc.valueRepo, tc.environmentRepo, tc.keyRepo, gateway, false, nil)
+	exported, err := service.ExportConfiguration(context.Background(), auth.User{}, selector, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(exported) != 2 {
+		t.Fatalf("Expected both services to be exported got: %+v", exported)
+	}
+
+	for _, env := range exported {
+		cv := env.Values[0]
+		revealed := !cv.Redacted && cv.StrValue != nil && *cv.StrValue == "hunter2"
+		if expected := env.Environment.Service == "revealable"; revealed != expected {
+			t.Errorf("Expected the password in %s to be revealed: %t got: %+v", env.Environment.Service, expected, cv)
+		}
+	}
+}
+
+func TestPreviewsRedactSensitiveValues(t *testing.T) {
+	tc := initTestDB(t)
+
+	svc := svcFixture(t, tc.serviceRepo, "svc1")
+	production := envFixture(t, tc.environmentRepo, "production", nil, svc.ID)
+	staging := envFixture(t, tc.environmentRepo, "staging", nil, svc.ID)
+
+	apiKey := configkeys.New(svc.ID, "apiKey", configkeys.TypeString)
+	apiKey.Sensitive = true
+	apiKey, err := tc.keyRepo.CreateConfigKey(context.Background(), apiKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	createConfigValue(t, tc.valueRepo, configvalues.NewString(production.ID, apiKey.ID, "prod-secret"))
+
+	service := configvalues.NewService(testLogger, tc.valueRepo, tc.environmentRepo, tc.keyRepo, auth.NewTestGateway(), false, nil)
+
+	deletePreview, err := service.PreviewEnvironmentDelete(context.Background(), auth.User{}, production.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(deletePreview.Values) != 1 || !deletePreview.Values[0].Redacted || deletePreview.Values[0].StrValue != nil {
+		t.Fatalf("Expected apiKey to be redacted got: %+v", deletePreview.Values)
+	}
+
+	// Promoting staging to production makes it inherit apiKey.
+	reparented := staging
+	reparented.PromotesToID = &production.ID
+	updatePreview, err := service.PreviewEnvironmentUpdate(context.Background(), auth.User{}, reparented)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(updatePreview.Diffs) != 1 || len(updatePreview.Diffs[0].Changes) != 1 {
+		t.Fatalf("Expected staging to inherit apiKey got: %+v", updatePreview.Diffs)
+	}
+
+	inherited := updatePreview.Diffs[0].Changes[0].After
+	if inherited == nil || !inherited.Redacted || inherited.StrValue != nil {
+		t.Fatalf("Expected the inherited apiKey to be redacted got: %+v", inherited)
+	}
+}
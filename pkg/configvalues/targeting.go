@@ -0,0 +1,193 @@
+package configvalues
+
+import (
+	"fmt"
+	"hash/fnv"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/config-source/cdb/pkg/configkeys"
+)
+
+// EvaluationContext is the document that targeting rules are evaluated
+// against, usually information about the user or host requesting config.
+// Nested objects can be addressed with dotted attribute names like
+// "user.id".
+type EvaluationContext map[string]interface{}
+
+type Operator string
+
+const (
+	OperatorEquals    Operator = "=="
+	OperatorNotEquals Operator = "!="
+	OperatorIn        Operator = "in"
+	OperatorNotIn     Operator = "not in"
+)
+
+// Condition compares an attribute of the EvaluationContext to Values. Missing
+// attributes never match.
+type Condition struct {
+	Attribute string
+	Operator  Operator
+	Values    []string
+}
+
+// Rollout limits a rule to Percentage of contexts bucketed by hashing
+// Attribute, so the same context always lands in the same bucket.
+type Rollout struct {
+	Attribute  string
+	Percentage int
+}
+
+// TargetingRule overrides the value of a ConfigValue when all of its
+// Conditions match and the context falls within its Rollout, if any.
+type TargetingRule struct {
+	Conditions []Condition
+	Rollout    *Rollout
+
+	StrValue  *string
+	BoolValue *bool
+}
+
+func lookupAttribute(evalCtx EvaluationContext, attribute string) (string, bool) {
+	var current interface{} = map[string]interface{}(evalCtx)
+	for _, part := range strings.Split(strings.TrimPrefix(attribute, "context."), ".") {
+		obj, ok := current.(map[string]interface{})
+		if !ok {
+			return "", false
+		}
+
+		current, ok = obj[part]
+		if !ok {
+			return "", false
+		}
+	}
+
+	switch v := current.(type) {
+	case string:
+		return v, true
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64), true
+	case int:
+		return strconv.Itoa(v), true
+	case bool:
+		return strconv.FormatBool(v), true
+	default:
+		return "", false
+	}
+}
+
+func (c Condition) matches(evalCtx EvaluationContext) bool {
+	value, ok := lookupAttribute(evalCtx, c.Attribute)
+	if !ok {
+		return false
+	}
+
+	switch c.Operator {
+	case OperatorEquals, OperatorIn:
+		return slices.Contains(c.Values, value)
+	case OperatorNotEquals, OperatorNotIn:
+		return !slices.Contains(c.Values, value)
+	default:
+		return false
+	}
+}
+
+// bucket deterministically maps the key and attribute value to [0, 100).
+// The key is included so that rollouts of different keys are independent.
+func bucket(key, value string) int {
+	hash := fnv.New32a()
+	hash.Write([]byte(key + "/" + value)) // nolint:errcheck
+	return int(hash.Sum32() % 100)
+}
+
+func (tr TargetingRule) matches(key string, evalCtx EvaluationContext) bool {
+	for _, condition := range tr.Conditions {
+		if !condition.matches(evalCtx) {
+			return false
+		}
+	}
+
+	if tr.Rollout == nil {
+		return true
+	}
+
+	value, ok := lookupAttribute(evalCtx, tr.Rollout.Attribute)
+	if !ok {
+		return false
+	}
+
+	return bucket(key, value) < tr.Rollout.Percentage
+}
+
+func (tr TargetingRule) valid(valueType configkeys.ValueType) error {
+	for _, condition := range tr.Conditions {
+		if condition.Attribute == "" {
+			return fmt.Errorf("%w: targeting conditions must have an Attribute", ErrNotValid)
+		}
+
+		switch condition.Operator {
+		case OperatorEquals, OperatorNotEquals:
+			if len(condition.Values) != 1 {
+				return fmt.Errorf("%w: %s conditions must have exactly one value", ErrNotValid, condition.Operator)
+			}
+		case OperatorIn, OperatorNotIn:
+			if len(condition.Values) == 0 {
+				return fmt.Errorf("%w: %s conditions must have at least one value", ErrNotValid, condition.Operator)
+			}
+		default:
+			return fmt.Errorf("%w: unrecognised targeting Operator: %q", ErrNotValid, condition.Operator)
+		}
+	}
+
+	if tr.Rollout != nil {
+		if tr.Rollout.Attribute == "" {
+			return fmt.Errorf("%w: rollouts must have an Attribute", ErrNotValid)
+		}
+
+		if tr.Rollout.Percentage < 0 || tr.Rollout.Percentage > 100 {
+			return fmt.Errorf("%w: rollout Percentage must be between 0 and 100", ErrNotValid)
+		}
+	}
+
+	switch valueType {
+	case configkeys.TypeBoolean:
+		if tr.BoolValue == nil || tr.StrValue != nil {
+			return fmt.Errorf("%w: targeting rules for boolean ConfigValues must only set BoolValue", ErrNotValid)
+		}
+	case configkeys.TypeString:
+		if tr.StrValue == nil || tr.BoolValue != nil {
+			return fmt.Errorf("%w: targeting rules for string ConfigValues must only set StrValue", ErrNotValid)
+		}
+	default:
+		return fmt.Errorf("%w: targeting rules are only supported for boolean and string ConfigValues", ErrNotValid)
+	}
+
+	return nil
+}
+
+// Evaluate returns a copy of the ConfigValue with the value of the first
+// matching targeting rule, or the plain value if none match. The returned
+// value has no targeting rules.
+func (cv *ConfigValue) Evaluate(evalCtx EvaluationContext) ConfigValue {
+	evaluated := *cv
+	evaluated.TargetingRules = nil
+
+	for _, rule := range cv.TargetingRules {
+		if !rule.matches(cv.Name, evalCtx) {
+			continue
+		}
+
+		switch cv.ValueType {
+		case configkeys.TypeBoolean:
+			evaluated.SetBoolValue(*rule.BoolValue)
+		case configkeys.TypeString:
+			evaluated.SetStrValue(*rule.StrValue)
+		}
+
+		return evaluated
+	}
+
+	return evaluated
+}
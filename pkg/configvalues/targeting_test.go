@@ -0,0 +1,170 @@
+package configvalues_test
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/config-source/cdb/pkg/configvalues"
+)
+
+func strPtr(s string) *string {
+	return &s
+}
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestEvaluateUsesFirstMatchingRule(t *testing.T) {
+	cv := configvalues.NewString(1, 1, "default")
+	cv.Name = "region-banner"
+	cv.TargetingRules = []configvalues.TargetingRule{
+		{
+			Conditions: []configvalues.Condition{
+				{Attribute: "context.region", Operator: configvalues.OperatorEquals, Values: []string{"eu"}},
+			},
+			StrValue: strPtr("eu"),
+		},
+		{
+			Conditions: []configvalues.Condition{
+				{Attribute: "user.tier", Operator: configvalues.OperatorIn, Values: []string{"gold", "platinum"}},
+			},
+			StrValue: strPtr("vip"),
+		},
+	}
+
+	if err := cv.Valid(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		evalCtx  configvalues.EvaluationContext
+		expected string
+	}{
+		{
+			evalCtx:  configvalues.EvaluationContext{"region": "eu", "user": map[string]interface{}{"tier": "gold"}},
+			expected: "eu",
+		},
+		{
+			evalCtx:  configvalues.EvaluationContext{"region": "us", "user": map[string]interface{}{"tier": "gold"}},
+			expected: "vip",
+		},
+		{
+			evalCtx:  configvalues.EvaluationContext{"region": "us"},
+			expected: "default",
+		},
+		{
+			evalCtx:  nil,
+			expected: "default",
+		},
+	}
+
+	for _, test := range tests {
+		evaluated := cv.Evaluate(test.evalCtx)
+		if *evaluated.StrValue != test.expected {
+			t.Errorf("Expected %s for %v got: %s", test.expected, test.evalCtx, *evaluated.StrValue)
+		}
+
+		if evaluated.TargetingRules != nil {
+			t.Errorf("Expected evaluated value to have no targeting rules got: %v", evaluated.TargetingRules)
+		}
+	}
+
+	if *cv.StrValue != "default" {
+		t.Fatalf("Expected Evaluate not to modify the original value got: %s", *cv.StrValue)
+	}
+}
+
+func TestEvaluateRolloutIsDeterministic(t *testing.T) {
+	cv := configvalues.NewBool(1, 1, false)
+	cv.Name = "new-checkout"
+	cv.TargetingRules = []configvalues.TargetingRule{
+		{
+			Rollout:   &configvalues.Rollout{Attribute: "userId", Percentage: 10},
+			BoolValue: boolPtr(true),
+		},
+	}
+
+	if err := cv.Valid(); err != nil {
+		t.Fatal(err)
+	}
+
+	enabled := 0
+	for i := 0; i < 1000; i++ {
+		evalCtx := configvalues.EvaluationContext{"userId": fmt.Sprintf("user-%d", i)}
+		first := *cv.Evaluate(evalCtx).BoolValue
+		if first != *cv.Evaluate(evalCtx).BoolValue {
+			t.Fatalf("Expected the same result for the same context: %v", evalCtx)
+		}
+
+		if first {
+			enabled++
+		}
+	}
+
+	if enabled < 50 || enabled > 150 {
+		t.Fatalf("Expected roughly 10%% of users to be enabled got: %d/1000", enabled)
+	}
+
+	if *cv.Evaluate(configvalues.EvaluationContext{}).BoolValue {
+		t.Fatal("Expected contexts without the rollout attribute to get the plain value")
+	}
+}
+
+func TestTargetingRulesAreValidated(t *testing.T) {
+	tests := []struct {
+		name string
+		cv   *configvalues.ConfigValue
+		rule configvalues.TargetingRule
+	}{
+		{
+			name: "integer keys",
+			cv:   configvalues.NewInt(1, 1, 1),
+			rule: configvalues.TargetingRule{StrValue: strPtr("x")},
+		},
+		{
+			name: "mismatched value",
+			cv:   configvalues.NewBool(1, 1, false),
+			rule: configvalues.TargetingRule{StrValue: strPtr("x")},
+		},
+		{
+			name: "unknown operator",
+			cv:   configvalues.NewString(1, 1, "x"),
+			rule: configvalues.TargetingRule{
+				Conditions: []configvalues.Condition{{Attribute: "region", Operator: "~=", Values: []string{"eu"}}},
+				StrValue:   strPtr("y"),
+			},
+		},
+		{
+			name: "equals with many values",
+			cv:   configvalues.NewString(1, 1, "x"),
+			rule: configvalues.TargetingRule{
+				Conditions: []configvalues.Condition{{Attribute: "region", Operator: configvalues.OperatorEquals, Values: []string{"eu", "us"}}},
+				StrValue:   strPtr("y"),
+			},
+		},
+		{
+			name: "percentage out of range",
+			cv:   configvalues.NewString(1, 1, "x"),
+			rule: configvalues.TargetingRule{
+				Rollout:  &configvalues.Rollout{Attribute: "userId", Percentage: 101},
+				StrValue: strPtr("y"),
+			},
+		},
+	}
+
+	for _, test := range tests {
+		test.cv.TargetingRules = []configvalues.TargetingRule{test.rule}
+		err := test.cv.Valid()
+		if !errors.Is(err, configvalues.ErrNotValid) {
+			t.Errorf("Expected ErrNotValid for %s got: %v", test.name, err)
+		}
+	}
+
+	cv := configvalues.NewBool(1, 1, false)
+	cv.TargetingRules = []configvalues.TargetingRule{{BoolValue: boolPtr(true)}}
+	if err := cv.Valid(); err != nil {
+		t.Fatalf("Expected a rule without conditions to be valid got: %s", err)
+	}
+}
@@ -3,11 +3,32 @@ package environments
 import (
 	"errors"
 	"fmt"
+	"slices"
 	"time"
 )
 
 var (
-	ErrNotFound = errors.New("environment not found")
+	ErrNotFound           = errors.New("environment not found")
+	ErrInvalidInheritance = errors.New("environment inheritance is not valid")
+	ErrNotEphemeral       = errors.New("environment is not ephemeral")
+	ErrPromotionCycle     = errors.New("environment cannot promote to itself or its descendants")
+	ErrPromotionService   = errors.New("environment cannot promote to an environment in another service")
+	ErrHasDescendants     = errors.New("environment has environments which promote to it")
+)
+
+// InheritanceMode controls which config values an environment inherits from
+// the environment it promotes to.
+type InheritanceMode string
+
+const (
+	// InheritFull inherits every value which can propagate.
+	InheritFull InheritanceMode = "full"
+	// InheritNone inherits nothing, the environment is isolated.
+	InheritNone InheritanceMode = "none"
+	// InheritAllowlist inherits only the keys in InheritanceKeys.
+	InheritAllowlist InheritanceMode = "allowlist"
+	// InheritDenylist inherits every key except those in InheritanceKeys.
+	InheritDenylist InheritanceMode = "denylist"
 )
 
 type Environment struct {
@@ -17,10 +38,24 @@ type Environment struct {
 	PromotesToID *int   `db:"promotes_to_id"`
 	Sensitive    bool   `db:"sensitive"`
 
+	InheritanceMode InheritanceMode `db:"inheritance_mode"`
+	// InheritanceKeys are the config key names used by the allowlist and
+	// denylist inheritance modes.
+	InheritanceKeys []string `db:"inheritance_keys"`
+
+	// Labels are used to select groups of environments, see LabelSelector.
+	Labels Labels `db:"labels"`
+
 	ServiceID int    `db:"service_id"`
 	Service   string `db:"service_name"`
 
+	// ExpiresAt is set for ephemeral environments which are deleted once it
+	// has passed.
+	ExpiresAt *time.Time `db:"expires_at"`
+
 	CreatedAt time.Time `db:"created_at"`
+	// DeletedAt is set when the environment is in the trash.
+	DeletedAt *time.Time `db:"deleted_at"`
 }
 
 func (e Environment) String() string {
@@ -37,6 +72,42 @@ func (e Environment) String() string {
 	)
 }
 
+// Ephemeral reports whether the environment will be deleted when it expires.
+func (e Environment) Ephemeral() bool {
+	return e.ExpiresAt != nil
+}
+
+// Inherits reports whether the environment inherits the config key named key
+// from the environment it promotes to.
+func (e Environment) Inherits(key string) bool {
+	switch e.InheritanceMode {
+	case InheritNone:
+		return false
+	case InheritAllowlist:
+		return slices.Contains(e.InheritanceKeys, key)
+	case InheritDenylist:
+		return !slices.Contains(e.InheritanceKeys, key)
+	default:
+		return true
+	}
+}
+
+// ValidInheritance checks that the inheritance mode is known and that keys are
+// only given for modes that use them.
+func (e Environment) ValidInheritance() error {
+	switch e.InheritanceMode {
+	case "", InheritFull, InheritNone:
+		if len(e.InheritanceKeys) > 0 {
+			return fmt.Errorf("%w: InheritanceKeys can only be set for %s or %s modes", ErrInvalidInheritance, InheritAllowlist, InheritDenylist)
+		}
+	case InheritAllowlist, InheritDenylist:
+	default:
+		return fmt.Errorf("%w: unrecognised InheritanceMode: %q", ErrInvalidInheritance, e.InheritanceMode)
+	}
+
+	return nil
+}
+
 type Tree struct {
 	Environment Environment
 	Children    []Tree
@@ -0,0 +1,248 @@
+package environments
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+var (
+	ErrInvalidLabel         = errors.New("label is not valid")
+	ErrInvalidLabelSelector = errors.New("label selector is not valid")
+)
+
+// Labels are arbitrary key/value pairs attached to an environment such as
+// tier=prod or region=eu. They follow the same syntax as Kubernetes labels.
+type Labels map[string]string
+
+var (
+	labelNamePattern   = regexp.MustCompile(`^[A-Za-z0-9]([-A-Za-z0-9_.]*[A-Za-z0-9])?$`)
+	labelPrefixPattern = regexp.MustCompile(`^[a-z0-9]([-a-z0-9.]*[a-z0-9])?$`)
+	setPattern         = regexp.MustCompile(`^(\S+)\s+(in|notin)\s*\((.*)\)$`)
+)
+
+func validLabelKey(key string) error {
+	name := key
+	if prefix, rest, ok := strings.Cut(key, "/"); ok {
+		if len(prefix) > 253 || !labelPrefixPattern.MatchString(prefix) {
+			return fmt.Errorf("%w: invalid prefix in key %q", ErrInvalidLabel, key)
+		}
+
+		name = rest
+	}
+
+	if len(name) > 63 || !labelNamePattern.MatchString(name) {
+		return fmt.Errorf("%w: invalid key %q", ErrInvalidLabel, key)
+	}
+
+	return nil
+}
+
+func validLabelValue(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	if len(value) > 63 || !labelNamePattern.MatchString(value) {
+		return fmt.Errorf("%w: invalid value %q", ErrInvalidLabel, value)
+	}
+
+	return nil
+}
+
+func (l Labels) Valid() error {
+	for key, value := range l {
+		if err := validLabelKey(key); err != nil {
+			return err
+		}
+
+		if err := validLabelValue(value); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+type SelectorOperator string
+
+const (
+	SelectorEquals       SelectorOperator = "="
+	SelectorNotEquals    SelectorOperator = "!="
+	SelectorIn           SelectorOperator = "in"
+	SelectorNotIn        SelectorOperator = "notin"
+	SelectorExists       SelectorOperator = "exists"
+	SelectorDoesNotExist SelectorOperator = "!"
+)
+
+// LabelRequirement is a single condition of a LabelSelector.
+type LabelRequirement struct {
+	Key      string
+	Operator SelectorOperator
+	Values   []string
+}
+
+func (req LabelRequirement) Matches(labels Labels) bool {
+	value, ok := labels[req.Key]
+
+	switch req.Operator {
+	case SelectorEquals, SelectorIn:
+		return ok && slices.Contains(req.Values, value)
+	case SelectorNotEquals, SelectorNotIn:
+		return !ok || !slices.Contains(req.Values, value)
+	case SelectorExists:
+		return ok
+	case SelectorDoesNotExist:
+		return !ok
+	default:
+		return false
+	}
+}
+
+func (req LabelRequirement) String() string {
+	switch req.Operator {
+	case SelectorEquals, SelectorNotEquals:
+		return req.Key + string(req.Operator) + req.Values[0]
+	case SelectorIn, SelectorNotIn:
+		return fmt.Sprintf("%s %s (%s)", req.Key, req.Operator, strings.Join(req.Values, ","))
+	case SelectorDoesNotExist:
+		return "!" + req.Key
+	default:
+		return req.Key
+	}
+}
+
+// LabelSelector matches environments whose labels satisfy all of its
+// requirements. An empty LabelSelector matches everything.
+type LabelSelector []LabelRequirement
+
+func (s LabelSelector) Matches(labels Labels) bool {
+	for _, req := range s {
+		if !req.Matches(labels) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Equalities returns the labels which must be present with an exact value for
+// the selector to match, these can be looked up using the labels index.
+func (s LabelSelector) Equalities() Labels {
+	equalities := Labels{}
+	for _, req := range s {
+		if req.Operator == SelectorEquals || (req.Operator == SelectorIn && len(req.Values) == 1) {
+			equalities[req.Key] = req.Values[0]
+		}
+	}
+
+	return equalities
+}
+
+func (s LabelSelector) String() string {
+	parts := make([]string, len(s))
+	for idx, req := range s {
+		parts[idx] = req.String()
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// splitRequirements splits on commas which are not inside of parentheses.
+func splitRequirements(raw string) ([]string, error) {
+	var parts []string
+	depth := 0
+	start := 0
+
+	for idx, char := range raw {
+		switch char {
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return nil, fmt.Errorf("%w: unbalanced parentheses", ErrInvalidLabelSelector)
+			}
+		case ',':
+			if depth == 0 {
+				parts = append(parts, raw[start:idx])
+				start = idx + 1
+			}
+		}
+	}
+
+	if depth != 0 {
+		return nil, fmt.Errorf("%w: unbalanced parentheses", ErrInvalidLabelSelector)
+	}
+
+	return append(parts, raw[start:]), nil
+}
+
+func parseRequirement(raw string) (LabelRequirement, error) {
+	var req LabelRequirement
+
+	switch {
+	case strings.HasPrefix(raw, "!"):
+		req = LabelRequirement{Key: strings.TrimSpace(raw[1:]), Operator: SelectorDoesNotExist}
+	case strings.Contains(raw, "!="):
+		key, value, _ := strings.Cut(raw, "!=")
+		req = LabelRequirement{Key: strings.TrimSpace(key), Operator: SelectorNotEquals, Values: []string{strings.TrimSpace(value)}}
+	case strings.Contains(raw, "="):
+		key, value, _ := strings.Cut(raw, "=")
+		value = strings.TrimPrefix(value, "=")
+		req = LabelRequirement{Key: strings.TrimSpace(key), Operator: SelectorEquals, Values: []string{strings.TrimSpace(value)}}
+	case setPattern.MatchString(raw):
+		match := setPattern.FindStringSubmatch(raw)
+		req = LabelRequirement{Key: match[1], Operator: SelectorOperator(match[2])}
+		for _, value := range strings.Split(match[3], ",") {
+			req.Values = append(req.Values, strings.TrimSpace(value))
+		}
+	default:
+		req = LabelRequirement{Key: raw, Operator: SelectorExists}
+	}
+
+	if err := validLabelKey(req.Key); err != nil {
+		return req, fmt.Errorf("%w: %w", ErrInvalidLabelSelector, err)
+	}
+
+	for _, value := range req.Values {
+		if err := validLabelValue(value); err != nil {
+			return req, fmt.Errorf("%w: %w", ErrInvalidLabelSelector, err)
+		}
+	}
+
+	return req, nil
+}
+
+// ParseLabelSelector parses a Kubernetes style label selector such as
+// "tier=prod,region in (eu,us),!deprecated".
+func ParseLabelSelector(raw string) (LabelSelector, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return LabelSelector{}, nil
+	}
+
+	parts, err := splitRequirements(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	selector := make(LabelSelector, 0, len(parts))
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			return nil, fmt.Errorf("%w: empty requirement in %q", ErrInvalidLabelSelector, raw)
+		}
+
+		req, err := parseRequirement(part)
+		if err != nil {
+			return nil, err
+		}
+
+		selector = append(selector, req)
+	}
+
+	return selector, nil
+}
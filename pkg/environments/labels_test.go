@@ -0,0 +1,121 @@
+package environments_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/config-source/cdb/pkg/environments"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	tests := []struct {
+		raw      string
+		expected environments.LabelSelector
+	}{
+		{
+			raw:      "",
+			expected: environments.LabelSelector{},
+		},
+		{
+			raw: "tier=prod",
+			expected: environments.LabelSelector{
+				{Key: "tier", Operator: environments.SelectorEquals, Values: []string{"prod"}},
+			},
+		},
+		{
+			raw: "tier == prod, region != eu",
+			expected: environments.LabelSelector{
+				{Key: "tier", Operator: environments.SelectorEquals, Values: []string{"prod"}},
+				{Key: "region", Operator: environments.SelectorNotEquals, Values: []string{"eu"}},
+			},
+		},
+		{
+			raw: "region in (eu, us),team notin (payments),example.com/owner,!deprecated",
+			expected: environments.LabelSelector{
+				{Key: "region", Operator: environments.SelectorIn, Values: []string{"eu", "us"}},
+				{Key: "team", Operator: environments.SelectorNotIn, Values: []string{"payments"}},
+				{Key: "example.com/owner", Operator: environments.SelectorExists},
+				{Key: "deprecated", Operator: environments.SelectorDoesNotExist},
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.raw, func(t *testing.T) {
+			selector, err := environments.ParseLabelSelector(tc.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if selector.String() != tc.expected.String() {
+				t.Errorf("expected: %s got: %s", tc.expected, selector)
+			}
+		})
+	}
+}
+
+func TestParseLabelSelectorRejectsInvalidSelectors(t *testing.T) {
+	for _, raw := range []string{
+		"tier=prod,",
+		"region in (eu",
+		"region in eu)",
+		"-tier=prod",
+		"tier=not valid",
+		"Bad_Prefix/tier",
+	} {
+		t.Run(raw, func(t *testing.T) {
+			_, err := environments.ParseLabelSelector(raw)
+			if !errors.Is(err, environments.ErrInvalidLabelSelector) {
+				t.Errorf("expected: %s got: %v", environments.ErrInvalidLabelSelector, err)
+			}
+		})
+	}
+}
+
+func TestLabelSelectorMatches(t *testing.T) {
+	labels := environments.Labels{"tier": "prod", "region": "eu"}
+
+	tests := map[string]bool{
+		"":                         true,
+		"tier=prod":                true,
+		"tier=staging":             false,
+		"tier!=staging":            true,
+		"team!=payments":           true,
+		"region in (eu,us)":        true,
+		"region notin (eu)":        false,
+		"region":                   true,
+		"team":                     false,
+		"!team":                    true,
+		"tier=prod,region in (us)": false,
+	}
+
+	for raw, expected := range tests {
+		t.Run(raw, func(t *testing.T) {
+			selector, err := environments.ParseLabelSelector(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if selector.Matches(labels) != expected {
+				t.Errorf("expected %s to match %v: %t", raw, labels, expected)
+			}
+		})
+	}
+}
+
+func TestLabelsValid(t *testing.T) {
+	valid := environments.Labels{"tier": "prod", "example.com/owner": "team-a", "empty": ""}
+	if err := valid.Valid(); err != nil {
+		t.Errorf("expected %v to be valid got: %s", valid, err)
+	}
+
+	for _, labels := range []environments.Labels{
+		{"": "prod"},
+		{"tier": "has space"},
+		{"-tier": "prod"},
+	} {
+		if err := labels.Valid(); !errors.Is(err, environments.ErrInvalidLabel) {
+			t.Errorf("expected %v to be invalid got: %v", labels, err)
+		}
+	}
+}
@@ -0,0 +1,64 @@
+package environments
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var (
+	ErrOverlayNotFound = errors.New("overlay not found")
+	ErrInvalidOverlay  = errors.New("overlay is not valid")
+)
+
+// Overlay is a named variant of a service's environments along some dimension
+// other than the promotion chain, for example region:eu or tenant:acme. Config
+// values can be set for an environment and overlay and are applied on top of
+// the environment's resolved configuration when the overlay is selected.
+type Overlay struct {
+	ID        int    `db:"id"`
+	ServiceID int    `db:"service_id"`
+	Dimension string `db:"dimension"`
+	Name      string `db:"name"`
+
+	CreatedAt time.Time `db:"created_at"`
+}
+
+// ParseOverlaySelector parses a selector of the form dimension:name.
+func ParseOverlaySelector(selector string) (Overlay, error) {
+	dimension, name, ok := strings.Cut(selector, ":")
+	if !ok || dimension == "" || name == "" {
+		return Overlay{}, fmt.Errorf("%w: overlay selectors must be of the form dimension:name got: %q", ErrInvalidOverlay, selector)
+	}
+
+	return Overlay{Dimension: dimension, Name: name}, nil
+}
+
+// Valid reports whether the overlay can be selected, which requires a
+// dimension and name that don't contain the selector's separator.
+func (o Overlay) Valid() error {
+	if o.Dimension == "" || o.Name == "" {
+		return fmt.Errorf("%w: overlays must have a dimension and name", ErrInvalidOverlay)
+	}
+
+	if strings.Contains(o.Dimension, ":") || strings.Contains(o.Name, ":") {
+		return fmt.Errorf("%w: overlay dimensions and names cannot contain ':' got: %q", ErrInvalidOverlay, o.Selector())
+	}
+
+	return nil
+}
+
+// Selector returns the dimension:name form used to select the overlay.
+func (o Overlay) Selector() string {
+	return fmt.Sprintf("%s:%s", o.Dimension, o.Name)
+}
+
+func (o Overlay) String() string {
+	return fmt.Sprintf(
+		"Overlay(id=%d, service=%d, selector=%s)",
+		o.ID,
+		o.ServiceID,
+		o.Selector(),
+	)
+}
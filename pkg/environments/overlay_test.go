@@ -0,0 +1,44 @@
+package environments_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/config-source/cdb/pkg/environments"
+)
+
+func TestOverlayValid(t *testing.T) {
+	tests := []struct {
+		overlay environments.Overlay
+		valid   bool
+	}{
+		{overlay: environments.Overlay{Dimension: "region", Name: "eu"}, valid: true},
+		{overlay: environments.Overlay{Dimension: "region"}},
+		{overlay: environments.Overlay{Name: "eu"}},
+		{overlay: environments.Overlay{Dimension: "region:eu", Name: "west"}},
+		{overlay: environments.Overlay{Dimension: "region", Name: "eu:west"}},
+	}
+
+	for _, test := range tests {
+		err := test.overlay.Valid()
+		if test.valid && err != nil {
+			t.Errorf("Expected %+v to be valid got: %v", test.overlay, err)
+		}
+
+		if !test.valid && !errors.Is(err, environments.ErrInvalidOverlay) {
+			t.Errorf("Expected %+v to be invalid got: %v", test.overlay, err)
+		}
+	}
+}
+
+func TestOverlaysRoundTripThroughTheirSelector(t *testing.T) {
+	overlay := environments.Overlay{Dimension: "region", Name: "eu"}
+	parsed, err := environments.ParseOverlaySelector(overlay.Selector())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if parsed != overlay {
+		t.Fatalf("Expected %+v got: %+v", overlay, parsed)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	_ "embed"
 	"errors"
+	"time"
 
 	"github.com/config-source/cdb/pkg/postgresutils"
 	"github.com/jackc/pgx/v5"
@@ -38,21 +39,96 @@ var listEnvironmentsSql string
 //go:embed queries/list_nonsensitive_environments.sql
 var listNonsensitiveEnvironmentsSql string
 
+//go:embed queries/list_environments_by_labels.sql
+var listEnvironmentsByLabelsSql string
+
 //go:embed queries/update_environment.sql
 var updateEnvironmentSql string
 
 //go:embed queries/delete_environment.sql
 var deleteEnvironmentSql string
 
+//go:embed queries/claim_expired_environments.sql
+var claimExpiredEnvironmentsSql string
+
+//go:embed queries/list_descendants.sql
+var listDescendantsSql string
+
+//go:embed queries/soft_delete_environment.sql
+var softDeleteEnvironmentSql string
+
+//go:embed queries/restore_environment.sql
+var restoreEnvironmentSql string
+
+//go:embed queries/get_deleted_environment_by_id.sql
+var getDeletedEnvironmentByIDSql string
+
+//go:embed queries/list_deleted_environments.sql
+var listDeletedEnvironmentsSql string
+
+//go:embed queries/purge_deleted_environments.sql
+var purgeDeletedEnvironmentsSql string
+
+//go:embed queries/extend_environment.sql
+var extendEnvironmentSql string
+
+//go:embed queries/create_overlay.sql
+var createOverlaySql string
+
+//go:embed queries/get_overlay_by_id.sql
+var getOverlayByIDSql string
+
+//go:embed queries/get_overlay_by_name.sql
+var getOverlayByNameSql string
+
+//go:embed queries/list_overlays.sql
+var listOverlaysSql string
+
+//go:embed queries/delete_overlay.sql
+var deleteOverlaySql string
+
+// withDefaults fills in the defaults for the non-null inheritance and labels
+// columns.
+func withDefaults(env Environment) Environment {
+	if env.InheritanceMode == "" {
+		env.InheritanceMode = InheritFull
+	}
+
+	if env.InheritanceKeys == nil {
+		env.InheritanceKeys = []string{}
+	}
+
+	if env.Labels == nil {
+		env.Labels = Labels{}
+	}
+
+	return env
+}
+
 func (r *Repository) CreateEnvironment(ctx context.Context, env Environment) (Environment, error) {
+	return createEnvironment(ctx, r.pool, env)
+}
+
+// CreateEnvironmentInTx creates the environment as part of a larger
+// transaction, the caller is responsible for committing it.
+func (r *Repository) CreateEnvironmentInTx(ctx context.Context, txn pgx.Tx, env Environment) (Environment, error) {
+	return createEnvironment(ctx, txn, env)
+}
+
+func createEnvironment(ctx context.Context, q postgresutils.Querier, env Environment) (Environment, error) {
+	env = withDefaults(env)
 	return postgresutils.GetOneLax[Environment](
-		r.pool,
+		q,
 		ctx,
 		createEnvironmentSql,
 		env.Name,
 		env.PromotesToID,
 		env.Sensitive,
 		env.ServiceID,
+		env.InheritanceMode,
+		env.InheritanceKeys,
+		env.ExpiresAt,
+		env.Labels,
 	)
 }
 
@@ -85,8 +161,34 @@ func (r *Repository) ListEnvironments(ctx context.Context, includeSensitive bool
 	return envs, err
 }
 
+// ListEnvironmentsBySelector returns the environments whose labels match the
+// selector. Exact matches are looked up using the labels index and the rest of
+// the selector is applied to the results.
+func (r *Repository) ListEnvironmentsBySelector(ctx context.Context, includeSensitive bool, selector LabelSelector) ([]Environment, error) {
+	envs, err := postgresutils.GetAll[Environment](
+		r.pool,
+		ctx,
+		listEnvironmentsByLabelsSql,
+		selector.Equalities(),
+		includeSensitive,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	matching := make([]Environment, 0, len(envs))
+	for _, env := range envs {
+		if selector.Matches(env.Labels) {
+			matching = append(matching, env)
+		}
+	}
+
+	return matching, nil
+}
+
 func (r *Repository) UpdateEnvironment(ctx context.Context, env Environment) (Environment, error) {
-	return postgresutils.GetOneLax[Environment](
+	env = withDefaults(env)
+	updated, err := postgresutils.GetOneLax[Environment](
 		r.pool,
 		ctx,
 		updateEnvironmentSql,
@@ -94,10 +196,216 @@ func (r *Repository) UpdateEnvironment(ctx context.Context, env Environment) (En
 		env.Name,
 		env.PromotesToID,
 		env.Sensitive,
+		env.InheritanceMode,
+		env.InheritanceKeys,
+		env.Labels,
 	)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return updated, ErrNotFound
+	}
+
+	return updated, err
+}
+
+// ListDescendants returns every environment which directly or indirectly
+// promotes to the environment with the given id.
+func (r *Repository) ListDescendants(ctx context.Context, id int) ([]Environment, error) {
+	return postgresutils.GetAll[Environment](r.pool, ctx, listDescendantsSql, id)
+}
+
+// CheckPromotion returns ErrPromotionCycle if env would promote to itself or
+// one of its descendants, ErrPromotionService if what it promotes to is in
+// another service and ErrNotFound if what it promotes to doesn't exist. The
+// ServiceID of env must be the stored one.
+func (r *Repository) CheckPromotion(ctx context.Context, env Environment) error {
+	if env.PromotesToID == nil {
+		return nil
+	}
+
+	if *env.PromotesToID == env.ID {
+		return ErrPromotionCycle
+	}
+
+	// Also catches promoting to an environment in the trash.
+	target, err := r.GetEnvironment(ctx, *env.PromotesToID)
+	if err != nil {
+		return err
+	}
+
+	// Otherwise the environment would inherit configuration which is only
+	// authorized against the other service.
+	if target.ServiceID != env.ServiceID {
+		return ErrPromotionService
+	}
+
+	descendants, err := r.ListDescendants(ctx, env.ID)
+	if err != nil {
+		return err
+	}
+
+	for _, descendant := range descendants {
+		if descendant.ID == *env.PromotesToID {
+			return ErrPromotionCycle
+		}
+	}
+
+	return nil
+}
+
+// ReapExpiredEnvironments deletes the ephemeral environments whose expiry has
+// passed and returns them. It is safe to run concurrently from multiple cdbd
+// replicas as each environment is only reaped by the replica which locked it.
+// Environments which can't be deleted, for example because another
+// environment promotes to them, are logged and left for the next reap.
+func (r *Repository) ReapExpiredEnvironments(ctx context.Context) ([]Environment, error) {
+	txn, err := r.pool.BeginTx(ctx, pgx.TxOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	expired, err := postgresutils.GetAll[Environment](txn, ctx, claimExpiredEnvironmentsSql)
+	if err != nil {
+		postgresutils.Rollback(ctx, txn, r.log)
+		return nil, err
+	}
+
+	reaped := make([]Environment, 0, len(expired))
+	for _, env := range expired {
+		// Each delete gets a savepoint so that one failing doesn't abort the
+		// others.
+		savepoint, err := txn.Begin(ctx)
+		if err != nil {
+			postgresutils.Rollback(ctx, txn, r.log)
+			return nil, err
+		}
+
+		if _, err := savepoint.Exec(ctx, deleteEnvironmentSql, env.ID); err != nil {
+			postgresutils.Rollback(ctx, savepoint, r.log)
+			r.log.Err(err).
+				Int("environmentID", env.ID).
+				Str("environment", env.Name).
+				Msg("failed to reap expired environment")
+			continue
+		}
+
+		if err := savepoint.Commit(ctx); err != nil {
+			postgresutils.Rollback(ctx, txn, r.log)
+			return nil, err
+		}
+
+		reaped = append(reaped, env)
+	}
+
+	return reaped, txn.Commit(ctx)
+}
+
+// ExtendEnvironment sets a new expiry on an ephemeral environment. Returns
+// ErrNotEphemeral if the environment has no expiry.
+func (r *Repository) ExtendEnvironment(ctx context.Context, id int, expiresAt time.Time) (Environment, error) {
+	env, err := postgresutils.GetOneLax[Environment](r.pool, ctx, extendEnvironmentSql, id, expiresAt)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		if _, getErr := r.GetEnvironment(ctx, id); getErr != nil {
+			return env, getErr
+		}
+
+		return env, ErrNotEphemeral
+	}
+
+	return env, err
 }
 
 func (r *Repository) DeleteEnvironment(ctx context.Context, id int) error {
 	_, err := r.pool.Exec(ctx, deleteEnvironmentSql, id)
 	return err
 }
+
+func (r *Repository) CreateOverlay(ctx context.Context, overlay Overlay) (Overlay, error) {
+	return postgresutils.GetOne[Overlay](
+		r.pool,
+		ctx,
+		createOverlaySql,
+		overlay.ServiceID,
+		overlay.Dimension,
+		overlay.Name,
+	)
+}
+
+func (r *Repository) GetOverlay(ctx context.Context, id int) (Overlay, error) {
+	overlay, err := postgresutils.GetOne[Overlay](r.pool, ctx, getOverlayByIDSql, id)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return overlay, ErrOverlayNotFound
+	}
+
+	return overlay, err
+}
+
+func (r *Repository) GetOverlayByName(ctx context.Context, serviceID int, dimension, name string) (Overlay, error) {
+	overlay, err := postgresutils.GetOne[Overlay](r.pool, ctx, getOverlayByNameSql, serviceID, dimension, name)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return overlay, ErrOverlayNotFound
+	}
+
+	return overlay, err
+}
+
+// ListOverlays returns the overlays for serviceID or all services when it is
+// nil.
+func (r *Repository) ListOverlays(ctx context.Context, serviceID *int) ([]Overlay, error) {
+	return postgresutils.GetAll[Overlay](r.pool, ctx, listOverlaysSql, serviceID)
+}
+
+func (r *Repository) DeleteOverlay(ctx context.Context, id int) error {
+	_, err := r.pool.Exec(ctx, deleteOverlaySql, id)
+	return err
+}
+
+// SoftDeleteEnvironment moves the environment to the trash, hiding it from every
+// other read until it is restored or purged.
+func (r *Repository) SoftDeleteEnvironment(ctx context.Context, id int) error {
+	tag, err := r.pool.Exec(ctx, softDeleteEnvironmentSql, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RestoreEnvironment takes the environment back out of the trash.
+func (r *Repository) RestoreEnvironment(ctx context.Context, id int) error {
+	tag, err := r.pool.Exec(ctx, restoreEnvironmentSql, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) GetDeletedEnvironment(ctx context.Context, id int) (Environment, error) {
+	environment, err := postgresutils.GetOne[Environment](r.pool, ctx, getDeletedEnvironmentByIDSql, id)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return environment, ErrNotFound
+	}
+
+	return environment, err
+}
+
+// ListDeletedEnvironments returns the environments in the trash, most recently
+// deleted first.
+func (r *Repository) ListDeletedEnvironments(ctx context.Context) ([]Environment, error) {
+	return postgresutils.GetAll[Environment](r.pool, ctx, listDeletedEnvironmentsSql)
+}
+
+// PurgeDeletedEnvironments permanently deletes environments which were moved to the
+// trash before the given time and returns how many were removed.
+func (r *Repository) PurgeDeletedEnvironments(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	tag, err := r.pool.Exec(ctx, purgeDeletedEnvironmentsSql, deletedBefore)
+	return tag.RowsAffected(), err
+}
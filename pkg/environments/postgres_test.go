@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"reflect"
+	"slices"
 	"testing"
 
 	"github.com/config-source/cdb/pkg/environments"
@@ -162,6 +163,10 @@ func TestUpdate(t *testing.T) {
 		Sensitive:    true,
 		ServiceID:    svc.ID,
 		CreatedAt:    env2.CreatedAt,
+
+		InheritanceMode: environments.InheritFull,
+		InheritanceKeys: []string{},
+		Labels:          environments.Labels{"tier": "prod"},
 	}
 
 	result, err := repo.UpdateEnvironment(context.Background(), updated)
@@ -174,6 +179,62 @@ func TestUpdate(t *testing.T) {
 	}
 }
 
+func TestListEnvironmentsBySelector(t *testing.T) {
+	repo, svcRepo := initTestDB(t)
+	svc := svcFixture(t, svcRepo, "svc1")
+
+	labelled := map[string]environments.Labels{
+		"prod-eu": {"tier": "prod", "region": "eu"},
+		"prod-us": {"tier": "prod", "region": "us"},
+		"staging": {"tier": "staging", "region": "eu"},
+		"dev":     {},
+	}
+
+	for name, labels := range labelled {
+		_, err := repo.CreateEnvironment(context.Background(), environments.Environment{
+			Name:      name,
+			ServiceID: svc.ID,
+			Labels:    labels,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := map[string][]string{
+		"tier=prod":                {"prod-eu", "prod-us"},
+		"tier=prod,region!=us":     {"prod-eu"},
+		"region in (eu)":           {"prod-eu", "staging"},
+		"tier notin (prod)":        {"dev", "staging"},
+		"!tier":                    {"dev"},
+		"region,tier in (staging)": {"staging"},
+	}
+
+	for raw, expected := range tests {
+		t.Run(raw, func(t *testing.T) {
+			selector, err := environments.ParseLabelSelector(raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			envs, err := repo.ListEnvironmentsBySelector(context.Background(), true, selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			names := make([]string, len(envs))
+			for idx, env := range envs {
+				names[idx] = env.Name
+			}
+
+			slices.Sort(names)
+			if !reflect.DeepEqual(expected, names) {
+				t.Errorf("expected: %v got: %v", expected, names)
+			}
+		})
+	}
+}
+
 func TestDelete(t *testing.T) {
 	repo, svcRepo := initTestDB(t)
 
@@ -193,3 +254,45 @@ func TestDelete(t *testing.T) {
 		t.Errorf("expected: %s got: %s", environments.ErrNotFound, err)
 	}
 }
+
+func TestOverlays(t *testing.T) {
+	repo, svcRepo := initTestDB(t)
+	svc := svcFixture(t, svcRepo, "svc1")
+
+	created, err := repo.CreateOverlay(context.Background(), environments.Overlay{
+		ServiceID: svc.ID,
+		Dimension: "region",
+		Name:      "eu",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	retrieved, err := repo.GetOverlayByName(context.Background(), svc.ID, "region", "eu")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(created, retrieved) {
+		t.Fatalf("Expected %s got: %s", created, retrieved)
+	}
+
+	overlays, err := repo.ListOverlays(context.Background(), &svc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(overlays) != 1 || overlays[0].Selector() != "region:eu" {
+		t.Fatalf("Expected only region:eu got: %v", overlays)
+	}
+
+	err = repo.DeleteOverlay(context.Background(), created.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.GetOverlay(context.Background(), created.ID)
+	if !errors.Is(err, environments.ErrOverlayNotFound) {
+		t.Fatalf("Expected ErrOverlayNotFound got: %v", err)
+	}
+}
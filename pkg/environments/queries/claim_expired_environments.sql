@@ -0,0 +1,7 @@
+-- Locks the expired environments so that only one cdbd replica reaps each of
+-- them, environments locked by another replica are skipped.
+SELECT environments.*, services.name as service_name FROM environments 
+JOIN services ON services.id = environments.service_id
+WHERE environments.expires_at <= now()
+ORDER BY environments.expires_at
+FOR UPDATE OF environments SKIP LOCKED;
@@ -2,12 +2,20 @@ INSERT INTO environments (
     name,
     promotes_to_id,
     sensitive,
-    service_id
+    service_id,
+    inheritance_mode,
+    inheritance_keys,
+    expires_at,
+    labels
 ) 
 VALUES (
     $1, 
     $2,
     $3,
-    $4
+    $4,
+    $5,
+    $6,
+    $7,
+    $8
 )
 RETURNING *;
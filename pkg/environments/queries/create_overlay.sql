@@ -0,0 +1,11 @@
+INSERT INTO overlays (
+    service_id,
+    dimension,
+    name
+)
+VALUES (
+    $1,
+    $2,
+    $3
+)
+RETURNING *;
@@ -0,0 +1,2 @@
+DELETE FROM overlays
+WHERE id = $1;
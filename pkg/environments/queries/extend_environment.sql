@@ -0,0 +1,4 @@
+UPDATE environments
+SET expires_at = $2
+WHERE id = $1 AND expires_at IS NOT NULL AND deleted_at IS NULL
+RETURNING *;
@@ -1,3 +1,5 @@
 SELECT environments.*, services.name as service_name FROM environments 
 JOIN services ON services.id = environments.service_id
-WHERE services.name = $1 AND environments.name = $2;
+WHERE services.name = $1 AND environments.name = $2
+    AND environments.deleted_at IS NULL
+    AND services.deleted_at IS NULL;
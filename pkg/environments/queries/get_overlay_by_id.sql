@@ -0,0 +1,2 @@
+SELECT * FROM overlays
+WHERE id = $1;
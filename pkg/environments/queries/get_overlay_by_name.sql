@@ -0,0 +1,2 @@
+SELECT * FROM overlays
+WHERE service_id = $1 AND dimension = $2 AND name = $3;
@@ -0,0 +1,4 @@
+SELECT environments.*, services.name as service_name FROM environments 
+JOIN services ON services.id = environments.service_id
+WHERE environments.deleted_at IS NOT NULL
+ORDER BY environments.deleted_at DESC;
@@ -0,0 +1,11 @@
+WITH RECURSIVE descendants AS (
+    SELECT id FROM environments WHERE promotes_to_id = $1 AND deleted_at IS NULL
+    UNION
+    SELECT environments.id FROM environments
+    JOIN descendants ON environments.promotes_to_id = descendants.id
+    WHERE environments.deleted_at IS NULL
+)
+SELECT environments.*, services.name as service_name FROM environments
+JOIN services ON services.id = environments.service_id
+WHERE environments.id IN (SELECT id FROM descendants)
+ORDER BY environments.id;
@@ -1,2 +1,3 @@
 SELECT environments.*, services.name as service_name FROM environments 
-JOIN services ON services.id = environments.service_id;
+JOIN services ON services.id = environments.service_id
+WHERE environments.deleted_at IS NULL AND services.deleted_at IS NULL;
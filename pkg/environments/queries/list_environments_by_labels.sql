@@ -0,0 +1,6 @@
+SELECT environments.*, services.name as service_name FROM environments 
+JOIN services ON services.id = environments.service_id
+WHERE environments.labels @> $1
+    AND ($2 OR sensitive = false)
+    AND environments.deleted_at IS NULL
+    AND services.deleted_at IS NULL;
@@ -1,3 +1,5 @@
 SELECT environments.*, services.name as service_name FROM environments 
 JOIN services ON services.id = environments.service_id
-WHERE sensitive = false;
+WHERE sensitive = false
+    AND environments.deleted_at IS NULL
+    AND services.deleted_at IS NULL;
@@ -0,0 +1,3 @@
+SELECT * FROM overlays
+WHERE ($1::integer IS NULL OR service_id = $1)
+ORDER BY service_id, dimension, name;
@@ -0,0 +1,2 @@
+DELETE FROM environments
+WHERE deleted_at <= $1;
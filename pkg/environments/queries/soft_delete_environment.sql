@@ -0,0 +1,3 @@
+UPDATE environments
+SET deleted_at = now()
+WHERE id = $1 AND deleted_at IS NULL;
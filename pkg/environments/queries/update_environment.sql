@@ -1,6 +1,9 @@
 UPDATE environments
 SET name = $2,
     promotes_to_id = $3,
-    sensitive = $4
-WHERE id = $1
+    sensitive = $4,
+    inheritance_mode = $5,
+    inheritance_keys = $6,
+    labels = $7
+WHERE id = $1 AND deleted_at IS NULL
 RETURNING *;
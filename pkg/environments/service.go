@@ -2,68 +2,168 @@ package environments
 
 import (
 	"context"
+	"strconv"
 
+	"github.com/config-source/cdb/pkg/audit"
 	"github.com/config-source/cdb/pkg/auth"
 )
 
 type Service struct {
-	auth auth.AuthorizationGateway
-	repo *Repository
+	auth  auth.AuthorizationGateway
+	repo  *Repository
+	audit *audit.Log
 }
 
-func NewService(repo *Repository, auth auth.AuthorizationGateway) *Service {
+func NewService(repo *Repository, auth auth.AuthorizationGateway, auditLog *audit.Log) *Service {
 	return &Service{
-		auth: auth,
-		repo: repo,
+		auth:  auth,
+		repo:  repo,
+		audit: auditLog,
 	}
 }
 
-func (svc *Service) CreateEnvironment(ctx context.Context, actor auth.User, env Environment) (Environment, error) {
-	canManageEnvironments, err := svc.auth.HasPermission(ctx, actor, auth.PermissionManageEnvironments)
-	if err != nil {
-		return Environment{}, err
-	}
+// access is what an actor may do with the environments of a single service.
+type access struct {
+	manage             bool
+	configure          bool
+	configureSensitive bool
+	read               bool
+	readSensitive      bool
+}
 
-	if !canManageEnvironments {
-		return Environment{}, auth.ErrUnauthorized
+func (a access) canSee(env Environment) bool {
+	if a.manage || a.configureSensitive || a.readSensitive {
+		return true
 	}
 
-	return svc.repo.CreateEnvironment(ctx, env)
+	return (a.configure || a.read) && !env.Sensitive
 }
 
-func (svc *Service) singleRetrievalPermissionChecks(ctx context.Context, actor auth.User, env Environment, retrievalErr error) (Environment, error) {
-	canManageEnvironments, err := svc.auth.HasPermission(ctx, actor, auth.PermissionManageEnvironments)
+func (a access) any() bool {
+	return a.manage || a.configure || a.configureSensitive || a.read || a.readSensitive
+}
+
+func (svc *Service) serviceAccess(ctx context.Context, actor auth.User, serviceID int) (access, error) {
+	var a access
+	var err error
+
+	a.manage, err = svc.auth.HasServicePermission(ctx, actor, serviceID, auth.PermissionManageEnvironments)
 	if err != nil {
-		return Environment{}, err
+		return a, err
 	}
 
-	canConfigureSensitiveEnvironments, err := svc.auth.HasPermission(
+	a.configureSensitive, err = svc.auth.HasServicePermission(
 		ctx,
 		actor,
+		serviceID,
 		auth.PermissionConfigureSensitiveEnvironments,
 	)
 	if err != nil {
-		return Environment{}, err
+		return a, err
 	}
 
-	canConfigureEnvironments, err := svc.auth.HasPermission(
+	a.configure, err = svc.auth.HasServicePermission(
 		ctx,
 		actor,
+		serviceID,
 		auth.PermissionConfigureEnvironments,
 	)
+	if err != nil {
+		return a, err
+	}
+
+	a.readSensitive, err = svc.auth.HasServicePermission(
+		ctx,
+		actor,
+		serviceID,
+		auth.PermissionReadSensitiveConfiguration,
+	)
+	if err != nil {
+		return a, err
+	}
+
+	a.read, err = svc.auth.HasServicePermission(ctx, actor, serviceID, auth.PermissionReadConfiguration)
+	return a, err
+}
+
+func (svc *Service) canManageEnvironments(ctx context.Context, actor auth.User, serviceID int) error {
+	canManageEnvironments, err := svc.auth.HasServicePermission(ctx, actor, serviceID, auth.PermissionManageEnvironments)
+	if err != nil {
+		return err
+	}
+
+	if !canManageEnvironments {
+		return auth.ErrUnauthorized
+	}
+
+	return nil
+}
+
+func (svc *Service) CreateEnvironment(ctx context.Context, actor auth.User, env Environment) (Environment, error) {
+	if err := svc.canManageEnvironments(ctx, actor, env.ServiceID); err != nil {
+		return Environment{}, err
+	}
+
+	if err := env.ValidInheritance(); err != nil {
+		return Environment{}, err
+	}
+
+	if err := env.Labels.Valid(); err != nil {
+		return Environment{}, err
+	}
+
+	if err := svc.repo.CheckPromotion(ctx, env); err != nil {
+		return Environment{}, err
+	}
+
+	created, err := svc.repo.CreateEnvironment(ctx, env)
 	if err != nil {
 		return Environment{}, err
 	}
 
-	if !canManageEnvironments && !canConfigureEnvironments && !canConfigureSensitiveEnvironments {
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionEnvironmentCreate,
+		TargetKind: audit.TargetEnvironment,
+		TargetID:   strconv.Itoa(created.ID),
+		After:      created,
+	})
+	return created, nil
+}
+
+func (svc *Service) singleRetrievalPermissionChecks(ctx context.Context, actor auth.User, env Environment, retrievalErr error) (Environment, error) {
+	if retrievalErr != nil {
+		return Environment{}, retrievalErr
+	}
+
+	if !actor.Scopes.AllowsEnvironment(env.ServiceID, env.ID, auth.AccessRead) {
 		return Environment{}, auth.ErrUnauthorized
 	}
 
-	if !(canConfigureSensitiveEnvironments || canManageEnvironments) && env.Sensitive {
-		return Environment{}, ErrNotFound
+	a, err := svc.serviceAccess(ctx, actor, env.ServiceID)
+	if err != nil {
+		return Environment{}, err
+	}
+
+	if a.canSee(env) {
+		return env, nil
+	}
+
+	// ACL entries grant access to single environments, even sensitive ones,
+	// on top of the actor's permissions.
+	granted, err := svc.auth.GetEnvironmentAccess(ctx, actor)
+	if err != nil {
+		return Environment{}, err
+	}
+
+	if granted[env.ID].Allows(auth.AccessRead) {
+		return env, nil
+	}
+
+	if !a.any() {
+		return Environment{}, auth.ErrUnauthorized
 	}
 
-	return env, retrievalErr
+	return Environment{}, ErrNotFound
 }
 
 func (svc *Service) GetEnvironmentByName(ctx context.Context, actor auth.User, serviceName, name string) (Environment, error) {
@@ -86,31 +186,67 @@ func (svc *Service) GetEnvironmentByID(ctx context.Context, actor auth.User, id
 	)
 }
 
-func (svc *Service) ListEnvironments(ctx context.Context, actor auth.User) ([]Environment, error) {
-	canManageEnvironments, err := svc.auth.HasPermission(ctx, actor, auth.PermissionManageEnvironments)
+// ListEnvironments returns the environments visible to the actor whose labels
+// match selector, an empty selector matches every environment.
+func (svc *Service) ListEnvironments(ctx context.Context, actor auth.User, selector LabelSelector) ([]Environment, error) {
+	canSeeAny, err := svc.auth.HasPermission(
+		ctx,
+		actor,
+		auth.PermissionManageEnvironments,
+		auth.PermissionConfigureEnvironments,
+		auth.PermissionConfigureSensitiveEnvironments,
+		auth.PermissionReadConfiguration,
+		auth.PermissionReadSensitiveConfiguration,
+	)
 	if err != nil {
 		return nil, err
 	}
 
-	if canManageEnvironments {
-		return svc.repo.ListEnvironments(ctx, true)
-	}
-
-	canSeeEnvirons, err := svc.auth.HasPermission(ctx, actor, auth.PermissionConfigureEnvironments)
+	environs, err := svc.listEnvironments(ctx, true, selector)
 	if err != nil {
 		return nil, err
 	}
 
-	canSeeSensitiveEnvirons, err := svc.auth.HasPermission(ctx, actor, auth.PermissionConfigureSensitiveEnvironments)
+	granted, err := svc.auth.GetEnvironmentAccess(ctx, actor)
 	if err != nil {
 		return nil, err
 	}
 
-	if !canSeeEnvirons && !canSeeSensitiveEnvirons {
+	permitted := make(map[int]access)
+	visible := make([]Environment, 0, len(environs))
+	for _, env := range environs {
+		if !actor.Scopes.AllowsEnvironment(env.ServiceID, env.ID, auth.AccessRead) {
+			continue
+		}
+
+		a, checked := permitted[env.ServiceID]
+		if !checked {
+			a, err = svc.serviceAccess(ctx, actor, env.ServiceID)
+			if err != nil {
+				return nil, err
+			}
+
+			permitted[env.ServiceID] = a
+		}
+
+		if a.canSee(env) || granted[env.ID].Allows(auth.AccessRead) {
+			visible = append(visible, env)
+		}
+	}
+
+	if len(visible) == 0 && !canSeeAny {
 		return nil, auth.ErrUnauthorized
 	}
 
-	return svc.repo.ListEnvironments(ctx, canSeeSensitiveEnvirons)
+	return visible, nil
+}
+
+func (svc *Service) listEnvironments(ctx context.Context, includeSensitive bool, selector LabelSelector) ([]Environment, error) {
+	if len(selector) == 0 {
+		return svc.repo.ListEnvironments(ctx, includeSensitive)
+	}
+
+	return svc.repo.ListEnvironmentsBySelector(ctx, includeSensitive, selector)
 }
 
 func getChildren(parent Environment, environments []Environment) []Tree {
@@ -129,27 +265,47 @@ func getChildren(parent Environment, environments []Environment) []Tree {
 	return children
 }
 
+// GetEnvironmentTree returns the promotion trees of the services the actor
+// can manage the environments of.
 func (svc *Service) GetEnvironmentTree(ctx context.Context, actor auth.User) ([]Tree, error) {
-	canManageEnvironments, err := svc.auth.HasPermission(ctx, actor, auth.PermissionManageEnvironments)
+	canManageAny, err := svc.auth.HasPermission(ctx, actor, auth.PermissionManageEnvironments)
 	if err != nil {
 		return nil, err
 	}
 
-	if !canManageEnvironments {
-		return nil, auth.ErrUnauthorized
-	}
-
 	environs, err := svc.repo.ListEnvironments(ctx, true)
 	if err != nil {
 		return nil, err
 	}
 
-	trees := []Tree{}
+	permitted := make(map[int]bool)
+	visible := make([]Environment, 0, len(environs))
 	for _, env := range environs {
+		canManage, checked := permitted[env.ServiceID]
+		if !checked {
+			canManage, err = svc.auth.HasServicePermission(ctx, actor, env.ServiceID, auth.PermissionManageEnvironments)
+			if err != nil {
+				return nil, err
+			}
+
+			permitted[env.ServiceID] = canManage
+		}
+
+		if canManage {
+			visible = append(visible, env)
+		}
+	}
+
+	if len(visible) == 0 && !canManageAny {
+		return nil, auth.ErrUnauthorized
+	}
+
+	trees := []Tree{}
+	for _, env := range visible {
 		if env.PromotesToID == nil {
 			trees = append(trees, Tree{
 				Environment: env,
-				Children:    getChildren(env, environs),
+				Children:    getChildren(env, visible),
 			})
 		}
 	}
@@ -158,29 +314,256 @@ func (svc *Service) GetEnvironmentTree(ctx context.Context, actor auth.User) ([]
 }
 
 func (svc *Service) UpdateEnvironment(ctx context.Context, actor auth.User, env Environment) (Environment, error) {
-	canManageEnvironments, err := svc.auth.HasPermission(ctx, actor, auth.PermissionManageEnvironments)
+	// Check against the stored service so that a request body can't claim a
+	// service the actor manages.
+	current, err := svc.repo.GetEnvironment(ctx, env.ID)
 	if err != nil {
 		return Environment{}, err
 	}
 
-	if !canManageEnvironments {
-		return Environment{}, auth.ErrUnauthorized
+	if err := svc.canManageEnvironments(ctx, actor, current.ServiceID); err != nil {
+		return Environment{}, err
+	}
+
+	if err := env.ValidInheritance(); err != nil {
+		return Environment{}, err
+	}
+
+	if err := env.Labels.Valid(); err != nil {
+		return Environment{}, err
+	}
+
+	// Environments can't move between services.
+	env.ServiceID = current.ServiceID
+	if err := svc.repo.CheckPromotion(ctx, env); err != nil {
+		return Environment{}, err
 	}
 
 	updated, err := svc.repo.UpdateEnvironment(ctx, env)
-	updated.Service = env.Service
-	return updated, err
+	updated.Service = current.Service
+	if err != nil {
+		return updated, err
+	}
+
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionEnvironmentUpdate,
+		TargetKind: audit.TargetEnvironment,
+		TargetID:   strconv.Itoa(updated.ID),
+		Before:     current,
+		After:      updated,
+	})
+	return updated, nil
 }
 
 func (svc *Service) DeleteEnvironment(ctx context.Context, actor auth.User, id int) error {
-	canManageEnvironments, err := svc.auth.HasPermission(ctx, actor, auth.PermissionManageEnvironments)
+	env, err := svc.repo.GetEnvironment(ctx, id)
 	if err != nil {
 		return err
 	}
 
-	if !canManageEnvironments {
-		return auth.ErrUnauthorized
+	if err := svc.canManageEnvironments(ctx, actor, env.ServiceID); err != nil {
+		return err
+	}
+
+	// Environments which inherit from this one would silently lose values, so
+	// they must be deleted or reparented first.
+	descendants, err := svc.repo.ListDescendants(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if len(descendants) > 0 {
+		return ErrHasDescendants
+	}
+
+	if err := svc.repo.SoftDeleteEnvironment(ctx, id); err != nil {
+		return err
+	}
+
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionEnvironmentDelete,
+		TargetKind: audit.TargetEnvironment,
+		TargetID:   strconv.Itoa(id),
+		Before:     env,
+	})
+	return nil
+}
+
+func (svc *Service) CreateOverlay(ctx context.Context, actor auth.User, overlay Overlay) (Overlay, error) {
+	if err := svc.canManageEnvironments(ctx, actor, overlay.ServiceID); err != nil {
+		return Overlay{}, err
+	}
+
+	if err := overlay.Valid(); err != nil {
+		return Overlay{}, err
+	}
+
+	created, err := svc.repo.CreateOverlay(ctx, overlay)
+	if err != nil {
+		return Overlay{}, err
+	}
+
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionOverlayCreate,
+		TargetKind: audit.TargetOverlay,
+		TargetID:   strconv.Itoa(created.ID),
+		After:      created,
+	})
+	return created, nil
+}
+
+// ListOverlays returns the overlays for serviceID, which only requires access
+// to that service, or the overlays of every service the actor can see when it
+// is nil.
+func (svc *Service) ListOverlays(ctx context.Context, actor auth.User, serviceID *int) ([]Overlay, error) {
+	seeOverlays := []auth.Permission{
+		auth.PermissionConfigureEnvironments,
+		auth.PermissionConfigureSensitiveEnvironments,
+	}
+
+	if serviceID != nil {
+		canSeeOverlays, err := svc.auth.HasServicePermission(ctx, actor, *serviceID, auth.PermissionManageEnvironments, seeOverlays...)
+		if err != nil {
+			return nil, err
+		}
+
+		if !canSeeOverlays {
+			return nil, auth.ErrUnauthorized
+		}
+
+		return svc.repo.ListOverlays(ctx, serviceID)
+	}
+
+	canSeeAny, err := svc.auth.HasPermission(ctx, actor, auth.PermissionManageEnvironments, seeOverlays...)
+	if err != nil {
+		return nil, err
+	}
+
+	overlays, err := svc.repo.ListOverlays(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	permitted := make(map[int]bool)
+	visible := make([]Overlay, 0, len(overlays))
+	for _, overlay := range overlays {
+		canSee, checked := permitted[overlay.ServiceID]
+		if !checked {
+			canSee, err = svc.auth.HasServicePermission(ctx, actor, overlay.ServiceID, auth.PermissionManageEnvironments, seeOverlays...)
+			if err != nil {
+				return nil, err
+			}
+
+			permitted[overlay.ServiceID] = canSee
+		}
+
+		if canSee {
+			visible = append(visible, overlay)
+		}
+	}
+
+	if len(visible) == 0 && !canSeeAny {
+		return nil, auth.ErrUnauthorized
+	}
+
+	return visible, nil
+}
+
+func (svc *Service) DeleteOverlay(ctx context.Context, actor auth.User, id int) error {
+	overlay, err := svc.repo.GetOverlay(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.canManageEnvironments(ctx, actor, overlay.ServiceID); err != nil {
+		return err
+	}
+
+	if err := svc.repo.DeleteOverlay(ctx, id); err != nil {
+		return err
+	}
+
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionOverlayDelete,
+		TargetKind: audit.TargetOverlay,
+		TargetID:   strconv.Itoa(id),
+		Before:     overlay,
+	})
+	return nil
+}
+
+// ListACL returns the ACL entries granting access to the environment.
+func (svc *Service) ListACL(ctx context.Context, actor auth.User, id int) ([]auth.ACLEntry, error) {
+	env, err := svc.repo.GetEnvironment(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := svc.canManageEnvironments(ctx, actor, env.ServiceID); err != nil {
+		return nil, err
+	}
+
+	return svc.auth.ListEnvironmentACL(ctx, id)
+}
+
+// GrantAccess adds an ACL entry to the environment giving a user or role read
+// or write access to it.
+func (svc *Service) GrantAccess(ctx context.Context, actor auth.User, entry auth.ACLEntry) (auth.ACLEntry, error) {
+	env, err := svc.repo.GetEnvironment(ctx, entry.EnvironmentID)
+	if err != nil {
+		return auth.ACLEntry{}, err
+	}
+
+	if err := svc.canManageEnvironments(ctx, actor, env.ServiceID); err != nil {
+		return auth.ACLEntry{}, err
+	}
+
+	created, err := svc.auth.CreateEnvironmentACLEntry(ctx, entry)
+	if err != nil {
+		return auth.ACLEntry{}, err
+	}
+
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionEnvironmentGrantAccess,
+		TargetKind: audit.TargetEnvironment,
+		TargetID:   strconv.Itoa(env.ID),
+		After:      created,
+	})
+	return created, nil
+}
+
+// RevokeAccess removes the ACL entry with entryID from the environment.
+func (svc *Service) RevokeAccess(ctx context.Context, actor auth.User, id, entryID int) error {
+	env, err := svc.repo.GetEnvironment(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.canManageEnvironments(ctx, actor, env.ServiceID); err != nil {
+		return err
+	}
+
+	entries, err := svc.auth.ListEnvironmentACL(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.auth.DeleteEnvironmentACLEntry(ctx, id, entryID); err != nil {
+		return err
+	}
+
+	var before *auth.ACLEntry
+	for _, entry := range entries {
+		if entry.ID == entryID {
+			before = &entry
+		}
 	}
 
-	return svc.repo.DeleteEnvironment(ctx, id)
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionEnvironmentRevokeAccess,
+		TargetKind: audit.TargetEnvironment,
+		TargetID:   strconv.Itoa(id),
+		Before:     before,
+	})
+	return nil
 }
@@ -0,0 +1,110 @@
+package environments_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/environments"
+)
+
+func TestCreateEnvironmentCannotPromoteToAnotherService(t *testing.T) {
+	repo, svcRepo := initTestDB(t)
+	service := environments.NewService(repo, auth.NewTestGateway(), nil)
+
+	svc1 := svcFixture(t, svcRepo, "svc1")
+	svc2 := svcFixture(t, svcRepo, "svc2")
+	production := envFixture(t, repo, "production", nil, svc2.ID)
+
+	_, err := service.CreateEnvironment(context.Background(), auth.User{}, environments.Environment{
+		Name:         "staging",
+		ServiceID:    svc1.ID,
+		PromotesToID: &production.ID,
+	})
+	if !errors.Is(err, environments.ErrPromotionService) {
+		t.Fatalf("Expected %s got: %v", environments.ErrPromotionService, err)
+	}
+}
+
+func TestUpdateEnvironmentCannotPromoteToAnotherService(t *testing.T) {
+	repo, svcRepo := initTestDB(t)
+	service := environments.NewService(repo, auth.NewTestGateway(), nil)
+
+	svc1 := svcFixture(t, svcRepo, "svc1")
+	svc2 := svcFixture(t, svcRepo, "svc2")
+	staging := envFixture(t, repo, "staging", nil, svc1.ID)
+	production := envFixture(t, repo, "production", nil, svc2.ID)
+
+	// The service in the request body must not be trusted.
+	staging.ServiceID = svc2.ID
+	staging.PromotesToID = &production.ID
+	_, err := service.UpdateEnvironment(context.Background(), auth.User{}, staging)
+	if !errors.Is(err, environments.ErrPromotionService) {
+		t.Fatalf("Expected %s got: %v", environments.ErrPromotionService, err)
+	}
+
+	stored, err := repo.GetEnvironment(context.Background(), staging.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.PromotesToID != nil {
+		t.Fatalf("Expected staging not to be updated got: %+v", stored)
+	}
+}
+
+func TestUpdateEnvironmentReturnsTheStoredServiceName(t *testing.T) {
+	repo, svcRepo := initTestDB(t)
+	service := environments.NewService(repo, auth.NewTestGateway(), nil)
+
+	svc1 := svcFixture(t, svcRepo, "svc1")
+	staging := envFixture(t, repo, "staging", nil, svc1.ID)
+
+	staging.Service = "svc2"
+	updated, err := service.UpdateEnvironment(context.Background(), auth.User{}, staging)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if updated.Service != svc1.Name {
+		t.Fatalf("Expected service %s got: %s", svc1.Name, updated.Service)
+	}
+}
+
+func TestListOverlaysOnlyReturnsOverlaysOfPermittedServices(t *testing.T) {
+	repo, svcRepo := initTestDB(t)
+	gateway := auth.NewTestGateway()
+	gateway.DenyPermissionCheck = true
+	service := environments.NewService(repo, gateway, nil)
+
+	svc1 := svcFixture(t, svcRepo, "svc1")
+	svc2 := svcFixture(t, svcRepo, "svc2")
+	for _, serviceID := range []int{svc1.ID, svc2.ID} {
+		_, err := repo.CreateOverlay(context.Background(), environments.Overlay{
+			ServiceID: serviceID,
+			Dimension: "region",
+			Name:      "eu",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	_, err := service.ListOverlays(context.Background(), auth.User{}, nil)
+	if !errors.Is(err, auth.ErrUnauthorized) {
+		t.Fatalf("Expected %s got: %v", auth.ErrUnauthorized, err)
+	}
+
+	gateway.ServicePermissions = map[int][]auth.Permission{
+		svc1.ID: {auth.PermissionConfigureEnvironments},
+	}
+	overlays, err := service.ListOverlays(context.Background(), auth.User{}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(overlays) != 1 || overlays[0].ServiceID != svc1.ID {
+		t.Fatalf("Expected only the overlay of %s got: %v", svc1.Name, overlays)
+	}
+}
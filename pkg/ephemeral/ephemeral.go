@@ -0,0 +1,29 @@
+package ephemeral
+
+import (
+	"errors"
+	"time"
+
+	"github.com/config-source/cdb/pkg/configvalues"
+)
+
+var (
+	ErrExpiresAtInPast = errors.New("ephemeral environments must expire in the future")
+	ErrNoParent        = errors.New("ephemeral environments must have a parent environment")
+)
+
+// Request describes an ephemeral environment to create as a child of
+// ParentID. If Name is empty one is generated from the parent's name and if
+// ExpiresAt is nil the service's default TTL is used.
+type Request struct {
+	ParentID  int
+	Name      string
+	ExpiresAt *time.Time
+	// Values are set directly on the new environment, overriding what it
+	// inherits from the parent.
+	Values []*configvalues.ConfigValue
+}
+
+type ExtendRequest struct {
+	ExpiresAt time.Time
+}
@@ -0,0 +1,241 @@
+package ephemeral
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/config-source/cdb/pkg/audit"
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/configvalues"
+	"github.com/config-source/cdb/pkg/environments"
+)
+
+type Service struct {
+	// DefaultTTL is how long ephemeral environments live when the request
+	// doesn't specify an expiry.
+	DefaultTTL time.Duration
+
+	envRepo *environments.Repository
+	values  *configvalues.Service
+	auth    auth.AuthorizationGateway
+	audit   *audit.Log
+}
+
+func NewService(
+	envRepo *environments.Repository,
+	values *configvalues.Service,
+	auth auth.AuthorizationGateway,
+	defaultTTL time.Duration,
+	auditLog *audit.Log,
+) *Service {
+	return &Service{
+		DefaultTTL: defaultTTL,
+		envRepo:    envRepo,
+		values:     values,
+		auth:       auth,
+		audit:      auditLog,
+	}
+}
+
+func (svc *Service) canManage(ctx context.Context, actor auth.User, serviceID int) error {
+	canManage, err := svc.auth.HasServicePermission(
+		ctx,
+		actor,
+		serviceID,
+		auth.PermissionManageEphemeralEnvironments,
+		auth.PermissionManageEnvironments,
+	)
+	if err != nil {
+		return err
+	}
+
+	if !canManage {
+		return auth.ErrUnauthorized
+	}
+
+	return nil
+}
+
+// getEphemeral returns the environment if it exists and is ephemeral so that
+// this service can never be used to modify permanent environments.
+func (svc *Service) getEphemeral(ctx context.Context, id int) (environments.Environment, error) {
+	env, err := svc.envRepo.GetEnvironment(ctx, id)
+	if err != nil {
+		return env, err
+	}
+
+	if !env.Ephemeral() {
+		return env, environments.ErrNotEphemeral
+	}
+
+	return env, nil
+}
+
+func generateName(parent environments.Environment) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+
+	return fmt.Sprintf("%s-%s", parent.Name, hex.EncodeToString(suffix)), nil
+}
+
+// CreateEnvironment creates an ephemeral child of the requested parent and sets
+// any override values on it. If the values can't be set the environment is
+// removed again.
+func (svc *Service) CreateEnvironment(ctx context.Context, actor auth.User, req Request) (environments.Environment, error) {
+	if req.ParentID == 0 {
+		return environments.Environment{}, ErrNoParent
+	}
+
+	expiresAt := time.Now().Add(svc.DefaultTTL)
+	if req.ExpiresAt != nil {
+		expiresAt = *req.ExpiresAt
+	}
+
+	if !expiresAt.After(time.Now()) {
+		return environments.Environment{}, ErrExpiresAtInPast
+	}
+
+	parent, err := svc.envRepo.GetEnvironment(ctx, req.ParentID)
+	if err != nil {
+		return environments.Environment{}, err
+	}
+
+	if err := svc.canManage(ctx, actor, parent.ServiceID); err != nil {
+		return environments.Environment{}, err
+	}
+
+	// The child inherits all of the parent's values so creating one mustn't
+	// let the actor read anything they couldn't read on the parent.
+	if err := svc.values.CanReadEnvironment(ctx, actor, parent); err != nil {
+		return environments.Environment{}, err
+	}
+
+	name := req.Name
+	if name == "" {
+		name, err = generateName(parent)
+		if err != nil {
+			return environments.Environment{}, err
+		}
+	}
+
+	env, err := svc.envRepo.CreateEnvironment(ctx, environments.Environment{
+		Name:         name,
+		PromotesToID: &parent.ID,
+		ServiceID:    parent.ServiceID,
+		// Children can read everything their parent has so they must be at
+		// least as sensitive.
+		Sensitive: parent.Sensitive,
+		ExpiresAt: &expiresAt,
+	})
+	if err != nil {
+		return environments.Environment{}, err
+	}
+
+	env.Service = parent.Service
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionEphemeralEnvironmentCreate,
+		TargetKind: audit.TargetEnvironment,
+		TargetID:   strconv.Itoa(env.ID),
+		After:      env,
+	})
+
+	if len(req.Values) > 0 {
+		_, err = svc.values.SetConfigurationValues(ctx, actor, env.ID, req.Values)
+		if err != nil {
+			if deleteErr := svc.envRepo.DeleteEnvironment(ctx, env.ID); deleteErr != nil {
+				return environments.Environment{}, errors.Join(err, deleteErr)
+			}
+
+			svc.audit.Record(ctx, actor, audit.Event{
+				Action:     audit.ActionEnvironmentDelete,
+				TargetKind: audit.TargetEnvironment,
+				TargetID:   strconv.Itoa(env.ID),
+				Before:     env,
+			})
+			return environments.Environment{}, err
+		}
+	}
+
+	return env, nil
+}
+
+func (svc *Service) ExtendEnvironment(ctx context.Context, actor auth.User, id int, expiresAt time.Time) (environments.Environment, error) {
+	if !expiresAt.After(time.Now()) {
+		return environments.Environment{}, ErrExpiresAtInPast
+	}
+
+	env, err := svc.getEphemeral(ctx, id)
+	if err != nil {
+		return env, err
+	}
+
+	if err := svc.canManage(ctx, actor, env.ServiceID); err != nil {
+		return environments.Environment{}, err
+	}
+
+	extended, err := svc.envRepo.ExtendEnvironment(ctx, id, expiresAt)
+	if err != nil {
+		return environments.Environment{}, err
+	}
+
+	extended.Service = env.Service
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionEphemeralEnvironmentExtend,
+		TargetKind: audit.TargetEnvironment,
+		TargetID:   strconv.Itoa(id),
+		Before:     env,
+		After:      extended,
+	})
+	return extended, nil
+}
+
+func (svc *Service) DeleteEnvironment(ctx context.Context, actor auth.User, id int) error {
+	env, err := svc.getEphemeral(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := svc.canManage(ctx, actor, env.ServiceID); err != nil {
+		return err
+	}
+
+	if err := svc.envRepo.DeleteEnvironment(ctx, id); err != nil {
+		return err
+	}
+
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionEnvironmentDelete,
+		TargetKind: audit.TargetEnvironment,
+		TargetID:   strconv.Itoa(id),
+		Before:     env,
+	})
+	return nil
+}
+
+// ReapExpiredEnvironments deletes every ephemeral environment which has
+// expired and returns how many were deleted. Environments which fail to be
+// deleted are logged and don't stop the others being reaped.
+func (svc *Service) ReapExpiredEnvironments(ctx context.Context) (int, error) {
+	reaped, err := svc.envRepo.ReapExpiredEnvironments(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, env := range reaped {
+		svc.audit.Record(ctx, audit.System, audit.Event{
+			Action:     audit.ActionEphemeralEnvironmentReap,
+			TargetKind: audit.TargetEnvironment,
+			TargetID:   strconv.Itoa(env.ID),
+			Before:     env,
+		})
+	}
+
+	return len(reaped), nil
+}
@@ -0,0 +1,217 @@
+package ephemeral_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/configkeys"
+	"github.com/config-source/cdb/pkg/configvalues"
+	"github.com/config-source/cdb/pkg/environments"
+	"github.com/config-source/cdb/pkg/ephemeral"
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/config-source/cdb/pkg/services"
+	"github.com/rs/zerolog"
+)
+
+type TestContext struct {
+	service         *ephemeral.Service
+	gateway         *auth.TestGateway
+	valueRepo       *configvalues.Repository
+	environmentRepo *environments.Repository
+	parent          environments.Environment
+}
+
+func initTestDB(t *testing.T) TestContext {
+	t.Helper()
+
+	pool := postgresutils.InitTestDB(t)
+	logger := zerolog.New(nil).Level(zerolog.Disabled)
+
+	svcRepo := services.NewRepository(logger, pool)
+	envRepo := environments.NewRepository(logger, pool)
+	keyRepo := configkeys.NewRepository(logger, pool)
+	valueRepo := configvalues.NewRepository(logger, pool, envRepo, keyRepo)
+
+	gateway := auth.NewTestGateway()
+	valueService := configvalues.NewService(logger, valueRepo, envRepo, keyRepo, gateway, true, nil)
+
+	svc, err := svcRepo.CreateService(context.Background(), services.Service{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parent, err := envRepo.CreateEnvironment(context.Background(), environments.Environment{
+		Name:      "staging",
+		ServiceID: svc.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return TestContext{
+		service:         ephemeral.NewService(envRepo, valueService, gateway, time.Hour, nil),
+		gateway:         gateway,
+		valueRepo:       valueRepo,
+		environmentRepo: envRepo,
+		parent:          parent,
+	}
+}
+
+func TestCreateEphemeralEnvironment(t *testing.T) {
+	tc := initTestDB(t)
+
+	cv := &configvalues.ConfigValue{Name: "owner"}
+	cv.SetStrValue("platform")
+
+	env, err := tc.service.CreateEnvironment(context.Background(), auth.User{}, ephemeral.Request{
+		ParentID: tc.parent.ID,
+		Values:   []*configvalues.ConfigValue{cv},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(env.Name, tc.parent.Name+"-") {
+		t.Fatalf("Expected a generated name based on %s got: %s", tc.parent.Name, env.Name)
+	}
+
+	if env.PromotesToID == nil || *env.PromotesToID != tc.parent.ID {
+		t.Fatalf("Expected environment to promote to %d got: %v", tc.parent.ID, env.PromotesToID)
+	}
+
+	if !env.Ephemeral() {
+		t.Fatal("Expected environment to be ephemeral")
+	}
+
+	owner, err := tc.valueRepo.GetConfigurationValue(context.Background(), env.ID, "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *owner.StrValue != "platform" {
+		t.Fatalf("Expected owner to be platform got: %s", *owner.StrValue)
+	}
+}
+
+func TestCreateEphemeralEnvironmentRequiresReadingTheParent(t *testing.T) {
+	tc := initTestDB(t)
+
+	production, err := tc.environmentRepo.CreateEnvironment(context.Background(), environments.Environment{
+		Name:      "production",
+		ServiceID: tc.parent.ServiceID,
+		Sensitive: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc.gateway.DenyPermissionCheck = true
+	tc.gateway.ServicePermissions[production.ServiceID] = []auth.Permission{
+		auth.PermissionManageEphemeralEnvironments,
+		auth.PermissionReadConfiguration,
+	}
+
+	_, err = tc.service.CreateEnvironment(context.Background(), auth.User{}, ephemeral.Request{ParentID: production.ID})
+	if !errors.Is(err, environments.ErrNotFound) {
+		t.Fatalf("Expected %s got: %v", environments.ErrNotFound, err)
+	}
+
+	tc.gateway.EnvironmentAccess[production.ID] = auth.AccessRead
+	if _, err := tc.service.CreateEnvironment(context.Background(), auth.User{}, ephemeral.Request{ParentID: production.ID}); err != nil {
+		t.Fatalf("Expected readers of the parent to create a child got: %s", err)
+	}
+}
+
+func TestCreateEphemeralEnvironmentRejectsPastExpiry(t *testing.T) {
+	tc := initTestDB(t)
+
+	expiresAt := time.Now().Add(-time.Minute)
+	_, err := tc.service.CreateEnvironment(context.Background(), auth.User{}, ephemeral.Request{
+		ParentID:  tc.parent.ID,
+		ExpiresAt: &expiresAt,
+	})
+	if !errors.Is(err, ephemeral.ErrExpiresAtInPast) {
+		t.Fatalf("Expected %s got: %s", ephemeral.ErrExpiresAtInPast, err)
+	}
+}
+
+func TestEphemeralServiceDoesNotModifyPermanentEnvironments(t *testing.T) {
+	tc := initTestDB(t)
+
+	err := tc.service.DeleteEnvironment(context.Background(), auth.User{}, tc.parent.ID)
+	if !errors.Is(err, environments.ErrNotEphemeral) {
+		t.Fatalf("Expected %s got: %s", environments.ErrNotEphemeral, err)
+	}
+
+	_, err = tc.service.ExtendEnvironment(context.Background(), auth.User{}, tc.parent.ID, time.Now().Add(time.Hour))
+	if !errors.Is(err, environments.ErrNotEphemeral) {
+		t.Fatalf("Expected %s got: %s", environments.ErrNotEphemeral, err)
+	}
+}
+
+func TestReapExpiredEnvironments(t *testing.T) {
+	tc := initTestDB(t)
+
+	expired := time.Now().Add(-time.Minute)
+	stale, err := tc.environmentRepo.CreateEnvironment(context.Background(), environments.Environment{
+		Name:         "stale",
+		ServiceID:    tc.parent.ServiceID,
+		PromotesToID: &tc.parent.ID,
+		ExpiresAt:    &expired,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fresh, err := tc.service.CreateEnvironment(context.Background(), auth.User{}, ephemeral.Request{
+		ParentID: tc.parent.ID,
+		Name:     "fresh",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Can't be deleted while another environment promotes to it, which
+	// mustn't stop the others being reaped.
+	blocked, err := tc.environmentRepo.CreateEnvironment(context.Background(), environments.Environment{
+		Name:         "blocked",
+		ServiceID:    tc.parent.ServiceID,
+		PromotesToID: &tc.parent.ID,
+		ExpiresAt:    &expired,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tc.environmentRepo.CreateEnvironment(context.Background(), environments.Environment{
+		Name:         "dependent",
+		ServiceID:    tc.parent.ServiceID,
+		PromotesToID: &blocked.ID,
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	reaped, err := tc.service.ReapExpiredEnvironments(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reaped != 1 {
+		t.Fatalf("Expected 1 environment to be reaped got: %d", reaped)
+	}
+
+	_, err = tc.environmentRepo.GetEnvironment(context.Background(), stale.ID)
+	if !errors.Is(err, environments.ErrNotFound) {
+		t.Fatalf("Expected %s got: %s", environments.ErrNotFound, err)
+	}
+
+	for _, id := range []int{fresh.ID, blocked.ID, tc.parent.ID} {
+		if _, err := tc.environmentRepo.GetEnvironment(context.Background(), id); err != nil {
+			t.Fatalf("Expected environment %d to survive got: %s", id, err)
+		}
+	}
+}
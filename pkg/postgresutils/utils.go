@@ -36,10 +36,16 @@ func HealthCheck(ctx context.Context, pool *pgxpool.Pool, logger zerolog.Logger)
 
 // boilerplate reducing utilities
 
+// Querier is satisfied by both *pgxpool.Pool and pgx.Tx so that the helpers
+// below can be used inside of transactions.
+type Querier interface {
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
 // GetOne runs the given query and serializes the returned row into T
 //
 // If more than a single row matches the given query an error is returned.
-func GetOne[T any](pool *pgxpool.Pool, ctx context.Context, sql string, args ...interface{}) (T, error) {
+func GetOne[T any](pool Querier, ctx context.Context, sql string, args ...interface{}) (T, error) {
 	rows, err := pool.Query(ctx, sql, args...)
 	if err != nil {
 		var def T
@@ -55,7 +61,7 @@ func GetOne[T any](pool *pgxpool.Pool, ctx context.Context, sql string, args ...
 //
 // GetOne should be preferred but when ignoring missing columns is desirable
 // this function should be used.
-func GetOneLax[T any](pool *pgxpool.Pool, ctx context.Context, sql string, args ...interface{}) (T, error) {
+func GetOneLax[T any](pool Querier, ctx context.Context, sql string, args ...interface{}) (T, error) {
 	rows, err := pool.Query(ctx, sql, args...)
 	if err != nil {
 		var def T
@@ -66,7 +72,7 @@ func GetOneLax[T any](pool *pgxpool.Pool, ctx context.Context, sql string, args
 }
 
 // GetAll runs the given query and serializes the returned rows into a slice of T
-func GetAll[T any](pool *pgxpool.Pool, ctx context.Context, sql string, args ...interface{}) ([]T, error) {
+func GetAll[T any](pool Querier, ctx context.Context, sql string, args ...interface{}) ([]T, error) {
 	rows, err := pool.Query(ctx, sql, args...)
 	if err != nil {
 		var def []T
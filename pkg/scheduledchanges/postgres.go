@@ -0,0 +1,126 @@
+package scheduledchanges
+
+import (
+	"context"
+	_ "embed"
+	"errors"
+	"time"
+
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/rs/zerolog"
+)
+
+type Repository struct {
+	pool *pgxpool.Pool
+	log  zerolog.Logger
+}
+
+func NewRepository(log zerolog.Logger, pool *pgxpool.Pool) *Repository {
+	return &Repository{
+		log:  log,
+		pool: pool,
+	}
+}
+
+//go:embed queries/create_scheduled_change.sql
+var createScheduledChangeSql string
+
+//go:embed queries/get_scheduled_change_by_id.sql
+var getScheduledChangeByIDSql string
+
+//go:embed queries/list_scheduled_changes.sql
+var listScheduledChangesSql string
+
+//go:embed queries/cancel_scheduled_change.sql
+var cancelScheduledChangeSql string
+
+//go:embed queries/reschedule_scheduled_change.sql
+var rescheduleScheduledChangeSql string
+
+//go:embed queries/claim_due_scheduled_change.sql
+var claimDueScheduledChangeSql string
+
+//go:embed queries/finish_scheduled_change.sql
+var finishScheduledChangeSql string
+
+func (r *Repository) CreateScheduledChange(ctx context.Context, change ScheduledChange) (ScheduledChange, error) {
+	return postgresutils.GetOne[ScheduledChange](
+		r.pool,
+		ctx,
+		createScheduledChangeSql,
+		change.EnvironmentID,
+		change.AuthorID,
+		change.ApplyAt,
+		change.Values,
+	)
+}
+
+func (r *Repository) GetScheduledChange(ctx context.Context, id int) (ScheduledChange, error) {
+	change, err := postgresutils.GetOne[ScheduledChange](r.pool, ctx, getScheduledChangeByIDSql, id)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return change, ErrNotFound
+	}
+
+	return change, err
+}
+
+// ListScheduledChanges returns scheduled changes ordered by when they apply.
+// A nil environmentID or status matches all environments or statuses.
+func (r *Repository) ListScheduledChanges(ctx context.Context, environmentID *int, status *Status) ([]ScheduledChange, error) {
+	return postgresutils.GetAll[ScheduledChange](
+		r.pool,
+		ctx,
+		listScheduledChangesSql,
+		environmentID,
+		status,
+	)
+}
+
+// updatePending runs a query which only modifies the change if it is still
+// pending and distinguishes between the change not existing and it no longer
+// being pending.
+func (r *Repository) updatePending(ctx context.Context, sql string, args ...interface{}) (ScheduledChange, error) {
+	change, err := postgresutils.GetOne[ScheduledChange](r.pool, ctx, sql, args...)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		if _, getErr := r.GetScheduledChange(ctx, args[0].(int)); getErr != nil {
+			return change, getErr
+		}
+
+		return change, ErrNotPending
+	}
+
+	return change, err
+}
+
+func (r *Repository) CancelScheduledChange(ctx context.Context, id int) (ScheduledChange, error) {
+	return r.updatePending(ctx, cancelScheduledChangeSql, id)
+}
+
+func (r *Repository) RescheduleChange(ctx context.Context, id int, applyAt time.Time) (ScheduledChange, error) {
+	return r.updatePending(ctx, rescheduleScheduledChangeSql, id, applyAt)
+}
+
+// ClaimDueChange atomically marks the oldest due change as APPLYING and
+// returns it. Rows locked by other replicas are skipped so each change is
+// claimed exactly once. Returns ErrNotFound when nothing is due.
+func (r *Repository) ClaimDueChange(ctx context.Context, staleClaimsBefore time.Time) (ScheduledChange, error) {
+	change, err := postgresutils.GetOne[ScheduledChange](r.pool, ctx, claimDueScheduledChangeSql, staleClaimsBefore)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return change, ErrNotFound
+	}
+
+	return change, err
+}
+
+func (r *Repository) FinishScheduledChange(ctx context.Context, id int, status Status, failureReason string) (ScheduledChange, error) {
+	return postgresutils.GetOne[ScheduledChange](
+		r.pool,
+		ctx,
+		finishScheduledChangeSql,
+		id,
+		status,
+		failureReason,
+	)
+}
@@ -0,0 +1,464 @@
+package scheduledchanges_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/config-source/cdb/pkg/audit"
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/configkeys"
+	"github.com/config-source/cdb/pkg/configvalues"
+	"github.com/config-source/cdb/pkg/environments"
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/config-source/cdb/pkg/scheduledchanges"
+	"github.com/config-source/cdb/pkg/services"
+	"github.com/rs/zerolog"
+)
+
+type TestContext struct {
+	repo            *scheduledchanges.Repository
+	service         *scheduledchanges.Service
+	gateway         *auth.TestGateway
+	auditLog        *audit.Log
+	valueRepo       *configvalues.Repository
+	keyRepo         *configkeys.Repository
+	environmentRepo *environments.Repository
+	env             environments.Environment
+}
+
+func initTestDB(t *testing.T) TestContext {
+	t.Helper()
+
+	pool := postgresutils.InitTestDB(t)
+	logger := zerolog.New(nil).Level(zerolog.Disabled)
+
+	svcRepo := services.NewRepository(logger, pool)
+	envRepo := environments.NewRepository(logger, pool)
+	keyRepo := configkeys.NewRepository(logger, pool)
+	valueRepo := configvalues.NewRepository(logger, pool, envRepo, keyRepo)
+	repo := scheduledchanges.NewRepository(logger, pool)
+
+	gateway := auth.NewTestGateway()
+	auditLog := audit.NewLog(logger, pool)
+	valueService := configvalues.NewService(logger, valueRepo, envRepo, keyRepo, gateway, true, nil)
+
+	svc, err := svcRepo.CreateService(context.Background(), services.Service{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := envRepo.CreateEnvironment(context.Background(), environments.Environment{
+		Name:      "production",
+		ServiceID: svc.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return TestContext{
+		repo:            repo,
+		service:         scheduledchanges.NewService(repo, envRepo, valueService, gateway, gateway, auditLog),
+		gateway:         gateway,
+		auditLog:        auditLog,
+		valueRepo:       valueRepo,
+		keyRepo:         keyRepo,
+		environmentRepo: envRepo,
+		env:             env,
+	}
+}
+
+func changeFixture(t *testing.T, tc TestContext, applyAt time.Time, authorID auth.UserID) scheduledchanges.ScheduledChange {
+	t.Helper()
+
+	cv := &configvalues.ConfigValue{Name: "owner"}
+	cv.SetStrValue("platform")
+
+	change, err := tc.repo.CreateScheduledChange(context.Background(), scheduledchanges.ScheduledChange{
+		EnvironmentID: tc.env.ID,
+		AuthorID:      authorID,
+		ApplyAt:       applyAt,
+		Values:        []*configvalues.ConfigValue{cv},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return change
+}
+
+func TestCreateScheduledChange(t *testing.T) {
+	tc := initTestDB(t)
+
+	applyAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	change := changeFixture(t, tc, applyAt, 1)
+
+	if change.ID == 0 {
+		t.Fatalf("Expected ID to be set got: %d", change.ID)
+	}
+
+	if change.Status != scheduledchanges.StatusPending {
+		t.Fatalf("Expected status %s got: %s", scheduledchanges.StatusPending, change.Status)
+	}
+
+	if !change.ApplyAt.Equal(applyAt) {
+		t.Fatalf("Expected ApplyAt %s got: %s", applyAt, change.ApplyAt)
+	}
+
+	if len(change.Values) != 1 || change.Values[0].Name != "owner" || *change.Values[0].StrValue != "platform" {
+		t.Fatalf("Expected values to round trip got: %+v", change.Values)
+	}
+}
+
+func TestCancelScheduledChange(t *testing.T) {
+	tc := initTestDB(t)
+	change := changeFixture(t, tc, time.Now().Add(time.Hour), 1)
+
+	cancelled, err := tc.repo.CancelScheduledChange(context.Background(), change.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cancelled.Status != scheduledchanges.StatusCancelled {
+		t.Fatalf("Expected status %s got: %s", scheduledchanges.StatusCancelled, cancelled.Status)
+	}
+
+	_, err = tc.repo.CancelScheduledChange(context.Background(), change.ID)
+	if !errors.Is(err, scheduledchanges.ErrNotPending) {
+		t.Fatalf("Expected %s got: %s", scheduledchanges.ErrNotPending, err)
+	}
+
+	_, err = tc.repo.CancelScheduledChange(context.Background(), change.ID+1)
+	if !errors.Is(err, scheduledchanges.ErrNotFound) {
+		t.Fatalf("Expected %s got: %s", scheduledchanges.ErrNotFound, err)
+	}
+}
+
+func TestClaimDueChangeOnlyClaimsDueChangesOnce(t *testing.T) {
+	tc := initTestDB(t)
+	due := changeFixture(t, tc, time.Now().Add(-time.Minute), 1)
+	changeFixture(t, tc, time.Now().Add(time.Hour), 1)
+
+	staleBefore := time.Now().Add(-time.Hour)
+	claimed, err := tc.repo.ClaimDueChange(context.Background(), staleBefore)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claimed.ID != due.ID {
+		t.Fatalf("Expected to claim change %d got: %d", due.ID, claimed.ID)
+	}
+
+	if claimed.Status != scheduledchanges.StatusApplying {
+		t.Fatalf("Expected status %s got: %s", scheduledchanges.StatusApplying, claimed.Status)
+	}
+
+	_, err = tc.repo.ClaimDueChange(context.Background(), staleBefore)
+	if !errors.Is(err, scheduledchanges.ErrNotFound) {
+		t.Fatalf("Expected %s got: %s", scheduledchanges.ErrNotFound, err)
+	}
+
+	reclaimed, err := tc.repo.ClaimDueChange(context.Background(), time.Now().Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if reclaimed.ID != due.ID {
+		t.Fatalf("Expected stale claim on %d to be reclaimed got: %d", due.ID, reclaimed.ID)
+	}
+}
+
+func TestApplyDueChangesAppliesAsAuthor(t *testing.T) {
+	tc := initTestDB(t)
+	author, err := tc.gateway.Register(context.Background(), "test@example.com", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	change := changeFixture(t, tc, time.Now().Add(-time.Minute), author.ID)
+
+	applied, err := tc.service.ApplyDueChanges(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if applied != 1 {
+		t.Fatalf("Expected 1 change to be applied got: %d", applied)
+	}
+
+	change, err = tc.repo.GetScheduledChange(context.Background(), change.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if change.Status != scheduledchanges.StatusApplied {
+		t.Fatalf("Expected status %s got: %s (%s)", scheduledchanges.StatusApplied, change.Status, change.Error)
+	}
+
+	cv, err := tc.valueRepo.GetConfigurationValue(context.Background(), tc.env.ID, "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *cv.StrValue != "platform" {
+		t.Fatalf("Expected owner to be platform got: %s", *cv.StrValue)
+	}
+}
+
+func TestApplyDueChangesFailsWhenAuthorLostPermission(t *testing.T) {
+	tc := initTestDB(t)
+	author, err := tc.gateway.Register(context.Background(), "test@example.com", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	change := changeFixture(t, tc, time.Now().Add(-time.Minute), author.ID)
+	tc.gateway.DenyPermissionCheck = true
+
+	applied, err := tc.service.ApplyDueChanges(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if applied != 0 {
+		t.Fatalf("Expected no changes to be applied got: %d", applied)
+	}
+
+	change, err = tc.repo.GetScheduledChange(context.Background(), change.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if change.Status != scheduledchanges.StatusFailed {
+		t.Fatalf("Expected status %s got: %s", scheduledchanges.StatusFailed, change.Status)
+	}
+
+	if change.Error == "" {
+		t.Fatal("Expected the failure reason to be recorded")
+	}
+}
+
+func TestApplyDueChangesFailsWhenAuthorIsDisabled(t *testing.T) {
+	tc := initTestDB(t)
+	author, err := tc.gateway.Register(context.Background(), "test@example.com", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	change := changeFixture(t, tc, time.Now().Add(-time.Minute), author.ID)
+	if _, err := tc.gateway.SetUserDisabled(context.Background(), author.ID, true); err != nil {
+		t.Fatal(err)
+	}
+
+	applied, err := tc.service.ApplyDueChanges(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if applied != 0 {
+		t.Fatalf("Expected no changes to be applied got: %d", applied)
+	}
+
+	change, err = tc.repo.GetScheduledChange(context.Background(), change.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if change.Status != scheduledchanges.StatusFailed {
+		t.Fatalf("Expected status %s got: %s", scheduledchanges.StatusFailed, change.Status)
+	}
+
+	if change.Error != auth.ErrUserDisabled.Error() {
+		t.Fatalf("Expected the failure reason to be %q got: %q", auth.ErrUserDisabled, change.Error)
+	}
+}
+
+func TestScheduledChangesRedactSensitiveValues(t *testing.T) {
+	tc := initTestDB(t)
+
+	owner := configkeys.New(tc.env.ServiceID, "owner", configkeys.TypeString)
+	owner.Sensitive = true
+	if _, err := tc.keyRepo.CreateConfigKey(context.Background(), owner); err != nil {
+		t.Fatal(err)
+	}
+
+	change := changeFixture(t, tc, time.Now().Add(time.Hour), 1)
+
+	retrieved, err := tc.service.GetScheduledChange(context.Background(), auth.User{}, change.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !retrieved.Values[0].Redacted || retrieved.Values[0].StrValue != nil {
+		t.Errorf("Expected owner to be redacted got: %+v", retrieved.Values[0])
+	}
+
+	changes, err := tc.service.ListScheduledChanges(context.Background(), auth.User{}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(changes) != 1 || !changes[0].Values[0].Redacted || changes[0].Values[0].StrValue != nil {
+		t.Errorf("Expected owner to be redacted got: %+v", changes)
+	}
+
+	// The stored value must be untouched so that it's applied as written.
+	stored, err := tc.repo.GetScheduledChange(context.Background(), change.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stored.Values[0].StrValue == nil || *stored.Values[0].StrValue != "platform" {
+		t.Errorf("Expected the stored value to be platform got: %+v", stored.Values[0])
+	}
+}
+
+func TestScheduledChangesAreAuditedRedacted(t *testing.T) {
+	tc := initTestDB(t)
+	author, err := tc.gateway.Register(context.Background(), "test@example.com", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner := configkeys.New(tc.env.ServiceID, "owner", configkeys.TypeString)
+	owner.Sensitive = true
+	if _, err := tc.keyRepo.CreateConfigKey(context.Background(), owner); err != nil {
+		t.Fatal(err)
+	}
+
+	cv := &configvalues.ConfigValue{Name: "owner"}
+	cv.SetStrValue("platform")
+	change, err := tc.service.ScheduleChange(context.Background(), author, scheduledchanges.ScheduledChange{
+		EnvironmentID: tc.env.ID,
+		ApplyAt:       time.Now().Add(time.Hour),
+		Values:        []*configvalues.ConfigValue{cv},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tc.service.CancelScheduledChange(context.Background(), author, change.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	changeFixture(t, tc, time.Now().Add(-time.Minute), author.ID)
+	if _, err := tc.service.ApplyDueChanges(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	kind := audit.TargetScheduledChange
+	entries, err := tc.auditLog.List(context.Background(), audit.Filter{TargetKind: &kind})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	actions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		actions = append(actions, entry.Action)
+		if strings.Contains(string(entry.Before)+string(entry.After), "platform") {
+			t.Errorf("Expected %s entry to be redacted got: %s %s", entry.Action, entry.Before, entry.After)
+		}
+	}
+
+	expected := []string{
+		audit.ActionScheduledChangeApply,
+		audit.ActionScheduledChangeCancel,
+		audit.ActionScheduledChangeCreate,
+	}
+	if strings.Join(actions, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected %v to be audited got: %v", expected, actions)
+	}
+}
+
+func TestScheduleChangeRejectsInvalidValues(t *testing.T) {
+	tc := initTestDB(t)
+	author, err := tc.gateway.Register(context.Background(), "test@example.com", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	owner := configkeys.New(tc.env.ServiceID, "owner", configkeys.TypeString)
+	if _, err := tc.keyRepo.CreateConfigKey(context.Background(), owner); err != nil {
+		t.Fatal(err)
+	}
+
+	overlayID := 1000
+	tests := map[string]func() *configvalues.ConfigValue{
+		"wrong type": func() *configvalues.ConfigValue {
+			return (&configvalues.ConfigValue{Name: "owner"}).SetIntValue(1)
+		},
+		// Dynamic config keys are enabled so unknown keys need a type.
+		"unknown key without a type": func() *configvalues.ConfigValue {
+			replicas := 1
+			return &configvalues.ConfigValue{Name: "maxReplicas", IntValue: &replicas}
+		},
+		"unknown overlay": func() *configvalues.ConfigValue {
+			cv := (&configvalues.ConfigValue{Name: "owner"}).SetStrValue("platform")
+			cv.OverlayID = &overlayID
+			return cv
+		},
+	}
+
+	for name, value := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := tc.service.ScheduleChange(context.Background(), author, scheduledchanges.ScheduledChange{
+				EnvironmentID: tc.env.ID,
+				ApplyAt:       time.Now().Add(time.Hour),
+				Values:        []*configvalues.ConfigValue{value()},
+			})
+			if !errors.Is(err, configvalues.ErrNotValid) {
+				t.Fatalf("Expected %s got: %v", configvalues.ErrNotValid, err)
+			}
+		})
+	}
+}
+
+func TestScheduledChangesInSensitiveEnvironmentsAreAuditedRedacted(t *testing.T) {
+	tc := initTestDB(t)
+	author, err := tc.gateway.Register(context.Background(), "test@example.com", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tc.env.Sensitive = true
+	if _, err := tc.environmentRepo.UpdateEnvironment(context.Background(), tc.env); err != nil {
+		t.Fatal(err)
+	}
+
+	cv := &configvalues.ConfigValue{Name: "owner"}
+	cv.SetStrValue("platform")
+	change, err := tc.service.ScheduleChange(context.Background(), author, scheduledchanges.ScheduledChange{
+		EnvironmentID: tc.env.ID,
+		ApplyAt:       time.Now().Add(time.Hour),
+		Values:        []*configvalues.ConfigValue{cv},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The author is still shown what they scheduled.
+	if change.Values[0].StrValue == nil {
+		t.Errorf("Expected owner not to be redacted for the author got: %+v", change.Values[0])
+	}
+
+	if _, err := tc.service.CancelScheduledChange(context.Background(), author, change.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := tc.auditLog.List(context.Background(), audit.Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) == 0 {
+		t.Fatal("Expected the scheduled change to be audited")
+	}
+
+	for _, entry := range entries {
+		if strings.Contains(string(entry.Before)+string(entry.After), "platform") {
+			t.Errorf("Expected %s entry to be redacted got: %s %s", entry.Action, entry.Before, entry.After)
+		}
+	}
+}
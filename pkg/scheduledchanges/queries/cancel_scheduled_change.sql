@@ -0,0 +1,4 @@
+UPDATE scheduled_changes
+SET status = 'CANCELLED'
+WHERE id = $1 AND status = 'PENDING'
+RETURNING *;
@@ -0,0 +1,19 @@
+-- Claims a single due change so that only one cdbd replica applies it. Changes
+-- stuck in APPLYING because a replica died mid-apply are reclaimed once their
+-- claim is older than $1.
+UPDATE scheduled_changes
+SET status = 'APPLYING',
+    claimed_at = now()
+WHERE id = (
+    SELECT id FROM scheduled_changes
+    WHERE
+        apply_at <= now()
+        AND (
+            status = 'PENDING'
+            OR (status = 'APPLYING' AND claimed_at < $1)
+        )
+    ORDER BY apply_at
+    FOR UPDATE SKIP LOCKED
+    LIMIT 1
+)
+RETURNING *;
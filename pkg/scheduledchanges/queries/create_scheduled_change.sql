@@ -0,0 +1,13 @@
+INSERT INTO scheduled_changes (
+    environment_id,
+    author_id,
+    apply_at,
+    config_values
+)
+VALUES (
+    $1,
+    $2,
+    $3,
+    $4
+)
+RETURNING *;
@@ -0,0 +1,6 @@
+UPDATE scheduled_changes
+SET status = $2,
+    error = $3,
+    applied_at = now()
+WHERE id = $1
+RETURNING *;
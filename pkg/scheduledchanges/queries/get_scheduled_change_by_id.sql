@@ -0,0 +1 @@
+SELECT * FROM scheduled_changes WHERE id = $1;
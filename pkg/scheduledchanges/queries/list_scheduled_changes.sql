@@ -0,0 +1,5 @@
+SELECT * FROM scheduled_changes
+WHERE
+    ($1::integer IS NULL OR environment_id = $1)
+    AND ($2::text IS NULL OR status = $2)
+ORDER BY apply_at;
@@ -0,0 +1,4 @@
+UPDATE scheduled_changes
+SET apply_at = $2
+WHERE id = $1 AND status = 'PENDING'
+RETURNING *;
@@ -0,0 +1,55 @@
+package scheduledchanges
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/configvalues"
+)
+
+var (
+	ErrNotFound      = errors.New("scheduled change not found")
+	ErrNotPending    = errors.New("scheduled change is no longer pending")
+	ErrApplyAtInPast = errors.New("scheduled changes must be applied in the future")
+	ErrNoValues      = errors.New("scheduled change must contain at least one config value")
+)
+
+type Status string
+
+const (
+	StatusPending   Status = "PENDING"
+	StatusApplying  Status = "APPLYING"
+	StatusApplied   Status = "APPLIED"
+	StatusFailed    Status = "FAILED"
+	StatusCancelled Status = "CANCELLED"
+)
+
+// ScheduledChange is a set of config value writes for an environment that
+// should be applied at ApplyAt with the permissions of the author.
+type ScheduledChange struct {
+	ID            int         `db:"id"`
+	EnvironmentID int         `db:"environment_id"`
+	AuthorID      auth.UserID `db:"author_id"`
+
+	ApplyAt time.Time                   `db:"apply_at"`
+	Status  Status                      `db:"status"`
+	Values  []*configvalues.ConfigValue `db:"config_values"`
+	// Error is the reason the change failed to apply, if it did.
+	Error string `db:"error"`
+
+	ClaimedAt *time.Time `db:"claimed_at"`
+	AppliedAt *time.Time `db:"applied_at"`
+	CreatedAt time.Time  `db:"created_at"`
+}
+
+func (sc ScheduledChange) String() string {
+	return fmt.Sprintf(
+		"ScheduledChange(id=%d, environment=%d, applyAt=%s, status=%s)",
+		sc.ID,
+		sc.EnvironmentID,
+		sc.ApplyAt.Format(time.RFC3339),
+		sc.Status,
+	)
+}
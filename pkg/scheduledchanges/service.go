@@ -0,0 +1,350 @@
+package scheduledchanges
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/config-source/cdb/pkg/audit"
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/configvalues"
+	"github.com/config-source/cdb/pkg/environments"
+)
+
+// StaleClaimTimeout is how long a change may sit in APPLYING before another
+// replica assumes the claiming replica died and applies it again.
+var StaleClaimTimeout = 5 * time.Minute
+
+type Service struct {
+	repo        *Repository
+	environRepo *environments.Repository
+	values      *configvalues.Service
+	authn       auth.AuthenticationGateway
+	authz       auth.AuthorizationGateway
+	audit       *audit.Log
+}
+
+func NewService(
+	repo *Repository,
+	environRepo *environments.Repository,
+	values *configvalues.Service,
+	authn auth.AuthenticationGateway,
+	authz auth.AuthorizationGateway,
+	auditLog *audit.Log,
+) *Service {
+	return &Service{
+		repo:        repo,
+		environRepo: environRepo,
+		values:      values,
+		authn:       authn,
+		authz:       authz,
+		audit:       auditLog,
+	}
+}
+
+func (svc *Service) canConfigureEnvironment(ctx context.Context, actor auth.User, envID int) error {
+	env, err := svc.environRepo.GetEnvironment(ctx, envID)
+	if err != nil {
+		return err
+	}
+
+	return svc.values.CanConfigureEnvironment(ctx, actor, env)
+}
+
+// protectValues redacts the values of sensitive config keys in the change.
+func (svc *Service) protectValues(ctx context.Context, actor auth.User, change ScheduledChange) (ScheduledChange, error) {
+	env, err := svc.environRepo.GetEnvironment(ctx, change.EnvironmentID)
+	if err != nil {
+		return ScheduledChange{}, err
+	}
+
+	if err := svc.values.RedactSensitiveValues(ctx, actor, env, change.Values); err != nil {
+		return ScheduledChange{}, err
+	}
+
+	return change, nil
+}
+
+// audited returns a copy of the change to record in the audit log with its
+// values redacted by configvalues.Service.RedactForAudit.
+func (svc *Service) audited(ctx context.Context, change ScheduledChange) ScheduledChange {
+	values := make([]*configvalues.ConfigValue, len(change.Values))
+	for idx, cv := range change.Values {
+		if cv != nil {
+			copied := *cv
+			values[idx] = &copied
+		}
+	}
+
+	env, err := svc.environRepo.GetEnvironment(ctx, change.EnvironmentID)
+	if err == nil {
+		err = svc.values.RedactForAudit(ctx, env, values)
+	}
+
+	if err != nil {
+		// Leave the values out rather than risk recording secrets.
+		values = nil
+	}
+
+	change.Values = values
+	return change
+}
+
+// canModify checks that the actor may cancel or reschedule the change. Only
+// the author or someone who can manage environments may do so.
+func (svc *Service) canModify(ctx context.Context, actor auth.User, change ScheduledChange) error {
+	if change.AuthorID == actor.ID {
+		return nil
+	}
+
+	env, err := svc.environRepo.GetEnvironment(ctx, change.EnvironmentID)
+	if err != nil {
+		return err
+	}
+
+	canManageEnvironments, err := svc.authz.HasServicePermission(ctx, actor, env.ServiceID, auth.PermissionManageEnvironments)
+	if err != nil {
+		return err
+	}
+
+	if !canManageEnvironments {
+		return auth.ErrUnauthorized
+	}
+
+	return nil
+}
+
+func (svc *Service) ScheduleChange(ctx context.Context, actor auth.User, change ScheduledChange) (ScheduledChange, error) {
+	if !change.ApplyAt.After(time.Now()) {
+		return ScheduledChange{}, ErrApplyAtInPast
+	}
+
+	if len(change.Values) == 0 {
+		return ScheduledChange{}, ErrNoValues
+	}
+
+	for _, cv := range change.Values {
+		if cv == nil || cv.Name == "" {
+			return ScheduledChange{}, fmt.Errorf("%w: scheduled values must have a Name", configvalues.ErrNotValid)
+		}
+	}
+
+	if err := svc.canConfigureEnvironment(ctx, actor, change.EnvironmentID); err != nil {
+		return ScheduledChange{}, err
+	}
+
+	// Otherwise mistakes would only be reported when the change fails to
+	// apply.
+	if err := svc.values.ValidateConfigurationValues(ctx, change.EnvironmentID, change.Values); err != nil {
+		return ScheduledChange{}, err
+	}
+
+	change.AuthorID = actor.ID
+	created, err := svc.repo.CreateScheduledChange(ctx, change)
+	if err != nil {
+		return ScheduledChange{}, err
+	}
+
+	created, err = svc.protectValues(ctx, actor, created)
+	if err != nil {
+		return ScheduledChange{}, err
+	}
+
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionScheduledChangeCreate,
+		TargetKind: audit.TargetScheduledChange,
+		TargetID:   strconv.Itoa(created.ID),
+		After:      svc.audited(ctx, created),
+	})
+	return created, nil
+}
+
+func (svc *Service) GetScheduledChange(ctx context.Context, actor auth.User, id int) (ScheduledChange, error) {
+	change, err := svc.repo.GetScheduledChange(ctx, id)
+	if err != nil {
+		return ScheduledChange{}, err
+	}
+
+	if err := svc.canConfigureEnvironment(ctx, actor, change.EnvironmentID); err != nil {
+		return ScheduledChange{}, err
+	}
+
+	return svc.protectValues(ctx, actor, change)
+}
+
+// ListScheduledChanges returns the scheduled changes for environments the
+// actor is able to configure.
+func (svc *Service) ListScheduledChanges(
+	ctx context.Context,
+	actor auth.User,
+	environmentID *int,
+	status *Status,
+) ([]ScheduledChange, error) {
+	if environmentID != nil {
+		if err := svc.canConfigureEnvironment(ctx, actor, *environmentID); err != nil {
+			return nil, err
+		}
+	}
+
+	changes, err := svc.repo.ListScheduledChanges(ctx, environmentID, status)
+	if err != nil {
+		return nil, err
+	}
+
+	permitted := make(map[int]bool)
+	visible := make([]ScheduledChange, 0, len(changes))
+	for _, change := range changes {
+		canSee, checked := permitted[change.EnvironmentID]
+		if !checked {
+			err := svc.canConfigureEnvironment(ctx, actor, change.EnvironmentID)
+			if err != nil && !errors.Is(err, auth.ErrUnauthorized) {
+				return nil, err
+			}
+
+			canSee = err == nil
+			permitted[change.EnvironmentID] = canSee
+		}
+
+		if !canSee {
+			continue
+		}
+
+		change, err := svc.protectValues(ctx, actor, change)
+		if err != nil {
+			return nil, err
+		}
+
+		visible = append(visible, change)
+	}
+
+	return visible, nil
+}
+
+func (svc *Service) CancelScheduledChange(ctx context.Context, actor auth.User, id int) (ScheduledChange, error) {
+	change, err := svc.GetScheduledChange(ctx, actor, id)
+	if err != nil {
+		return ScheduledChange{}, err
+	}
+
+	if err := svc.canModify(ctx, actor, change); err != nil {
+		return ScheduledChange{}, err
+	}
+
+	cancelled, err := svc.repo.CancelScheduledChange(ctx, id)
+	if err != nil {
+		return ScheduledChange{}, err
+	}
+
+	cancelled, err = svc.protectValues(ctx, actor, cancelled)
+	if err != nil {
+		return ScheduledChange{}, err
+	}
+
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionScheduledChangeCancel,
+		TargetKind: audit.TargetScheduledChange,
+		TargetID:   strconv.Itoa(id),
+		Before:     svc.audited(ctx, change),
+		After:      svc.audited(ctx, cancelled),
+	})
+	return cancelled, nil
+}
+
+func (svc *Service) RescheduleChange(ctx context.Context, actor auth.User, id int, applyAt time.Time) (ScheduledChange, error) {
+	if !applyAt.After(time.Now()) {
+		return ScheduledChange{}, ErrApplyAtInPast
+	}
+
+	change, err := svc.GetScheduledChange(ctx, actor, id)
+	if err != nil {
+		return ScheduledChange{}, err
+	}
+
+	if err := svc.canModify(ctx, actor, change); err != nil {
+		return ScheduledChange{}, err
+	}
+
+	rescheduled, err := svc.repo.RescheduleChange(ctx, id, applyAt)
+	if err != nil {
+		return ScheduledChange{}, err
+	}
+
+	rescheduled, err = svc.protectValues(ctx, actor, rescheduled)
+	if err != nil {
+		return ScheduledChange{}, err
+	}
+
+	svc.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionScheduledChangeReschedule,
+		TargetKind: audit.TargetScheduledChange,
+		TargetID:   strconv.Itoa(id),
+		Before:     svc.audited(ctx, change),
+		After:      svc.audited(ctx, rescheduled),
+	})
+	return rescheduled, nil
+}
+
+// apply writes the change's values as its author. The author is looked up
+// fresh so that permission changes and disabling since scheduling are
+// respected.
+func (svc *Service) apply(ctx context.Context, change ScheduledChange) error {
+	author, err := svc.authn.GetUser(ctx, change.AuthorID)
+	if err != nil {
+		return fmt.Errorf("unable to load author: %w", err)
+	}
+
+	if author.Disabled {
+		return auth.ErrUserDisabled
+	}
+
+	_, err = svc.values.SetConfigurationValues(ctx, author, change.EnvironmentID, change.Values)
+	return err
+}
+
+// recordApply audits a change being applied, or failing to, as the system.
+// The values it wrote are audited as its author.
+func (svc *Service) recordApply(ctx context.Context, finished ScheduledChange) {
+	svc.audit.Record(ctx, audit.System, audit.Event{
+		Action:     audit.ActionScheduledChangeApply,
+		TargetKind: audit.TargetScheduledChange,
+		TargetID:   strconv.Itoa(finished.ID),
+		After:      svc.audited(ctx, finished),
+	})
+}
+
+// ApplyDueChanges claims and applies every change whose ApplyAt has passed. It
+// is safe to run concurrently from multiple cdbd replicas. Changes which fail
+// to apply are marked FAILED with the reason, the returned error is only for
+// failures to talk to the database.
+func (svc *Service) ApplyDueChanges(ctx context.Context) (int, error) {
+	applied := 0
+
+	for ctx.Err() == nil {
+		change, err := svc.repo.ClaimDueChange(ctx, time.Now().Add(-StaleClaimTimeout))
+		if errors.Is(err, ErrNotFound) {
+			return applied, nil
+		} else if err != nil {
+			return applied, err
+		}
+
+		status := StatusApplied
+		failureReason := ""
+		if applyErr := svc.apply(ctx, change); applyErr != nil {
+			status = StatusFailed
+			failureReason = applyErr.Error()
+		} else {
+			applied++
+		}
+
+		finished, err := svc.repo.FinishScheduledChange(ctx, change.ID, status, failureReason)
+		if err != nil {
+			return applied, err
+		}
+
+		svc.recordApply(ctx, finished)
+	}
+
+	return applied, ctx.Err()
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	_ "embed"
 	"errors"
+	"time"
 
 	"github.com/config-source/cdb/pkg/postgresutils"
 	"github.com/jackc/pgx/v5"
@@ -35,6 +36,21 @@ var getServiceByNameSql string
 //go:embed queries/list_services.sql
 var listServicesSql string
 
+//go:embed queries/soft_delete_service.sql
+var softDeleteServiceSql string
+
+//go:embed queries/restore_service.sql
+var restoreServiceSql string
+
+//go:embed queries/get_deleted_service_by_id.sql
+var getDeletedServiceByIDSql string
+
+//go:embed queries/list_deleted_services.sql
+var listDeletedServicesSql string
+
+//go:embed queries/purge_deleted_services.sql
+var purgeDeletedServicesSql string
+
 func (r *Repository) CreateService(ctx context.Context, svc Service) (Service, error) {
 	return postgresutils.GetOne[Service](r.pool, ctx, createServiceSql, svc.Name)
 }
@@ -60,3 +76,54 @@ func (r *Repository) GetServiceByName(ctx context.Context, name string) (Service
 func (r *Repository) ListServices(ctx context.Context, includeSensitive bool) ([]Service, error) {
 	return postgresutils.GetAll[Service](r.pool, ctx, listServicesSql)
 }
+
+// SoftDeleteService moves the service to the trash, hiding it from every
+// other read until it is restored or purged.
+func (r *Repository) SoftDeleteService(ctx context.Context, id int) error {
+	tag, err := r.pool.Exec(ctx, softDeleteServiceSql, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// RestoreService takes the service back out of the trash.
+func (r *Repository) RestoreService(ctx context.Context, id int) error {
+	tag, err := r.pool.Exec(ctx, restoreServiceSql, id)
+	if err != nil {
+		return err
+	}
+
+	if tag.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) GetDeletedService(ctx context.Context, id int) (Service, error) {
+	svc, err := postgresutils.GetOne[Service](r.pool, ctx, getDeletedServiceByIDSql, id)
+	if err != nil && errors.Is(err, pgx.ErrNoRows) {
+		return svc, ErrNotFound
+	}
+
+	return svc, err
+}
+
+// ListDeletedServices returns the services in the trash, most recently
+// deleted first.
+func (r *Repository) ListDeletedServices(ctx context.Context) ([]Service, error) {
+	return postgresutils.GetAll[Service](r.pool, ctx, listDeletedServicesSql)
+}
+
+// PurgeDeletedServices permanently deletes services which were moved to the
+// trash before the given time and returns how many were removed.
+func (r *Repository) PurgeDeletedServices(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	tag, err := r.pool.Exec(ctx, purgeDeletedServicesSql, deletedBefore)
+	return tag.RowsAffected(), err
+}
@@ -0,0 +1 @@
+SELECT * FROM services WHERE id = $1 AND deleted_at IS NOT NULL;
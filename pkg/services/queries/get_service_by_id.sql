@@ -1 +1 @@
-SELECT * FROM services WHERE id = $1;
+SELECT * FROM services WHERE id = $1 AND deleted_at IS NULL;
@@ -1 +1 @@
-SELECT * FROM services WHERE name = $1;
+SELECT * FROM services WHERE name = $1 AND deleted_at IS NULL;
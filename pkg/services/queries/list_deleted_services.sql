@@ -0,0 +1,3 @@
+SELECT * FROM services
+WHERE deleted_at IS NOT NULL
+ORDER BY deleted_at DESC;
@@ -1 +1 @@
-SELECT * FROM services;
+SELECT * FROM services WHERE deleted_at IS NULL;
@@ -0,0 +1,2 @@
+DELETE FROM services
+WHERE deleted_at <= $1;
@@ -6,21 +6,37 @@ import (
 	"time"
 )
 
+// RoleName is a role a user can hold on a single service.
 type RoleName string
 
 const (
-	Owner     = "OWNER"
-	Developer = "DEVELOPER"
+	// Owner manages the service's config keys and environments, including
+	// sensitive ones.
+	Owner RoleName = "OWNER"
+	// Developer configures the service's non-sensitive environments.
+	Developer RoleName = "DEVELOPER"
 )
 
 var (
-	ErrNotFound = errors.New("service not found")
+	ErrNotFound    = errors.New("service not found")
+	ErrUnknownRole = errors.New("service role must be OWNER or DEVELOPER")
 )
 
+func (r RoleName) Valid() error {
+	switch r {
+	case Owner, Developer:
+		return nil
+	default:
+		return fmt.Errorf("%w: got %q", ErrUnknownRole, r)
+	}
+}
+
 type Service struct {
 	ID        int       `db:"id"`
 	Name      string    `db:"name"`
 	CreatedAt time.Time `db:"created_at"`
+	// DeletedAt is set when the service is in the trash.
+	DeletedAt *time.Time `db:"deleted_at"`
 }
 
 func (e Service) String() string {
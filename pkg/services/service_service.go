@@ -2,19 +2,23 @@ package services
 
 import (
 	"context"
+	"strconv"
 
+	"github.com/config-source/cdb/pkg/audit"
 	"github.com/config-source/cdb/pkg/auth"
 )
 
 type ServiceService struct {
-	auth auth.AuthorizationGateway
-	repo *Repository
+	auth  auth.AuthorizationGateway
+	repo  *Repository
+	audit *audit.Log
 }
 
-func NewServiceService(repo *Repository, auth auth.AuthorizationGateway) *ServiceService {
+func NewServiceService(repo *Repository, auth auth.AuthorizationGateway, auditLog *audit.Log) *ServiceService {
 	return &ServiceService{
-		auth: auth,
-		repo: repo,
+		auth:  auth,
+		repo:  repo,
+		audit: auditLog,
 	}
 }
 
@@ -28,48 +32,200 @@ func (s *ServiceService) CreateService(ctx context.Context, actor auth.User, svc
 		return Service{}, auth.ErrUnauthorized
 	}
 
-	return s.repo.CreateService(ctx, svc)
+	created, err := s.repo.CreateService(ctx, svc)
+	if err != nil {
+		return Service{}, err
+	}
+
+	s.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionServiceCreate,
+		TargetKind: audit.TargetService,
+		TargetID:   strconv.Itoa(created.ID),
+		After:      created,
+	})
+	return created, nil
+}
+
+// DeleteService moves the service to the trash, its environments and config
+// keys are hidden until it is restored.
+func (s *ServiceService) DeleteService(ctx context.Context, actor auth.User, id int) error {
+	canManageEnvironments, err := s.auth.HasServicePermission(ctx, actor, id, auth.PermissionManageEnvironments)
+	if err != nil {
+		return err
+	}
+
+	if !canManageEnvironments {
+		return auth.ErrUnauthorized
+	}
+
+	before, err := s.repo.GetService(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.SoftDeleteService(ctx, id); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionServiceDelete,
+		TargetKind: audit.TargetService,
+		TargetID:   strconv.Itoa(id),
+		Before:     before,
+	})
+	return nil
 }
 
-func (s *ServiceService) viewPermissionCheck(ctx context.Context, actor auth.User) error {
-	canViewServices, err := s.auth.HasPermission(
+func (s *ServiceService) canView(ctx context.Context, actor auth.User, serviceID int) (bool, error) {
+	return s.auth.HasServicePermission(
 		ctx,
 		actor,
+		serviceID,
 		auth.PermissionManageEnvironments,
 		auth.PermissionConfigureEnvironments,
 		auth.PermissionConfigureSensitiveEnvironments,
+		auth.PermissionReadConfiguration,
+		auth.PermissionReadSensitiveConfiguration,
 	)
+}
+
+func (s *ServiceService) viewPermissionCheck(ctx context.Context, actor auth.User, svc Service, retrievalErr error) (Service, error) {
+	if retrievalErr != nil {
+		return Service{}, retrievalErr
+	}
+
+	canViewService, err := s.canView(ctx, actor, svc.ID)
 	if err != nil {
-		return err
+		return Service{}, err
 	}
 
-	if !canViewServices {
-		return auth.ErrUnauthorized
+	if !canViewService {
+		return Service{}, auth.ErrUnauthorized
 	}
 
-	return nil
+	return svc, nil
 }
 
 func (s *ServiceService) GetServiceByName(ctx context.Context, actor auth.User, name string) (Service, error) {
-	if err := s.viewPermissionCheck(ctx, actor); err != nil {
-		return Service{}, err
+	svc, err := s.repo.GetServiceByName(ctx, name)
+	return s.viewPermissionCheck(ctx, actor, svc, err)
+}
+
+func (s *ServiceService) GetServiceByID(ctx context.Context, actor auth.User, id int) (Service, error) {
+	svc, err := s.repo.GetService(ctx, id)
+	return s.viewPermissionCheck(ctx, actor, svc, err)
+}
+
+// ListServices returns the services the actor has access to either through a
+// global role or a role on the service itself.
+func (s *ServiceService) ListServices(ctx context.Context, actor auth.User) ([]Service, error) {
+	canViewAll, err := s.auth.HasPermission(
+		ctx,
+		actor,
+		auth.PermissionManageEnvironments,
+		auth.PermissionConfigureEnvironments,
+		auth.PermissionConfigureSensitiveEnvironments,
+		auth.PermissionReadConfiguration,
+		auth.PermissionReadSensitiveConfiguration,
+	)
+	if err != nil {
+		return nil, err
 	}
 
-	return s.repo.GetServiceByName(ctx, name)
+	svcs, err := s.repo.ListServices(ctx, true)
+	if err != nil || canViewAll {
+		return svcs, err
+	}
+
+	visible := make([]Service, 0, len(svcs))
+	for _, svc := range svcs {
+		canViewService, err := s.canView(ctx, actor, svc.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if canViewService {
+			visible = append(visible, svc)
+		}
+	}
+
+	if len(visible) == 0 {
+		return nil, auth.ErrUnauthorized
+	}
+
+	return visible, nil
 }
 
-func (s *ServiceService) GetServiceByID(ctx context.Context, actor auth.User, id int) (Service, error) {
-	if err := s.viewPermissionCheck(ctx, actor); err != nil {
-		return Service{}, err
+func (s *ServiceService) ListRoles(ctx context.Context, actor auth.User, serviceID int) ([]auth.ServiceRole, error) {
+	if _, err := s.repo.GetService(ctx, serviceID); err != nil {
+		return nil, err
 	}
 
-	return s.repo.GetService(ctx, id)
+	return s.auth.ListServiceRoles(ctx, actor, serviceID)
 }
 
-func (s *ServiceService) ListServices(ctx context.Context, actor auth.User) ([]Service, error) {
-	if err := s.viewPermissionCheck(ctx, actor); err != nil {
+// AssignRole gives the user role on the service, replacing any role they
+// already had on it.
+func (s *ServiceService) AssignRole(ctx context.Context, actor auth.User, serviceID int, userID auth.UserID, role RoleName) error {
+	if err := role.Valid(); err != nil {
+		return err
+	}
+
+	if _, err := s.repo.GetService(ctx, serviceID); err != nil {
+		return err
+	}
+
+	before, err := s.serviceRole(ctx, actor, serviceID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.auth.AssignServiceRole(ctx, actor, auth.User{ID: userID}, serviceID, string(role)); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionServiceAssignRole,
+		TargetKind: audit.TargetService,
+		TargetID:   strconv.Itoa(serviceID),
+		Before:     before,
+		After:      auth.ServiceRole{UserID: userID, ServiceID: serviceID, Role: string(role)},
+	})
+	return nil
+}
+
+// serviceRole returns the user's role on the service, or nil if they don't
+// have one, to record in the audit log.
+func (s *ServiceService) serviceRole(ctx context.Context, actor auth.User, serviceID int, userID auth.UserID) (*auth.ServiceRole, error) {
+	roles, err := s.auth.ListServiceRoles(ctx, actor, serviceID)
+	if err != nil {
 		return nil, err
 	}
 
-	return s.repo.ListServices(ctx, true)
+	for _, role := range roles {
+		if role.UserID == userID {
+			return &role, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *ServiceService) RemoveRole(ctx context.Context, actor auth.User, serviceID int, userID auth.UserID) error {
+	before, err := s.serviceRole(ctx, actor, serviceID, userID)
+	if err != nil {
+		return err
+	}
+
+	if err := s.auth.RemoveServiceRole(ctx, actor, auth.User{ID: userID}, serviceID); err != nil {
+		return err
+	}
+
+	s.audit.Record(ctx, actor, audit.Event{
+		Action:     audit.ActionServiceRemoveRole,
+		TargetKind: audit.TargetService,
+		TargetID:   strconv.Itoa(serviceID),
+		Before:     before,
+	})
+	return nil
 }
@@ -0,0 +1,315 @@
+package trash
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/config-source/cdb/pkg/audit"
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/configkeys"
+	"github.com/config-source/cdb/pkg/environments"
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/config-source/cdb/pkg/services"
+)
+
+type Service struct {
+	// Retention is how long deleted items are kept before being purged.
+	Retention time.Duration
+
+	envRepo *environments.Repository
+	keyRepo *configkeys.Repository
+	svcRepo *services.Repository
+	auth    auth.AuthorizationGateway
+	audit   *audit.Log
+}
+
+func NewService(
+	envRepo *environments.Repository,
+	keyRepo *configkeys.Repository,
+	svcRepo *services.Repository,
+	auth auth.AuthorizationGateway,
+	retention time.Duration,
+	auditLog *audit.Log,
+) *Service {
+	return &Service{
+		Retention: retention,
+		envRepo:   envRepo,
+		keyRepo:   keyRepo,
+		svcRepo:   svcRepo,
+		auth:      auth,
+		audit:     auditLog,
+	}
+}
+
+// permissionFor returns the permission needed to see and restore items of
+// kind in the trash, matching the permission needed to delete them.
+func permissionFor(kind Kind) (auth.Permission, error) {
+	switch kind {
+	case KindEnvironment, KindService:
+		return auth.PermissionManageEnvironments, nil
+	case KindConfigKey:
+		return auth.PermissionManageConfigKeys, nil
+	default:
+		return "", ErrUnknownKind
+	}
+}
+
+// canManage reports whether the actor can see and restore items of kind which
+// belong to the service with serviceID.
+func (svc *Service) canManage(ctx context.Context, actor auth.User, kind Kind, serviceID int) (bool, error) {
+	permission, err := permissionFor(kind)
+	if err != nil {
+		return false, err
+	}
+
+	return svc.auth.HasServicePermission(ctx, actor, serviceID, permission)
+}
+
+func (svc *Service) ListTrash(ctx context.Context, actor auth.User) (Trash, error) {
+	trash := Trash{
+		Retention:    svc.Retention.String(),
+		Environments: []environments.Environment{},
+		ConfigKeys:   []configkeys.ConfigKey{},
+		Services:     []services.Service{},
+	}
+
+	canManageAny, err := svc.auth.HasPermission(
+		ctx,
+		actor,
+		auth.PermissionManageEnvironments,
+		auth.PermissionManageConfigKeys,
+	)
+	if err != nil {
+		return Trash{}, err
+	}
+
+	// The trash usually holds many items from the same services.
+	type check struct {
+		kind      Kind
+		serviceID int
+	}
+	checked := make(map[check]bool)
+	canManage := func(kind Kind, serviceID int) (bool, error) {
+		if allowed, ok := checked[check{kind, serviceID}]; ok {
+			return allowed, nil
+		}
+
+		allowed, err := svc.canManage(ctx, actor, kind, serviceID)
+		checked[check{kind, serviceID}] = allowed
+		return allowed, err
+	}
+
+	deletedEnvironments, err := svc.envRepo.ListDeletedEnvironments(ctx)
+	if err != nil {
+		return Trash{}, err
+	}
+
+	for _, env := range deletedEnvironments {
+		allowed, err := canManage(KindEnvironment, env.ServiceID)
+		if err != nil {
+			return Trash{}, err
+		}
+
+		if allowed {
+			trash.Environments = append(trash.Environments, env)
+		}
+	}
+
+	deletedServices, err := svc.svcRepo.ListDeletedServices(ctx)
+	if err != nil {
+		return Trash{}, err
+	}
+
+	for _, service := range deletedServices {
+		allowed, err := canManage(KindService, service.ID)
+		if err != nil {
+			return Trash{}, err
+		}
+
+		if allowed {
+			trash.Services = append(trash.Services, service)
+		}
+	}
+
+	deletedConfigKeys, err := svc.keyRepo.ListDeletedConfigKeys(ctx)
+	if err != nil {
+		return Trash{}, err
+	}
+
+	for _, ck := range deletedConfigKeys {
+		allowed, err := canManage(KindConfigKey, ck.ServiceID)
+		if err != nil {
+			return Trash{}, err
+		}
+
+		if allowed {
+			trash.ConfigKeys = append(trash.ConfigKeys, ck)
+		}
+	}
+
+	empty := len(trash.Environments) == 0 && len(trash.Services) == 0 && len(trash.ConfigKeys) == 0
+	if empty && !canManageAny {
+		return Trash{}, auth.ErrUnauthorized
+	}
+
+	return trash, nil
+}
+
+// dependencyDeleted converts a not found error for something the restored
+// item depends on into ErrDependencyDeleted.
+func dependencyDeleted(err error) error {
+	if errors.Is(err, environments.ErrNotFound) || errors.Is(err, services.ErrNotFound) {
+		return ErrDependencyDeleted
+	}
+
+	return err
+}
+
+// nameInUse converts the unique violation from restoring an item whose name
+// has been reused while it was in the trash into ErrNameInUse.
+func nameInUse(err error) error {
+	if err != nil && postgresutils.IsUniqueConstraintErr(err) {
+		return ErrNameInUse
+	}
+
+	return err
+}
+
+// Restore takes the item out of the trash. Environments and config keys can
+// only be restored if their service, and for environments what they promote
+// to, are not in the trash, and no item can be restored while something else
+// is using its name.
+func (svc *Service) Restore(ctx context.Context, actor auth.User, kind Kind, id int) error {
+	if _, err := permissionFor(kind); err != nil {
+		return err
+	}
+
+	authorize := func(serviceID int) error {
+		canManage, err := svc.canManage(ctx, actor, kind, serviceID)
+		if err != nil {
+			return err
+		}
+
+		if !canManage {
+			return auth.ErrUnauthorized
+		}
+
+		return nil
+	}
+
+	switch kind {
+	case KindEnvironment:
+		env, err := svc.envRepo.GetDeletedEnvironment(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := authorize(env.ServiceID); err != nil {
+			return err
+		}
+
+		if _, err := svc.svcRepo.GetService(ctx, env.ServiceID); err != nil {
+			return dependencyDeleted(err)
+		}
+
+		if env.PromotesToID != nil {
+			if _, err := svc.envRepo.GetEnvironment(ctx, *env.PromotesToID); err != nil {
+				return dependencyDeleted(err)
+			}
+		}
+
+		if err := svc.envRepo.RestoreEnvironment(ctx, id); err != nil {
+			return nameInUse(err)
+		}
+
+		restored := env
+		restored.DeletedAt = nil
+		svc.audit.Record(ctx, actor, audit.Event{
+			Action:     audit.ActionEnvironmentRestore,
+			TargetKind: audit.TargetEnvironment,
+			TargetID:   strconv.Itoa(id),
+			Before:     env,
+			After:      restored,
+		})
+		return nil
+	case KindConfigKey:
+		ck, err := svc.keyRepo.GetDeletedConfigKey(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := authorize(ck.ServiceID); err != nil {
+			return err
+		}
+
+		if _, err := svc.svcRepo.GetService(ctx, ck.ServiceID); err != nil {
+			return dependencyDeleted(err)
+		}
+
+		if err := svc.keyRepo.RestoreConfigKey(ctx, id); err != nil {
+			return nameInUse(err)
+		}
+
+		restored := ck
+		restored.DeletedAt = nil
+		svc.audit.Record(ctx, actor, audit.Event{
+			Action:     audit.ActionConfigKeyRestore,
+			TargetKind: audit.TargetConfigKey,
+			TargetID:   strconv.Itoa(id),
+			Before:     ck,
+			After:      restored,
+		})
+		return nil
+	default:
+		deleted, err := svc.svcRepo.GetDeletedService(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		if err := authorize(deleted.ID); err != nil {
+			return err
+		}
+
+		if err := svc.svcRepo.RestoreService(ctx, id); err != nil {
+			return nameInUse(err)
+		}
+
+		restored := deleted
+		restored.DeletedAt = nil
+		svc.audit.Record(ctx, actor, audit.Event{
+			Action:     audit.ActionServiceRestore,
+			TargetKind: audit.TargetService,
+			TargetID:   strconv.Itoa(id),
+			Before:     deleted,
+			After:      restored,
+		})
+		return nil
+	}
+}
+
+// Purge permanently deletes everything which has been in the trash for longer
+// than the retention period. Purging a service also removes all of its
+// environments and config keys.
+func (svc *Service) Purge(ctx context.Context) (PurgeResult, error) {
+	var (
+		result PurgeResult
+		err    error
+	)
+
+	before := time.Now().Add(-svc.Retention)
+
+	result.Environments, err = svc.envRepo.PurgeDeletedEnvironments(ctx, before)
+	if err != nil {
+		return result, err
+	}
+
+	result.ConfigKeys, err = svc.keyRepo.PurgeDeletedConfigKeys(ctx, before)
+	if err != nil {
+		return result, err
+	}
+
+	result.Services, err = svc.svcRepo.PurgeDeletedServices(ctx, before)
+	return result, err
+}
@@ -0,0 +1,309 @@
+package trash_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/config-source/cdb/pkg/auth"
+	"github.com/config-source/cdb/pkg/configkeys"
+	"github.com/config-source/cdb/pkg/environments"
+	"github.com/config-source/cdb/pkg/postgresutils"
+	"github.com/config-source/cdb/pkg/services"
+	"github.com/config-source/cdb/pkg/trash"
+	"github.com/rs/zerolog"
+)
+
+type TestContext struct {
+	trash    *trash.Service
+	envs     *environments.Service
+	envRepo  *environments.Repository
+	keyRepo  *configkeys.Repository
+	svcRepo  *services.Repository
+	services *services.ServiceService
+	gateway  *auth.TestGateway
+	svc      services.Service
+}
+
+func initTestDB(t *testing.T) TestContext {
+	t.Helper()
+
+	pool := postgresutils.InitTestDB(t)
+	logger := zerolog.New(nil).Level(zerolog.Disabled)
+
+	envRepo := environments.NewRepository(logger, pool)
+	keyRepo := configkeys.NewRepository(logger, pool)
+	svcRepo := services.NewRepository(logger, pool)
+	gateway := auth.NewTestGateway()
+
+	svc, err := svcRepo.CreateService(context.Background(), services.Service{Name: "test"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return TestContext{
+		trash:    trash.NewService(envRepo, keyRepo, svcRepo, gateway, time.Hour, nil),
+		envs:     environments.NewService(envRepo, gateway, nil),
+		envRepo:  envRepo,
+		keyRepo:  keyRepo,
+		svcRepo:  svcRepo,
+		services: services.NewServiceService(svcRepo, gateway, nil),
+		gateway:  gateway,
+		svc:      svc,
+	}
+}
+
+func envFixture(t *testing.T, tc TestContext, name string, promotesToID *int) environments.Environment {
+	t.Helper()
+
+	env, err := tc.envRepo.CreateEnvironment(context.Background(), environments.Environment{
+		Name:         name,
+		PromotesToID: promotesToID,
+		ServiceID:    tc.svc.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return env
+}
+
+func TestDeletedEnvironmentsCanBeRestored(t *testing.T) {
+	tc := initTestDB(t)
+	production := envFixture(t, tc, "production", nil)
+
+	err := tc.envs.DeleteEnvironment(context.Background(), auth.User{}, production.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tc.envRepo.GetEnvironment(context.Background(), production.ID)
+	if !errors.Is(err, environments.ErrNotFound) {
+		t.Fatalf("Expected %s got: %s", environments.ErrNotFound, err)
+	}
+
+	items, err := tc.trash.ListTrash(context.Background(), auth.User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items.Environments) != 1 || items.Environments[0].ID != production.ID || items.Environments[0].DeletedAt == nil {
+		t.Fatalf("Expected production to be in the trash got: %+v", items.Environments)
+	}
+
+	err = tc.trash.Restore(context.Background(), auth.User{}, trash.KindEnvironment, production.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tc.envRepo.GetEnvironment(context.Background(), production.ID); err != nil {
+		t.Fatalf("Expected production to be restored got: %s", err)
+	}
+}
+
+func TestEnvironmentsWithDescendantsCannotBeDeleted(t *testing.T) {
+	tc := initTestDB(t)
+	production := envFixture(t, tc, "production", nil)
+	envFixture(t, tc, "staging", &production.ID)
+
+	err := tc.envs.DeleteEnvironment(context.Background(), auth.User{}, production.ID)
+	if !errors.Is(err, environments.ErrHasDescendants) {
+		t.Fatalf("Expected %s got: %s", environments.ErrHasDescendants, err)
+	}
+}
+
+func TestRestoreRequiresDependenciesToBeRestored(t *testing.T) {
+	tc := initTestDB(t)
+	production := envFixture(t, tc, "production", nil)
+	staging := envFixture(t, tc, "staging", &production.ID)
+
+	for _, id := range []int{staging.ID, production.ID} {
+		if err := tc.envs.DeleteEnvironment(context.Background(), auth.User{}, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := tc.trash.Restore(context.Background(), auth.User{}, trash.KindEnvironment, staging.ID)
+	if !errors.Is(err, trash.ErrDependencyDeleted) {
+		t.Fatalf("Expected %s got: %s", trash.ErrDependencyDeleted, err)
+	}
+
+	err = tc.services.DeleteService(context.Background(), auth.User{}, tc.svc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tc.trash.Restore(context.Background(), auth.User{}, trash.KindEnvironment, production.ID)
+	if !errors.Is(err, trash.ErrDependencyDeleted) {
+		t.Fatalf("Expected %s got: %s", trash.ErrDependencyDeleted, err)
+	}
+}
+
+func TestNamesInTheTrashCanBeReused(t *testing.T) {
+	tc := initTestDB(t)
+	production := envFixture(t, tc, "production", nil)
+	ck, err := tc.keyRepo.CreateConfigKey(context.Background(), configkeys.New(tc.svc.ID, "owner", configkeys.TypeString))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tc.envs.DeleteEnvironment(context.Background(), auth.User{}, production.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tc.keyRepo.SoftDeleteConfigKey(context.Background(), ck.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tc.services.DeleteService(context.Background(), auth.User{}, tc.svc.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	replacement, err := tc.svcRepo.CreateService(context.Background(), services.Service{Name: tc.svc.Name})
+	if err != nil {
+		t.Fatalf("Expected the service's name to be reusable got: %s", err)
+	}
+
+	err = tc.trash.Restore(context.Background(), auth.User{}, trash.KindService, tc.svc.ID)
+	if !errors.Is(err, trash.ErrNameInUse) {
+		t.Fatalf("Expected %s got: %v", trash.ErrNameInUse, err)
+	}
+
+	if err := tc.services.DeleteService(context.Background(), auth.User{}, replacement.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tc.trash.Restore(context.Background(), auth.User{}, trash.KindService, tc.svc.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tc.envRepo.CreateEnvironment(context.Background(), environments.Environment{
+		Name:      production.Name,
+		ServiceID: tc.svc.ID,
+	}); err != nil {
+		t.Fatalf("Expected the environment's name to be reusable got: %s", err)
+	}
+
+	if _, err := tc.keyRepo.CreateConfigKey(context.Background(), configkeys.New(tc.svc.ID, ck.Name, configkeys.TypeString)); err != nil {
+		t.Fatalf("Expected the config key's name to be reusable got: %s", err)
+	}
+
+	err = tc.trash.Restore(context.Background(), auth.User{}, trash.KindEnvironment, production.ID)
+	if !errors.Is(err, trash.ErrNameInUse) {
+		t.Errorf("Expected %s restoring the environment got: %v", trash.ErrNameInUse, err)
+	}
+
+	err = tc.trash.Restore(context.Background(), auth.User{}, trash.KindConfigKey, ck.ID)
+	if !errors.Is(err, trash.ErrNameInUse) {
+		t.Errorf("Expected %s restoring the config key got: %v", trash.ErrNameInUse, err)
+	}
+}
+
+func TestDeletedServicesHideTheirConfigKeys(t *testing.T) {
+	tc := initTestDB(t)
+
+	ck, err := tc.keyRepo.CreateConfigKey(context.Background(), configkeys.New(tc.svc.ID, "owner", configkeys.TypeString))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = tc.services.DeleteService(context.Background(), auth.User{}, tc.svc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = tc.keyRepo.GetConfigKey(context.Background(), ck.ID)
+	if !errors.Is(err, configkeys.ErrNotFound) {
+		t.Fatalf("Expected %s got: %s", configkeys.ErrNotFound, err)
+	}
+
+	err = tc.trash.Restore(context.Background(), auth.User{}, trash.KindService, tc.svc.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tc.keyRepo.GetConfigKey(context.Background(), ck.ID); err != nil {
+		t.Fatalf("Expected owner to be visible again got: %s", err)
+	}
+}
+
+func TestPurgeOnlyRemovesItemsPastRetention(t *testing.T) {
+	tc := initTestDB(t)
+	production := envFixture(t, tc, "production", nil)
+
+	err := tc.envs.DeleteEnvironment(context.Background(), auth.User{}, production.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	purged, err := tc.trash.Purge(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if purged.Environments != 0 {
+		t.Fatalf("Expected nothing to be purged got: %+v", purged)
+	}
+
+	tc.trash.Retention = 0
+	purged, err = tc.trash.Purge(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if purged.Environments != 1 {
+		t.Fatalf("Expected production to be purged got: %+v", purged)
+	}
+
+	_, err = tc.envRepo.GetDeletedEnvironment(context.Background(), production.ID)
+	if !errors.Is(err, environments.ErrNotFound) {
+		t.Fatalf("Expected %s got: %s", environments.ErrNotFound, err)
+	}
+}
+
+func TestServiceOwnersManageTheirOwnTrash(t *testing.T) {
+	tc := initTestDB(t)
+	production := envFixture(t, tc, "production", nil)
+
+	other, err := tc.svcRepo.CreateService(context.Background(), services.Service{Name: "other"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherProduction, err := tc.envRepo.CreateEnvironment(context.Background(), environments.Environment{
+		Name:      "production",
+		ServiceID: other.ID,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, id := range []int{production.ID, otherProduction.ID} {
+		if err := tc.envs.DeleteEnvironment(context.Background(), auth.User{}, id); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tc.gateway.DenyPermissionCheck = true
+	tc.gateway.ServicePermissions[tc.svc.ID] = []auth.Permission{auth.PermissionManageEnvironments}
+
+	items, err := tc.trash.ListTrash(context.Background(), auth.User{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(items.Environments) != 1 || items.Environments[0].ID != production.ID {
+		t.Fatalf("Expected only the owned production to be in the trash got: %+v", items.Environments)
+	}
+
+	err = tc.trash.Restore(context.Background(), auth.User{}, trash.KindEnvironment, otherProduction.ID)
+	if !errors.Is(err, auth.ErrUnauthorized) {
+		t.Fatalf("Expected %s got: %v", auth.ErrUnauthorized, err)
+	}
+
+	err = tc.trash.Restore(context.Background(), auth.User{}, trash.KindEnvironment, production.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
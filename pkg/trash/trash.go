@@ -0,0 +1,42 @@
+package trash
+
+import (
+	"errors"
+
+	"github.com/config-source/cdb/pkg/configkeys"
+	"github.com/config-source/cdb/pkg/environments"
+	"github.com/config-source/cdb/pkg/services"
+)
+
+var (
+	ErrDependencyDeleted = errors.New("restore what this depends on first, it is also in the trash")
+	ErrNameInUse         = errors.New("something else has been created with this name, rename or delete it first")
+	ErrUnknownKind       = errors.New("unknown kind of item in the trash")
+)
+
+// Kind is the type of an item in the trash.
+type Kind string
+
+const (
+	KindEnvironment Kind = "environments"
+	KindConfigKey   Kind = "config-keys"
+	KindService     Kind = "services"
+)
+
+// Trash is everything which has been deleted but not yet purged. Only the
+// kinds the actor is able to manage are populated.
+type Trash struct {
+	// Retention is how long items stay in the trash before they are purged.
+	Retention string
+
+	Environments []environments.Environment
+	ConfigKeys   []configkeys.ConfigKey
+	Services     []services.Service
+}
+
+// PurgeResult counts the items permanently deleted by a purge.
+type PurgeResult struct {
+	Environments int64
+	ConfigKeys   int64
+	Services     int64
+}